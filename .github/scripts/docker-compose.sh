@@ -43,7 +43,7 @@ start-services() {
     # Ждем сервис
     echo "Waiting for subs service to start..."
     for i in {1..30}; do
-        if docker compose $COMPOSE_FLAGS exec -T subs wget -qO- http://localhost:8080/health > /dev/null 2>&1; then
+        if docker compose $COMPOSE_FLAGS exec -T subs wget -qO- http://localhost:8080/readyz > /dev/null 2>&1; then
             echo "Subs service is ready!"
             break
         fi
@@ -29,7 +29,7 @@ else
         sh -c "
             echo 'Waiting for subs service to respond...'
             for i in {1..10}; do
-                if wget -qO- http://subs:8080/health > /dev/null 2>&1; then
+                if wget -qO- http://subs:8080/readyz > /dev/null 2>&1; then
                     echo 'Service is responding!'
                     break
                 fi
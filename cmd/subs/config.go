@@ -1,40 +1,652 @@
 package main
 
 import (
-	"log"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
 
+	common "github.com/end1essrage/efmob-tz/pkg/common/cmd"
+	m "github.com/end1essrage/efmob-tz/pkg/common/interfaces/http/middleware"
+	l "github.com/end1essrage/efmob-tz/pkg/common/logger"
+	"github.com/end1essrage/efmob-tz/pkg/common/ratelimit"
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
 )
 
-// Config хранит конфигурацию приложения
+// Config конфигурация сервиса. Источники по возрастанию приоритета:
+// значения по умолчанию для ENV, YAML файл, переменные окружения, флаги --set
 type Config struct {
-	Env         string
-	ServiceName string
-	Port        string
-	PostgresDSN string // например "host=localhost user=postgres password=pass dbname=subs port=5432 sslmode=disable"
+	Env         string `yaml:"env"`
+	ServiceName string `yaml:"service_name"`
+
+	Log         LogConfig         `yaml:"log"`
+	HTTP        HTTPConfig        `yaml:"http"`
+	GRPC        GRPCConfig        `yaml:"grpc"`
+	Shutdown    ShutdownConfig    `yaml:"shutdown"`
+	Postgres    PostgresConfig    `yaml:"postgres"`
+	Outbox      OutboxConfig      `yaml:"outbox"`
+	Idempotency IdempotencyConfig `yaml:"idempotency"`
+	GraphQL     GraphQLConfig     `yaml:"graphql"`
+	JWT         JWTConfig         `yaml:"jwt"`
+	RateLimit   RateLimitConfig   `yaml:"rate_limit"`
+	Tracing     TracingConfig     `yaml:"tracing"`
+	Metrics     MetricsConfig     `yaml:"metrics"`
+	Health      HealthConfig      `yaml:"health"`
+}
+
+type LogConfig struct {
+	// Level debug, info, warn, error. Меняется без перезапуска
+	Level string `yaml:"level"`
+}
+
+type HTTPConfig struct {
+	Port              string        `yaml:"port"`
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`
+	// RequestTimeout отмена контекста запроса
+	RequestTimeout time.Duration `yaml:"request_timeout"`
+	// ExportTimeout отмена контекста потоковой выгрузки, она идет дольше обычного запроса
+	ExportTimeout time.Duration `yaml:"export_timeout"`
+}
+
+type GRPCConfig struct {
+	Port string `yaml:"port"`
+}
+
+type ShutdownConfig struct {
+	// Timeout ожидание активных запросов
+	Timeout time.Duration `yaml:"timeout"`
+	// DrainDelay пауза между readyz=fail и остановкой сервера
+	DrainDelay time.Duration `yaml:"drain_delay"`
+}
+
+type PostgresConfig struct {
+	DSN   string      `yaml:"dsn"` // например "host=localhost user=postgres password=pass dbname=subs port=5432 sslmode=disable"
+	Retry RetryConfig `yaml:"retry"`
+	// AutoMigrate применять миграции при старте. В PROD выключено - схему обновляет subs migrate up
+	AutoMigrate bool `yaml:"auto_migrate"`
+}
+
+// RetryConfig повторы изменяющих запросов при временных ошибках
+type RetryConfig struct {
+	Attempts int           `yaml:"attempts"`
+	Interval time.Duration `yaml:"interval"`
+	Jitter   time.Duration `yaml:"jitter"`
+}
+
+type OutboxConfig struct {
+	Interval  time.Duration `yaml:"interval"`   // период опроса outbox
+	BatchSize int           `yaml:"batch_size"` // кол-во событий, читаемых воркером за один проход
+	Workers   int           `yaml:"workers"`    // кол-во параллельных публикаторов
+	// LagThreshold ожидание готового к публикации события, после которого сервис не готов
+	LagThreshold time.Duration `yaml:"lag_threshold"`
+	// HeartbeatTimeout молчание воркера, после которого сервис не жив
+	HeartbeatTimeout time.Duration `yaml:"heartbeat_timeout"`
+}
+
+type IdempotencyConfig struct {
+	TTL time.Duration `yaml:"ttl"` // время хранения ответов по Idempotency-Key
+	// Lease на сколько запрос занимает ключ, ключ упавшего запроса освобождается по его истечении
+	Lease        time.Duration `yaml:"lease"`
+	MaxBodyBytes int64         `yaml:"max_body_bytes"` // предел тела запроса с Idempotency-Key
+}
+
+type GraphQLConfig struct {
+	MaxDepth      int `yaml:"max_depth"`      // максимальная вложенность запроса /graphql
+	MaxComplexity int `yaml:"max_complexity"` // максимальная сложность запроса /graphql
+}
+
+type JWTConfig struct {
+	Secret   string        `yaml:"secret"`    // общий секрет HS256
+	JWKSFile string        `yaml:"jwks_file"` // локальный JWKS с ключами RS256/ES256
+	Issuer   string        `yaml:"issuer"`    // ожидаемый iss, пусто - не проверяется
+	Audience string        `yaml:"audience"`  // ожидаемый aud, пусто - не проверяется
+	Leeway   time.Duration `yaml:"leeway"`    // допустимое расхождение часов
+}
+
+// RateLimitConfig лимиты запросов. Default, Routes и IP меняются без перезапуска
+type RateLimitConfig struct {
+	Default        string   `yaml:"default"`         // лимит клиента на маршруты без своего правила, "100/1m:30"
+	Routes         string   `yaml:"routes"`          // лимиты маршрутов, "POST /subscriptions/import=20/1m:5;..."
+	IP             string   `yaml:"ip"`              // лимит IP до аутентификации на маршруты API, в т.ч. неверные ключи; пробы и метрики без лимита
+	TrustedProxies []string `yaml:"trusted_proxies"` // прокси, которым доверяем X-Forwarded-For: адреса или сети
+	Store          string   `yaml:"store"`           // memory - лимит на реплику, postgres - общий для реплик
+	FailOpen       bool     `yaml:"fail_open"`       // при недоступном хранилище лимитов пропускать запросы
+}
+
+type TracingConfig struct {
+	Endpoint    string  `yaml:"endpoint"`     // OTLP gRPC коллектор трасс, например http://alloy:4317. Пусто - трассы не экспортируются
+	SampleRatio float64 `yaml:"sample_ratio"` // доля записываемых трасс
+}
+
+type MetricsConfig struct {
+	Interval time.Duration `yaml:"interval"` // период пересчета gauge метрик из базы
+}
+
+type HealthConfig struct {
+	Timeout  time.Duration `yaml:"timeout"`   // время на одну проверку проб
+	CacheTTL time.Duration `yaml:"cache_ttl"` // время жизни результата проверки
+}
+
+// AuthEnabled задан хотя бы один источник ключей JWT
+func (c *Config) AuthEnabled() bool {
+	return c.JWT.Secret != "" || c.JWT.JWKSFile != ""
+}
+
+// Limits разобранные лимиты запросов
+func (c *RateLimitConfig) Limits() (ratelimit.Limit, []m.RateLimitRule, error) {
+	def, err := ratelimit.ParseLimit(c.Default)
+	if err != nil {
+		return ratelimit.Limit{}, nil, err
+	}
+	routes, err := m.ParseRateLimitRules(c.Routes)
+	if err != nil {
+		return ratelimit.Limit{}, nil, err
+	}
+	return def, routes, nil
+}
+
+// Proxies доверенные прокси, одиночный адрес - сеть из одного адреса
+func (c *RateLimitConfig) Proxies() ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, s := range c.TrustedProxies {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			addr, err := netip.ParseAddr(s)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
 }
 
-// LoadConfig загружает конфигурацию
-func LoadConfig() *Config {
+// defaultConfig значения по умолчанию для окружения
+func defaultConfig(env common.ENV) Config {
+	cfg := Config{
+		Env: string(env),
+		Log: LogConfig{Level: "debug"},
+		HTTP: HTTPConfig{
+			Port:              "8080",
+			ReadTimeout:       5 * time.Second,
+			ReadHeaderTimeout: 2 * time.Second,
+			WriteTimeout:      10 * time.Second,
+			IdleTimeout:       60 * time.Second,
+			RequestTimeout:    30 * time.Second,
+			ExportTimeout:     10 * time.Minute,
+		},
+		GRPC:     GRPCConfig{Port: "9090"},
+		Shutdown: ShutdownConfig{Timeout: 10 * time.Second},
+		Postgres: PostgresConfig{
+			Retry:       RetryConfig{Attempts: 3, Interval: 2 * time.Second, Jitter: 500 * time.Millisecond},
+			AutoMigrate: true,
+		},
+		Outbox: OutboxConfig{
+			Interval:         5 * time.Second,
+			BatchSize:        100,
+			Workers:          4,
+			LagThreshold:     5 * time.Minute,
+			HeartbeatTimeout: time.Minute,
+		},
+		Idempotency: IdempotencyConfig{TTL: 24 * time.Hour, Lease: time.Minute, MaxBodyBytes: 1 << 20},
+		GraphQL:     GraphQLConfig{MaxDepth: 8, MaxComplexity: 1000},
+		JWT:         JWTConfig{Leeway: 30 * time.Second},
+		RateLimit: RateLimitConfig{
+			Default:  "100/1m:30",
+			Routes:   "POST /subscriptions/import=20/1m:5;GET /subscriptions/export=20/1m:5",
+			IP:       "600/1m:100",
+			Store:    "postgres",
+			FailOpen: true,
+		},
+		Tracing: TracingConfig{SampleRatio: 1},
+		Metrics: MetricsConfig{Interval: 30 * time.Second},
+		Health:  HealthConfig{Timeout: 2 * time.Second, CacheTTL: 2 * time.Second},
+	}
+
+	switch env {
+	case common.ENV_DEV:
+		// одна реплика, лимиты в памяти
+		cfg.RateLimit.Store = "memory"
+	case common.ENV_PROD:
+		cfg.Log.Level = "info"
+		cfg.Tracing.SampleRatio = 0.1
+		cfg.Shutdown.DrainDelay = 5 * time.Second
+		cfg.Postgres.AutoMigrate = false
+	}
+
+	return cfg
+}
+
+// envAliases прежние имена переменных окружения. Основное имя - путь ключа в верхнем регистре: http.port -> HTTP_PORT
+var envAliases = map[string][]string{
+	"http.port":                  {"PORT"},
+	"jwt.jwks_file":              {"JWKS_FILE"},
+	"rate_limit.default":         {"RATE_LIMIT"},
+	"rate_limit.trusted_proxies": {"TRUSTED_PROXIES"},
+	"outbox.heartbeat_timeout":   {"WORKER_HEARTBEAT_TIMEOUT"},
+	"tracing.endpoint":           {"OTEL_EXPORTER_OTLP_ENDPOINT"},
+	"tracing.sample_ratio":       {"OTEL_TRACES_SAMPLER_ARG"},
+}
+
+// envName основное имя переменной окружения ключа
+func envName(key string) string {
+	return strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// Validate проверяет всю конфигурацию и возвращает все ошибки сразу
+func (c *Config) Validate() error {
+	var errs []error
+	add := func(field, format string, args ...any) {
+		names := append([]string{envName(field)}, envAliases[field]...)
+		errs = append(errs, fmt.Errorf("%s (%s): %s", field, strings.Join(names, ", "), fmt.Sprintf(format, args...)))
+	}
+	positive := func(field string, d time.Duration) {
+		if d <= 0 {
+			add(field, "must be positive, got %s", d)
+		}
+	}
+
+	switch common.ENV(c.Env) {
+	case common.ENV_DEV, common.ENV_TEST, common.ENV_PROD:
+	default:
+		add("env", "must be one of dev, test, prod, got %q", c.Env)
+	}
+	if c.ServiceName == "" {
+		add("service_name", "must be set")
+	}
+	if _, err := logrus.ParseLevel(c.Log.Level); err != nil {
+		add("log.level", "%v", err)
+	}
+
+	if c.HTTP.Port == "" {
+		add("http.port", "must be set")
+	}
+	if c.GRPC.Port == "" {
+		add("grpc.port", "must be set")
+	} else if c.GRPC.Port == c.HTTP.Port {
+		add("grpc.port", "must differ from http.port")
+	}
+	positive("http.read_timeout", c.HTTP.ReadTimeout)
+	positive("http.read_header_timeout", c.HTTP.ReadHeaderTimeout)
+	positive("http.write_timeout", c.HTTP.WriteTimeout)
+	positive("http.idle_timeout", c.HTTP.IdleTimeout)
+	positive("http.request_timeout", c.HTTP.RequestTimeout)
+	positive("http.export_timeout", c.HTTP.ExportTimeout)
+	positive("shutdown.timeout", c.Shutdown.Timeout)
+	if c.Shutdown.DrainDelay < 0 {
+		add("shutdown.drain_delay", "must not be negative")
+	}
+
+	// DEV поднимает postgres в контейнере сам
+	if c.Postgres.DSN == "" && common.ENV(c.Env) != common.ENV_DEV {
+		add("postgres.dsn", "must be set")
+	}
+	if c.Postgres.Retry.Attempts <= 0 {
+		add("postgres.retry.attempts", "must be positive")
+	}
+	positive("postgres.retry.interval", c.Postgres.Retry.Interval)
+	if c.Postgres.Retry.Jitter < 0 {
+		add("postgres.retry.jitter", "must not be negative")
+	}
+
+	positive("outbox.interval", c.Outbox.Interval)
+	if c.Outbox.BatchSize <= 0 {
+		add("outbox.batch_size", "must be positive")
+	}
+	if c.Outbox.Workers <= 0 {
+		add("outbox.workers", "must be positive")
+	}
+	positive("outbox.lag_threshold", c.Outbox.LagThreshold)
+	positive("outbox.heartbeat_timeout", c.Outbox.HeartbeatTimeout)
+	positive("idempotency.ttl", c.Idempotency.TTL)
+	// раньше таймаута запроса ключ заберет повтор, пока первый запрос еще выполняется
+	if c.Idempotency.Lease <= c.HTTP.RequestTimeout {
+		add("idempotency.lease", "must be greater than http.request_timeout")
+	}
+	if c.Idempotency.MaxBodyBytes <= 0 {
+		add("idempotency.max_body_bytes", "must be positive")
+	}
+
+	if c.GraphQL.MaxDepth <= 0 {
+		add("graphql.max_depth", "must be positive")
+	}
+	if c.GraphQL.MaxComplexity <= 0 {
+		add("graphql.max_complexity", "must be positive")
+	}
+
+	// без аутентификации любой клиент видит чужие подписки - в проде недопустимо
+	if common.ENV(c.Env) == common.ENV_PROD && !c.AuthEnabled() {
+		add("jwt.secret", "jwt.secret or jwt.jwks_file must be set in PROD")
+	}
+	if c.JWT.Secret != "" && len(c.JWT.Secret) < 32 {
+		add("jwt.secret", "must be at least 32 bytes")
+	}
+	if c.JWT.Leeway < 0 {
+		add("jwt.leeway", "must not be negative")
+	}
+
+	if _, err := ratelimit.ParseLimit(c.RateLimit.Default); err != nil {
+		add("rate_limit.default", "%v", err)
+	}
+	if _, err := m.ParseRateLimitRules(c.RateLimit.Routes); err != nil {
+		add("rate_limit.routes", "%v", err)
+	}
+	if _, err := ratelimit.ParseLimit(c.RateLimit.IP); err != nil {
+		add("rate_limit.ip", "%v", err)
+	}
+	if _, err := c.RateLimit.Proxies(); err != nil {
+		add("rate_limit.trusted_proxies", "%v", err)
+	}
+	if c.RateLimit.Store != "memory" && c.RateLimit.Store != "postgres" {
+		add("rate_limit.store", "must be memory or postgres, got %q", c.RateLimit.Store)
+	}
+
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		add("tracing.sample_ratio", "must be between 0 and 1, got %v", c.Tracing.SampleRatio)
+	}
+	positive("metrics.interval", c.Metrics.Interval)
+	positive("health.timeout", c.Health.Timeout)
+	if c.Health.CacheTTL < 0 {
+		add("health.cache_ttl", "must not be negative")
+	}
+
+	return errors.Join(errs...)
+}
+
+const redacted = "******"
+
+var dsnPassword = regexp.MustCompile(`(password=)(\S+)`)
+
+// Redacted копия без секретов для вывода
+func (c Config) Redacted() Config {
+	if c.JWT.Secret != "" {
+		c.JWT.Secret = redacted
+	}
+	c.Postgres.DSN = redactDSN(c.Postgres.DSN)
+	return c
+}
+
+// redactDSN скрывает пароль в DSN вида key=value и URL
+func redactDSN(dsn string) string {
+	if strings.Contains(dsn, "://") {
+		if at := strings.LastIndex(dsn, "@"); at > 0 {
+			scheme := strings.Index(dsn, "://") + 3
+			if colon := strings.Index(dsn[scheme:at], ":"); colon >= 0 {
+				return dsn[:scheme+colon+1] + redacted + dsn[at:]
+			}
+		}
+		return dsn
+	}
+	return dsnPassword.ReplaceAllString(dsn, "${1}"+redacted)
+}
+
+// Print выводит итоговую конфигурацию в YAML без секретов
+func (c Config) Print(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(c.Redacted()); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// Flags флаги командной строки
+type Flags struct {
+	// ConfigFile YAML файл, по умолчанию CONFIG_FILE или config.yaml, если он есть
+	ConfigFile  string
+	PrintConfig bool
+	// Set значения key=value поверх всех источников
+	Set []string
+	// Args подкоманда с аргументами, например migrate up
+	Args []string
+}
+
+type setFlag []string
+
+func (s *setFlag) String() string     { return strings.Join(*s, ",") }
+func (s *setFlag) Set(v string) error { *s = append(*s, v); return nil }
+
+// ParseFlags разбирает аргументы командной строки
+func ParseFlags(args []string) (Flags, error) {
+	var f Flags
+	fs := flag.NewFlagSet("subs", flag.ContinueOnError)
+	fs.StringVar(&f.ConfigFile, "config", os.Getenv("CONFIG_FILE"), "YAML config file")
+	fs.BoolVar(&f.PrintConfig, "print-config", false, "print effective config with secrets redacted and exit")
+	fs.Var((*setFlag)(&f.Set), "set", "override a config key, e.g. --set http.port=8081 (repeatable)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: subs [flags] [migrate up|down|status|to <version>]\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return f, err
+	}
+	f.Args = fs.Args()
+	// флаги можно указать и после подкоманды: subs migrate --config prod.yaml up
+	if len(f.Args) > 0 {
+		if err := fs.Parse(f.Args[1:]); err != nil {
+			return f, err
+		}
+		f.Args = append([]string{f.Args[0]}, fs.Args()...)
+	}
+
+	if f.ConfigFile == "" {
+		if _, err := os.Stat("config.yaml"); err == nil {
+			f.ConfigFile = "config.yaml"
+		}
+	}
+	return f, nil
+}
+
+// LoadConfig собирает конфигурацию из всех источников и проверяет ее
+func LoadConfig(f Flags) (*Config, error) {
+	loadDotEnv()
+
 	v := viper.New()
-	v.SetConfigFile(".env") // можно использовать .env или config.yaml
-	v.AutomaticEnv()        // fallback на env vars
+	if f.ConfigFile != "" {
+		v.SetConfigFile(f.ConfigFile)
+		if err := v.ReadInConfig(); err != nil {
+			return nil, fmt.Errorf("read %s: %w", f.ConfigFile, err)
+		}
+	}
+
+	overrides := make(map[string]string, len(f.Set))
+	for _, kv := range f.Set {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok {
+			return nil, fmt.Errorf("--set %q: expected key=value", kv)
+		}
+		overrides[strings.ToLower(strings.TrimSpace(key))] = value
+	}
+
+	// окружение нужно раньше остальных ключей - от него зависят значения по умолчанию
+	env := v.GetString("env")
+	if e, ok := os.LookupEnv("ENV"); ok {
+		env = e
+	}
+	if e, ok := overrides["env"]; ok {
+		env = e
+	}
+
+	defaults, err := flatten(defaultConfig(common.ENV(env)))
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range defaults {
+		v.SetDefault(key, value)
+		names := append([]string{envName(key)}, envAliases[key]...)
+		if err := v.BindEnv(append([]string{key}, names...)...); err != nil {
+			return nil, err
+		}
+	}
+	for key, value := range overrides {
+		if _, ok := defaults[key]; !ok {
+			return nil, fmt.Errorf("--set %s: unknown key", key)
+		}
+		v.Set(key, value)
+	}
 
+	var cfg Config
+	err = v.Unmarshal(&cfg, func(dc *mapstructure.DecoderConfig) {
+		dc.TagName = "yaml"
+		// опечатка в ключе файла - ошибка, а не молча значение по умолчанию
+		dc.ErrorUnused = true
+	})
+	if err != nil {
+		return nil, err
+	}
+	// TRUSTED_PROXIES="10.0.0.0/8, 10.1.0.1"
+	for i, s := range cfg.RateLimit.TrustedProxies {
+		cfg.RateLimit.TrustedProxies[i] = strings.TrimSpace(s)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// loadDotEnv переменные из .env, если файл есть. Окружение процесса важнее
+func loadDotEnv() {
+	v := viper.New()
+	v.SetConfigFile(".env")
+	v.SetConfigType("env")
 	if err := v.ReadInConfig(); err != nil {
-		log.Printf("config file not found, using env vars only: %v", err)
+		return
 	}
+	for _, key := range v.AllKeys() {
+		name := strings.ToUpper(key)
+		if _, ok := os.LookupEnv(name); !ok {
+			_ = os.Setenv(name, v.GetString(key))
+		}
+	}
+}
 
-	cfg := &Config{
-		Env:         v.GetString("ENV"),
-		ServiceName: v.GetString("SERVICE_NAME"),
-		Port:        v.GetString("PORT"),
-		PostgresDSN: v.GetString("POSTGRES_DSN"),
+// flatten ключи конфигурации в виде http.port -> значение
+func flatten(cfg Config) (map[string]any, error) {
+	raw, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var tree map[string]any
+	if err := yaml.Unmarshal(raw, &tree); err != nil {
+		return nil, err
 	}
 
-	// базовая валидация
-	if cfg.Env == "" || cfg.ServiceName == "" || cfg.Port == "" {
-		log.Fatalf("ENV, SERVICE_NAME and PORT must be set")
+	out := make(map[string]any)
+	var walk func(prefix string, node map[string]any)
+	walk = func(prefix string, node map[string]any) {
+		for k, v := range node {
+			if child, ok := v.(map[string]any); ok {
+				walk(prefix+k+".", child)
+				continue
+			}
+			out[prefix+k] = v
+		}
 	}
+	walk("", tree)
+	return out, nil
+}
 
-	return cfg
+// changedKeys ключи, значения которых различаются
+func changedKeys(old, cur *Config) ([]string, error) {
+	a, err := flatten(*old)
+	if err != nil {
+		return nil, err
+	}
+	b, err := flatten(*cur)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for k, v := range b {
+		if fmt.Sprint(a[k]) != fmt.Sprint(v) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// reloadable ключи, которые применяются без перезапуска
+var reloadable = map[string]bool{
+	"log.level":          true,
+	"rate_limit.default": true,
+	"rate_limit.routes":  true,
+	"rate_limit.ip":      true,
+}
+
+// withReloadable копия cfg с безопасными для перезагрузки значениями из next
+func withReloadable(cfg, next *Config) *Config {
+	c := *cfg
+	c.Log.Level = next.Log.Level
+	c.RateLimit.Default = next.RateLimit.Default
+	c.RateLimit.Routes = next.RateLimit.Routes
+	c.RateLimit.IP = next.RateLimit.IP
+	return &c
+}
+
+// WatchConfig следит за YAML файлом и передает в apply действующую конфигурацию, если новая валидна.
+// В нее попадают только ключи из reloadable, остальные изменения ждут перезапуска
+func WatchConfig(f Flags, cfg *Config, apply func(cur *Config, changed []string)) {
+	if f.ConfigFile == "" {
+		return
+	}
+
+	log := l.Logger().Log("config", "WatchConfig")
+
+	v := viper.New()
+	v.SetConfigFile(f.ConfigFile)
+	current := cfg
+	v.OnConfigChange(func(fsnotify.Event) {
+		next, err := LoadConfig(f)
+		if err != nil {
+			log.Errorf("новая конфигурация отклонена:\n%v", err)
+			return
+		}
+
+		keys, err := changedKeys(current, next)
+		if err != nil {
+			log.Errorf("сравнение конфигурации: %v", err)
+			return
+		}
+
+		var applied []string
+		for _, k := range keys {
+			if reloadable[k] {
+				applied = append(applied, k)
+			} else {
+				log.Warnf("%s изменится после перезапуска", k)
+			}
+		}
+		if len(applied) == 0 {
+			return
+		}
+
+		current = withReloadable(current, next)
+		apply(current, applied)
+		log.Infof("конфигурация перезагружена: %s", strings.Join(applied, ", "))
+	})
+	v.WatchConfig()
 }
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// baseEnv минимальное окружение для валидной конфигурации
+func baseEnv(t *testing.T) {
+	t.Setenv("ENV", "test")
+	t.Setenv("SERVICE_NAME", "subs")
+	t.Setenv("POSTGRES_DSN", "host=db user=postgres password=pass dbname=subs")
+}
+
+func writeFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+// replaceFile атомарно подменяет файл, как это делают редакторы и ConfigMap
+func replaceFile(t *testing.T, path, content string) {
+	tmp := path + ".tmp"
+	require.NoError(t, os.WriteFile(tmp, []byte(content), 0o600))
+	require.NoError(t, os.Rename(tmp, path))
+}
+
+func TestLoadConfig_Defaults(t *testing.T) {
+	baseEnv(t)
+
+	cfg, err := LoadConfig(Flags{})
+	require.NoError(t, err)
+	require.Equal(t, "debug", cfg.Log.Level)
+	require.Equal(t, "8080", cfg.HTTP.Port)
+	require.Equal(t, 5*time.Second, cfg.Outbox.Interval)
+	require.Equal(t, "postgres", cfg.RateLimit.Store)
+	require.True(t, cfg.Postgres.AutoMigrate)
+	require.Equal(t, time.Minute, cfg.Idempotency.Lease)
+	require.Equal(t, 10*time.Minute, cfg.HTTP.ExportTimeout)
+	require.Equal(t, int64(1<<20), cfg.Idempotency.MaxBodyBytes)
+
+	// у каждого окружения свои значения по умолчанию
+	t.Setenv("ENV", "dev")
+	cfg, err = LoadConfig(Flags{})
+	require.NoError(t, err)
+	require.Equal(t, "memory", cfg.RateLimit.Store)
+
+	t.Setenv("ENV", "prod")
+	t.Setenv("JWT_SECRET", strings.Repeat("s", 32))
+	cfg, err = LoadConfig(Flags{})
+	require.NoError(t, err)
+	require.Equal(t, "info", cfg.Log.Level)
+	require.Equal(t, 0.1, cfg.Tracing.SampleRatio)
+	require.Equal(t, 5*time.Second, cfg.Shutdown.DrainDelay)
+	require.False(t, cfg.Postgres.AutoMigrate)
+}
+
+func TestLoadConfig_Precedence(t *testing.T) {
+	baseEnv(t)
+	path := writeFile(t, `
+log:
+  level: warn
+http:
+  port: "8000"
+  request_timeout: 15s
+outbox:
+  workers: 2
+  batch_size: 50
+rate_limit:
+  trusted_proxies: ["10.0.0.0/8"]
+`)
+
+	// файл < окружение < --set, прежние имена переменных работают
+	t.Setenv("OUTBOX_WORKERS", "6")
+	t.Setenv("PORT", "8001")
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.0/8, 192.168.0.1")
+
+	cfg, err := LoadConfig(Flags{ConfigFile: path, Set: []string{"outbox.workers=8"}})
+	require.NoError(t, err)
+	require.Equal(t, "warn", cfg.Log.Level)
+	require.Equal(t, 15*time.Second, cfg.HTTP.RequestTimeout)
+	require.Equal(t, 50, cfg.Outbox.BatchSize)
+	require.Equal(t, "8001", cfg.HTTP.Port)
+	require.Equal(t, 8, cfg.Outbox.Workers)
+	require.Equal(t, []string{"10.0.0.0/8", "192.168.0.1"}, cfg.RateLimit.TrustedProxies)
+
+	// основное имя важнее прежнего
+	t.Setenv("HTTP_PORT", "8002")
+	cfg, err = LoadConfig(Flags{ConfigFile: path})
+	require.NoError(t, err)
+	require.Equal(t, "8002", cfg.HTTP.Port)
+
+	// окружение из файла меняет значения по умолчанию
+	t.Setenv("ENV", "")
+	os.Unsetenv("ENV")
+	path = writeFile(t, "env: dev\n")
+	cfg, err = LoadConfig(Flags{ConfigFile: path})
+	require.NoError(t, err)
+	require.Equal(t, "memory", cfg.RateLimit.Store)
+}
+
+func TestLoadConfig_Errors(t *testing.T) {
+	baseEnv(t)
+	t.Setenv("ENV", "prod")
+	t.Setenv("OUTBOX_WORKERS", "0")
+	t.Setenv("RATE_LIMIT", "fast")
+	t.Setenv("GRPC_PORT", "8080")
+	t.Setenv("IDEMPOTENCY_LEASE", "10s")
+
+	// все ошибки сразу, с именем ключа и переменной
+	_, err := LoadConfig(Flags{})
+	require.Error(t, err)
+	msg := err.Error()
+	for _, want := range []string{
+		"outbox.workers (OUTBOX_WORKERS): must be positive",
+		"rate_limit.default (RATE_LIMIT_DEFAULT, RATE_LIMIT)",
+		"grpc.port (GRPC_PORT): must differ from http.port",
+		"jwt.secret (JWT_SECRET): jwt.secret or jwt.jwks_file must be set in PROD",
+		"idempotency.lease (IDEMPOTENCY_LEASE): must be greater than http.request_timeout",
+	} {
+		require.Contains(t, msg, want)
+	}
+
+	baseEnv(t)
+	t.Setenv("OUTBOX_WORKERS", "")
+	os.Unsetenv("OUTBOX_WORKERS")
+	t.Setenv("RATE_LIMIT", "")
+	os.Unsetenv("RATE_LIMIT")
+	t.Setenv("GRPC_PORT", "")
+	os.Unsetenv("GRPC_PORT")
+	t.Setenv("IDEMPOTENCY_LEASE", "")
+	os.Unsetenv("IDEMPOTENCY_LEASE")
+
+	// опечатка в файле
+	_, err = LoadConfig(Flags{ConfigFile: writeFile(t, "outbox:\n  wrokers: 2\n")})
+	require.ErrorContains(t, err, "wrokers")
+
+	_, err = LoadConfig(Flags{Set: []string{"outbox.wrokers=2"}})
+	require.ErrorContains(t, err, "unknown key")
+
+	_, err = LoadConfig(Flags{Set: []string{"outbox.workers"}})
+	require.ErrorContains(t, err, "key=value")
+}
+
+func TestParseFlags(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	f, err := ParseFlags([]string{"--print-config", "--config", "/etc/subs.yaml", "--set", "a=1", "--set", "b=2"})
+	require.NoError(t, err)
+	require.True(t, f.PrintConfig)
+	require.Equal(t, "/etc/subs.yaml", f.ConfigFile)
+	require.Equal(t, []string{"a=1", "b=2"}, f.Set)
+	require.Empty(t, f.Args)
+
+	// подкоманда, флаги до и после нее
+	f, err = ParseFlags([]string{"--set", "a=1", "migrate", "--config", "/etc/subs.yaml", "to", "2"})
+	require.NoError(t, err)
+	require.Equal(t, "/etc/subs.yaml", f.ConfigFile)
+	require.Equal(t, []string{"a=1"}, f.Set)
+	require.Equal(t, []string{"migrate", "to", "2"}, f.Args)
+}
+
+func TestConfig_Print(t *testing.T) {
+	baseEnv(t)
+	t.Setenv("JWT_SECRET", strings.Repeat("s", 32))
+
+	cfg, err := LoadConfig(Flags{})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, cfg.Print(&buf))
+	out := buf.String()
+	require.NotContains(t, out, "password=pass")
+	require.NotContains(t, out, strings.Repeat("s", 32))
+	require.Contains(t, out, "request_timeout: 30s")
+	// исходная конфигурация не меняется
+	require.Equal(t, strings.Repeat("s", 32), cfg.JWT.Secret)
+
+	require.Equal(t, "postgres://user:******@db:5432/subs", redactDSN("postgres://user:secret@db:5432/subs"))
+	require.Equal(t, "postgres://db/subs", redactDSN("postgres://db/subs"))
+}
+
+func TestWatchConfig(t *testing.T) {
+	baseEnv(t)
+	path := writeFile(t, "log:\n  level: info\nrate_limit:\n  default: 100/1m\n")
+	flags := Flags{ConfigFile: path}
+
+	cfg, err := LoadConfig(flags)
+	require.NoError(t, err)
+
+	applied := make(chan *Config, 10)
+	WatchConfig(flags, cfg, func(cur *Config, changed []string) {
+		applied <- cur
+	})
+
+	// невалидный файл отклоняется, прежняя конфигурация остается
+	replaceFile(t, path, "log:\n  level: info\nrate_limit:\n  default: fast\n")
+	select {
+	case <-applied:
+		t.Fatal("invalid config applied")
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	// безопасные ключи применяются, остальные ждут перезапуска
+	replaceFile(t, path, "log:\n  level: warn\nrate_limit:\n  default: 10/1m\nhttp:\n  port: \"9000\"\n")
+	select {
+	case cur := <-applied:
+		require.Equal(t, "warn", cur.Log.Level)
+		require.Equal(t, "10/1m", cur.RateLimit.Default)
+		require.Equal(t, "8080", cur.HTTP.Port)
+	case <-time.After(5 * time.Second):
+		t.Fatal("config was not reloaded")
+	}
+}
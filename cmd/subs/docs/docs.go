@@ -15,8 +15,416 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/admin/api-keys": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "All keys including revoked ones, newest first. Keys themselves are never returned",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "api-keys"
+                ],
+                "summary": "List API keys",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.APIKeyListResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing, invalid or expired bearer token or API key",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Not permitted for the caller's roles and scopes",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Issue a key for batch jobs and partner systems, sent as \"Authorization: ApiKey \u003ckey\u003e\".\nThe key is shown only in this response, the service stores its hash",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "api-keys"
+                ],
+                "summary": "Issue API key",
+                "parameters": [
+                    {
+                        "description": "Key owner and scopes",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/http.APIKeyCreateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/http.APIKeyIssuedResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing, invalid or expired bearer token or API key",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Not permitted for the caller's roles and scopes",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request body too large",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/api-keys/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Revoke the key permanently, repeated revocation is a no-op",
+                "tags": [
+                    "api-keys"
+                ],
+                "summary": "Revoke API key",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API key ID (UUID)",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing, invalid or expired bearer token or API key",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Not permitted for the caller's roles and scopes",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/api-keys/{id}/rotate": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Issue a new key with the same owner and scopes. The previous key stops working immediately",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "api-keys"
+                ],
+                "summary": "Rotate API key",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API key ID (UUID)",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.APIKeyIssuedResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing, invalid or expired bearer token or API key",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Not permitted for the caller's roles and scopes",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "The key is revoked",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/outbox": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Unpublished events of the tenant, oldest first, with failed attempts and the last error",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "outbox"
+                ],
+                "summary": "Inspect outbox",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Event type",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Subscription ID (UUID)",
+                        "name": "aggregate_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only events with failed publish attempts",
+                        "name": "failed",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Max events, 1-1000, default 100",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.OutboxResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing, invalid or expired bearer token or API key",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Not permitted for the caller's roles and scopes",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/outbox/requeue": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Clear the retry delay of events deferred after failed publishes, they are published on the next worker pass.\nPass event ids or all=true",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "outbox"
+                ],
+                "summary": "Requeue outbox events",
+                "parameters": [
+                    {
+                        "description": "Events to requeue",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/http.OutboxRequeueRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.OutboxRequeueResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing, invalid or expired bearer token or API key",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Not permitted for the caller's roles and scopes",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request body too large",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/subscriptions": {
             "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
                 "description": "List subscriptions with filters",
                 "produces": [
                     "application/json"
@@ -26,16 +434,367 @@ const docTemplate = `{
                 ],
                 "summary": "List subscriptions",
                 "parameters": [
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "csv",
+                        "description": "User IDs (UUID), repeated or comma separated",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "csv",
+                        "description": "Service names, repeated or comma separated",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Service name match: exact (default), icase or fuzzy (substring or trigram similarity, min 3 chars)",
+                        "name": "service_match",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Minimal price",
+                        "name": "price_min",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximal price",
+                        "name": "price_max",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Created from (YYYY-MM-DD)",
+                        "name": "created_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Created to, inclusive (YYYY-MM-DD)",
+                        "name": "created_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "RSQL expression, e.g. 'price=gt=500;(service_name==Netflix,service_name==Spotify)'. Fields: user_id, service_name, price, start_date, end_date (MM-YYYY), created_at (YYYY-MM-DD)",
+                        "name": "filter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start period from (MM-YYYY)",
+                        "name": "start_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start period to  (MM-YYYY)",
+                        "name": "start_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End period from (MM-YYYY)",
+                        "name": "end_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End period to  (MM-YYYY)",
+                        "name": "end_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "includes items with empty end_date(by default - true: if end_to != nil - false)",
+                        "name": "nil_end",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page num can use 0 or 1 for first",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page Size / Limit, max 1000",
+                        "name": "page_size",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Opaque cursor from X-Next-Cursor, page is ignored when set",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Return total count in X-Total-Count",
+                        "name": "with_total",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Multi-field sort, e.g. '-price,start_date'. Fields: start_date, end_date, price, service_name, created_at. Overrides order_by",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sorting field name",
+                        "name": "order_by",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sorting direction use 'asc'(default) or 'desc'",
+                        "name": "direction",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/http.Subscription"
+                            }
+                        },
+                        "headers": {
+                            "Link": {
+                                "type": "string",
+                                "description": "Next page URL with rel=next"
+                            },
+                            "X-Next-Cursor": {
+                                "type": "string",
+                                "description": "Cursor of the next page, absent on the last page"
+                            },
+                            "X-Total-Count": {
+                                "type": "integer",
+                                "description": "Total count, only with with_total=true"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing, invalid or expired bearer token or API key",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Not permitted for the caller's roles and scopes",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Create a new subscription",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subs"
+                ],
+                "summary": "Create subscription",
+                "parameters": [
+                    {
+                        "description": "Subscription data",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/http.SubscriptionCreateRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key for safe retries, repeated requests get the stored response",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/http.Subscription"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Subscription version"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing, invalid or expired bearer token or API key",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Not permitted for the caller's roles and scopes",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Concurrent modification or request with the same Idempotency-Key in progress",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request body too large",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Idempotency-Key reused with a different request",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/export": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Stream subscriptions matching filters as a file. Rows are read from the database with a cursor",
+                "produces": [
+                    "text/csv",
+                    "application/x-ndjson",
+                    "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+                ],
+                "tags": [
+                    "subs"
+                ],
+                "summary": "Export subscriptions",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "csv (default), ndjson or xlsx",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Add computed columns months_active and total_paid",
+                        "name": "computed",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "csv",
+                        "description": "User IDs (UUID), repeated or comma separated",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "csv",
+                        "description": "Service names, repeated or comma separated",
+                        "name": "service_name",
+                        "in": "query"
+                    },
                     {
                         "type": "string",
-                        "description": "User ID (UUID)",
-                        "name": "user_id",
+                        "description": "Service name match: exact (default), icase or fuzzy (substring or trigram similarity, min 3 chars)",
+                        "name": "service_match",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Minimal price",
+                        "name": "price_min",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximal price",
+                        "name": "price_max",
                         "in": "query"
                     },
                     {
                         "type": "string",
-                        "description": "Service name",
-                        "name": "service_name",
+                        "description": "Created from (YYYY-MM-DD)",
+                        "name": "created_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Created to, inclusive (YYYY-MM-DD)",
+                        "name": "created_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "RSQL expression, e.g. 'price=gt=500;(service_name==Netflix,service_name==Spotify)'. Fields: user_id, service_name, price, start_date, end_date (MM-YYYY), created_at (YYYY-MM-DD)",
+                        "name": "filter",
                         "in": "query"
                     },
                     {
@@ -69,15 +828,9 @@ const docTemplate = `{
                         "in": "query"
                     },
                     {
-                        "type": "integer",
-                        "description": "Page num can use 0 or 1 for first",
-                        "name": "page",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Page Size / Limit",
-                        "name": "page_size",
+                        "type": "string",
+                        "description": "Multi-field sort, e.g. '-price,start_date'. Fields: start_date, end_date, price, service_name, created_at. Overrides order_by",
+                        "name": "sort",
                         "in": "query"
                     },
                     {
@@ -95,12 +848,9 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK",
+                        "description": "Subscriptions file, ndjson rows are ExportRow",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/http.Subscription"
-                            }
+                            "type": "file"
                         }
                     },
                     "400": {
@@ -109,6 +859,18 @@ const docTemplate = `{
                             "$ref": "#/definitions/http.ErrorResponse"
                         }
                     },
+                    "401": {
+                        "description": "Missing, invalid or expired bearer token or API key",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Not permitted for the caller's roles and scopes",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -116,11 +878,22 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
+            }
+        },
+        "/subscriptions/import": {
             "post": {
-                "description": "Create a new subscription",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Bulk create subscriptions from CSV (header: user_id,service_name,price,start_date,end_date)\nor NDJSON (one SubscriptionCreateRequest per line). The file is processed as a stream.",
                 "consumes": [
-                    "application/json"
+                    "text/csv",
+                    "application/x-ndjson"
                 ],
                 "produces": [
                     "application/json"
@@ -128,23 +901,20 @@ const docTemplate = `{
                 "tags": [
                     "subs"
                 ],
-                "summary": "Create subscription",
+                "summary": "Import subscriptions",
                 "parameters": [
                     {
-                        "description": "Subscription data",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/http.SubscriptionCreateRequest"
-                        }
+                        "type": "string",
+                        "description": "atomic (default) - all or nothing, best_effort - skip invalid rows",
+                        "name": "mode",
+                        "in": "query"
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/http.Subscription"
+                            "$ref": "#/definitions/http.ImportResponse"
                         }
                     },
                     "400": {
@@ -153,12 +923,36 @@ const docTemplate = `{
                             "$ref": "#/definitions/http.ErrorResponse"
                         }
                     },
-                    "409": {
-                        "description": "Conflict",
+                    "401": {
+                        "description": "Missing, invalid or expired bearer token or API key",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Not permitted for the caller's roles and scopes",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Import file exceeds 32 MiB",
                         "schema": {
                             "$ref": "#/definitions/http.ErrorResponse"
                         }
                     },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Atomic import rejected, nothing created",
+                        "schema": {
+                            "$ref": "#/definitions/http.ImportResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -170,6 +964,14 @@ const docTemplate = `{
         },
         "/subscriptions/total": {
             "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
                 "description": "Calculate total cost for selected period",
                 "produces": [
                     "application/json"
@@ -180,18 +982,62 @@ const docTemplate = `{
                 "summary": "Calculate total subscription cost",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "User ID (UUID)",
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "csv",
+                        "description": "User IDs (UUID), repeated or comma separated",
                         "name": "user_id",
                         "in": "query",
                         "required": true
                     },
                     {
-                        "type": "string",
-                        "description": "Service name",
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "csv",
+                        "description": "Service names, repeated or comma separated",
                         "name": "service_name",
                         "in": "query"
                     },
+                    {
+                        "type": "string",
+                        "description": "Service name match: exact (default), icase or fuzzy (substring or trigram similarity, min 3 chars)",
+                        "name": "service_match",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Minimal price",
+                        "name": "price_min",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximal price",
+                        "name": "price_max",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Created from (YYYY-MM-DD)",
+                        "name": "created_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Created to, inclusive (YYYY-MM-DD)",
+                        "name": "created_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "RSQL expression, e.g. 'price=gt=500;(service_name==Netflix,service_name==Spotify)'. Fields: user_id, service_name, price, start_date, end_date (MM-YYYY), created_at (YYYY-MM-DD)",
+                        "name": "filter",
+                        "in": "query"
+                    },
                     {
                         "type": "string",
                         "description": "Start period from (MM-YYYY)",
@@ -236,6 +1082,18 @@ const docTemplate = `{
                             "$ref": "#/definitions/http.ErrorResponse"
                         }
                     },
+                    "401": {
+                        "description": "Missing, invalid or expired bearer token or API key",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Not permitted for the caller's roles and scopes",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -247,6 +1105,14 @@ const docTemplate = `{
         },
         "/subscriptions/{id}": {
             "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
                 "description": "Get subscription by ID",
                 "produces": [
                     "application/json"
@@ -262,6 +1128,180 @@ const docTemplate = `{
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag from previous response",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.Subscription"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Subscription version"
+                            }
+                        }
+                    },
+                    "304": {
+                        "description": "Not Modified"
+                    },
+                    "401": {
+                        "description": "Missing, invalid or expired bearer token or API key",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Not permitted for the caller's roles and scopes",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Delete subscription by ID",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subs"
+                ],
+                "summary": "Delete subscription",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Subscription ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Single ETag from GET, delete only if version matches. A list of ETags is rejected with 400",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Several ETags in If-Match",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing, invalid or expired bearer token or API key",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Not permitted for the caller's roles and scopes",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "412": {
+                        "description": "Version does not match If-Match",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Update subscription by ID.\nSupports application/json (SubscriptionUpdateRequest), application/merge-patch+json (RFC 7396)\nand application/json-patch+json (RFC 6902) applied to the Subscription representation.\nOnly price, start_date and end_date can be changed.",
+                "consumes": [
+                    "application/json",
+                    "application/merge-patch+json",
+                    "application/json-patch+json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subs"
+                ],
+                "summary": "Update subscription",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Subscription ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Updated data",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/http.SubscriptionUpdateRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Single ETag from GET, update only if version matches. A list of ETags is rejected with 400",
+                        "name": "If-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -269,6 +1309,33 @@ const docTemplate = `{
                         "description": "OK",
                         "schema": {
                             "$ref": "#/definitions/http.Subscription"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "New subscription version"
+                            }
+                        }
+                    },
+                    "204": {
+                        "description": "Nothing to update"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing, invalid or expired bearer token or API key",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Not permitted for the caller's roles and scopes",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
                         }
                     },
                     "404": {
@@ -277,6 +1344,30 @@ const docTemplate = `{
                             "$ref": "#/definitions/http.ErrorResponse"
                         }
                     },
+                    "409": {
+                        "description": "Concurrent modification or failed JSON Patch test operation",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "412": {
+                        "description": "Version does not match If-Match",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request body too large",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -284,37 +1375,49 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "delete": {
-                "description": "Delete subscription by ID",
+            }
+        },
+        "/users/{user_id}/renewals.ics": {
+            "get": {
+                "description": "RFC 5545 calendar with one monthly recurring event per active subscription.\nCalendar apps can't send auth headers, so access is checked by the feed token",
                 "produces": [
-                    "application/json"
+                    "text/calendar"
                 ],
                 "tags": [
-                    "subs"
+                    "users"
                 ],
-                "summary": "Delete subscription",
+                "summary": "Renewals calendar feed",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Subscription ID",
-                        "name": "id",
+                        "description": "User ID (UUID)",
+                        "name": "user_id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Feed token",
+                        "name": "token",
+                        "in": "query",
+                        "required": true
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "No Content"
+                    "200": {
+                        "description": "iCalendar",
+                        "schema": {
+                            "type": "string"
+                        }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "400": {
+                        "description": "Bad Request",
                         "schema": {
                             "$ref": "#/definitions/http.ErrorResponse"
                         }
                     },
-                    "409": {
-                        "description": "Conflict",
+                    "404": {
+                        "description": "Unknown user or invalid token",
                         "schema": {
                             "$ref": "#/definitions/http.ErrorResponse"
                         }
@@ -326,40 +1429,47 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "patch": {
-                "description": "Update subscription by ID",
-                "consumes": [
-                    "application/json"
+            }
+        },
+        "/users/{user_id}/renewals/token": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
                 ],
+                "description": "Issue a new unguessable token for the renewals calendar feed. The previous token stops working",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "subs"
+                    "users"
                 ],
-                "summary": "Update subscription",
+                "summary": "Issue calendar feed token",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Subscription ID",
-                        "name": "id",
+                        "description": "User ID (UUID)",
+                        "name": "user_id",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "description": "Updated data",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/http.SubscriptionUpdateRequest"
-                        }
+                        "type": "string",
+                        "description": "Caller user ID set by the API gateway when authentication is disabled, must match user_id",
+                        "name": "X-User-ID",
+                        "in": "header"
                     }
                 ],
                 "responses": {
-                    "202": {
-                        "description": "Accepted"
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/http.FeedTokenResponse"
+                        }
                     },
                     "400": {
                         "description": "Bad Request",
@@ -367,14 +1477,20 @@ const docTemplate = `{
                             "$ref": "#/definitions/http.ErrorResponse"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "401": {
+                        "description": "Missing, invalid or expired bearer token or API key, or missing X-User-ID without authentication",
                         "schema": {
                             "$ref": "#/definitions/http.ErrorResponse"
                         }
                     },
-                    "409": {
-                        "description": "Conflict",
+                    "403": {
+                        "description": "Not permitted for the caller's roles and scopes, or X-User-ID does not match user_id",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Another user's feed",
                         "schema": {
                             "$ref": "#/definitions/http.ErrorResponse"
                         }
@@ -390,6 +1506,93 @@ const docTemplate = `{
         }
     },
     "definitions": {
+        "http.APIKey": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "hint": {
+                    "description": "Beginning of the key to recognize it\nexample: efm_3f0c2d5e",
+                    "type": "string"
+                },
+                "id": {
+                    "description": "ID (UUID)\nexample: 3f0c2d5e-8d6b-4c1e-9a57-1b2f3c4d5e6f",
+                    "type": "string"
+                },
+                "last_used_at": {
+                    "type": "string"
+                },
+                "name": {
+                    "description": "Key name\nexample: billing export job",
+                    "type": "string"
+                },
+                "owner": {
+                    "description": "Principal subject for requests with the key\nexample: billing-job",
+                    "type": "string"
+                },
+                "revoked_at": {
+                    "type": "string"
+                },
+                "scopes": {
+                    "description": "Permissions of the key\nexample: [\"subs:read:any\"]",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "http.APIKeyCreateRequest": {
+            "type": "object",
+            "properties": {
+                "expires_at": {
+                    "description": "Expiration time (RFC 3339), omitted - the key doesn't expire\nrequired: false\nnullable: true",
+                    "type": "string"
+                },
+                "name": {
+                    "description": "Human-readable key name\nrequired: true\nexample: billing export job",
+                    "type": "string"
+                },
+                "owner": {
+                    "description": "Principal subject for requests with the key: user ID (UUID) or service name\nrequired: true\nexample: billing-job",
+                    "type": "string"
+                },
+                "scopes": {
+                    "description": "Permissions of the key, same values as token scopes\nrequired: true\nexample: [\"subs:read:any\"]",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "http.APIKeyIssuedResponse": {
+            "type": "object",
+            "properties": {
+                "api_key": {
+                    "$ref": "#/definitions/http.APIKey"
+                },
+                "key": {
+                    "description": "The key for \"Authorization: ApiKey \u003ckey\u003e\", shown only once",
+                    "type": "string"
+                }
+            }
+        },
+        "http.APIKeyListResponse": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/http.APIKey"
+                    }
+                }
+            }
+        },
         "http.ErrorResponse": {
             "type": "object",
             "properties": {
@@ -403,9 +1606,163 @@ const docTemplate = `{
                 }
             }
         },
+        "http.FeedTokenResponse": {
+            "type": "object",
+            "properties": {
+                "token": {
+                    "description": "Feed token, shown only once",
+                    "type": "string"
+                },
+                "url": {
+                    "description": "Calendar feed URL relative to the service root\nexample: /users/60601fee-2bf1-4721-ae6f-7636e79a0cba/renewals.ics?token=...\u0026tenant=default",
+                    "type": "string"
+                }
+            }
+        },
+        "http.ImportResponse": {
+            "type": "object",
+            "properties": {
+                "created": {
+                    "description": "Created subscriptions",
+                    "type": "integer"
+                },
+                "failed": {
+                    "description": "Failed rows",
+                    "type": "integer"
+                },
+                "mode": {
+                    "description": "Import mode\nexample: atomic",
+                    "type": "string"
+                },
+                "rows": {
+                    "description": "Per-row report",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/http.ImportRowResponse"
+                    }
+                },
+                "total": {
+                    "description": "Total processed rows",
+                    "type": "integer"
+                }
+            }
+        },
+        "http.ImportRowResponse": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "description": "Error code, same as ErrorResponse.Code\nexample: INVALID_PRICE",
+                    "type": "string"
+                },
+                "error": {
+                    "description": "Error message",
+                    "type": "string"
+                },
+                "id": {
+                    "description": "Created subscription ID, empty if the row failed or atomic import was rejected",
+                    "type": "string"
+                },
+                "line": {
+                    "description": "Line number in the imported file\nexample: 2",
+                    "type": "integer"
+                }
+            }
+        },
         "http.NullableStringUpdate": {
             "type": "object"
         },
+        "http.OutboxEvent": {
+            "type": "object",
+            "properties": {
+                "aggregate_id": {
+                    "description": "Subscription ID the event belongs to",
+                    "type": "string"
+                },
+                "attempts": {
+                    "description": "Failed publish attempts since the event was queued\nexample: 3",
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "description": "ID (UUID)",
+                    "type": "string"
+                },
+                "last_error": {
+                    "description": "Error of the last failed attempt\nexample: broker unavailable",
+                    "type": "string"
+                },
+                "next_attempt_at": {
+                    "description": "The event and later events of its subscription wait until this time, omitted - queued",
+                    "type": "string"
+                },
+                "payload": {
+                    "description": "Event payload as published",
+                    "type": "object"
+                },
+                "type": {
+                    "description": "Event type\nexample: subscription_created",
+                    "type": "string"
+                }
+            }
+        },
+        "http.OutboxRequeueRequest": {
+            "type": "object",
+            "properties": {
+                "all": {
+                    "description": "Requeue all deferred events, ids must be empty",
+                    "type": "boolean"
+                },
+                "ids": {
+                    "description": "Event IDs to requeue",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "http.OutboxRequeueResponse": {
+            "type": "object",
+            "properties": {
+                "requeued": {
+                    "description": "Events returned to the queue\nexample: 1",
+                    "type": "integer"
+                }
+            }
+        },
+        "http.OutboxResponse": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/http.OutboxEvent"
+                    }
+                },
+                "stats": {
+                    "$ref": "#/definitions/http.OutboxStats"
+                }
+            }
+        },
+        "http.OutboxStats": {
+            "type": "object",
+            "properties": {
+                "failed": {
+                    "description": "Events with failed publish attempts",
+                    "type": "integer"
+                },
+                "oldest": {
+                    "description": "Creation time of the oldest unpublished event",
+                    "type": "string"
+                },
+                "pending": {
+                    "description": "Unpublished events",
+                    "type": "integer"
+                }
+            }
+        },
         "http.Subscription": {
             "type": "object",
             "properties": {
@@ -432,6 +1789,10 @@ const docTemplate = `{
                 "user_id": {
                     "description": "User ID (UUID)\nexample: 60601fee-2bf1-4721-ae6f-7636e79a0cba",
                     "type": "string"
+                },
+                "version": {
+                    "description": "Version for optimistic concurrency, same as ETag\nexample: 1",
+                    "type": "integer"
                 }
             }
         },
@@ -455,7 +1816,7 @@ const docTemplate = `{
                     "type": "string"
                 },
                 "user_id": {
-                    "description": "User ID (UUID)\nrequired: true",
+                    "description": "User ID (UUID), for regular users taken from the token subject\nrequired: true",
                     "type": "string"
                 }
             }
@@ -491,6 +1852,20 @@ const docTemplate = `{
             }
         }
     },
+    "securityDefinitions": {
+        "ApiKeyAuth": {
+            "description": "API key as \"ApiKey \u003ckey\u003e\", issued by /admin/api-keys",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
+        },
+        "BearerAuth": {
+            "description": "JWT as \"Bearer \u003ctoken\u003e\"",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
+        }
+    },
     "tags": [
         {
             "description": "Subscriptions control",
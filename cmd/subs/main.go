@@ -3,21 +3,37 @@ package main
 import (
 	"context"
 	_ "embed"
+	"log"
+	"net"
 	"net/http"
 	"os"
 	"sync"
 	"time"
 
+	"github.com/end1essrage/efmob-tz/pkg/common/auth"
 	common "github.com/end1essrage/efmob-tz/pkg/common/cmd"
+	probes "github.com/end1essrage/efmob-tz/pkg/common/health"
+	cg "github.com/end1essrage/efmob-tz/pkg/common/interfaces/grpc"
+	m "github.com/end1essrage/efmob-tz/pkg/common/interfaces/http/middleware"
 	l "github.com/end1essrage/efmob-tz/pkg/common/logger"
 	common_metrics "github.com/end1essrage/efmob-tz/pkg/common/metrics"
+	"github.com/end1essrage/efmob-tz/pkg/common/persistance/apikeys"
+	"github.com/end1essrage/efmob-tz/pkg/common/persistance/idempotency"
+	ratelimit_store "github.com/end1essrage/efmob-tz/pkg/common/persistance/ratelimit"
+	"github.com/end1essrage/efmob-tz/pkg/common/ratelimit"
 	common_test "github.com/end1essrage/efmob-tz/pkg/common/testing"
+	"github.com/end1essrage/efmob-tz/pkg/common/tracing"
 	"github.com/end1essrage/efmob-tz/pkg/subs/application/container"
 	subs_repo "github.com/end1essrage/efmob-tz/pkg/subs/infrastructure/persistance/subs"
 	"github.com/end1essrage/efmob-tz/pkg/subs/infrastructure/publisher"
+	subs_gql "github.com/end1essrage/efmob-tz/pkg/subs/interfaces/gql"
+	subs_grpc "github.com/end1essrage/efmob-tz/pkg/subs/interfaces/grpc"
 	subs_http "github.com/end1essrage/efmob-tz/pkg/subs/interfaces/http"
 	subs_metrics "github.com/end1essrage/efmob-tz/pkg/subs/metrics"
 	"github.com/go-chi/chi/v5"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -34,12 +50,48 @@ import (
 // @schemes http
 // @tag.name subs
 // @tag.description Subscriptions control
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description JWT as "Bearer <token>"
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name Authorization
+// @description API key as "ApiKey <key>", issued by /admin/api-keys
 func main() {
-	// зaгружаем энвы
-	cfg := LoadConfig()
+	// загружаем конфигурацию: файл, энвы, флаги
+	flags, err := ParseFlags(os.Args[1:])
+	if err != nil {
+		log.Fatalf("flags: %v", err)
+	}
+	cfg, err := LoadConfig(flags)
+	if err != nil {
+		log.Fatalf("invalid config:\n%v", err)
+	}
+	if flags.PrintConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			log.Fatalf("print config: %v", err)
+		}
+		return
+	}
 
 	//создаем инстанс логгера
-	logger := l.New(cfg.ServiceName, true, common.ENV(cfg.Env) != common.ENV_PROD).Log("main", "main")
+	wrapper := l.New(cfg.ServiceName, true, cfg.Log.Level == "debug")
+	if err := wrapper.SetLevel(cfg.Log.Level); err != nil {
+		log.Fatalf("log level: %v", err)
+	}
+	logger := wrapper.Log("main", "main")
+
+	// подкоманды
+	if len(flags.Args) > 0 {
+		if flags.Args[0] != "migrate" {
+			logger.Fatalf("unknown command %q", flags.Args[0])
+		}
+		if err := runMigrate(common.Context(), cfg, flags.Args[1:], os.Stdout); err != nil {
+			logger.Fatalf("migrate: %v", err)
+		}
+		return
+	}
 
 	logger.Infof("запуск %s сервиса", cfg.ServiceName)
 
@@ -50,16 +102,16 @@ func main() {
 	//корневой контекст
 	ctx := common.Context()
 
-	//создаем роутер
-	r, cleanup := createSubsMicroservice(ctx, cfg)
+	//создаем роутер и grpc сервер
+	r, grpcServer, healthServer, probes, cleanup := createSubsMicroservice(ctx, flags, cfg)
 	//создаем http сервер
 	server := &http.Server{
-		Addr:              ":" + cfg.Port,
+		Addr:              ":" + cfg.HTTP.Port,
 		Handler:           r,
-		ReadTimeout:       5 * time.Second,
-		WriteTimeout:      10 * time.Second,
-		IdleTimeout:       60 * time.Second,
-		ReadHeaderTimeout: 2 * time.Second}
+		ReadTimeout:       cfg.HTTP.ReadTimeout,
+		WriteTimeout:      cfg.HTTP.WriteTimeout,
+		IdleTimeout:       cfg.HTTP.IdleTimeout,
+		ReadHeaderTimeout: cfg.HTTP.ReadHeaderTimeout}
 
 	//запускаем http сервер
 	go func() {
@@ -68,22 +120,51 @@ func main() {
 		}
 	}()
 
+	//запускаем grpc сервер на отдельном порту
+	lis, err := net.Listen("tcp", ":"+cfg.GRPC.Port)
+	if err != nil {
+		logger.Fatalf("grpc listen error: %v", err)
+	}
+	go func() {
+		if err := grpcServer.Serve(lis); err != nil {
+			logger.Fatalf("grpc server error: %v", err)
+		}
+	}()
+
 	//ждем сигнала остановки
 	<-ctx.Done()
 	logger.Infof("остановка %s серёвиса", cfg.ServiceName)
 
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	// readyz отдает fail, балансировщик перестает слать запросы, пока сервер еще их принимает
+	probes.Shutdown()
+	healthServer.Shutdown()
+	time.Sleep(cfg.Shutdown.DrainDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Shutdown.Timeout)
 	defer cancel()
 
 	if err := server.Shutdown(shutdownCtx); err != nil {
 		logger.Errorf("http shutdown error: %v", err)
 	}
 
+	// health отдает NOT_SERVING с начала остановки, пока дожидаемся активных вызовов
+	grpcStopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(grpcStopped)
+	}()
+	select {
+	case <-grpcStopped:
+	case <-shutdownCtx.Done():
+		logger.Errorf("grpc shutdown error: %v", shutdownCtx.Err())
+		grpcServer.Stop()
+	}
+
 	// очищаем ресурсы
 	cleanup()
 }
 
-func createSubsMicroservice(ctx context.Context, cfg *Config) (*chi.Mux, func()) {
+func createSubsMicroservice(ctx context.Context, flags Flags, cfg *Config) (*chi.Mux, *grpc.Server, *health.Server, *probes.Registry, func()) {
 	log := l.Logger().Log("main", "createSubsMicroservice")
 
 	// бд
@@ -100,7 +181,24 @@ func createSubsMicroservice(ctx context.Context, cfg *Config) (*chi.Mux, func())
 		}
 	}
 
-	dsn := cfg.PostgresDSN
+	// трассировка, выгружается последней - после остановки всех источников спанов
+	shutdownTracing, err := tracing.Init(ctx, tracing.Config{
+		ServiceName: cfg.ServiceName,
+		Endpoint:    cfg.Tracing.Endpoint,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		log.Fatalf("failed to init tracing: %v", err)
+	}
+	pushCleanup(func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(shutdownCtx); err != nil {
+			log.Errorf("ошибка выгрузки трасс: %v", err)
+		}
+	})
+
+	dsn := cfg.Postgres.DSN
 	// DEV - создаем тест контейнер
 	if common.ENV(cfg.Env) == common.ENV_DEV {
 		container, cs, err := common_test.SetupPostgresContainer(ctx)
@@ -124,45 +222,168 @@ func createSubsMicroservice(ctx context.Context, cfg *Config) (*chi.Mux, func())
 	if err != nil {
 		log.Fatalf("failed to connect to postgres: %v", err)
 	}
+	if err := gormDB.Use(tracing.GormPlugin{}); err != nil {
+		log.Fatalf("failed to register gorm tracing: %v", err)
+	}
 
-	pgRepo := subs_repo.NewGormSubscriptionRepo(gormDB)
+	pgRepo := subs_repo.NewGormSubscriptionRepo(gormDB).WithRetryPolicy(subs_repo.RetryPolicy{
+		Attempts: cfg.Postgres.Retry.Attempts,
+		Interval: cfg.Postgres.Retry.Interval,
+		Jitter:   cfg.Postgres.Retry.Jitter,
+	})
+	idempotencyStore := idempotency.NewGormStore(gormDB)
+	apiKeyStore := apikeys.NewGormStore(gormDB)
+	rateLimitStore := ratelimit_store.NewGormStore(gormDB)
 
-	// выключаем миграцию в проде
-	if common.ENV(cfg.Env) != common.ENV_PROD {
-		// Авто-миграция
-		if err := pgRepo.Migrate(); err != nil {
-			log.Fatalf("failed to auto-migrate: %v", err)
+	// миграции при старте, в проде выключены - схему обновляет subs migrate up
+	if cfg.Postgres.AutoMigrate {
+		migrator, err := newMigrator(gormDB)
+		if err != nil {
+			log.Fatalf("failed to load migrations: %v", err)
+		}
+		if err := migrator.Up(ctx); err != nil {
+			log.Fatalf("failed to migrate: %v", err)
 		}
 	}
 
+	// пробы liveness и readiness
+	registry := probes.NewRegistry(probes.Config{
+		Timeout:  cfg.Health.Timeout,
+		CacheTTL: cfg.Health.CacheTTL,
+	})
+
 	sqlDB, err := gormDB.DB()
 	if err == nil {
+		registry.Register("postgres", probes.Readiness, probes.Ping(sqlDB))
 		// регистрируем очистку подключения
 		pushCleanup(func() {
 			_ = sqlDB.Close()
 		})
+		// метрики пула соединений
+		common_metrics.RegisterDBStats(sqlDB, "subs")
 	}
 
-	di := container.NewContainer(pgRepo, pgRepo, pgRepo)
+	di := container.NewContainer(pgRepo, pgRepo, pgRepo, pgRepo, pgRepo, apiKeyStore, pgRepo)
+
+	// аутентификация jwt и ключами API, без ключей JWT - все запросы анонимные
+	authenticate := func(next http.Handler) http.Handler { return next }
+	var authenticator *auth.Authenticator
+	if cfg.AuthEnabled() {
+		jwtVerifier, err := auth.NewJWTVerifier(auth.JWTConfig{
+			HS256Secret: []byte(cfg.JWT.Secret),
+			JWKSFile:    cfg.JWT.JWKSFile,
+			Issuer:      cfg.JWT.Issuer,
+			Audience:    cfg.JWT.Audience,
+			Leeway:      cfg.JWT.Leeway,
+		})
+		if err != nil {
+			log.Fatalf("failed to init jwt verifier: %v", err)
+		}
+		authenticator = auth.NewAuthenticator(
+			auth.Scheme{Name: auth.SchemeBearer, Verifier: jwtVerifier},
+			auth.Scheme{Name: auth.SchemeAPIKey, Verifier: auth.NewAPIKeyVerifier(apiKeyStore)},
+		)
+		authenticate = m.Authenticate(authenticator, cfg.ServiceName)
+	} else {
+		log.Warn("JWT не настроен, аутентификация выключена")
+	}
 
 	log.Info("di контейнер собран")
 
 	//создаем хендлер
 	h := subs_http.NewSubsHandler(
-		common.ENV(os.Getenv("ENV")),
+		common.ENV(cfg.Env),
 		di,
 	)
 	log.Info("хендлеры инициализированы")
 
+	// в памяти у каждой реплики свой лимит, в postgres - общий
+	var limitStore ratelimit.Store = ratelimit.NewMemoryStore(time.Minute)
+	if cfg.RateLimit.Store == "postgres" {
+		limitStore = rateLimitStore
+	}
+	// значения уже проверены в Validate
+	defaultLimit, routeLimits, _ := cfg.RateLimit.Limits()
+	ipLimit, _ := ratelimit.ParseLimit(cfg.RateLimit.IP)
+	trustedProxies, _ := cfg.RateLimit.Proxies()
+	rateLimiter, err := m.NewRateLimiter(m.RateLimiterConfig{
+		Default:        defaultLimit,
+		Routes:         routeLimits,
+		TrustedProxies: trustedProxies,
+		Store:          limitStore,
+		FailOpen:       cfg.RateLimit.FailOpen,
+	})
+	if err != nil {
+		log.Fatalf("failed to init rate limiter: %v", err)
+	}
+	rateLimit := m.RateLimit(rateLimiter)
+	ipRateLimiter, err := m.NewRateLimiter(m.RateLimiterConfig{
+		Default:        ipLimit,
+		TrustedProxies: trustedProxies,
+		Store:          limitStore,
+		FailOpen:       cfg.RateLimit.FailOpen,
+	})
+	if err != nil {
+		log.Fatalf("failed to init ip rate limiter: %v", err)
+	}
+
 	//заполняем роуты
-	r := common.CreateRouter()
+	r := common.CreateRouter(registry, m.TimeoutConfig{
+		Default: cfg.HTTP.RequestTimeout,
+		Routes:  map[string]time.Duration{"GET /subscriptions/export": cfg.HTTP.ExportTimeout},
+	})
+
+	// уровень логов и лимиты запросов применяются без перезапуска
+	WatchConfig(flags, cfg, func(cur *Config, _ []string) {
+		if err := l.Logger().SetLevel(cur.Log.Level); err != nil {
+			log.Errorf("log level: %v", err)
+		}
+		def, routes, _ := cur.RateLimit.Limits()
+		if err := rateLimiter.SetLimits(def, routes); err != nil {
+			log.Errorf("rate limits: %v", err)
+		}
+		ip, _ := ratelimit.ParseLimit(cur.RateLimit.IP)
+		if err := ipRateLimiter.SetLimits(ip, nil); err != nil {
+			log.Errorf("ip rate limit: %v", err)
+		}
+	})
 
-	subs_http.AddRoutes(r, h)
+	// лимит по IP до аутентификации только на API: пробы и метрики не должны получать 429,
+	// лимиты клиентов ставятся на маршруты после аутентификации - см. middleware.RateLimit
+	r.Group(func(api chi.Router) {
+		api.Use(m.IPRateLimit(ipRateLimiter))
+
+		subs_http.AddRoutes(api, h, authenticate, rateLimit, m.Idempotency(idempotencyStore, m.IdempotencyConfig{
+			TTL:          cfg.Idempotency.TTL,
+			Lease:        cfg.Idempotency.Lease,
+			MaxBodyBytes: cfg.Idempotency.MaxBodyBytes,
+		}))
+		subs_gql.AddRoutes(api, authenticate(rateLimit(subs_gql.NewHandler(di, subs_gql.Options{
+			MaxDepth:      cfg.GraphQL.MaxDepth,
+			MaxComplexity: cfg.GraphQL.MaxComplexity,
+			Introspection: common.ENV(cfg.Env) != common.ENV_PROD,
+		}))))
+	})
 	log.Info("роуты созданы")
 
+	// grpc делит с http ведра клиентов и лимиты, в т.ч. после перезагрузки конфигурации
+	grpcServer, healthServer := common.CreateGRPCServer(authenticator, &cg.RateLimits{
+		Store:    limitStore,
+		IP:       ipRateLimiter.DefaultLimit,
+		Client:   rateLimiter.DefaultLimit,
+		FailOpen: cfg.RateLimit.FailOpen,
+	})
+	subs_grpc.Register(grpcServer, subs_grpc.NewServer(di))
+	healthServer.SetServingStatus(subs_grpc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	log.Info("grpc сервисы зарегистрированы")
+
 	// создаем и запускаем EventWorker
 	publisher := publisher.NewMockPublisher()
-	worker := subs_repo.NewEventWorker(gormDB, publisher, 5*time.Second, 100)
+	worker := subs_repo.NewEventWorker(gormDB, publisher, cfg.Outbox.Interval, cfg.Outbox.BatchSize, cfg.Outbox.Workers)
+
+	// зависший воркер лечится перезапуском, отставший outbox - снятием трафика
+	registry.Register("event_worker", probes.Liveness, worker.Heartbeat().Check(cfg.Outbox.HeartbeatTimeout))
+	registry.Register("outbox_lag", probes.Readiness, subs_repo.OutboxLagCheck(gormDB, cfg.Outbox.LagThreshold))
 
 	workerCtx, workerCancel := context.WithCancel(ctx)
 
@@ -174,6 +395,30 @@ func createSubsMicroservice(ctx context.Context, cfg *Config) (*chi.Mux, func())
 		worker.Run(workerCtx)
 	}()
 
+	// пересчитываем gauge метрики подписок и outbox
+	metricsCollector := subs_repo.NewMetricsCollector(gormDB, cfg.Metrics.Interval)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		metricsCollector.Run(workerCtx)
+	}()
+
+	// чистим истёкшие ключи идемпотентности
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		idempotencyStore.RunJanitor(workerCtx, time.Hour)
+	}()
+
+	// чистим полные ведра лимитов
+	if cfg.RateLimit.Store == "postgres" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rateLimitStore.RunJanitor(workerCtx, 10*time.Minute)
+		}()
+	}
+
 	pushCleanup(func() {
 		workerCancel()
 		wg.Wait()
@@ -181,5 +426,5 @@ func createSubsMicroservice(ctx context.Context, cfg *Config) (*chi.Mux, func())
 
 	log.Info("EventWorker запущен")
 
-	return r, popAllCleanup
+	return r, grpcServer, healthServer, registry, popAllCleanup
 }
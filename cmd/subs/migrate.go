@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/persistance/migrate"
+	"github.com/end1essrage/efmob-tz/pkg/subs/infrastructure/persistance/migrations"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newMigrator мигратор встроенных миграций сервиса
+func newMigrator(db *gorm.DB) (*migrate.Migrator, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	list, err := migrations.Load()
+	if err != nil {
+		return nil, err
+	}
+	return migrate.New(sqlDB, list), nil
+}
+
+// runMigrate подкоманда migrate up|down|status|to <version>
+func runMigrate(ctx context.Context, cfg *Config, args []string, out io.Writer) error {
+	// аргументы проверяем до подключения к базе
+	var run func(m *migrate.Migrator) error
+	switch {
+	case len(args) == 1 && args[0] == "up":
+		run = func(m *migrate.Migrator) error { return m.Up(ctx) }
+	case len(args) == 1 && args[0] == "down":
+		run = func(m *migrate.Migrator) error { return m.Down(ctx) }
+	case len(args) == 1 && args[0] == "status":
+		run = func(m *migrate.Migrator) error {
+			statuses, err := m.Status(ctx)
+			if err != nil {
+				return err
+			}
+			return printMigrationStatus(out, statuses)
+		}
+	case len(args) == 2 && args[0] == "to":
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil || version < 0 {
+			return fmt.Errorf("version %q: want a non-negative number", args[1])
+		}
+		run = func(m *migrate.Migrator) error { return m.To(ctx, version) }
+	default:
+		return errors.New("usage: subs migrate up|down|status|to <version>")
+	}
+
+	if cfg.Postgres.DSN == "" {
+		return errors.New("postgres.dsn must be set")
+	}
+	db, err := gorm.Open(postgres.Open(cfg.Postgres.DSN), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		return fmt.Errorf("connect to postgres: %w", err)
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+
+	m, err := newMigrator(db)
+	if err != nil {
+		return err
+	}
+	return run(m)
+}
+
+func printMigrationStatus(out io.Writer, statuses []migrate.Status) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+	for _, s := range statuses {
+		name, applied := s.Name, "pending"
+		if s.AppliedAt != nil {
+			applied = s.AppliedAt.Format(time.RFC3339)
+		}
+		if s.Unknown {
+			name = "? (newer than this binary)"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, name, applied)
+	}
+	return w.Flush()
+}
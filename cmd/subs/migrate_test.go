@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/persistance/migrate"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunMigrate_Usage(t *testing.T) {
+	// аргументы проверяются до подключения к базе
+	cfg := &Config{}
+	for _, args := range [][]string{nil, {"sideways"}, {"up", "now"}, {"to"}} {
+		err := runMigrate(context.Background(), cfg, args, &bytes.Buffer{})
+		require.ErrorContains(t, err, "usage", args)
+	}
+	require.ErrorContains(t, runMigrate(context.Background(), cfg, []string{"to", "-1"}, &bytes.Buffer{}), "non-negative")
+	require.ErrorContains(t, runMigrate(context.Background(), cfg, []string{"up"}, &bytes.Buffer{}), "postgres.dsn")
+}
+
+func TestPrintMigrationStatus(t *testing.T) {
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	var buf bytes.Buffer
+	require.NoError(t, printMigrationStatus(&buf, []migrate.Status{
+		{Version: 1, Name: "init", AppliedAt: &at},
+		{Version: 2, Name: "service_name_trgm"},
+		{Version: 9, AppliedAt: &at, Unknown: true},
+	}))
+	require.Equal(t, ""+
+		"VERSION  NAME                        APPLIED\n"+
+		"1        init                        2026-01-02T03:04:05Z\n"+
+		"2        service_name_trgm           pending\n"+
+		"9        ? (newer than this binary)  2026-01-02T03:04:05Z\n", buf.String())
+}
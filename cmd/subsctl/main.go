@@ -0,0 +1,203 @@
+// subsctl - административная утилита сервиса подписок.
+// Подписки, суммы и outbox идут через HTTP API, миграции - напрямую в postgres
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/subs/client"
+)
+
+const usage = `usage: subsctl [flags] <command> [args]
+
+commands:
+  subs list [filters]          list subscriptions, --all follows cursors
+  subs get <id>
+  subs create --user-id <id> --service <name> --price <n> --start <MM-YYYY> [--end <MM-YYYY>]
+  subs update <id> [--price <n>] [--start <MM-YYYY>] [--end <MM-YYYY> | --clear-end]
+  subs delete <id>
+  total --user-id <id> [filters]
+  outbox list [--type <t>] [--aggregate-id <id>] [--failed] [--limit <n>]
+  outbox requeue (--all | <id>...)
+  migrate up|down|status|to <version>
+
+flags (also accepted after the command):
+`
+
+// globals общие флаги, перекрывают профиль
+type globals struct {
+	config  string
+	profile string
+	output  string
+	url     string
+	token   string
+	apiKey  string
+	tenant  string
+	dsn     string
+	timeout time.Duration
+}
+
+// app окружение команды, profile заполняет parse
+type app struct {
+	g       *globals
+	args    []string
+	out     io.Writer
+	stderr  io.Writer
+	profile Profile
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "subsctl:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	g := &globals{}
+	fs := newFlagSet("subsctl", g, stderr)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	cmd, rest := fs.Arg(0), fs.Args()[1:]
+
+	// у подкоманд свои флаги, общие разбираются вместе с ними
+	var sub string
+	if cmd == "subs" || cmd == "outbox" {
+		if len(rest) == 0 {
+			return fmt.Errorf("%s: missing subcommand", cmd)
+		}
+		sub, rest = rest[0], rest[1:]
+	}
+
+	a := &app{g: g, args: rest, out: stdout, stderr: stderr}
+	switch cmd {
+	case "subs":
+		return a.subs(ctx, sub)
+	case "total":
+		return a.total(ctx)
+	case "outbox":
+		return a.outbox(ctx, sub)
+	case "migrate":
+		return a.migrate(ctx)
+	default:
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+}
+
+func newFlagSet(name string, g *globals, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&g.config, "config", g.config, "profiles file, default $SUBSCTL_CONFIG or ~/.config/subsctl/config.yaml")
+	fs.StringVar(&g.profile, "profile", g.profile, "profile name, default $SUBSCTL_PROFILE or current from the profiles file")
+	fs.StringVar(&g.output, "o", g.output, "output: table, json or csv")
+	fs.StringVar(&g.url, "url", g.url, "API base url")
+	fs.StringVar(&g.token, "token", g.token, "bearer token")
+	fs.StringVar(&g.apiKey, "api-key", g.apiKey, "API key")
+	fs.StringVar(&g.tenant, "tenant", g.tenant, "tenant id")
+	fs.StringVar(&g.dsn, "dsn", g.dsn, "postgres DSN for migrate")
+	if g.timeout == 0 {
+		g.timeout = 30 * time.Second
+	}
+	fs.DurationVar(&g.timeout, "timeout", g.timeout, "API request timeout")
+	return fs
+}
+
+// parseInterspersed разрешает флаги после позиционных аргументов: subs get <id> -o json
+func parseInterspersed(fs *flag.FlagSet, args []string) error {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+	return fs.Parse(append([]string{"--"}, positional...))
+}
+
+// flagSet флаги подкоманды вместе с общими
+func (a *app) flagSet(name string) *flag.FlagSet {
+	return newFlagSet("subsctl "+name, a.g, a.stderr)
+}
+
+// parse разбирает аргументы подкоманды и выбирает профиль
+func (a *app) parse(fs *flag.FlagSet) error {
+	if err := parseInterspersed(fs, a.args); err != nil {
+		return err
+	}
+	path, explicit := a.g.config, a.g.config != ""
+	if !explicit {
+		path = defaultProfilesPath()
+	}
+	profiles, err := loadProfiles(path, explicit)
+	if err != nil {
+		return err
+	}
+	a.profile, err = resolveProfile(profiles, a.g)
+	return err
+}
+
+// client клиент API профиля
+func (a *app) client() (*client.Client, error) {
+	p := a.profile
+	if p.URL == "" {
+		return nil, errors.New("api url is not set: use --url, SUBSCTL_URL or a profile")
+	}
+	opts := []client.Option{client.WithHTTPClient(&http.Client{Timeout: a.g.timeout})}
+	switch {
+	case p.Token != "":
+		opts = append(opts, client.WithToken(p.Token))
+	case p.APIKey != "":
+		opts = append(opts, client.WithAPIKey(p.APIKey))
+	}
+	if p.Tenant != "" {
+		opts = append(opts, client.WithTenant(p.Tenant))
+	}
+	return client.New(p.URL, opts...)
+}
+
+func (a *app) render(t table) error {
+	return render(a.out, a.profile.Output, t)
+}
+
+// stringList повторяемый флаг, значения можно перечислять через запятую
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(v string) error {
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			*l = append(*l, s)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/end1essrage/efmob-tz/pkg/subs/client"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// runCLI запускает subsctl без профилей пользователя
+func runCLI(t *testing.T, args ...string) (string, string, error) {
+	t.Helper()
+	t.Setenv("SUBSCTL_CONFIG", filepath.Join(t.TempDir(), "missing.yaml"))
+	var stdout, stderr bytes.Buffer
+	err := run(context.Background(), args, &stdout, &stderr)
+	return stdout.String(), stderr.String(), err
+}
+
+func writeProfiles(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestResolveProfile(t *testing.T) {
+	path := writeProfiles(t, `
+current: dev
+profiles:
+  dev:
+    url: http://dev:8080
+    token: dev-token
+  prod:
+    url: https://prod
+    api_key: prod-key
+    output: json
+`)
+	f, err := loadProfiles(path, true)
+	require.NoError(t, err)
+
+	p, err := resolveProfile(f, &globals{})
+	require.NoError(t, err)
+	require.Equal(t, Profile{URL: "http://dev:8080", Token: "dev-token", Output: "table"}, p)
+
+	t.Setenv("SUBSCTL_PROFILE", "prod")
+	t.Setenv("SUBSCTL_TENANT", "acme")
+	p, err = resolveProfile(f, &globals{output: "csv"})
+	require.NoError(t, err)
+	require.Equal(t, Profile{URL: "https://prod", APIKey: "prod-key", Tenant: "acme", Output: "csv"}, p)
+
+	_, err = resolveProfile(f, &globals{profile: "stage"})
+	require.ErrorContains(t, err, `profile "stage" not found, known: dev, prod`)
+
+	_, err = resolveProfile(f, &globals{output: "xml"})
+	require.ErrorContains(t, err, "want table, json or csv")
+}
+
+func TestLoadProfiles_Errors(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing.yaml")
+	f, err := loadProfiles(missing, false)
+	require.NoError(t, err)
+	require.Empty(t, f.Profiles)
+
+	_, err = loadProfiles(missing, true)
+	require.Error(t, err)
+
+	_, err = loadProfiles(writeProfiles(t, "profiles:\n  dev:\n    uri: http://dev\n"), true)
+	require.ErrorContains(t, err, "uri")
+}
+
+func TestRun_Usage(t *testing.T) {
+	_, _, err := runCLI(t, "launch")
+	require.ErrorContains(t, err, `unknown command "launch"`)
+
+	_, _, err = runCLI(t, "subs", "explode")
+	require.ErrorContains(t, err, "want list, get, create, update or delete")
+
+	_, _, err = runCLI(t, "subs", "get", "not-a-uuid", "--url", "http://localhost")
+	require.ErrorContains(t, err, "not-a-uuid")
+
+	_, _, err = runCLI(t, "subs", "list")
+	require.ErrorContains(t, err, "api url is not set")
+
+	_, _, err = runCLI(t, "outbox", "requeue", "--url", "http://localhost")
+	require.ErrorContains(t, err, "pass event ids or --all")
+
+	_, _, err = runCLI(t, "outbox", "requeue", "--all", uuid.NewString(), "--url", "http://localhost")
+	require.ErrorContains(t, err, "pass event ids or --all")
+
+	_, _, err = runCLI(t, "migrate", "sideways")
+	require.ErrorContains(t, err, "usage")
+
+	_, _, err = runCLI(t, "migrate", "up")
+	require.ErrorContains(t, err, "dsn is not set")
+}
+
+func TestRun_SubsList(t *testing.T) {
+	user := uuid.New()
+	subs := []client.Subscription{
+		{ID: uuid.New(), UserID: user, ServiceName: "Netflix", Price: 400, StartDate: "01-2025", Version: 1},
+		{ID: uuid.New(), UserID: user, ServiceName: "Spotify, Family", Price: 200, StartDate: "02-2025", EndDate: "12-2025", Version: 3},
+	}
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/subscriptions", r.URL.Path)
+		require.Equal(t, "Bearer t", r.Header.Get("Authorization"))
+		require.Equal(t, user.String(), r.URL.Query().Get("user_id"))
+		requests = append(requests, r.URL.Query().Get("cursor"))
+
+		// две страницы по одной подписке
+		page := subs[:1]
+		if r.URL.Query().Get("cursor") == "c1" {
+			page = subs[1:]
+		} else {
+			w.Header().Set("X-Next-Cursor", "c1")
+		}
+		json.NewEncoder(w).Encode(page)
+	}))
+	defer srv.Close()
+
+	out, errOut, err := runCLI(t, "--url", srv.URL, "--token", "t", "subs", "list", "--user-id", user.String())
+	require.NoError(t, err)
+	require.Contains(t, out, "Netflix")
+	require.NotContains(t, out, "Spotify")
+	require.Equal(t, "next cursor: c1\n", errOut)
+
+	// флаги после подкоманды, все страницы
+	requests = nil
+	out, _, err = runCLI(t, "subs", "list", "--all", "--user-id", user.String(), "-o", "csv", "--url", srv.URL, "--token", "t")
+	require.NoError(t, err)
+	require.Equal(t, []string{"", "c1"}, requests)
+	require.Equal(t, ""+
+		"ID,USER_ID,SERVICE,PRICE,START,END,VERSION\n"+
+		subs[0].ID.String()+","+user.String()+",Netflix,400,01-2025,,1\n"+
+		subs[1].ID.String()+","+user.String()+`,"Spotify, Family",200,02-2025,12-2025,3`+"\n", out)
+
+	out, _, err = runCLI(t, "subs", "list", "--all", "--user-id", user.String(), "-o", "json", "--url", srv.URL, "--token", "t")
+	require.NoError(t, err)
+	var got []client.Subscription
+	require.NoError(t, json.Unmarshal([]byte(out), &got))
+	require.Equal(t, subs, got)
+}
+
+func TestRun_ProfileAndUpdate(t *testing.T) {
+	id := uuid.New()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPatch, r.Method)
+		require.Equal(t, "/subscriptions/"+id.String(), r.URL.Path)
+		require.Equal(t, "ApiKey k", r.Header.Get("Authorization"))
+		require.Equal(t, "acme", r.Header.Get("X-Tenant-ID"))
+		body, _ := io.ReadAll(r.Body)
+		require.JSONEq(t, `{"price":0,"end_date":null}`, string(body))
+		json.NewEncoder(w).Encode(client.Subscription{ID: id, ServiceName: "Netflix", Version: 2})
+	}))
+	defer srv.Close()
+
+	path := writeProfiles(t, "profiles:\n  stage:\n    url: "+srv.URL+"\n    api_key: k\n    tenant: acme\n    output: json\n")
+	out, _, err := runCLI(t, "--config", path, "--profile", "stage", "subs", "update", id.String(), "--price", "0", "--clear-end")
+	require.NoError(t, err)
+	var sub client.Subscription
+	require.NoError(t, json.Unmarshal([]byte(out), &sub))
+	require.Equal(t, 2, sub.Version)
+
+	_, _, err = runCLI(t, "--config", path, "--profile", "stage", "subs", "update", id.String(), "--end", "12-2025", "--clear-end")
+	require.ErrorContains(t, err, "mutually exclusive")
+}
+
+func TestRun_Outbox(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/admin/outbox":
+			require.Equal(t, "true", r.URL.Query().Get("failed"))
+			w.Write([]byte(`{"stats":{"pending":3,"failed":1},"items":[{"id":"` + uuid.NewString() +
+				`","aggregate_id":"` + uuid.NewString() + `","type":"subscription_updated","payload":{},` +
+				`"created_at":"2026-01-02T03:04:05Z","attempts":2,"last_error":"broker\tunavailable\n","next_attempt_at":"2026-01-02T03:10:05Z"}]}`))
+		case "/admin/outbox/requeue":
+			body, _ := io.ReadAll(r.Body)
+			require.JSONEq(t, `{"all":true}`, string(body))
+			w.Write([]byte(`{"requeued":1}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	out, errOut, err := runCLI(t, "--url", srv.URL, "outbox", "list", "--failed")
+	require.NoError(t, err)
+	require.Equal(t, "pending: 3, failed: 1, oldest: -\n", errOut)
+	require.Contains(t, out, "2026-01-02T03:10:05Z  broker unavailable\n")
+
+	out, _, err = runCLI(t, "--url", srv.URL, "-o", "json", "outbox", "requeue", "--all")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"requeued":1}`, out)
+}
+
+func TestRun_APIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"error":"forbidden","code":"FORBIDDEN"}`))
+	}))
+	defer srv.Close()
+
+	_, _, err := runCLI(t, "--url", srv.URL, "subs", "delete", uuid.NewString())
+	require.EqualError(t, err, "api: 403 FORBIDDEN: forbidden")
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/persistance/migrate"
+	"github.com/end1essrage/efmob-tz/pkg/subs/infrastructure/persistance/migrations"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// migrate миграции встроены в subsctl - версия утилиты должна совпадать с сервисом
+func (a *app) migrate(ctx context.Context) error {
+	fs := a.flagSet("migrate")
+	if err := a.parse(fs); err != nil {
+		return err
+	}
+
+	// аргументы проверяем до подключения к базе
+	args := fs.Args()
+	var run func(m *migrate.Migrator) error
+	switch {
+	case len(args) == 1 && args[0] == "up":
+		run = func(m *migrate.Migrator) error { return m.Up(ctx) }
+	case len(args) == 1 && args[0] == "down":
+		run = func(m *migrate.Migrator) error { return m.Down(ctx) }
+	case len(args) == 1 && args[0] == "status":
+		run = func(m *migrate.Migrator) error {
+			statuses, err := m.Status(ctx)
+			if err != nil {
+				return err
+			}
+			return a.renderMigrationStatus(statuses)
+		}
+	case len(args) == 2 && args[0] == "to":
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil || version < 0 {
+			return fmt.Errorf("version %q: want a non-negative number", args[1])
+		}
+		run = func(m *migrate.Migrator) error { return m.To(ctx, version) }
+	default:
+		return errors.New("usage: subsctl migrate up|down|status|to <version>")
+	}
+
+	if a.profile.DSN == "" {
+		return errors.New("postgres dsn is not set: use --dsn, SUBSCTL_DSN or a profile")
+	}
+	db, err := sql.Open("pgx", a.profile.DSN)
+	if err != nil {
+		return fmt.Errorf("connect to postgres: %w", err)
+	}
+	defer db.Close()
+
+	list, err := migrations.Load()
+	if err != nil {
+		return err
+	}
+	return run(migrate.New(db, list))
+}
+
+func (a *app) renderMigrationStatus(statuses []migrate.Status) error {
+	type status struct {
+		Version   int64      `json:"version"`
+		Name      string     `json:"name"`
+		AppliedAt *time.Time `json:"applied_at"`
+		Unknown   bool       `json:"unknown,omitempty"`
+	}
+
+	rows := make([][]string, len(statuses))
+	value := make([]status, len(statuses))
+	for i, s := range statuses {
+		name, applied := s.Name, "pending"
+		if s.AppliedAt != nil {
+			applied = s.AppliedAt.Format(time.RFC3339)
+		}
+		if s.Unknown {
+			name = "? (newer than this binary)"
+		}
+		rows[i] = []string{strconv.FormatInt(s.Version, 10), name, applied}
+		value[i] = status{s.Version, s.Name, s.AppliedAt, s.Unknown}
+	}
+	return a.render(table{header: []string{"VERSION", "NAME", "APPLIED"}, rows: rows, value: value})
+}
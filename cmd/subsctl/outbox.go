@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/subs/client"
+	"github.com/google/uuid"
+)
+
+func (a *app) outbox(ctx context.Context, sub string) error {
+	switch sub {
+	case "list":
+		return a.outboxList(ctx)
+	case "requeue":
+		return a.outboxRequeue(ctx)
+	default:
+		return fmt.Errorf("outbox: unknown subcommand %q, want list or requeue", sub)
+	}
+}
+
+func (a *app) outboxList(ctx context.Context) error {
+	fs := a.flagSet("outbox list")
+	var opts client.OutboxOptions
+	var aggregate string
+	fs.StringVar(&opts.Type, "type", "", "event type")
+	fs.StringVar(&aggregate, "aggregate-id", "", "subscription id")
+	fs.BoolVar(&opts.Failed, "failed", false, "only events with failed publish attempts")
+	fs.IntVar(&opts.Limit, "limit", 0, "max events, 1-1000")
+	if err := a.parse(fs); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("outbox list: unexpected arguments %v", fs.Args())
+	}
+	if aggregate != "" {
+		id, err := uuid.Parse(aggregate)
+		if err != nil {
+			return fmt.Errorf("--aggregate-id %q: %w", aggregate, err)
+		}
+		opts.AggregateID = &id
+	}
+
+	c, err := a.client()
+	if err != nil {
+		return err
+	}
+	report, err := c.ListOutbox(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	// сводка в stderr, чтобы не мешать разбору таблицы и csv
+	if a.profile.Output != "json" {
+		oldest := "-"
+		if report.Stats.Oldest != nil {
+			oldest = report.Stats.Oldest.Format(time.RFC3339)
+		}
+		fmt.Fprintf(a.stderr, "pending: %d, failed: %d, oldest: %s\n", report.Stats.Pending, report.Stats.Failed, oldest)
+	}
+
+	rows := make([][]string, len(report.Items))
+	for i, ev := range report.Items {
+		next := ""
+		if ev.NextAttemptAt != nil {
+			next = ev.NextAttemptAt.Format(time.RFC3339)
+		}
+		rows[i] = []string{
+			ev.ID.String(), ev.AggregateID.String(), ev.Type, ev.CreatedAt.Format(time.RFC3339),
+			strconv.Itoa(ev.Attempts), next, ev.LastError,
+		}
+	}
+	return a.render(table{
+		header: []string{"ID", "AGGREGATE_ID", "TYPE", "CREATED", "ATTEMPTS", "NEXT_ATTEMPT", "LAST_ERROR"},
+		rows:   rows,
+		value:  report,
+	})
+}
+
+func (a *app) outboxRequeue(ctx context.Context) error {
+	fs := a.flagSet("outbox requeue")
+	var all bool
+	fs.BoolVar(&all, "all", false, "requeue all deferred events")
+	if err := a.parse(fs); err != nil {
+		return err
+	}
+	if all == (fs.NArg() > 0) {
+		return errors.New("outbox requeue: pass event ids or --all")
+	}
+	ids := make([]uuid.UUID, fs.NArg())
+	for i, s := range fs.Args() {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			return fmt.Errorf("outbox requeue: id %q: %w", s, err)
+		}
+		ids[i] = id
+	}
+
+	c, err := a.client()
+	if err != nil {
+		return err
+	}
+	n, err := c.RequeueOutbox(ctx, ids, all)
+	if err != nil {
+		return err
+	}
+	return a.render(table{
+		header: []string{"REQUEUED"},
+		rows:   [][]string{{strconv.FormatInt(n, 10)}},
+		value:  map[string]int64{"requeued": n},
+	})
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Profile окружение, с которым работает subsctl
+type Profile struct {
+	URL    string `yaml:"url"`     // адрес API, например http://localhost:8080
+	Token  string `yaml:"token"`   // bearer токен
+	APIKey string `yaml:"api_key"` // ключ API, если токен не задан
+	Tenant string `yaml:"tenant"`  // X-Tenant-ID, пусто - тенант вызывающего
+	DSN    string `yaml:"dsn"`     // postgres для migrate
+	Output string `yaml:"output"`  // table, json или csv
+}
+
+// ProfilesFile файл профилей
+type ProfilesFile struct {
+	Current  string             `yaml:"current"` // профиль по умолчанию
+	Profiles map[string]Profile `yaml:"profiles"`
+}
+
+// defaultProfilesPath $SUBSCTL_CONFIG или ~/.config/subsctl/config.yaml
+func defaultProfilesPath() string {
+	if p := os.Getenv("SUBSCTL_CONFIG"); p != "" {
+		return p
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "subsctl", "config.yaml")
+}
+
+// loadProfiles отсутствующий файл по умолчанию не ошибка, явно указанный - ошибка
+func loadProfiles(path string, explicit bool) (*ProfilesFile, error) {
+	f := &ProfilesFile{}
+	if path == "" {
+		return f, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && !explicit {
+		return f, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("profiles: %w", err)
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(f); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("profiles %s: %w", path, err)
+	}
+	return f, nil
+}
+
+// resolveProfile выбирает профиль: флаг, SUBSCTL_PROFILE, current из файла, default.
+// Поля профиля перекрываются окружением SUBSCTL_* и флагами
+func resolveProfile(f *ProfilesFile, g *globals) (Profile, error) {
+	name := g.profile
+	if name == "" {
+		name = os.Getenv("SUBSCTL_PROFILE")
+	}
+	if name == "" {
+		name = f.Current
+	}
+	if name == "" {
+		name = "default"
+	}
+
+	p, ok := f.Profiles[name]
+	// без файла профилей работаем на окружении и флагах
+	if !ok && (len(f.Profiles) > 0 || g.profile != "") {
+		names := make([]string, 0, len(f.Profiles))
+		for n := range f.Profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return p, fmt.Errorf("profile %q not found, known: %s", name, strings.Join(names, ", "))
+	}
+
+	for _, o := range []struct {
+		dst       *string
+		env, flag string
+	}{
+		{&p.URL, "SUBSCTL_URL", g.url},
+		{&p.Token, "SUBSCTL_TOKEN", g.token},
+		{&p.APIKey, "SUBSCTL_API_KEY", g.apiKey},
+		{&p.Tenant, "SUBSCTL_TENANT", g.tenant},
+		{&p.DSN, "SUBSCTL_DSN", g.dsn},
+		{&p.Output, "SUBSCTL_OUTPUT", g.output},
+	} {
+		if v := os.Getenv(o.env); v != "" {
+			*o.dst = v
+		}
+		if o.flag != "" {
+			*o.dst = o.flag
+		}
+	}
+
+	if p.Output == "" {
+		p.Output = "table"
+	}
+	switch p.Output {
+	case "table", "json", "csv":
+	default:
+		return p, fmt.Errorf("output %q: want table, json or csv", p.Output)
+	}
+	return p, nil
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+
+	"github.com/end1essrage/efmob-tz/pkg/subs/client"
+	"github.com/google/uuid"
+)
+
+var subscriptionHeader = []string{"ID", "USER_ID", "SERVICE", "PRICE", "START", "END", "VERSION"}
+
+func subscriptionRow(s client.Subscription) []string {
+	return []string{s.ID.String(), s.UserID.String(), s.ServiceName, strconv.Itoa(s.Price), s.StartDate, s.EndDate, strconv.Itoa(s.Version)}
+}
+
+func (a *app) renderSubscriptions(items []client.Subscription) error {
+	rows := make([][]string, len(items))
+	for i, s := range items {
+		rows[i] = subscriptionRow(s)
+	}
+	return a.render(table{header: subscriptionHeader, rows: rows, value: items})
+}
+
+func (a *app) subs(ctx context.Context, sub string) error {
+	switch sub {
+	case "list":
+		return a.subsList(ctx)
+	case "get":
+		return a.subsGet(ctx)
+	case "create":
+		return a.subsCreate(ctx)
+	case "update":
+		return a.subsUpdate(ctx)
+	case "delete":
+		return a.subsDelete(ctx)
+	default:
+		return fmt.Errorf("subs: unknown subcommand %q, want list, get, create, update or delete", sub)
+	}
+}
+
+// filterFlags фильтры как у GET /subscriptions и /subscriptions/total
+type filterFlags struct {
+	userIDs  stringList
+	services stringList
+	f        client.Filter
+	priceMin optionalInt
+	priceMax optionalInt
+	nilEnd   optionalBool
+}
+
+func (ff *filterFlags) register(fs *flag.FlagSet) {
+	fs.Var(&ff.userIDs, "user-id", "user id, repeatable or comma separated")
+	fs.Var(&ff.services, "service", "service name, repeatable or comma separated")
+	fs.StringVar(&ff.f.ServiceMatch, "service-match", "", "service name match: exact, icase or fuzzy")
+	fs.Var(&ff.priceMin, "price-min", "minimal price")
+	fs.Var(&ff.priceMax, "price-max", "maximal price")
+	fs.StringVar(&ff.f.CreatedFrom, "created-from", "", "created from, YYYY-MM-DD")
+	fs.StringVar(&ff.f.CreatedTo, "created-to", "", "created to inclusive, YYYY-MM-DD")
+	fs.StringVar(&ff.f.Filter, "filter", "", "RSQL expression, e.g. 'price=gt=500'")
+	fs.StringVar(&ff.f.StartFrom, "start-from", "", "start period from, MM-YYYY")
+	fs.StringVar(&ff.f.StartTo, "start-to", "", "start period to, MM-YYYY")
+	fs.StringVar(&ff.f.EndFrom, "end-from", "", "end period from, MM-YYYY")
+	fs.StringVar(&ff.f.EndTo, "end-to", "", "end period to, MM-YYYY")
+	fs.Var(&ff.nilEnd, "nil-end", "include subscriptions without end date")
+}
+
+func (ff *filterFlags) filter() (client.Filter, error) {
+	f := ff.f
+	for _, s := range ff.userIDs {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			return f, fmt.Errorf("--user-id %q: %w", s, err)
+		}
+		f.UserIDs = append(f.UserIDs, id)
+	}
+	f.ServiceNames = ff.services
+	f.PriceMin, f.PriceMax, f.NilEnd = ff.priceMin.v, ff.priceMax.v, ff.nilEnd.v
+	return f, nil
+}
+
+func (a *app) subsList(ctx context.Context) error {
+	fs := a.flagSet("subs list")
+	var ff filterFlags
+	ff.register(fs)
+	var opts client.ListOptions
+	var all bool
+	fs.IntVar(&opts.Page, "page", 0, "page number from 1")
+	fs.IntVar(&opts.PageSize, "page-size", 0, "page size, max 1000")
+	fs.StringVar(&opts.Cursor, "cursor", "", "cursor from a previous page")
+	fs.StringVar(&opts.Sort, "sort", "", "multi-field sort, e.g. '-price,start_date'")
+	fs.BoolVar(&opts.WithTotal, "with-total", false, "print total count to stderr")
+	fs.BoolVar(&all, "all", false, "fetch all pages")
+	if err := a.parse(fs); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("subs list: unexpected arguments %v", fs.Args())
+	}
+	var err error
+	if opts.Filter, err = ff.filter(); err != nil {
+		return err
+	}
+
+	c, err := a.client()
+	if err != nil {
+		return err
+	}
+
+	var items []client.Subscription
+	for page, err := range c.Pages(ctx, opts) {
+		if err != nil {
+			return err
+		}
+		items = append(items, page.Items...)
+		if page.Total != nil {
+			fmt.Fprintf(a.stderr, "total: %d\n", *page.Total)
+		}
+		if !all {
+			if page.NextCursor != "" {
+				fmt.Fprintf(a.stderr, "next cursor: %s\n", page.NextCursor)
+			}
+			break
+		}
+	}
+	if items == nil {
+		items = []client.Subscription{}
+	}
+	return a.renderSubscriptions(items)
+}
+
+// idArg единственный позиционный аргумент - ID
+func idArg(fs *flag.FlagSet, cmd string) (uuid.UUID, error) {
+	if fs.NArg() != 1 {
+		return uuid.Nil, fmt.Errorf("%s: want exactly one id", cmd)
+	}
+	id, err := uuid.Parse(fs.Arg(0))
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%s: id %q: %w", cmd, fs.Arg(0), err)
+	}
+	return id, nil
+}
+
+func (a *app) subsGet(ctx context.Context) error {
+	fs := a.flagSet("subs get")
+	if err := a.parse(fs); err != nil {
+		return err
+	}
+	id, err := idArg(fs, "subs get")
+	if err != nil {
+		return err
+	}
+
+	c, err := a.client()
+	if err != nil {
+		return err
+	}
+	sub, err := c.GetSubscription(ctx, id)
+	if err != nil {
+		return err
+	}
+	return a.render(table{header: subscriptionHeader, rows: [][]string{subscriptionRow(*sub)}, value: sub})
+}
+
+func (a *app) subsCreate(ctx context.Context) error {
+	fs := a.flagSet("subs create")
+	var userID, end string
+	var in client.CreateSubscription
+	fs.StringVar(&userID, "user-id", "", "user id")
+	fs.StringVar(&in.ServiceName, "service", "", "service name")
+	fs.IntVar(&in.Price, "price", 0, "monthly price")
+	fs.StringVar(&in.StartDate, "start", "", "start, MM-YYYY")
+	fs.StringVar(&end, "end", "", "end, MM-YYYY")
+	if err := a.parse(fs); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("subs create: unexpected arguments %v", fs.Args())
+	}
+	if userID == "" || in.ServiceName == "" || in.StartDate == "" {
+		return errors.New("subs create: --user-id, --service and --start are required")
+	}
+	var err error
+	if in.UserID, err = uuid.Parse(userID); err != nil {
+		return fmt.Errorf("--user-id %q: %w", userID, err)
+	}
+	if end != "" {
+		in.EndDate = &end
+	}
+
+	c, err := a.client()
+	if err != nil {
+		return err
+	}
+	sub, err := c.CreateSubscription(ctx, in)
+	if err != nil {
+		return err
+	}
+	return a.render(table{header: subscriptionHeader, rows: [][]string{subscriptionRow(*sub)}, value: sub})
+}
+
+func (a *app) subsUpdate(ctx context.Context) error {
+	fs := a.flagSet("subs update")
+	var price optionalInt
+	var start, end optionalString
+	var in client.UpdateSubscription
+	fs.Var(&price, "price", "monthly price")
+	fs.Var(&start, "start", "start, MM-YYYY")
+	fs.Var(&end, "end", "end, MM-YYYY")
+	fs.BoolVar(&in.ClearEndDate, "clear-end", false, "remove end date")
+	if err := a.parse(fs); err != nil {
+		return err
+	}
+	id, err := idArg(fs, "subs update")
+	if err != nil {
+		return err
+	}
+	if in.ClearEndDate && end.v != nil {
+		return errors.New("subs update: --end and --clear-end are mutually exclusive")
+	}
+	in.Price, in.StartDate, in.EndDate = price.v, start.v, end.v
+
+	c, err := a.client()
+	if err != nil {
+		return err
+	}
+	sub, err := c.UpdateSubscription(ctx, id, in)
+	if err != nil {
+		return err
+	}
+	if sub == nil {
+		fmt.Fprintln(a.stderr, "nothing to update")
+		return nil
+	}
+	return a.render(table{header: subscriptionHeader, rows: [][]string{subscriptionRow(*sub)}, value: sub})
+}
+
+func (a *app) subsDelete(ctx context.Context) error {
+	fs := a.flagSet("subs delete")
+	if err := a.parse(fs); err != nil {
+		return err
+	}
+	id, err := idArg(fs, "subs delete")
+	if err != nil {
+		return err
+	}
+
+	c, err := a.client()
+	if err != nil {
+		return err
+	}
+	return c.DeleteSubscription(ctx, id)
+}
+
+func (a *app) total(ctx context.Context) error {
+	fs := a.flagSet("total")
+	var ff filterFlags
+	ff.register(fs)
+	if err := a.parse(fs); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("total: unexpected arguments %v", fs.Args())
+	}
+	f, err := ff.filter()
+	if err != nil {
+		return err
+	}
+
+	c, err := a.client()
+	if err != nil {
+		return err
+	}
+	total, err := c.TotalCost(ctx, f)
+	if err != nil {
+		return err
+	}
+	return a.render(table{
+		header: []string{"TOTAL"},
+		rows:   [][]string{{strconv.Itoa(total)}},
+		value:  map[string]int{"total": total},
+	})
+}
+
+// optionalInt, optionalString, optionalBool отличают незаданный флаг от нулевого значения
+
+type optionalInt struct{ v *int }
+
+func (o *optionalInt) String() string {
+	if o.v == nil {
+		return ""
+	}
+	return strconv.Itoa(*o.v)
+}
+
+func (o *optionalInt) Set(s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return err
+	}
+	o.v = &n
+	return nil
+}
+
+type optionalString struct{ v *string }
+
+func (o *optionalString) String() string {
+	if o.v == nil {
+		return ""
+	}
+	return *o.v
+}
+
+func (o *optionalString) Set(s string) error {
+	o.v = &s
+	return nil
+}
+
+type optionalBool struct{ v *bool }
+
+func (o *optionalBool) String() string {
+	if o.v == nil {
+		return ""
+	}
+	return strconv.FormatBool(*o.v)
+}
+
+func (o *optionalBool) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	o.v = &b
+	return nil
+}
+
+func (o *optionalBool) IsBoolFlag() bool { return true }
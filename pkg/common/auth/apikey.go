@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/logger"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	ErrAPIKeyRevoked  = errors.New("api key revoked")
+)
+
+const (
+	// apiKeyPrefix отличает ключи от других секретов в логах и сканерах
+	apiKeyPrefix = "efm_"
+	// apiKeySecretSize длина секрета в байтах до кодирования
+	apiKeySecretSize = 32
+	// apiKeyHintSize сколько символов ключа показывается после выпуска
+	apiKeyHintSize = len(apiKeyPrefix) + 8
+	// apiKeyTouchInterval last_used_at обновляется не чаще, чтобы не писать в бд на каждый запрос
+	apiKeyTouchInterval = time.Minute
+)
+
+// APIKey ключ доступа для пакетных задач и партнеров. Хранится только хэш ключа
+type APIKey struct {
+	ID   uuid.UUID
+	Name string
+	// TenantID тенант, в котором ключ выпущен, запросы с ключом работают в нем
+	TenantID string
+	// Owner субъект principal, uuid - пользователь
+	Owner  string
+	Scopes []string
+	Hash   string
+	// Hint начало ключа, чтобы узнать его в списке
+	Hint       string
+	CreatedAt  time.Time
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+}
+
+// Expired ключ с истекшим сроком
+func (k *APIKey) Expired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
+
+// Principal владелец ключа с правами из его скоупов
+func (k *APIKey) Principal() *Principal {
+	p := &Principal{
+		Subject:  k.Owner,
+		TenantID: k.TenantID,
+		APIKeyID: k.ID,
+		Scopes:   append([]string(nil), k.Scopes...),
+	}
+	if id, err := uuid.Parse(k.Owner); err == nil {
+		p.UserID = id
+	}
+	return p
+}
+
+// NewAPIKeySecret генерирует ключ вида efm_<id>_<секрет>.
+// id в ключе позволяет найти запись без перебора хэшей
+func NewAPIKeySecret(id uuid.UUID) (string, error) {
+	buf := make([]byte, apiKeySecretSize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return apiKeyPrefix + hex.EncodeToString(id[:]) + "_" + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// HashAPIKey в хранилище лежит только хэш ключа
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// APIKeyHint начало ключа для списка ключей
+func APIKeyHint(key string) string {
+	if len(key) < apiKeyHintSize {
+		return key
+	}
+	return key[:apiKeyHintSize]
+}
+
+// ParseAPIKeyID id записи из ключа
+func ParseAPIKeyID(key string) (uuid.UUID, bool) {
+	rest, ok := strings.CutPrefix(key, apiKeyPrefix)
+	if !ok {
+		return uuid.Nil, false
+	}
+	rawID, secret, ok := strings.Cut(rest, "_")
+	if !ok || secret == "" {
+		return uuid.Nil, false
+	}
+	b, err := hex.DecodeString(rawID)
+	if err != nil {
+		return uuid.Nil, false
+	}
+	id, err := uuid.FromBytes(b)
+	if err != nil {
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// APIKeyStore чтение ключей для проверки. Тенант запроса еще не известен - поиск по всем тенантам
+type APIKeyStore interface {
+	// GetAPIKey возвращает ErrAPIKeyNotFound, если ключа нет
+	GetAPIKey(ctx context.Context, id uuid.UUID) (*APIKey, error)
+	// TouchAPIKey отмечает использование ключа
+	TouchAPIKey(ctx context.Context, id uuid.UUID, at time.Time) error
+}
+
+// APIKeyVerifier проверяет ключи схемы ApiKey
+type APIKeyVerifier struct {
+	store APIKeyStore
+	now   func() time.Time
+}
+
+var _ Verifier = (*APIKeyVerifier)(nil)
+
+func NewAPIKeyVerifier(store APIKeyStore) *APIKeyVerifier {
+	return &APIKeyVerifier{store: store, now: time.Now}
+}
+
+// Verify ищет ключ по id и сравнивает хэш. Ошибки оборачивают ErrInvalidToken или ErrTokenExpired
+func (v *APIKeyVerifier) Verify(ctx context.Context, key string) (*Principal, error) {
+	id, ok := ParseAPIKeyID(key)
+	if !ok {
+		return nil, fmt.Errorf("%w: malformed api key", ErrInvalidToken)
+	}
+
+	k, err := v.store.GetAPIKey(ctx, id)
+	if err != nil {
+		if errors.Is(err, ErrAPIKeyNotFound) {
+			return nil, fmt.Errorf("%w: unknown api key", ErrInvalidToken)
+		}
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(HashAPIKey(key)), []byte(k.Hash)) != 1 {
+		return nil, fmt.Errorf("%w: api key mismatch", ErrInvalidToken)
+	}
+	now := v.now()
+	if k.RevokedAt != nil {
+		return nil, fmt.Errorf("%w: api key revoked", ErrInvalidToken)
+	}
+	if k.Expired(now) {
+		return nil, ErrTokenExpired
+	}
+
+	if k.LastUsedAt == nil || now.Sub(*k.LastUsedAt) >= apiKeyTouchInterval {
+		// ошибка отметки не мешает запросу
+		if err := v.store.TouchAPIKey(ctx, k.ID, now); err != nil {
+			logger.Logger().WithFields(logger.LogOptions{
+				Pkg:  "auth",
+				Func: "APIKeyVerifier.Verify",
+				Ctx:  ctx,
+			}).Errorf("ошибка отметки использования ключа %s: %v", k.ID, err)
+		}
+	}
+
+	return k.Principal(), nil
+}
@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// memKeyStore ключи в памяти
+type memKeyStore struct {
+	keys    map[uuid.UUID]*APIKey
+	touched int
+}
+
+func (s *memKeyStore) GetAPIKey(_ context.Context, id uuid.UUID) (*APIKey, error) {
+	k, ok := s.keys[id]
+	if !ok {
+		return nil, ErrAPIKeyNotFound
+	}
+	cp := *k
+	return &cp, nil
+}
+
+func (s *memKeyStore) TouchAPIKey(_ context.Context, id uuid.UUID, at time.Time) error {
+	s.touched++
+	s.keys[id].LastUsedAt = &at
+	return nil
+}
+
+func (s *memKeyStore) issue(t *testing.T, owner string, scopes ...string) (*APIKey, string) {
+	t.Helper()
+	k := &APIKey{ID: uuid.New(), Owner: owner, Scopes: scopes}
+	secret, err := NewAPIKeySecret(k.ID)
+	require.NoError(t, err)
+	k.Hash = HashAPIKey(secret)
+	s.keys[k.ID] = k
+	return k, secret
+}
+
+func TestAPIKeySecret(t *testing.T) {
+	id := uuid.New()
+	secret, err := NewAPIKeySecret(id)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(secret, "efm_"))
+	require.Equal(t, "efm_"+strings.ReplaceAll(id.String(), "-", "")[:8], APIKeyHint(secret))
+
+	got, ok := ParseAPIKeyID(secret)
+	require.True(t, ok)
+	require.Equal(t, id, got)
+
+	other, err := NewAPIKeySecret(id)
+	require.NoError(t, err)
+	require.NotEqual(t, secret, other)
+
+	for _, bad := range []string{"", "efm_", "efm_zz_secret", "key_" + secret[4:], "efm_" + strings.ReplaceAll(id.String(), "-", "")} {
+		_, ok := ParseAPIKeyID(bad)
+		require.False(t, ok, bad)
+	}
+}
+
+func TestAPIKeyVerifier(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	store := &memKeyStore{keys: map[uuid.UUID]*APIKey{}}
+	v := NewAPIKeyVerifier(store)
+	v.now = func() time.Time { return now }
+
+	userID := uuid.New()
+	key, secret := store.issue(t, userID.String(), "subs:read:own")
+
+	p, err := v.Verify(ctx, secret)
+	require.NoError(t, err)
+	require.Equal(t, userID, p.UserID)
+	require.Equal(t, []string{"subs:read:own"}, p.Scopes)
+	require.Empty(t, p.Roles)
+	require.Equal(t, 1, store.touched)
+
+	// повторное использование в пределах интервала не пишет в хранилище
+	_, err = v.Verify(ctx, secret)
+	require.NoError(t, err)
+	require.Equal(t, 1, store.touched)
+
+	now = now.Add(apiKeyTouchInterval)
+	_, err = v.Verify(ctx, secret)
+	require.NoError(t, err)
+	require.Equal(t, 2, store.touched)
+
+	// сервисный владелец без id пользователя
+	_, svcSecret := store.issue(t, "billing-job", "subs:read:any")
+	p, err = v.Verify(ctx, svcSecret)
+	require.NoError(t, err)
+	require.Equal(t, "billing-job", p.Subject)
+	require.Equal(t, uuid.Nil, p.UserID)
+
+	// подмена секрета при известном id
+	_, err = v.Verify(ctx, secret[:len(secret)-2]+"xx")
+	require.ErrorIs(t, err, ErrInvalidToken)
+
+	unknown, err := NewAPIKeySecret(uuid.New())
+	require.NoError(t, err)
+	_, err = v.Verify(ctx, unknown)
+	require.ErrorIs(t, err, ErrInvalidToken)
+
+	_, err = v.Verify(ctx, "not-a-key")
+	require.ErrorIs(t, err, ErrInvalidToken)
+
+	expires := now
+	store.keys[key.ID].ExpiresAt = &expires
+	_, err = v.Verify(ctx, secret)
+	require.ErrorIs(t, err, ErrTokenExpired)
+
+	store.keys[key.ID].ExpiresAt = nil
+	store.keys[key.ID].RevokedAt = &now
+	_, err = v.Verify(ctx, secret)
+	require.ErrorIs(t, err, ErrInvalidToken)
+}
+
+type failingKeyStore struct{}
+
+func (failingKeyStore) GetAPIKey(context.Context, uuid.UUID) (*APIKey, error) {
+	return nil, errors.New("db is down")
+}
+
+func (failingKeyStore) TouchAPIKey(context.Context, uuid.UUID, time.Time) error {
+	return nil
+}
+
+func TestAPIKeyVerifier_StoreError(t *testing.T) {
+	secret, err := NewAPIKeySecret(uuid.New())
+	require.NoError(t, err)
+
+	// сбой хранилища не выдается за невалидный ключ
+	_, err = NewAPIKeyVerifier(failingKeyStore{}).Verify(context.Background(), secret)
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestAuthenticator(t *testing.T) {
+	store := &memKeyStore{keys: map[uuid.UUID]*APIKey{}}
+	_, secret := store.issue(t, "partner", "subs:read:any")
+	a := NewAuthenticator(Scheme{Name: SchemeAPIKey, Verifier: NewAPIKeyVerifier(store)})
+
+	require.Equal(t, []string{"ApiKey"}, a.Schemes())
+
+	p, scheme, err := a.Authenticate(context.Background(), "apikey "+secret)
+	require.NoError(t, err)
+	require.Equal(t, SchemeAPIKey, scheme)
+	require.Equal(t, "partner", p.Subject)
+
+	for _, header := range []string{"", "ApiKey", "ApiKey ", "Bearer " + secret} {
+		_, _, err := a.Authenticate(context.Background(), header)
+		require.ErrorIs(t, err, ErrNoCredentials, header)
+	}
+}
@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// ErrNoCredentials заголовка Authorization нет или схема не поддерживается
+var ErrNoCredentials = errors.New("no credentials")
+
+// Схемы заголовка Authorization
+const (
+	SchemeBearer = "Bearer"
+	SchemeAPIKey = "ApiKey"
+)
+
+// Scheme схема заголовка Authorization и проверка ее токенов
+type Scheme struct {
+	Name     string
+	Verifier Verifier
+}
+
+// Authenticator выбирает проверку по схеме заголовка Authorization.
+// Все схемы дают один тип Principal - дальше по цепочке источник не важен
+type Authenticator struct {
+	schemes []Scheme
+}
+
+func NewAuthenticator(schemes ...Scheme) *Authenticator {
+	return &Authenticator{schemes: schemes}
+}
+
+// Schemes имена схем в порядке регистрации, для WWW-Authenticate
+func (a *Authenticator) Schemes() []string {
+	names := make([]string, len(a.schemes))
+	for i, s := range a.schemes {
+		names[i] = s.Name
+	}
+	return names
+}
+
+// Authenticate проверяет значение заголовка Authorization.
+// Возвращает схему запроса, чтобы ответить на ошибку ее challenge
+func (a *Authenticator) Authenticate(ctx context.Context, header string) (*Principal, string, error) {
+	name, token, ok := strings.Cut(header, " ")
+	if !ok {
+		return nil, "", ErrNoCredentials
+	}
+	token = strings.TrimSpace(token)
+
+	for _, s := range a.schemes {
+		if !strings.EqualFold(name, s.Name) {
+			continue
+		}
+		if token == "" {
+			return nil, s.Name, ErrNoCredentials
+		}
+		p, err := s.Verifier.Verify(ctx, token)
+		return p, s.Name, err
+	}
+	return nil, "", ErrNoCredentials
+}
@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrTokenExpired = errors.New("token expired")
+)
+
+// JWTConfig ключи и ожидаемые значения claims. Нужен хотя бы один источник ключей
+type JWTConfig struct {
+	// HS256Secret общий секрет для HS256
+	HS256Secret []byte
+	// JWKSFile путь к локальному JWKS с публичными ключами RS256/ES256
+	JWKSFile string
+	// Issuer, Audience проверяются, если заданы
+	Issuer   string
+	Audience string
+	// Leeway допустимое расхождение часов
+	Leeway time.Duration
+}
+
+// claims поля токена, которые использует сервис
+type claims struct {
+	jwt.RegisteredClaims
+	Roles []string `json:"roles,omitempty"`
+	// Scope OAuth2 скоупы через пробел
+	Scope string `json:"scope,omitempty"`
+	// TenantID тенант пользователя
+	TenantID string `json:"tenant_id,omitempty"`
+}
+
+// JWTVerifier проверяет bearer токены
+type JWTVerifier struct {
+	parser   *jwt.Parser
+	hsSecret []byte
+	keys     map[string]crypto.PublicKey
+}
+
+var _ Verifier = (*JWTVerifier)(nil)
+
+func NewJWTVerifier(cfg JWTConfig) (*JWTVerifier, error) {
+	v := &JWTVerifier{hsSecret: cfg.HS256Secret}
+
+	methods := make([]string, 0, 3)
+	if len(cfg.HS256Secret) > 0 {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if cfg.JWKSFile != "" {
+		keys, err := LoadJWKS(cfg.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		v.keys = keys
+		methods = append(methods, jwt.SigningMethodRS256.Alg(), jwt.SigningMethodES256.Alg())
+	}
+	if len(methods) == 0 {
+		return nil, errors.New("jwt: no HS256 secret or JWKS file configured")
+	}
+
+	opts := []jwt.ParserOption{
+		// алгоритм из заголовка ограничен настроенными ключами - иначе возможна подмена alg
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(cfg.Leeway),
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+	v.parser = jwt.NewParser(opts...)
+
+	return v, nil
+}
+
+// Verify проверяет подпись и claims. Ошибки оборачивают ErrInvalidToken или ErrTokenExpired
+func (v *JWTVerifier) Verify(_ context.Context, token string) (*Principal, error) {
+	var c claims
+	if _, err := v.parser.ParseWithClaims(token, &c, v.key); err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, ErrTokenExpired
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	if c.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidToken)
+	}
+
+	p := &Principal{
+		Subject:  c.Subject,
+		TenantID: c.TenantID,
+		Roles:    c.Roles,
+		Scopes:   strings.Fields(c.Scope),
+	}
+	// субъект не пользователь (сервис, сотрудник) - права на свои данные ему не помогут
+	if id, err := uuid.Parse(c.Subject); err == nil {
+		p.UserID = id
+	}
+
+	return p, nil
+}
+
+// key ключ проверки подписи по alg и kid заголовка
+func (v *JWTVerifier) key(t *jwt.Token) (interface{}, error) {
+	if t.Method == jwt.SigningMethodHS256 {
+		return v.hsSecret, nil
+	}
+
+	kid, _ := t.Header["kid"].(string)
+	if kid == "" && len(v.keys) == 1 {
+		// единственный ключ можно не указывать
+		for _, k := range v.keys {
+			return k, nil
+		}
+	}
+	k, ok := v.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return k, nil
+}
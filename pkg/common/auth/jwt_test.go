@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func b64(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+// writeJWKS пишет публичные ключи во временный JWKS файл
+func writeJWKS(t *testing.T, rsaKey *rsa.PrivateKey, ecKey *ecdsa.PrivateKey) string {
+	t.Helper()
+
+	x, y := make([]byte, 32), make([]byte, 32)
+	ecKey.X.FillBytes(x)
+	ecKey.Y.FillBytes(y)
+
+	data, err := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{
+			{"kty": "RSA", "kid": "rsa-1", "use": "sig", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+			{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": b64(x), "y": b64(y)},
+			{"kty": "RSA", "kid": "enc-1", "use": "enc", "n": "AQAB", "e": "AQAB"},
+		},
+	})
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, c jwt.MapClaims) string {
+	t.Helper()
+	tok := jwt.NewWithClaims(method, c)
+	if kid != "" {
+		tok.Header["kid"] = kid
+	}
+	s, err := tok.SignedString(key)
+	require.NoError(t, err)
+	return s
+}
+
+func userClaims(sub string, exp time.Duration) jwt.MapClaims {
+	return jwt.MapClaims{"sub": sub, "exp": time.Now().Add(exp).Unix()}
+}
+
+func TestJWTVerifier_HS256(t *testing.T) {
+	v, err := NewJWTVerifier(JWTConfig{HS256Secret: testSecret, Issuer: "idp", Audience: "subs"})
+	require.NoError(t, err)
+
+	userID := uuid.New()
+	c := userClaims(userID.String(), time.Minute)
+	c["iss"], c["aud"] = "idp", "subs"
+	c["roles"] = []string{"user"}
+	c["scope"] = "subs:read subs:write"
+	c["tenant_id"] = "acme"
+
+	p, err := v.Verify(context.Background(), sign(t, jwt.SigningMethodHS256, testSecret, "", c))
+	require.NoError(t, err)
+	require.Equal(t, userID, p.UserID)
+	require.Equal(t, "acme", p.TenantID)
+	require.Equal(t, []string{"subs:read", "subs:write"}, p.Scopes)
+	require.True(t, p.HasRole("user"))
+	require.True(t, p.HasScope("subs:write"))
+
+	tests := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{"expired", sign(t, jwt.SigningMethodHS256, testSecret, "", jwt.MapClaims{"sub": userID.String(), "exp": time.Now().Add(-time.Hour).Unix(), "iss": "idp", "aud": "subs"}), ErrTokenExpired},
+		{"no exp", sign(t, jwt.SigningMethodHS256, testSecret, "", jwt.MapClaims{"sub": userID.String(), "iss": "idp", "aud": "subs"}), ErrInvalidToken},
+		{"wrong secret", sign(t, jwt.SigningMethodHS256, []byte("another-secret-another-secret-00"), "", c), ErrInvalidToken},
+		{"wrong issuer", sign(t, jwt.SigningMethodHS256, testSecret, "", jwt.MapClaims{"sub": userID.String(), "exp": time.Now().Add(time.Minute).Unix(), "iss": "evil", "aud": "subs"}), ErrInvalidToken},
+		{"alg none", sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "", c), ErrInvalidToken},
+		{"garbage", "not.a.token", ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := v.Verify(context.Background(), tt.token)
+			require.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestJWTVerifier_Subject(t *testing.T) {
+	v, err := NewJWTVerifier(JWTConfig{HS256Secret: testSecret})
+	require.NoError(t, err)
+
+	// субъект не uuid - principal без пользователя
+	p, err := v.Verify(context.Background(), sign(t, jwt.SigningMethodHS256, testSecret, "", userClaims("support@example.com", time.Minute)))
+	require.NoError(t, err)
+	require.Equal(t, "support@example.com", p.Subject)
+	require.Equal(t, uuid.Nil, p.UserID)
+
+	_, err = v.Verify(context.Background(), sign(t, jwt.SigningMethodHS256, testSecret, "", jwt.MapClaims{"exp": time.Now().Add(time.Minute).Unix()}))
+	require.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestJWTVerifier_JWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	v, err := NewJWTVerifier(JWTConfig{JWKSFile: writeJWKS(t, rsaKey, ecKey)})
+	require.NoError(t, err)
+
+	userID := uuid.New()
+	c := userClaims(userID.String(), time.Minute)
+
+	p, err := v.Verify(context.Background(), sign(t, jwt.SigningMethodRS256, rsaKey, "rsa-1", c))
+	require.NoError(t, err)
+	require.Equal(t, userID, p.UserID)
+
+	p, err = v.Verify(context.Background(), sign(t, jwt.SigningMethodES256, ecKey, "ec-1", c))
+	require.NoError(t, err)
+	require.Equal(t, userID, p.UserID)
+
+	// ключ одного типа под kid другого
+	_, err = v.Verify(context.Background(), sign(t, jwt.SigningMethodES256, ecKey, "rsa-1", c))
+	require.ErrorIs(t, err, ErrInvalidToken)
+
+	// ключ шифрования не используется для подписи
+	_, err = v.Verify(context.Background(), sign(t, jwt.SigningMethodRS256, rsaKey, "enc-1", c))
+	require.ErrorIs(t, err, ErrInvalidToken)
+
+	// HS256 не настроен - публичный ключ нельзя использовать как секрет
+	_, err = v.Verify(context.Background(), sign(t, jwt.SigningMethodHS256, rsaKey.N.Bytes(), "rsa-1", c))
+	require.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestNewJWTVerifier_Config(t *testing.T) {
+	_, err := NewJWTVerifier(JWTConfig{})
+	require.Error(t, err)
+
+	_, err = NewJWTVerifier(JWTConfig{JWKSFile: filepath.Join(t.TempDir(), "missing.json")})
+	require.Error(t, err)
+
+	_, err = ParseJWKS([]byte(`{"keys":[{"kty":"EC","kid":"a","crv":"P-256","x":"AQ","y":"AQ"}]}`))
+	require.Error(t, err)
+
+	_, err = ParseJWKS([]byte(`{"keys":[]}`))
+	require.Error(t, err)
+}
@@ -0,0 +1,48 @@
+// Package auth аутентифицированный пользователь запроса и проверка его токенов
+package auth
+
+import (
+	"context"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+// Principal аутентифицированный вызывающий
+type Principal struct {
+	// Subject идентификатор из токена (sub)
+	Subject string
+	// UserID Subject как id пользователя, uuid.Nil - субъект не пользователь
+	UserID uuid.UUID
+	// TenantID тенант вызывающего, пустой - тенант по умолчанию
+	TenantID string
+	// APIKeyID ключ API, которым вошел вызывающий, uuid.Nil - вход по токену
+	APIKeyID uuid.UUID
+	Roles    []string
+	Scopes   []string
+}
+
+func (p *Principal) HasRole(role string) bool {
+	return slices.Contains(p.Roles, role)
+}
+
+func (p *Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope)
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext principal запроса, false - вызов без аутентификации
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
+
+// Verifier проверяет токен и возвращает его владельца
+type Verifier interface {
+	Verify(ctx context.Context, token string) (*Principal, error)
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"github.com/end1essrage/efmob-tz/pkg/common/auth"
+	cg "github.com/end1essrage/efmob-tz/pkg/common/interfaces/grpc"
+	"github.com/end1essrage/efmob-tz/pkg/common/metrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+// CreateGRPCServer сервер с общими интерсепторами, рефлексией и стандартным health сервисом.
+// authenticator nil - вызовы без аутентификации, limits nil - без лимитов.
+// Статус сервисов выставляет вызывающий через возвращаемый health.Server
+func CreateGRPCServer(authenticator *auth.Authenticator, limits *cg.RateLimits, opts ...grpc.ServerOption) (*grpc.Server, *health.Server) {
+	// порядок важен: id запроса нужен логам, паника должна попасть в метрики и логи
+	unary := []grpc.UnaryServerInterceptor{
+		cg.RequestIDUnaryInterceptor,
+		metrics.GRPCMetricsUnaryInterceptor,
+		cg.LoggingUnaryInterceptor,
+		cg.RecoveryUnaryInterceptor,
+		cg.TenantUnaryInterceptor,
+	}
+	stream := []grpc.StreamServerInterceptor{
+		cg.RequestIDStreamInterceptor,
+		metrics.GRPCMetricsStreamInterceptor,
+		cg.LoggingStreamInterceptor,
+		cg.RecoveryStreamInterceptor,
+		cg.TenantStreamInterceptor,
+	}
+	// лимит по адресу до аутентификации, лимит клиента - после нее, как в http
+	if limits != nil {
+		unary = append(unary, cg.IPRateLimitUnaryInterceptor(limits))
+		stream = append(stream, cg.IPRateLimitStreamInterceptor(limits))
+	}
+	if authenticator != nil {
+		unary = append(unary, cg.AuthUnaryInterceptor(authenticator))
+		stream = append(stream, cg.AuthStreamInterceptor(authenticator))
+	}
+	if limits != nil {
+		unary = append(unary, cg.RateLimitUnaryInterceptor(limits))
+		stream = append(stream, cg.RateLimitStreamInterceptor(limits))
+	}
+
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	)
+
+	s := grpc.NewServer(opts...)
+
+	hs := health.NewServer()
+	healthpb.RegisterHealthServer(s, hs)
+	reflection.Register(s)
+
+	return s, hs
+}
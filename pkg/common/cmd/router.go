@@ -4,9 +4,11 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/end1essrage/efmob-tz/pkg/common/health"
 	m "github.com/end1essrage/efmob-tz/pkg/common/interfaces/http/middleware"
 	"github.com/end1essrage/efmob-tz/pkg/common/logger"
 	"github.com/end1essrage/efmob-tz/pkg/common/metrics"
+	"github.com/end1essrage/efmob-tz/pkg/common/tracing"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -30,7 +32,7 @@ var MiddlewareLogger = func(next http.Handler) http.Handler {
 		// Логируем
 		duration := time.Since(start)
 
-		logger.Logger().Log("router", "middleware").WithFields(logrus.Fields{
+		logger.Logger().WithFields(logger.LogOptions{Pkg: "router", Func: "middleware", Ctx: r.Context()}).WithFields(logrus.Fields{
 			"request_id":  requestID,
 			"method":      r.Method,
 			"path":        r.URL.Path,
@@ -45,32 +47,34 @@ var MiddlewareLogger = func(next http.Handler) http.Handler {
 	})
 }
 
+// CreateRouter общий роутер: пробы, метрики, swagger и middleware запросов.
+// timeouts таймауты контекста запроса, у потоковых выгрузок свои - см. middleware.Timeout.
+// Пробы и метрики не лимитируются - лимит по IP до аутентификации ставится на группу маршрутов API,
+// см. middleware.IPRateLimit
 // TODO metrics middleware
-func CreateRouter() *chi.Mux {
+func CreateRouter(probes *health.Registry, timeouts m.TimeoutConfig) *chi.Mux {
 	r := chi.NewRouter()
 
 	// порядок важен
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.RequestID)
-
-	// 100 - в минуту 30 - берст
-	rateLimiter := m.NewRateLimiter(time.Minute, 100, 30)
-	r.Use(m.RateLimitMiddleware(rateLimiter))
+	r.Use(tracing.Middleware)
 
 	r.Use(metrics.HTTPMetricsMiddleware)
 	r.Use(MiddlewareLogger)
-	r.Use(middleware.Timeout(30 * time.Second))
+	r.Use(m.Tenant)
+	r.Use(m.Timeout(timeouts))
 
 	// swagger docs
 	r.Get("/swagger/*", httpSwagger.Handler(
 		httpSwagger.URL("/swagger/doc.json"),
 	))
 
-	// Health check
-	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write([]byte("OK"))
-	})
+	// пробы: livez - процесс жив, readyz - готов принимать трафик
+	r.Get("/livez", probes.LiveHandler())
+	r.Get("/readyz", probes.ReadyHandler())
+	// для старых проверок docker и CI
+	r.Get("/health", probes.ReadyHandler())
 
 	// Metrics
 	r.Get("/metrics", promhttp.Handler().ServeHTTP)
@@ -0,0 +1,267 @@
+// Package health проверки liveness и readiness сервиса с кэшированием результатов
+package health
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/logger"
+)
+
+// Kind к какой пробе относится проверка
+type Kind int
+
+const (
+	// Liveness провал - процесс сломан, его нужно перезапустить
+	Liveness Kind = iota
+	// Readiness провал - процесс жив, но трафик на него направлять нельзя
+	Readiness
+)
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Check проверка зависимости, ctx ограничен таймаутом реестра
+type Check func(ctx context.Context) error
+
+// ErrShuttingDown сервис останавливается и больше не принимает трафик
+var ErrShuttingDown = errors.New("shutting down")
+
+type Config struct {
+	// Timeout время на одну проверку
+	Timeout time.Duration
+	// CacheTTL сколько переиспользуется результат проверки - частые пробы не нагружают зависимости
+	CacheTTL time.Duration
+}
+
+// Result результат одной проверки
+type Result struct {
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	Duration  string    `json:"duration"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Report ответ пробы
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+type entry struct {
+	name  string
+	kind  Kind
+	check Check
+
+	// mu держится на время проверки - конкурентные пробы ждут один результат
+	mu     sync.Mutex
+	result Result
+}
+
+// Registry реестр проверок
+type Registry struct {
+	cfg Config
+	now func() time.Time
+
+	mu      sync.RWMutex
+	entries []*entry
+
+	shuttingDown atomic.Bool
+}
+
+func NewRegistry(cfg Config) *Registry {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 2 * time.Second
+	}
+	return &Registry{cfg: cfg, now: time.Now}
+}
+
+// Register добавляет проверку, имя должно быть уникальным
+func (r *Registry) Register(name string, kind Kind, check Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, e := range r.entries {
+		if e.name == name {
+			panic(fmt.Sprintf("health: check %q already registered", name))
+		}
+	}
+	r.entries = append(r.entries, &entry{name: name, kind: kind, check: check})
+}
+
+// Shutdown переводит readiness в fail, liveness не меняется.
+// Вызывается в начале graceful shutdown, чтобы балансировщик успел убрать инстанс
+func (r *Registry) Shutdown() {
+	r.shuttingDown.Store(true)
+}
+
+// Live проверки liveness
+func (r *Registry) Live(ctx context.Context) Report {
+	return r.run(ctx, Liveness)
+}
+
+// Ready проверки readiness
+func (r *Registry) Ready(ctx context.Context) Report {
+	report := r.run(ctx, Readiness)
+	if r.shuttingDown.Load() {
+		report.Status = StatusFail
+		report.Checks["shutdown"] = Result{
+			Status:    StatusFail,
+			Error:     ErrShuttingDown.Error(),
+			Duration:  "0s",
+			CheckedAt: r.now(),
+		}
+	}
+	return report
+}
+
+func (r *Registry) run(ctx context.Context, kind Kind) Report {
+	r.mu.RLock()
+	var entries []*entry
+	for _, e := range r.entries {
+		if e.kind == kind {
+			entries = append(entries, e)
+		}
+	}
+	r.mu.RUnlock()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(entries))}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, e := range entries {
+		wg.Add(1)
+		go func(e *entry) {
+			defer wg.Done()
+			res := r.result(ctx, e)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[e.name] = res
+			if res.Status != StatusOK {
+				report.Status = StatusFail
+			}
+		}(e)
+	}
+	wg.Wait()
+
+	return report
+}
+
+// result результат из кэша или новая проверка
+func (r *Registry) result(ctx context.Context, e *entry) Result {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.result.CheckedAt.IsZero() && r.now().Sub(e.result.CheckedAt) < r.cfg.CacheTTL {
+		return e.result
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.Timeout)
+	defer cancel()
+
+	start := r.now()
+	err := e.check(ctx)
+	res := Result{
+		Status:    StatusOK,
+		Duration:  r.now().Sub(start).String(),
+		CheckedAt: start,
+	}
+	if err != nil {
+		res.Status = StatusFail
+		res.Error = err.Error()
+	}
+
+	// проверка упала из-за отмены запроса пробы - не кэшируем, ошибка не про зависимость
+	if ctx.Err() != nil && errors.Is(context.Cause(ctx), context.Canceled) {
+		return res
+	}
+
+	if res.Status != e.result.Status && !e.result.CheckedAt.IsZero() {
+		logger.Logger().WithFields(logger.LogOptions{
+			Pkg:  "health",
+			Func: "result",
+			Ctx:  ctx,
+		}).Warnf("проверка %s: %s -> %s %s", e.name, e.result.Status, res.Status, res.Error)
+	}
+
+	e.result = res
+	return res
+}
+
+// LiveHandler отдает отчет liveness: 200 или 503
+func (r *Registry) LiveHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		writeReport(w, r.Live(req.Context()))
+	}
+}
+
+// ReadyHandler отдает отчет readiness: 200 или 503
+func (r *Registry) ReadyHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		writeReport(w, r.Ready(req.Context()))
+	}
+}
+
+func writeReport(w http.ResponseWriter, report Report) {
+	status := http.StatusOK
+	if report.Status != StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+// Ping проверка доступности базы
+func Ping(db *sql.DB) Check {
+	return func(ctx context.Context) error {
+		return db.PingContext(ctx)
+	}
+}
+
+// Heartbeat отметка жизни фонового воркера
+type Heartbeat struct {
+	last atomic.Int64
+}
+
+// Beat отмечает, что воркер жив
+func (h *Heartbeat) Beat() {
+	h.last.Store(time.Now().UnixNano())
+}
+
+// Last время последней отметки, нулевое - отметок не было
+func (h *Heartbeat) Last() time.Time {
+	ns := h.last.Load()
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+// Check проверка, что воркер отмечался не позже чем staleAfter назад
+func (h *Heartbeat) Check(staleAfter time.Duration) Check {
+	return func(context.Context) error {
+		last := h.Last()
+		if last.IsZero() {
+			return errors.New("worker has not started")
+		}
+		if age := time.Since(last); age > staleAfter {
+			return fmt.Errorf("last heartbeat %s ago, threshold %s", age.Truncate(time.Second), staleAfter)
+		}
+		return nil
+	}
+}
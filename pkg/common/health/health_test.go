@@ -0,0 +1,143 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry(Config{Timeout: time.Second})
+
+	var down atomic.Bool
+	r.Register("postgres", Readiness, func(context.Context) error {
+		if down.Load() {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+	r.Register("worker", Liveness, func(context.Context) error { return nil })
+
+	ready := r.Ready(context.Background())
+	require.Equal(t, StatusOK, ready.Status)
+	require.Len(t, ready.Checks, 1)
+	require.Equal(t, StatusOK, ready.Checks["postgres"].Status)
+
+	// проверки readiness не влияют на liveness
+	down.Store(true)
+	ready = r.Ready(context.Background())
+	require.Equal(t, StatusFail, ready.Status)
+	require.Equal(t, "connection refused", ready.Checks["postgres"].Error)
+
+	live := r.Live(context.Background())
+	require.Equal(t, StatusOK, live.Status)
+	require.Contains(t, live.Checks, "worker")
+
+	require.Panics(t, func() { r.Register("worker", Liveness, nil) })
+}
+
+func TestRegistry_Timeout(t *testing.T) {
+	r := NewRegistry(Config{Timeout: 20 * time.Millisecond})
+	r.Register("slow", Readiness, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	start := time.Now()
+	report := r.Ready(context.Background())
+	require.Less(t, time.Since(start), time.Second)
+	require.Equal(t, StatusFail, report.Status)
+	require.Equal(t, context.DeadlineExceeded.Error(), report.Checks["slow"].Error)
+}
+
+func TestRegistry_Cache(t *testing.T) {
+	r := NewRegistry(Config{Timeout: time.Second, CacheTTL: time.Minute})
+	now := time.Unix(1_700_000_000, 0)
+	r.now = func() time.Time { return now }
+
+	var calls atomic.Int32
+	r.Register("postgres", Readiness, func(context.Context) error {
+		calls.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	})
+
+	// конкурентные пробы ждут одну проверку
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.Equal(t, StatusOK, r.Ready(context.Background()).Status)
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, int32(1), calls.Load())
+
+	now = now.Add(time.Minute)
+	r.Ready(context.Background())
+	require.Equal(t, int32(2), calls.Load())
+
+	// отмененная проба не кэширует свою ошибку
+	now = now.Add(time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r.Ready(ctx)
+	r.Ready(context.Background())
+	require.Equal(t, int32(4), calls.Load())
+}
+
+func TestRegistry_Shutdown(t *testing.T) {
+	r := NewRegistry(Config{})
+	r.Register("worker", Liveness, func(context.Context) error { return nil })
+
+	require.Equal(t, StatusOK, r.Ready(context.Background()).Status)
+
+	r.Shutdown()
+	ready := r.Ready(context.Background())
+	require.Equal(t, StatusFail, ready.Status)
+	require.Equal(t, ErrShuttingDown.Error(), ready.Checks["shutdown"].Error)
+	// процесс жив, перезапускать его не нужно
+	require.Equal(t, StatusOK, r.Live(context.Background()).Status)
+}
+
+func TestHandlers(t *testing.T) {
+	r := NewRegistry(Config{})
+	r.Register("postgres", Readiness, func(context.Context) error { return errors.New("down") })
+
+	rec := httptest.NewRecorder()
+	r.ReadyHandler()(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var report Report
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	require.Equal(t, StatusFail, report.Status)
+	require.Equal(t, "down", report.Checks["postgres"].Error)
+
+	rec = httptest.NewRecorder()
+	r.LiveHandler()(rec, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"status":"ok","checks":{}}`, rec.Body.String())
+}
+
+func TestHeartbeat(t *testing.T) {
+	var h Heartbeat
+	check := h.Check(time.Minute)
+
+	require.ErrorContains(t, check(context.Background()), "not started")
+
+	h.Beat()
+	require.NoError(t, check(context.Background()))
+
+	h.last.Store(time.Now().Add(-2 * time.Minute).UnixNano())
+	require.ErrorContains(t, check(context.Background()), "threshold 1m0s")
+}
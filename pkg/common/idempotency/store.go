@@ -0,0 +1,30 @@
+// Package idempotency ключи идемпотентности: сохраненные ответы запросов и контракт их хранилища
+package idempotency
+
+import (
+	"context"
+	"time"
+)
+
+// Record сохранённый результат запроса по ключу
+type Record struct {
+	Key         string
+	RequestHash string
+	Completed   bool
+	StatusCode  int
+	ContentType string
+	Body        []byte
+}
+
+// Store хранилище ключей идемпотентности
+type Store interface {
+	// Acquire резервирует ключ за текущим запросом на lease, ответ хранится ttl.
+	// Если ключ уже занят и не истёк - возвращает существующую запись.
+	// Незавершенный ключ с истекшим lease (запрос упал, не освободив его) переходит
+	// к повтору с тем же requestHash
+	Acquire(ctx context.Context, key, requestHash string, ttl, lease time.Duration) (*Record, error)
+	// Complete сохраняет ответ по ключу
+	Complete(ctx context.Context, key string, status int, contentType string, body []byte) error
+	// Release освобождает ключ, если ответ не должен кэшироваться
+	Release(ctx context.Context, key string) error
+}
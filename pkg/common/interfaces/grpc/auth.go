@@ -0,0 +1,82 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/auth"
+	"github.com/end1essrage/efmob-tz/pkg/common/logger"
+	"github.com/end1essrage/efmob-tz/pkg/common/tenant"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// publicServices сервисы без аутентификации: пробы и рефлексия
+var publicServices = []string{
+	"/grpc.health.v1.Health/",
+	"/grpc.reflection.",
+}
+
+// AuthUnaryInterceptor проверяет метаданные authorization по схемам authenticator,
+// как middleware.Authenticate в http
+func AuthUnaryInterceptor(a *auth.Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := authenticate(ctx, a, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func AuthStreamInterceptor(a *auth.Authenticator) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(ss.Context(), a, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func authenticate(ctx context.Context, a *auth.Authenticator, method string) (context.Context, error) {
+	for _, prefix := range publicServices {
+		if strings.HasPrefix(method, prefix) {
+			return ctx, nil
+		}
+	}
+
+	var header string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if h := md.Get("authorization"); len(h) > 0 {
+			header = h[0]
+		}
+	}
+
+	p, scheme, err := a.Authenticate(ctx, header)
+	if errors.Is(err, auth.ErrNoCredentials) {
+		return ctx, status.Error(codes.Unauthenticated, "authorization required")
+	}
+	if err != nil {
+		logger.Logger().WithFields(logger.LogOptions{
+			Pkg:  "grpc",
+			Func: "authenticate",
+			Ctx:  ctx,
+		}).Warnf("токен %s отклонен: %v", scheme, err)
+
+		if errors.Is(err, auth.ErrTokenExpired) {
+			return ctx, status.Error(codes.Unauthenticated, "token expired")
+		}
+		return ctx, status.Error(codes.Unauthenticated, "invalid token")
+	}
+
+	ctx, err = tenant.Bind(ctx, p.TenantID)
+	if err != nil {
+		return ctx, status.Error(codes.PermissionDenied, "tenant does not match credentials")
+	}
+
+	return auth.WithPrincipal(ctx, p), nil
+}
@@ -0,0 +1,117 @@
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/logger"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// RequestIDHeader ключ метаданных с id запроса, как X-Request-Id в http
+const RequestIDHeader = "x-request-id"
+
+// withRequestID берет id из метаданных или генерирует новый, кладет его в контекст
+// под ключом chi, чтобы логгер подхватывал его так же, как в http
+func withRequestID(ctx context.Context) context.Context {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(RequestIDHeader); len(v) > 0 {
+			id = v[0]
+		}
+	}
+	if id == "" {
+		id = uuid.NewString()
+	}
+
+	// ошибку не проверяем - заголовки ответа уже могли быть отправлены
+	_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDHeader, id))
+
+	return context.WithValue(ctx, middleware.RequestIDKey, id)
+}
+
+func RequestIDUnaryInterceptor(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	return handler(withRequestID(ctx), req)
+}
+
+func RequestIDStreamInterceptor(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &serverStream{ServerStream: ss, ctx: withRequestID(ss.Context())})
+}
+
+func LoggingUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	logRequest(ctx, info.FullMethod, start, err)
+	return resp, err
+}
+
+func LoggingStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	logRequest(ss.Context(), info.FullMethod, start, err)
+	return err
+}
+
+func logRequest(ctx context.Context, method string, start time.Time, err error) {
+	duration := time.Since(start)
+
+	fields := logrus.Fields{
+		"method":      method,
+		"code":        status.Code(err).String(),
+		"duration":    duration.String(),
+		"duration_ms": duration.Milliseconds(),
+		"time":        start.Format(time.RFC3339),
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		fields["remote_addr"] = p.Addr.String()
+	}
+
+	logger.Logger().WithFields(logger.LogOptions{
+		Pkg:  "grpc",
+		Func: "interceptor",
+		Ctx:  ctx,
+	}).WithFields(fields).Info("gRPC request")
+}
+
+// RecoveryUnaryInterceptor паника в обработчике превращается в codes.Internal
+func RecoveryUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = recovered(ctx, info.FullMethod, p)
+		}
+	}()
+	return handler(ctx, req)
+}
+
+func RecoveryStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = recovered(ss.Context(), info.FullMethod, p)
+		}
+	}()
+	return handler(srv, ss)
+}
+
+func recovered(ctx context.Context, method string, p interface{}) error {
+	logger.Logger().WithFields(logger.LogOptions{
+		Pkg:  "grpc",
+		Func: "Recover",
+		Ctx:  ctx,
+	}).Errorf("паника в %s: %v", method, p)
+	return status.Error(codes.Internal, "internal error")
+}
+
+// serverStream поток с подмененным контекстом
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context { return s.ctx }
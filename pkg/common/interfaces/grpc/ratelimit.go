@@ -0,0 +1,119 @@
+package grpc
+
+import (
+	"context"
+	"math"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/logger"
+	"github.com/end1essrage/efmob-tz/pkg/common/ratelimit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// RetryAfterMetadataKey через сколько секунд повторять вызов, отклоненный лимитом, как Retry-After в http
+const RetryAfterMetadataKey = "retry-after"
+
+// RateLimits лимиты вызовов, ведра общие с http: тот же store и те же ключи клиентов.
+// Лимиты - функции, чтобы перезагрузка конфигурации действовала и на grpc
+type RateLimits struct {
+	Store ratelimit.Store
+	// IP лимит по адресу до аутентификации, как middleware.IPRateLimit
+	IP func() ratelimit.Limit
+	// Client лимит клиента после аутентификации - общее ведро клиента маршрутов без своего правила
+	Client func() ratelimit.Limit
+	// FailOpen пропускать вызовы, когда store недоступен. Иначе - Unavailable
+	FailOpen bool
+}
+
+// IPRateLimitUnaryInterceptor ограничивает вызовы по адресу до аутентификации -
+// под лимит попадают и вызовы с неверными ключами и токенами
+func IPRateLimitUnaryInterceptor(l *RateLimits) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := l.take(ctx, info.FullMethod, ratelimit.PreAuthPrefix, l.IP(), ratelimit.IPKey(peerAddr(ctx))); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func IPRateLimitStreamInterceptor(l *RateLimits) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := ss.Context()
+		if err := l.take(ctx, info.FullMethod, ratelimit.PreAuthPrefix, l.IP(), ratelimit.IPKey(peerAddr(ctx))); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+// RateLimitUnaryInterceptor ограничивает вызовы клиента. Ставится после аутентификации,
+// иначе вызовы пользователей считаются по адресу
+func RateLimitUnaryInterceptor(l *RateLimits) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := l.take(ctx, info.FullMethod, "", l.Client(), ratelimit.ClientKey(ctx, peerAddr(ctx))); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func RateLimitStreamInterceptor(l *RateLimits) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := ss.Context()
+		if err := l.take(ctx, info.FullMethod, "", l.Client(), ratelimit.ClientKey(ctx, peerAddr(ctx))); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+// take забирает токен из ведра клиента. Пробы и рефлексия не лимитируются
+func (l *RateLimits) take(ctx context.Context, method, prefix string, lim ratelimit.Limit, client string) error {
+	for _, p := range publicServices {
+		if strings.HasPrefix(method, p) {
+			return nil
+		}
+	}
+
+	log := logger.Logger().WithFields(logger.LogOptions{
+		Pkg:  "grpc",
+		Func: "rateLimit",
+		Ctx:  ctx,
+	})
+
+	d, err := l.Store.Take(ctx, prefix+ratelimit.Key(ratelimit.AnyRoute, client), lim, time.Now())
+	if err != nil {
+		log.Errorf("хранилище лимитов недоступно: %v", err)
+		if l.FailOpen {
+			return nil
+		}
+		return status.Error(codes.Unavailable, "rate limiter unavailable")
+	}
+	if d.Allowed {
+		return nil
+	}
+
+	log.Warnf("превышен лимит %s для %s на %s", lim, client, method)
+	// ошибку не проверяем - заголовки ответа уже могли быть отправлены
+	_ = grpc.SetHeader(ctx, metadata.Pairs(RetryAfterMetadataKey, strconv.FormatInt(int64(math.Ceil(d.RetryAfter.Seconds())), 10)))
+	return status.Error(codes.ResourceExhausted, "rate limit exceeded")
+}
+
+// peerAddr адрес клиента без порта. grpc слушает напрямую, мимо прокси - X-Forwarded-For не нужен
+func peerAddr(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	if ap, err := netip.ParseAddrPort(p.Addr.String()); err == nil {
+		return ap.Addr().Unmap().String()
+	}
+	return p.Addr.String()
+}
@@ -0,0 +1,45 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/tenant"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// TenantMetadataKey метаданные выбора тенанта, как заголовок X-Tenant-ID в http
+const TenantMetadataKey = "x-tenant-id"
+
+func TenantUnaryInterceptor(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := withTenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func TenantStreamInterceptor(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := withTenant(ss.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+}
+
+func withTenant(ctx context.Context) (context.Context, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx, nil
+	}
+	v := md.Get(TenantMetadataKey)
+	if len(v) == 0 || v[0] == "" {
+		return ctx, nil
+	}
+	if !tenant.Valid(v[0]) {
+		return ctx, status.Error(codes.InvalidArgument, "invalid tenant id")
+	}
+	return tenant.WithTenant(ctx, v[0]), nil
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/auth"
+	"github.com/end1essrage/efmob-tz/pkg/common/interfaces/http/utils"
+	l "github.com/end1essrage/efmob-tz/pkg/common/logger"
+	"github.com/end1essrage/efmob-tz/pkg/common/tenant"
+)
+
+// Authenticate проверяет Authorization по схемам authenticator и кладет principal в контекст.
+// Тенант запроса закрепляется за тенантом вызывающего, чужой тенант в заголовке - 403.
+// Без учетных данных - 401 с challenge каждой схемы, с невалидным токеном - challenge его схемы (RFC 6750)
+func Authenticate(a *auth.Authenticator, realm string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, scheme, err := a.Authenticate(r.Context(), r.Header.Get("Authorization"))
+			if errors.Is(err, auth.ErrNoCredentials) {
+				challenges := make([]string, 0, len(a.Schemes()))
+				for _, s := range a.Schemes() {
+					challenges = append(challenges, fmt.Sprintf("%s realm=%q", s, realm))
+				}
+				unauthorized(w, challenges, "authorization required")
+				return
+			}
+			if err != nil {
+				l.Logger().WithFields(l.LogOptions{
+					Pkg:  "middleware",
+					Func: "Authenticate",
+					Ctx:  r.Context(),
+				}).Warnf("токен %s отклонен: %v", scheme, err)
+
+				desc := "invalid token"
+				if errors.Is(err, auth.ErrTokenExpired) {
+					desc = "token expired"
+				}
+				unauthorized(w, []string{fmt.Sprintf("%s realm=%q, error=\"invalid_token\", error_description=%q", scheme, realm, desc)}, desc)
+				return
+			}
+
+			ctx, err := tenant.Bind(r.Context(), p.TenantID)
+			if err != nil {
+				utils.WriteJSON(w, http.StatusForbidden, map[string]string{
+					"error": "tenant does not match credentials",
+					"code":  "TENANT_MISMATCH",
+				})
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(ctx, p)))
+		})
+	}
+}
+
+func unauthorized(w http.ResponseWriter, challenges []string, msg string) {
+	for _, c := range challenges {
+		w.Header().Add("WWW-Authenticate", c)
+	}
+	utils.WriteJSON(w, http.StatusUnauthorized, map[string]string{
+		"error": msg,
+		"code":  "UNAUTHORIZED",
+	})
+}
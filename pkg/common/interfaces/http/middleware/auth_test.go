@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/auth"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeVerifier принимает токены из карты
+type fakeVerifier map[string]error
+
+func (f fakeVerifier) Verify(_ context.Context, token string) (*auth.Principal, error) {
+	err, ok := f[token]
+	if !ok {
+		return nil, auth.ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &auth.Principal{Subject: token, UserID: uuid.New()}, nil
+}
+
+func TestAuthenticate(t *testing.T) {
+	a := auth.NewAuthenticator(
+		auth.Scheme{Name: auth.SchemeBearer, Verifier: fakeVerifier{"good": nil, "old": auth.ErrTokenExpired}},
+		auth.Scheme{Name: auth.SchemeAPIKey, Verifier: fakeVerifier{"good": nil}},
+	)
+
+	var got *auth.Principal
+	h := Authenticate(a, "subs")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = auth.FromContext(r.Context())
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name      string
+		header    string
+		status    int
+		challenge []string
+	}{
+		{"valid", "Bearer good", http.StatusNoContent, nil},
+		{"scheme case", "bearer good", http.StatusNoContent, nil},
+		{"api key", "ApiKey good", http.StatusNoContent, nil},
+		{"missing", "", http.StatusUnauthorized, []string{`Bearer realm="subs"`, `ApiKey realm="subs"`}},
+		{"empty token", "Bearer ", http.StatusUnauthorized, []string{`Bearer realm="subs"`, `ApiKey realm="subs"`}},
+		{"other scheme", "Basic Z29vZA==", http.StatusUnauthorized, []string{`Bearer realm="subs"`, `ApiKey realm="subs"`}},
+		{"invalid", "Bearer bad", http.StatusUnauthorized, []string{`Bearer realm="subs", error="invalid_token", error_description="invalid token"`}},
+		{"expired", "Bearer old", http.StatusUnauthorized, []string{`Bearer realm="subs", error="invalid_token", error_description="token expired"`}},
+		{"invalid api key", "ApiKey old", http.StatusUnauthorized, []string{`ApiKey realm="subs", error="invalid_token", error_description="invalid token"`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = nil
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			require.Equal(t, tt.status, rec.Code)
+			require.Equal(t, tt.challenge, rec.Header().Values("WWW-Authenticate"))
+			if tt.status == http.StatusUnauthorized {
+				require.Nil(t, got)
+				require.Contains(t, rec.Body.String(), `"code":"UNAUTHORIZED"`)
+			} else {
+				require.Equal(t, "good", got.Subject)
+			}
+		})
+	}
+}
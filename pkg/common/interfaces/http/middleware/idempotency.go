@@ -0,0 +1,179 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/auth"
+	"github.com/end1essrage/efmob-tz/pkg/common/idempotency"
+	"github.com/end1essrage/efmob-tz/pkg/common/interfaces/http/utils"
+	l "github.com/end1essrage/efmob-tz/pkg/common/logger"
+	"github.com/end1essrage/efmob-tz/pkg/common/tenant"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotencyConfig настройки Idempotency
+type IdempotencyConfig struct {
+	// TTL время хранения ответа по ключу
+	TTL time.Duration
+	// Lease на сколько запрос занимает ключ. Ключ упавшего запроса освобождается по его истечении,
+	// поэтому Lease должен быть больше таймаута запроса. 0 - defaultIdempotencyLease
+	Lease time.Duration
+	// MaxBodyBytes предел тела запроса, которое буферизуется для хэша. 0 - defaultIdempotencyMaxBody
+	MaxBodyBytes int64
+}
+
+const (
+	defaultIdempotencyLease   = time.Minute
+	defaultIdempotencyMaxBody = 1 << 20
+)
+
+// Idempotency повторяет сохранённый ответ для запросов с одинаковым Idempotency-Key.
+// Ключ с другим телом запроса - 422, ключ, запрос по которому ещё выполняется - 409.
+// Ключ действует в пределах тенанта, для аутентифицированных запросов - и principal
+func Idempotency(store idempotency.Store, cfg IdempotencyConfig) func(http.Handler) http.Handler {
+	if cfg.Lease <= 0 {
+		cfg.Lease = defaultIdempotencyLease
+	}
+	if cfg.MaxBodyBytes <= 0 {
+		cfg.MaxBodyBytes = defaultIdempotencyMaxBody
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			log := l.Logger().WithFields(l.LogOptions{
+				Pkg:  "middleware",
+				Func: "Idempotency",
+				Ctx:  r.Context(),
+			}).WithField("idempotency_key", key)
+
+			// ключи разных пользователей не пересекаются - чужой ответ не повторить
+			if p, ok := auth.FromContext(r.Context()); ok {
+				key = p.Subject + ":" + key
+			}
+			key = tenant.Current(r.Context()) + ":" + key
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, cfg.MaxBodyBytes))
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				utils.WriteJSON(w, http.StatusRequestEntityTooLarge, map[string]string{
+					"error": "request body too large",
+					"code":  "REQUEST_TOO_LARGE",
+				})
+				return
+			}
+			if err != nil {
+				utils.WriteJSON(w, http.StatusBadRequest, map[string]string{
+					"error": "invalid request format",
+					"code":  "VALIDATION_ERROR",
+				})
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			hash := requestHash(r, body)
+
+			existing, err := store.Acquire(r.Context(), key, hash, cfg.TTL, cfg.Lease)
+			if err != nil {
+				log.Errorf("ошибка резервирования ключа: %v", err)
+				utils.WriteJSON(w, http.StatusInternalServerError, map[string]string{
+					"error": "INTERNAL_ERROR",
+					"code":  "INTERNAL_ERROR",
+				})
+				return
+			}
+
+			if existing != nil {
+				switch {
+				case existing.RequestHash != hash:
+					log.Warn("ключ переиспользован с другим запросом")
+					utils.WriteJSON(w, http.StatusUnprocessableEntity, map[string]string{
+						"error": "idempotency key reused with different request",
+						"code":  "IDEMPOTENCY_KEY_REUSED",
+					})
+				case !existing.Completed:
+					log.Warn("запрос по ключу ещё выполняется")
+					utils.WriteJSON(w, http.StatusConflict, map[string]string{
+						"error": "request with this idempotency key is in progress",
+						"code":  "IDEMPOTENCY_REQUEST_IN_PROGRESS",
+					})
+				default:
+					log.Info("повтор сохранённого ответа")
+					if existing.ContentType != "" {
+						w.Header().Set("Content-Type", existing.ContentType)
+					}
+					w.Header().Set("Idempotent-Replayed", "true")
+					w.WriteHeader(existing.StatusCode)
+					_, _ = w.Write(existing.Body)
+				}
+				return
+			}
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			var buf bytes.Buffer
+			ww.Tee(&buf)
+
+			// контекст запроса может быть отменён, а ключ нужно освободить в любом случае
+			storeCtx := context.WithoutCancel(r.Context())
+
+			completed := false
+			defer func() {
+				if completed {
+					return
+				}
+				if err := store.Release(storeCtx, key); err != nil {
+					log.Errorf("ошибка освобождения ключа: %v", err)
+				}
+			}()
+
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			// серверные ошибки не кэшируем - клиент должен иметь возможность повторить
+			if status >= http.StatusInternalServerError {
+				return
+			}
+
+			if err := store.Complete(storeCtx, key, status, ww.Header().Get("Content-Type"), buf.Bytes()); err != nil {
+				log.Errorf("ошибка сохранения ответа: %v", err)
+				return
+			}
+			completed = true
+		})
+	}
+}
+
+// requestHash отпечаток запроса: метод, путь, query и тело.
+// Query канонизируется - порядок параметров не делает запрос другим.
+// Без query отпечаток прежний - сохраненные ключи остаются действительны
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	_, _ = h.Write([]byte(r.Method))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(r.URL.Path))
+	_, _ = h.Write([]byte{0})
+	if query := r.URL.Query().Encode(); query != "" {
+		_, _ = h.Write([]byte("?" + query))
+		_, _ = h.Write([]byte{0})
+	}
+	_, _ = h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
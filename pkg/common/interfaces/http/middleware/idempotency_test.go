@@ -0,0 +1,246 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/auth"
+	"github.com/end1essrage/efmob-tz/pkg/common/idempotency"
+	"github.com/end1essrage/efmob-tz/pkg/common/tenant"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]*idempotency.Record
+}
+
+func newMemoryIdempotencyStore() *memoryIdempotencyStore {
+	return &memoryIdempotencyStore{records: map[string]*idempotency.Record{}}
+}
+
+func (s *memoryIdempotencyStore) Acquire(_ context.Context, key, hash string, _, _ time.Duration) (*idempotency.Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rec, ok := s.records[key]; ok {
+		copied := *rec
+		return &copied, nil
+	}
+	s.records[key] = &idempotency.Record{Key: key, RequestHash: hash}
+	return nil, nil
+}
+
+func (s *memoryIdempotencyStore) Complete(_ context.Context, key string, status int, contentType string, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec := s.records[key]
+	rec.Completed = true
+	rec.StatusCode = status
+	rec.ContentType = contentType
+	rec.Body = append([]byte(nil), body...)
+	return nil
+}
+
+func (s *memoryIdempotencyStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rec, ok := s.records[key]; ok && !rec.Completed {
+		delete(s.records, key)
+	}
+	return nil
+}
+
+func doPost(h http.Handler, key, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(body))
+	if key != "" {
+		r.Header.Set(IdempotencyKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestIdempotency_ReplaysStoredResponse(t *testing.T) {
+	var calls int32
+	h := Idempotency(newMemoryIdempotencyStore(), IdempotencyConfig{TTL: time.Hour})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"n":` + strconv.Itoa(int(n)) + `}`))
+	}))
+
+	first := doPost(h, "key-1", `{"price":100}`)
+	second := doPost(h, "key-1", `{"price":100}`)
+
+	require.Equal(t, int32(1), calls)
+	require.Equal(t, http.StatusCreated, second.Code)
+	require.Equal(t, first.Body.String(), second.Body.String())
+	require.Equal(t, "true", second.Header().Get("Idempotent-Replayed"))
+	require.Equal(t, "application/json", second.Header().Get("Content-Type"))
+}
+
+func TestIdempotency_DifferentBodyIsRejected(t *testing.T) {
+	h := Idempotency(newMemoryIdempotencyStore(), IdempotencyConfig{TTL: time.Hour})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	doPost(h, "key-1", `{"price":100}`)
+	w := doPost(h, "key-1", `{"price":200}`)
+
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	require.Contains(t, w.Body.String(), "IDEMPOTENCY_KEY_REUSED")
+}
+
+func TestIdempotency_DifferentQueryIsRejected(t *testing.T) {
+	var calls int32
+	h := Idempotency(newMemoryIdempotencyStore(), IdempotencyConfig{TTL: time.Hour})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	post := func(target string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, target, strings.NewReader(`{}`))
+		r.Header.Set(IdempotencyKeyHeader, "key-1")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	require.Equal(t, http.StatusOK, post("/admin/outbox/requeue?all=true&limit=10").Code)
+
+	// те же параметры в другом порядке - тот же запрос, ответ повторяется
+	w := post("/admin/outbox/requeue?limit=10&all=true")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "true", w.Header().Get("Idempotent-Replayed"))
+
+	// другие параметры с тем же ключом - другой запрос
+	w = post("/admin/outbox/requeue?all=false&limit=10")
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	require.Contains(t, w.Body.String(), "IDEMPOTENCY_KEY_REUSED")
+	require.Equal(t, int32(1), calls)
+}
+
+func TestIdempotency_InFlightRequestConflicts(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	h := Idempotency(newMemoryIdempotencyStore(), IdempotencyConfig{TTL: time.Hour})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- doPost(h, "key-1", `{}`) }()
+
+	<-started
+	w := doPost(h, "key-1", `{}`)
+	close(release)
+
+	require.Equal(t, http.StatusConflict, w.Code)
+	require.Contains(t, w.Body.String(), "IDEMPOTENCY_REQUEST_IN_PROGRESS")
+	require.Equal(t, http.StatusCreated, (<-done).Code)
+}
+
+func TestIdempotency_ServerErrorIsNotStored(t *testing.T) {
+	var calls int32
+	h := Idempotency(newMemoryIdempotencyStore(), IdempotencyConfig{TTL: time.Hour})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	require.Equal(t, http.StatusInternalServerError, doPost(h, "key-1", `{}`).Code)
+	require.Equal(t, http.StatusCreated, doPost(h, "key-1", `{}`).Code)
+	require.Equal(t, int32(2), calls)
+}
+
+func TestIdempotency_WithoutKeyPassesThrough(t *testing.T) {
+	var calls int32
+	h := Idempotency(newMemoryIdempotencyStore(), IdempotencyConfig{TTL: time.Hour})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	doPost(h, "", `{}`)
+	doPost(h, "", `{}`)
+
+	require.Equal(t, int32(2), calls)
+}
+
+func TestIdempotency_KeysArePerPrincipal(t *testing.T) {
+	var calls int32
+	h := Idempotency(newMemoryIdempotencyStore(), IdempotencyConfig{TTL: time.Hour})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	post := func(subject string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(`{"price":100}`))
+		r.Header.Set(IdempotencyKeyHeader, "key-1")
+		r = r.WithContext(auth.WithPrincipal(r.Context(), &auth.Principal{Subject: subject}))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	post("alice")
+	// тот же ключ другого пользователя - отдельный запрос, а не чужой ответ
+	w := post("bob")
+
+	require.Equal(t, int32(2), calls)
+	require.Empty(t, w.Header().Get("Idempotent-Replayed"))
+}
+
+func TestIdempotency_KeysArePerTenant(t *testing.T) {
+	var calls int32
+	h := Idempotency(newMemoryIdempotencyStore(), IdempotencyConfig{TTL: time.Hour})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	post := func(tenantID string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(`{"price":100}`))
+		r.Header.Set(IdempotencyKeyHeader, "key-1")
+		r = r.WithContext(tenant.WithTenant(r.Context(), tenantID))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	post("acme")
+	w := post("globex")
+
+	require.Equal(t, int32(2), calls)
+	require.Empty(t, w.Header().Get("Idempotent-Replayed"))
+}
+
+func TestIdempotency_BodyTooLarge(t *testing.T) {
+	var calls int32
+	store := newMemoryIdempotencyStore()
+	h := Idempotency(store, IdempotencyConfig{TTL: time.Hour, MaxBodyBytes: 8})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	w := doPost(h, "key-1", `{"price":100}`)
+
+	require.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	require.Contains(t, w.Body.String(), "REQUEST_TOO_LARGE")
+	require.Equal(t, int32(0), calls)
+
+	// ключ не занят - запрос в пределах лимита проходит
+	require.Equal(t, http.StatusCreated, doPost(h, "key-1", `{}`).Code)
+}
@@ -1,100 +1,302 @@
 package middleware
 
 import (
+	"context"
+	"fmt"
+	"math"
 	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
 	"sync/atomic"
 	"time"
 
 	"github.com/end1essrage/efmob-tz/pkg/common/interfaces/http/utils"
 	l "github.com/end1essrage/efmob-tz/pkg/common/logger"
+	"github.com/end1essrage/efmob-tz/pkg/common/ratelimit"
+	"github.com/go-chi/chi/v5"
 )
 
-type RateLimiter struct {
-	requests chan time.Time
-	rate     time.Duration
-	burst    int
-	stop     chan struct{}
-	running  atomic.Bool
+// RateLimitRule лимит маршрута. Pattern - шаблон chi, можно с методом: "POST /subscriptions/import"
+type RateLimitRule struct {
+	Pattern string
+	Limit   ratelimit.Limit
 }
 
-func NewRateLimiter(rate time.Duration, limit int, burst int) *RateLimiter {
-	if burst <= 0 {
-		burst = limit
+// ParseRateLimitRules разбирает правила вида "POST /subscriptions/import=10/1m:5; /admin/*=30/1m"
+func ParseRateLimitRules(s string) ([]RateLimitRule, error) {
+	var rules []RateLimitRule
+	for _, part := range strings.Split(s, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		i := strings.LastIndex(part, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("rate limit rule %q: want <pattern>=<limit>", part)
+		}
+		lim, err := ratelimit.ParseLimit(part[i+1:])
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, RateLimitRule{Pattern: strings.TrimSpace(part[:i]), Limit: lim})
 	}
-	if burst > limit*2 {
-		burst = limit * 2
+	return rules, nil
+}
+
+// RateLimiterConfig настройки RateLimiter
+type RateLimiterConfig struct {
+	// Default лимит маршрутов без своего правила
+	Default ratelimit.Limit
+	Routes  []RateLimitRule
+	// TrustedProxies прокси, которым доверяем X-Forwarded-For
+	TrustedProxies []netip.Prefix
+	// Store состояние ведер, nil - в памяти процесса
+	Store ratelimit.Store
+	// FailOpen пропускать запросы, когда store недоступен. Иначе - 503
+	FailOpen bool
+}
+
+// RateLimiter ведра токенов по клиенту и маршруту.
+// Клиент - ключ API, пользователь или IP для запросов без аутентификации
+type RateLimiter struct {
+	// rules заменяются целиком при перезагрузке конфигурации
+	rules    atomic.Pointer[rateLimitRules]
+	proxies  []netip.Prefix
+	store    ratelimit.Store
+	failOpen bool
+	now      func() time.Time
+}
+
+// rateLimitRules лимиты маршрутов, после сборки не меняются
+type rateLimitRules struct {
+	defaultLimit ratelimit.Limit
+	// routes маршруты с правилами, шаблон ищется как в роутере
+	routes *chi.Mux
+	limits map[string]ratelimit.Limit
+}
+
+func NewRateLimiter(cfg RateLimiterConfig) (*RateLimiter, error) {
+	if cfg.Store == nil {
+		cfg.Store = ratelimit.NewMemoryStore(time.Minute)
 	}
 
 	rl := &RateLimiter{
-		requests: make(chan time.Time, burst),
-		rate:     rate / time.Duration(limit), // интервал между токенами
-		burst:    burst,
-		stop:     make(chan struct{}),
+		proxies:  cfg.TrustedProxies,
+		store:    cfg.Store,
+		failOpen: cfg.FailOpen,
+		now:      time.Now,
 	}
-
-	// Заполняем начальными токенами
-	for i := 0; i < burst; i++ {
-		rl.requests <- time.Now()
+	if err := rl.SetLimits(cfg.Default, cfg.Routes); err != nil {
+		return nil, err
 	}
 
-	// Запускаем пополнение токенов
-	rl.running.Store(true)
-	go rl.refill()
+	return rl, nil
+}
 
-	return rl
+// SetLimits заменяет лимиты на лету. Состояние ведер сохраняется,
+// поэтому новый лимит действует с учетом уже сделанных запросов.
+// При ошибке остаются прежние лимиты
+func (rl *RateLimiter) SetLimits(def ratelimit.Limit, routes []RateLimitRule) error {
+	rules, err := buildRateLimitRules(def, routes)
+	if err != nil {
+		return err
+	}
+	rl.rules.Store(rules)
+	return nil
 }
 
-func (rl *RateLimiter) refill() {
-	ticker := time.NewTicker(rl.rate)
-	defer ticker.Stop()
+func buildRateLimitRules(def ratelimit.Limit, routes []RateLimitRule) (*rateLimitRules, error) {
+	if err := def.Validate(); err != nil {
+		return nil, err
+	}
+
+	rules := &rateLimitRules{
+		defaultLimit: def,
+		routes:       chi.NewRouter(),
+		limits:       make(map[string]ratelimit.Limit, len(routes)),
+	}
+
+	noop := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	for _, rule := range routes {
+		if err := rule.Limit.Validate(); err != nil {
+			return nil, fmt.Errorf("route %q: %w", rule.Pattern, err)
+		}
+
+		method, pattern, ok := strings.Cut(rule.Pattern, " ")
+		if !ok {
+			method, pattern = "", rule.Pattern
+		}
+		pattern = strings.TrimSpace(pattern)
+		if !strings.HasPrefix(pattern, "/") {
+			return nil, fmt.Errorf("route %q: pattern must start with '/'", rule.Pattern)
+		}
 
-	for {
-		select {
-		case <-rl.stop:
-			rl.running.Store(false)
-			return
-		case <-ticker.C:
-			select {
-			case rl.requests <- time.Now():
-				// Токен добавлен
-			default:
-				// Bucket полный
+		// chi паникует на некорректных шаблонах и методах - отдаем ошибкой
+		err := func() (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("route %q: %v", rule.Pattern, r)
+				}
+			}()
+			if method == "" {
+				rules.routes.Handle(pattern, noop)
+			} else {
+				method = strings.ToUpper(method)
+				rules.routes.Method(method, pattern, noop)
 			}
+			return nil
+		}()
+		if err != nil {
+			return nil, err
 		}
+		rules.limits[strings.TrimSpace(method+" "+pattern)] = rule.Limit
 	}
+
+	return rules, nil
 }
 
-func (rl *RateLimiter) Stop() {
-	if rl.running.Load() {
-		close(rl.stop)
+// route шаблон правила запроса и его лимит, без правила - общее ведро
+func (rl *RateLimiter) route(r *http.Request) (string, ratelimit.Limit) {
+	rules := rl.rules.Load()
+	pattern := rules.routes.Find(chi.NewRouteContext(), r.Method, r.URL.Path)
+	if pattern != "" {
+		if lim, ok := rules.limits[r.Method+" "+pattern]; ok {
+			return r.Method + " " + pattern, lim
+		}
+		if lim, ok := rules.limits[pattern]; ok {
+			return pattern, lim
+		}
 	}
+	return ratelimit.AnyRoute, rules.defaultLimit
+}
+
+// client ключ клиента запроса
+func (rl *RateLimiter) client(r *http.Request) string {
+	return ratelimit.ClientKey(r.Context(), ClientIP(r, rl.proxies))
+}
+
+// DefaultLimit текущий лимит маршрутов без своего правила
+func (rl *RateLimiter) DefaultLimit() ratelimit.Limit {
+	return rl.rules.Load().defaultLimit
 }
 
-func (rl *RateLimiter) Allow() bool {
-	select {
-	case <-rl.requests:
-		return true
-	default:
+// Take забирает токен из ведра key
+func (rl *RateLimiter) Take(ctx context.Context, key string, lim ratelimit.Limit) (ratelimit.Decision, error) {
+	return rl.store.Take(ctx, key, lim, rl.now())
+}
+
+// ClientIP адрес клиента. X-Forwarded-For учитывается, только если запрос пришел от доверенного прокси:
+// адреса читаются справа налево до первого недоверенного, левее него клиент может записать что угодно
+func ClientIP(r *http.Request, trusted []netip.Prefix) string {
+	addr, err := parseAddr(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	isTrusted := func(a netip.Addr) bool {
+		for _, p := range trusted {
+			if p.Contains(a) {
+				return true
+			}
+		}
 		return false
 	}
+	if !isTrusted(addr) {
+		return addr.String()
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := parseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		addr = hop
+		if !isTrusted(hop) {
+			break
+		}
+	}
+	return addr.String()
 }
 
-func RateLimitMiddleware(rl *RateLimiter) func(http.Handler) http.Handler {
+// parseAddr адрес из host:port или голого ip
+func parseAddr(s string) (netip.Addr, error) {
+	if ap, err := netip.ParseAddrPort(s); err == nil {
+		return ap.Addr().Unmap(), nil
+	}
+	a, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	return a.Unmap(), nil
+}
+
+// seconds длительность в целых секундах с округлением вверх
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
+
+// RateLimit ограничивает запросы клиента к маршруту. Ставится после аутентификации,
+// иначе запросы пользователей считаются по IP.
+// Заголовки RateLimit-* (draft-ietf-httpapi-ratelimit-headers) показывают состояние ведра
+func RateLimit(rl *RateLimiter) func(http.Handler) http.Handler {
+	return rateLimit(rl, "RateLimit", "", rl.client)
+}
+
+// IPRateLimit ограничивает запросы по IP до аутентификации - под лимит попадают и запросы,
+// которые до RateLimit не доходят: неверные ключи и токены.
+// Ведра отдельные от RateLimit, даже в общем store
+func IPRateLimit(rl *RateLimiter) func(http.Handler) http.Handler {
+	return rateLimit(rl, "IPRateLimit", ratelimit.PreAuthPrefix, func(r *http.Request) string {
+		return ratelimit.IPKey(ClientIP(r, rl.proxies))
+	})
+}
+
+// rateLimit ведро запроса - prefix, маршрут и клиент
+func rateLimit(rl *RateLimiter, name, prefix string, clientOf func(*http.Request) string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if !rl.Allow() {
-				l.Logger().Log("middleware", "rate_limiter").Warn("Rate limit exceeded")
+			log := l.Logger().WithFields(l.LogOptions{
+				Pkg:  "middleware",
+				Func: name,
+				Ctx:  r.Context(),
+			})
 
-				// Добавляем заголовки согласно RFC 6585
-				w.Header().Set("Retry-After", "60")
-				utils.WriteJSON(w, http.StatusTooManyRequests, "Rate limit exceeded. Please try again later.")
+			route, lim := rl.route(r)
+			client := clientOf(r)
+			d, err := rl.Take(r.Context(), prefix+ratelimit.Key(route, client), lim)
+			if err != nil {
+				log.Errorf("хранилище лимитов недоступно: %v", err)
+				if rl.failOpen {
+					next.ServeHTTP(w, r)
+					return
+				}
+				w.Header().Set("Retry-After", "1")
+				utils.WriteJSON(w, http.StatusServiceUnavailable, map[string]string{
+					"error": "rate limiter unavailable",
+					"code":  "RATE_LIMIT_UNAVAILABLE",
+				})
 				return
 			}
 
-			// Добавляем информацию о лимитах в заголовки
-			w.Header().Set("X-RateLimit-Limit", "100")
-			w.Header().Set("X-RateLimit-Remaining", "99") // TODO: вычислять реальное значение
-			w.Header().Set("X-RateLimit-Reset", "60")
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(lim.Capacity()))
+			h.Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+			h.Set("RateLimit-Reset", seconds(d.Reset))
+			h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%s;burst=%d", lim.Requests, seconds(lim.Period), lim.Capacity()))
+
+			if !d.Allowed {
+				log.Warnf("превышен лимит %s для %s на %s", lim, client, route)
+
+				// RFC 6585
+				h.Set("Retry-After", seconds(d.RetryAfter))
+				utils.WriteJSON(w, http.StatusTooManyRequests, map[string]string{
+					"error": "rate limit exceeded",
+					"code":  "RATE_LIMITED",
+				})
+				return
+			}
 
 			next.ServeHTTP(w, r)
 		})
@@ -0,0 +1,251 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/auth"
+	"github.com/end1essrage/efmob-tz/pkg/common/ratelimit"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock время лимитера под управлением теста
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time      { return c.t }
+func (c *fakeClock) add(d time.Duration) { c.t = c.t.Add(d) }
+
+func mustLimit(t *testing.T, s string) ratelimit.Limit {
+	lim, err := ratelimit.ParseLimit(s)
+	require.NoError(t, err)
+	return lim
+}
+
+func newTestLimiter(t *testing.T, cfg RateLimiterConfig) (*RateLimiter, *fakeClock) {
+	rl, err := NewRateLimiter(cfg)
+	require.NoError(t, err)
+	clock := &fakeClock{t: time.Unix(1_700_000_000, 0)}
+	rl.now = clock.now
+	return rl, clock
+}
+
+func TestParseRateLimitRules(t *testing.T) {
+	rules, err := ParseRateLimitRules("POST /subscriptions/import=10/1m:5; /admin/*=30/1m;")
+	require.NoError(t, err)
+	require.Equal(t, []RateLimitRule{
+		{Pattern: "POST /subscriptions/import", Limit: ratelimit.Limit{Requests: 10, Period: time.Minute, Burst: 5}},
+		{Pattern: "/admin/*", Limit: ratelimit.Limit{Requests: 30, Period: time.Minute}},
+	}, rules)
+
+	_, err = ParseRateLimitRules("/admin/*")
+	require.Error(t, err)
+}
+
+func TestNewRateLimiter_InvalidRules(t *testing.T) {
+	def := ratelimit.Limit{Requests: 1, Period: time.Second}
+	for _, rule := range []RateLimitRule{
+		{Pattern: "admin", Limit: def},
+		{Pattern: "BREW /coffee", Limit: def},
+		{Pattern: "/x", Limit: ratelimit.Limit{}},
+	} {
+		_, err := NewRateLimiter(RateLimiterConfig{Default: def, Routes: []RateLimitRule{rule}})
+		require.Error(t, err, rule.Pattern)
+	}
+	_, err := NewRateLimiter(RateLimiterConfig{})
+	require.Error(t, err)
+}
+
+func TestClientIP(t *testing.T) {
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+
+	tests := []struct {
+		name   string
+		remote string
+		xff    []string
+		want   string
+	}{
+		{"direct", "203.0.113.7:5000", nil, "203.0.113.7"},
+		{"untrusted proxy", "203.0.113.7:5000", []string{"198.51.100.1"}, "203.0.113.7"},
+		{"trusted proxy", "10.0.0.2:5000", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"spoofed left", "10.0.0.2:5000", []string{"1.1.1.1, 198.51.100.1, 10.0.0.3"}, "198.51.100.1"},
+		{"several headers", "10.0.0.2:5000", []string{"1.1.1.1", "198.51.100.1"}, "198.51.100.1"},
+		{"all trusted", "10.0.0.2:5000", []string{"10.0.0.5"}, "10.0.0.5"},
+		{"garbage", "10.0.0.2:5000", []string{"1.1.1.1, junk"}, "10.0.0.2"},
+		{"ipv6 mapped", "[::ffff:203.0.113.7]:5000", nil, "203.0.113.7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remote
+			for _, v := range tt.xff {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+			require.Equal(t, tt.want, ClientIP(req, trusted))
+		})
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	rl, clock := newTestLimiter(t, RateLimiterConfig{
+		Default: mustLimit(t, "60/1m:2"),
+		Routes: []RateLimitRule{
+			{Pattern: "POST /subscriptions/import", Limit: mustLimit(t, "1/1m")},
+			{Pattern: "/admin/*", Limit: mustLimit(t, "10/1m:3")},
+		},
+	})
+	h := RateLimit(rl)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	do := func(method, path, ip string, p *auth.Principal) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = ip + ":1234"
+		if p != nil {
+			req = req.WithContext(auth.WithPrincipal(req.Context(), p))
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// заголовки считают реальный остаток
+	rec := do(http.MethodGet, "/subscriptions", "203.0.113.1", nil)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	require.Equal(t, "2", rec.Header().Get("RateLimit-Limit"))
+	require.Equal(t, "1", rec.Header().Get("RateLimit-Remaining"))
+	require.Equal(t, "1", rec.Header().Get("RateLimit-Reset"))
+	require.Equal(t, "60;w=60;burst=2", rec.Header().Get("RateLimit-Policy"))
+
+	// маршруты без правила делят ведро клиента
+	require.Equal(t, http.StatusNoContent, do(http.MethodGet, "/subscriptions/total", "203.0.113.1", nil).Code)
+	rec = do(http.MethodGet, "/subscriptions", "203.0.113.1", nil)
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	require.Equal(t, "1", rec.Header().Get("Retry-After"))
+	require.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+	require.Contains(t, rec.Body.String(), `"code":"RATE_LIMITED"`)
+
+	// шумный клиент не мешает остальным
+	require.Equal(t, http.StatusNoContent, do(http.MethodGet, "/subscriptions", "203.0.113.2", nil).Code)
+
+	// свой лимит маршрута с методом
+	require.Equal(t, http.StatusNoContent, do(http.MethodPost, "/subscriptions/import", "203.0.113.1", nil).Code)
+	rec = do(http.MethodPost, "/subscriptions/import", "203.0.113.1", nil)
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	require.Equal(t, "60", rec.Header().Get("Retry-After"))
+
+	// шаблон без метода
+	require.Equal(t, "3", do(http.MethodDelete, "/admin/api-keys/1", "203.0.113.1", nil).Header().Get("RateLimit-Limit"))
+
+	// пользователи и ключи API считаются отдельно от IP и друг от друга
+	alice := &auth.Principal{Subject: "alice"}
+	require.Equal(t, http.StatusNoContent, do(http.MethodGet, "/subscriptions", "203.0.113.1", alice).Code)
+	require.Equal(t, http.StatusNoContent, do(http.MethodGet, "/subscriptions", "203.0.113.1", alice).Code)
+	require.Equal(t, http.StatusTooManyRequests, do(http.MethodGet, "/subscriptions", "203.0.113.9", alice).Code)
+
+	key := &auth.Principal{Subject: "alice", APIKeyID: uuid.New()}
+	require.Equal(t, http.StatusNoContent, do(http.MethodGet, "/subscriptions", "203.0.113.1", key).Code)
+
+	// ведро пополняется со временем
+	clock.add(time.Second)
+	require.Equal(t, http.StatusNoContent, do(http.MethodGet, "/subscriptions", "203.0.113.1", nil).Code)
+}
+
+// brokenStore хранилище лимитов, которое недоступно
+type brokenStore struct{}
+
+func (brokenStore) Take(context.Context, string, ratelimit.Limit, time.Time) (ratelimit.Decision, error) {
+	return ratelimit.Decision{}, errors.New("connection refused")
+}
+
+func TestRateLimit_StoreUnavailable(t *testing.T) {
+	for _, failOpen := range []bool{true, false} {
+		rl, _ := newTestLimiter(t, RateLimiterConfig{
+			Default:  mustLimit(t, "60/1m"),
+			Store:    brokenStore{},
+			FailOpen: failOpen,
+		})
+		h := RateLimit(rl)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}))
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/subscriptions", nil))
+
+		if failOpen {
+			require.Equal(t, http.StatusNoContent, rec.Code)
+			require.Empty(t, rec.Header().Get("RateLimit-Limit"))
+		} else {
+			require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+			require.Equal(t, "1", rec.Header().Get("Retry-After"))
+			require.Contains(t, rec.Body.String(), `"code":"RATE_LIMIT_UNAVAILABLE"`)
+		}
+	}
+}
+
+func TestRateLimiter_SetLimits(t *testing.T) {
+	rl, _ := newTestLimiter(t, RateLimiterConfig{Default: mustLimit(t, "60/1m:2")})
+	h := RateLimit(rl)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	do := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = "203.0.113.1:1234"
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	require.Equal(t, "2", do(http.MethodGet, "/subscriptions").Header().Get("RateLimit-Limit"))
+
+	// новые лимиты действуют без пересоздания лимитера
+	require.NoError(t, rl.SetLimits(mustLimit(t, "100/1m:10"), []RateLimitRule{
+		{Pattern: "POST /subscriptions/import", Limit: mustLimit(t, "1/1m")},
+	}))
+	require.Equal(t, "100;w=60;burst=10", do(http.MethodGet, "/subscriptions").Header().Get("RateLimit-Policy"))
+	require.Equal(t, "1", do(http.MethodPost, "/subscriptions/import").Header().Get("RateLimit-Limit"))
+
+	// невалидные лимиты отклоняются, прежние остаются
+	require.Error(t, rl.SetLimits(mustLimit(t, "1/1s"), []RateLimitRule{{Pattern: "admin", Limit: mustLimit(t, "1/1s")}}))
+	require.Error(t, rl.SetLimits(ratelimit.Limit{}, nil))
+	require.Equal(t, "100;w=60;burst=10", do(http.MethodGet, "/subscriptions").Header().Get("RateLimit-Policy"))
+}
+
+func TestIPRateLimit(t *testing.T) {
+	store := ratelimit.NewMemoryStore(time.Minute)
+	ipRL, _ := newTestLimiter(t, RateLimiterConfig{Default: mustLimit(t, "2/1m"), Store: store})
+	rl, _ := newTestLimiter(t, RateLimiterConfig{Default: mustLimit(t, "60/1m:1"), Store: store})
+
+	h := IPRateLimit(ipRL)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	do := func(ip string, p *auth.Principal) int {
+		req := httptest.NewRequest(http.MethodGet, "/subscriptions", nil)
+		req.RemoteAddr = ip + ":1234"
+		if p != nil {
+			req = req.WithContext(auth.WithPrincipal(req.Context(), p))
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// principal не учитывается - считается только IP
+	alice := &auth.Principal{Subject: "alice"}
+	require.Equal(t, http.StatusNoContent, do("203.0.113.1", nil))
+	require.Equal(t, http.StatusNoContent, do("203.0.113.1", alice))
+	require.Equal(t, http.StatusTooManyRequests, do("203.0.113.1", nil))
+	require.Equal(t, http.StatusNoContent, do("203.0.113.2", alice))
+
+	// ведра в общем store не пересекаются с лимитом клиентов
+	d, err := rl.Take(context.Background(), ratelimit.AnyRoute+"|ip:203.0.113.1", rl.rules.Load().defaultLimit)
+	require.NoError(t, err)
+	require.True(t, d.Allowed)
+}
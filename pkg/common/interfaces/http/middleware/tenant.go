@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/interfaces/http/utils"
+	"github.com/end1essrage/efmob-tz/pkg/common/tenant"
+)
+
+// TenantHeader заголовок выбора тенанта. Для ссылок без заголовков (ленты календаря) - параметр tenant
+const TenantHeader = "X-Tenant-ID"
+
+// Tenant кладет в контекст тенанта из заголовка или параметра запроса.
+// С аутентификацией выбор сверяется с тенантом вызывающего в Authenticate
+func Tenant(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(TenantHeader)
+		if id == "" {
+			id = r.URL.Query().Get("tenant")
+		}
+		if id == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !tenant.Valid(id) {
+			utils.WriteJSON(w, http.StatusBadRequest, map[string]string{
+				"error": "invalid tenant id",
+				"code":  "INVALID_TENANT",
+			})
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(tenant.WithTenant(r.Context(), id)))
+	})
+}
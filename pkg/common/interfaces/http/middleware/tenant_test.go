@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/auth"
+	"github.com/end1essrage/efmob-tz/pkg/common/tenant"
+	"github.com/stretchr/testify/require"
+)
+
+// tenantVerifier токен - id тенанта вызывающего
+type tenantVerifier struct{}
+
+func (tenantVerifier) Verify(_ context.Context, token string) (*auth.Principal, error) {
+	if token == "none" {
+		return &auth.Principal{Subject: token}, nil
+	}
+	return &auth.Principal{Subject: token, TenantID: token}, nil
+}
+
+func TestTenant(t *testing.T) {
+	var got string
+	h := Tenant(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = tenant.Current(r.Context())
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name   string
+		url    string
+		header string
+		status int
+		tenant string
+	}{
+		{"no tenant", "/", "", http.StatusNoContent, tenant.Default},
+		{"header", "/", "acme", http.StatusNoContent, "acme"},
+		{"query", "/?tenant=acme", "", http.StatusNoContent, "acme"},
+		{"header wins", "/?tenant=globex", "acme", http.StatusNoContent, "acme"},
+		{"invalid", "/", "Acme; drop", http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = ""
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.header != "" {
+				req.Header.Set(TenantHeader, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			require.Equal(t, tt.status, rec.Code)
+			require.Equal(t, tt.tenant, got)
+			if tt.status == http.StatusBadRequest {
+				require.Contains(t, rec.Body.String(), `"code":"INVALID_TENANT"`)
+			}
+		})
+	}
+}
+
+func TestAuthenticate_BindsTenant(t *testing.T) {
+	a := auth.NewAuthenticator(auth.Scheme{Name: auth.SchemeBearer, Verifier: tenantVerifier{}})
+
+	var got string
+	h := Tenant(Authenticate(a, "subs")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = tenant.Current(r.Context())
+		w.WriteHeader(http.StatusNoContent)
+	})))
+
+	tests := []struct {
+		name   string
+		token  string
+		header string
+		status int
+		tenant string
+	}{
+		{"principal tenant", "acme", "", http.StatusNoContent, "acme"},
+		{"same header", "acme", "acme", http.StatusNoContent, "acme"},
+		{"foreign header", "acme", "globex", http.StatusForbidden, ""},
+		{"no principal tenant", "none", "", http.StatusNoContent, tenant.Default},
+		{"no principal tenant foreign header", "none", "globex", http.StatusForbidden, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = ""
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			if tt.header != "" {
+				req.Header.Set(TenantHeader, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			require.Equal(t, tt.status, rec.Code)
+			require.Equal(t, tt.tenant, got)
+			if tt.status == http.StatusForbidden {
+				require.Contains(t, rec.Body.String(), `"code":"TENANT_MISMATCH"`)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// TimeoutConfig таймауты контекста запроса
+type TimeoutConfig struct {
+	// Default таймаут всех маршрутов
+	Default time.Duration
+	// Routes свои таймауты маршрутов "METHOD /path" - потоковые выгрузки идут дольше обычного запроса
+	Routes map[string]time.Duration
+}
+
+// Timeout отменяет контекст запроса по таймауту маршрута, по истечении до ответа - 504.
+// Маршрут сверяется по точному пути: ставится до роутинга, шаблоны chi еще не известны
+func Timeout(cfg TimeoutConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		def := middleware.Timeout(cfg.Default)(next)
+
+		routes := make(map[string]http.Handler, len(cfg.Routes))
+		for route, d := range cfg.Routes {
+			routes[route] = middleware.Timeout(d)(next)
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path := r.URL.Path
+			if len(path) > 1 {
+				path = strings.TrimSuffix(path, "/")
+			}
+			if h, ok := routes[r.Method+" "+path]; ok {
+				h.ServeHTTP(w, r)
+				return
+			}
+			def.ServeHTTP(w, r)
+		})
+	}
+}
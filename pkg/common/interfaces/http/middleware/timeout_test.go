@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeout_RouteOverridesDefault(t *testing.T) {
+	h := Timeout(TimeoutConfig{
+		Default: time.Second,
+		Routes:  map[string]time.Duration{"GET /subscriptions/export": time.Hour},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dl, ok := r.Context().Deadline()
+		require.True(t, ok)
+		w.Header().Set("X-Left", time.Until(dl).Round(time.Minute).String())
+	}))
+
+	cases := map[string]string{
+		"GET /subscriptions/export":   "1h0m0s",
+		"GET /subscriptions/export/":  "1h0m0s",
+		"POST /subscriptions/export":  "0s",
+		"GET /subscriptions":          "0s",
+		"GET /subscriptions/export/x": "0s",
+	}
+	for route, want := range cases {
+		method, path, _ := strings.Cut(route, " ")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		require.Equal(t, want, w.Header().Get("X-Left"), route)
+	}
+}
+
+func TestTimeout_ExpiredRequestGets504(t *testing.T) {
+	h := Timeout(TimeoutConfig{Default: 10 * time.Millisecond})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/subscriptions", nil))
+
+	require.Equal(t, http.StatusGatewayTimeout, w.Code)
+}
@@ -1,10 +1,15 @@
 package utils
 
 import (
+	"encoding"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
+	"net/url"
 	"reflect"
 	"strconv"
+	"strings"
 )
 
 func WriteJSON(w http.ResponseWriter, status int, payload interface{}) {
@@ -13,17 +18,43 @@ func WriteJSON(w http.ResponseWriter, status int, payload interface{}) {
 	_ = json.NewEncoder(w).Encode(payload) // Игнорируем ошибку энкодинга, не критично
 }
 
+// MaxJSONBodySize предел тела JSON запроса, больше - 413
+const MaxJSONBodySize = 1 << 20
+
+// DecodeJSONBody декодирует тело не больше MaxJSONBodySize в dst, при ошибке пишет ответ
 func DecodeJSONBody(w http.ResponseWriter, r *http.Request, dst interface{}) error {
-	if err := json.NewDecoder(r.Body).Decode(dst); err != nil {
-		WriteJSON(w, http.StatusBadRequest, map[string]string{
-			"error": "invalid request format",
-			"code":  "VALIDATION_ERROR",
-		})
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxJSONBodySize)).Decode(dst); err != nil {
+		writeBodyError(w, err)
 		return err
 	}
 	return nil
 }
 
+// ReadJSONBody читает тело не больше MaxJSONBodySize целиком, при ошибке пишет ответ
+func ReadJSONBody(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxJSONBodySize))
+	if err != nil {
+		writeBodyError(w, err)
+		return nil, err
+	}
+	return body, nil
+}
+
+func writeBodyError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		WriteJSON(w, http.StatusRequestEntityTooLarge, map[string]string{
+			"error": "request body too large",
+			"code":  "REQUEST_TOO_LARGE",
+		})
+		return
+	}
+	WriteJSON(w, http.StatusBadRequest, map[string]string{
+		"error": "invalid request format",
+		"code":  "VALIDATION_ERROR",
+	})
+}
+
 // ParseQuery парсит query-параметры из запроса r в структуру dst.
 // dst должен быть указателем на структуру с тегами `schema:"<name>"`.
 func ParseQuery(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
@@ -45,14 +76,33 @@ func ParseQuery(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
 		return false
 	}
 
-	v = v.Elem()
+	if tag, err := parseQueryFields(values, v.Elem()); err != nil {
+		WriteJSON(w, http.StatusBadRequest, map[string]string{
+			"error": "invalid param " + tag + ", " + err.Error(),
+			"code":  "BAD_QUERY",
+		})
+		return false
+	}
+
+	return true
+}
+
+// parseQueryFields заполняет поля структуры v, встроенные структуры разбираются как свои поля.
+// При ошибке возвращает имя параметра
+func parseQueryFields(values url.Values, v reflect.Value) (string, error) {
 	t := v.Type()
 
 	for i := 0; i < v.NumField(); i++ {
 		field := v.Field(i)
 		fieldType := t.Field(i)
-		tag := fieldType.Tag.Get("schema")
+		// опции после запятой (omitempty) не часть имени параметра
+		tag, _, _ := strings.Cut(fieldType.Tag.Get("schema"), ",")
 		if tag == "" {
+			if fieldType.Anonymous && field.Kind() == reflect.Struct {
+				if tag, err := parseQueryFields(values, field); err != nil {
+					return tag, err
+				}
+			}
 			continue
 		}
 
@@ -61,40 +111,73 @@ func ParseQuery(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
 			continue
 		}
 
-		strVal := vals[0]
+		if err := setQueryField(field, vals); err != nil {
+			return tag, err
+		}
+	}
 
-		// Разбираем по типу поля
-		switch field.Kind() {
-		case reflect.String:
-			field.SetString(strVal)
-		case reflect.Ptr:
-			if field.Type().Elem().Kind() == reflect.String {
-				field.Set(reflect.ValueOf(&strVal))
-			}
-		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-			intVal, err := strconv.ParseInt(strVal, 10, 64)
-			if err != nil {
-				WriteJSON(w, http.StatusBadRequest, map[string]string{
-					"error": "invalid param, should be int",
-					"code":  "BAD_QUERY",
-				})
-				return false
+	return "", nil
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// setQueryField заполняет поле значениями параметра.
+// Указатели создаются, слайсы собираются из повторов и значений через запятую
+func setQueryField(field reflect.Value, vals []string) error {
+	if field.Kind() == reflect.Ptr {
+		elem := reflect.New(field.Type().Elem())
+		if err := setQueryField(elem.Elem(), vals); err != nil {
+			return err
+		}
+		field.Set(elem)
+		return nil
+	}
+
+	if reflect.PointerTo(field.Type()).Implements(textUnmarshalerType) {
+		return field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(vals[0]))
+	}
+
+	if field.Kind() == reflect.Slice {
+		var items []string
+		for _, v := range vals {
+			for _, item := range strings.Split(v, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
 			}
-			field.SetInt(intVal)
-		case reflect.Bool:
-			boolVal, err := strconv.ParseBool(strVal)
-			if err != nil {
-				WriteJSON(w, http.StatusBadRequest, map[string]string{
-					"error": "invalid param, should be bool",
-					"code":  "BAD_QUERY",
-				})
-				return false
+		}
+
+		slice := reflect.MakeSlice(field.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setQueryField(slice.Index(i), []string{item}); err != nil {
+				return err
 			}
-			field.SetBool(boolVal)
-		default:
-			// Игнорируем unsupported типы
 		}
+		field.Set(slice)
+		return nil
 	}
 
-	return true
+	strVal := vals[0]
+
+	// Разбираем по типу поля
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(strVal)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		intVal, err := strconv.ParseInt(strVal, 10, 64)
+		if err != nil {
+			return errors.New("should be int")
+		}
+		field.SetInt(intVal)
+	case reflect.Bool:
+		boolVal, err := strconv.ParseBool(strVal)
+		if err != nil {
+			return errors.New("should be bool")
+		}
+		field.SetBool(boolVal)
+	default:
+		// Игнорируем unsupported типы
+	}
+
+	return nil
 }
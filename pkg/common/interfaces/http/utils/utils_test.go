@@ -3,8 +3,10 @@ package utils
 import (
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
 )
 
@@ -17,6 +19,21 @@ func TestWriteJSON(t *testing.T) {
 	require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
 }
 
+func TestDecodeJSONBody_TooLarge(t *testing.T) {
+	body := `{"name":"` + strings.Repeat("a", MaxJSONBodySize) + `"}`
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	var dst map[string]string
+	require.Error(t, DecodeJSONBody(w, r, &dst))
+	require.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":`))
+	w = httptest.NewRecorder()
+	require.Error(t, DecodeJSONBody(w, r, &dst))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
+
 func TestParseQuery_StringAndPtr(t *testing.T) {
 	type Query struct {
 		Name    string  `schema:"name"`
@@ -82,3 +99,81 @@ func TestParseQuery_InvalidDst(t *testing.T) {
 	require.False(t, ok)
 	require.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
 }
+
+func TestParseQuery_PointersUUIDAndOptions(t *testing.T) {
+	type Query struct {
+		UserID   *uuid.UUID `schema:"user_id,omitempty"`
+		PageSize *int       `schema:"page_size,omitempty"`
+		NilEnd   *bool      `schema:"nil_end,omitempty"`
+		Missing  *int       `schema:"missing,omitempty"`
+	}
+
+	id := uuid.New()
+	r := httptest.NewRequest(http.MethodGet, "/?user_id="+id.String()+"&page_size=20&nil_end=false", nil)
+	w := httptest.NewRecorder()
+
+	var q Query
+	ok := ParseQuery(w, r, &q)
+	require.True(t, ok)
+	require.Equal(t, id, *q.UserID)
+	require.Equal(t, 20, *q.PageSize)
+	require.False(t, *q.NilEnd)
+	require.Nil(t, q.Missing)
+}
+
+func TestParseQuery_Slices(t *testing.T) {
+	type Query struct {
+		Names []string    `schema:"name"`
+		IDs   []uuid.UUID `schema:"id"`
+	}
+
+	a, b := uuid.New(), uuid.New()
+	r := httptest.NewRequest(http.MethodGet, "/?name=Netflix,Ivi&name=Okko&id="+a.String()+","+b.String(), nil)
+	w := httptest.NewRecorder()
+
+	var q Query
+	ok := ParseQuery(w, r, &q)
+	require.True(t, ok)
+	require.Equal(t, []string{"Netflix", "Ivi", "Okko"}, q.Names)
+	require.Equal(t, []uuid.UUID{a, b}, q.IDs)
+}
+
+func TestParseQuery_BadUUID(t *testing.T) {
+	type Query struct {
+		UserID *uuid.UUID `schema:"user_id"`
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/?user_id=nope", nil)
+	w := httptest.NewRecorder()
+
+	var q Query
+	ok := ParseQuery(w, r, &q)
+	require.False(t, ok)
+	require.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+}
+
+func TestParseQuery_EmbeddedStruct(t *testing.T) {
+	type Filter struct {
+		Name  *string `schema:"name"`
+		Price *int    `schema:"price"`
+	}
+	type Query struct {
+		Filter
+		Page *int `schema:"page"`
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/?name=Netflix&price=100&page=2", nil)
+	w := httptest.NewRecorder()
+
+	var q Query
+	ok := ParseQuery(w, r, &q)
+	require.True(t, ok)
+	require.Equal(t, "Netflix", *q.Name)
+	require.Equal(t, 100, *q.Price)
+	require.Equal(t, 2, *q.Page)
+
+	r = httptest.NewRequest(http.MethodGet, "/?price=abc", nil)
+	w = httptest.NewRecorder()
+	require.False(t, ParseQuery(w, r, &Query{}))
+	require.Contains(t, w.Body.String(), "invalid param price")
+}
@@ -1,6 +1,11 @@
 package logger
 
-import l "github.com/sirupsen/logrus"
+import (
+	l "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
 
 // хук для метаинфы о сервисе
 type MetaHook struct {
@@ -15,3 +20,26 @@ func (h *MetaHook) Fire(entry *l.Entry) error {
 	entry.Data["service"] = h.Name
 	return nil
 }
+
+// TraceHook отмечает ошибкой спан из контекста записи
+type TraceHook struct{}
+
+func (h *TraceHook) Levels() []l.Level {
+	return []l.Level{l.PanicLevel, l.FatalLevel, l.ErrorLevel}
+}
+
+func (h *TraceHook) Fire(entry *l.Entry) error {
+	if entry.Context == nil {
+		return nil
+	}
+	span := trace.SpanFromContext(entry.Context)
+	if !span.IsRecording() {
+		return nil
+	}
+	span.SetStatus(codes.Error, entry.Message)
+	span.AddEvent("log", trace.WithAttributes(
+		attribute.String("log.severity", entry.Level.String()),
+		attribute.String("log.message", entry.Message),
+	))
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/go-chi/chi/v5/middleware"
 	l "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
@@ -24,10 +25,8 @@ type LogOptions struct {
 
 // получить инстанс логгера
 func Logger() *Wrapper {
-	if instance == nil {
-		return New("-", true, true)
-	}
-	return instance
+	// instance читается только после once - резолверы GraphQL логируют конкурентно
+	return New("-", true, true)
 }
 
 // конфигурация нового логгера
@@ -50,11 +49,27 @@ func (log *Wrapper) WithFields(opts LogOptions) *l.Entry {
 		if reqID != "" {
 			entry = entry.WithField("request_id", reqID)
 		}
+		// связываем строку лога с трассой
+		if sc := trace.SpanContextFromContext(opts.Ctx); sc.IsValid() {
+			entry = entry.WithField("trace_id", sc.TraceID().String()).
+				WithField("span_id", sc.SpanID().String()).
+				WithContext(opts.Ctx)
+		}
 	}
 
 	return entry
 }
 
+// SetLevel меняет уровень логов на лету: debug, info, warn, error
+func (log *Wrapper) SetLevel(level string) error {
+	lvl, err := l.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	log.logger.SetLevel(lvl)
+	return nil
+}
+
 // Для обратной совместимости
 func (log *Wrapper) Log(pkg, fn string) *l.Entry {
 	return log.WithFields(LogOptions{Pkg: pkg, Func: fn})
@@ -94,6 +109,8 @@ func newLogger(serviceName string, jsonFormat bool, debug bool) *l.Logger {
 
 	//записываем название сервиса
 	logger.AddHook(&MetaHook{Name: serviceName})
+	//ошибки попадают в спан запроса
+	logger.AddHook(&TraceHook{})
 
 	return logger
 }
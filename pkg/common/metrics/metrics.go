@@ -6,5 +6,7 @@ func Register() {
 	prometheus.MustRegister(
 		HTTPRequestsTotal,
 		HTTPRequestDuration,
+		GRPCRequestsTotal,
+		GRPCRequestDuration,
 	)
 }
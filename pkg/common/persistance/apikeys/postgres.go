@@ -0,0 +1,190 @@
+package apikeys
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/auth"
+	"github.com/end1essrage/efmob-tz/pkg/common/persistance"
+	"github.com/end1essrage/efmob-tz/pkg/common/tenant"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type KeyModel struct {
+	ID       uuid.UUID `gorm:"type:uuid;primaryKey"`
+	TenantID string    `gorm:"type:text;not null;default:'default';index"`
+	Name     string    `gorm:"type:text;not null"`
+	Owner    string    `gorm:"type:text;not null;index"`
+	// Scopes через пробел, как scope в jwt
+	Scopes     string     `gorm:"type:text;not null"`
+	Hash       string     `gorm:"type:text;not null"`
+	Hint       string     `gorm:"type:text;not null"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime"`
+	ExpiresAt  *time.Time `gorm:"index"`
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+}
+
+func (KeyModel) TableName() string {
+	return "api_keys"
+}
+
+func (m *KeyModel) toKey() *auth.APIKey {
+	return &auth.APIKey{
+		ID:         m.ID,
+		TenantID:   m.TenantID,
+		Name:       m.Name,
+		Owner:      m.Owner,
+		Scopes:     strings.Fields(m.Scopes),
+		Hash:       m.Hash,
+		Hint:       m.Hint,
+		CreatedAt:  m.CreatedAt,
+		ExpiresAt:  m.ExpiresAt,
+		LastUsedAt: m.LastUsedAt,
+		RevokedAt:  m.RevokedAt,
+	}
+}
+
+// GormStore хранит хэши ключей API в postgres.
+// Управление ключами идет в транзакции тенанта, проверка ключа - в транзакции CrossTenant:
+// тенант запроса еще неизвестен
+type GormStore struct {
+	db *gorm.DB
+}
+
+func NewGormStore(db *gorm.DB) *GormStore {
+	return &GormStore{db: db}
+}
+
+// Migrate создаёт таблицу
+func (s *GormStore) Migrate() error {
+	if err := s.db.AutoMigrate(&KeyModel{}); err != nil {
+		return err
+	}
+	return persistance.EnableTenantRLS(s.db, "api_keys")
+}
+
+// CreateAPIKey сохраняет ключ в тенанте из контекста
+func (s *GormStore) CreateAPIKey(ctx context.Context, key *auth.APIKey) error {
+	model := KeyModel{
+		ID:        key.ID,
+		TenantID:  tenant.Current(ctx),
+		Name:      key.Name,
+		Owner:     key.Owner,
+		Scopes:    strings.Join(key.Scopes, " "),
+		Hash:      key.Hash,
+		Hint:      key.Hint,
+		ExpiresAt: key.ExpiresAt,
+	}
+	err := persistance.InTenant(ctx, s.db, func(tx *gorm.DB) error {
+		return tx.Create(&model).Error
+	})
+	if err != nil {
+		return err
+	}
+	key.TenantID = model.TenantID
+	key.CreatedAt = model.CreatedAt
+	return nil
+}
+
+// GetAPIKey ищет ключ во всех тенантах - тенант запроса определяется по ключу
+func (s *GormStore) GetAPIKey(ctx context.Context, id uuid.UUID) (*auth.APIKey, error) {
+	var key *auth.APIKey
+	err := persistance.CrossTenant(ctx, s.db, func(tx *gorm.DB) error {
+		var err error
+		key, err = getAPIKey(tx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func getAPIKey(db *gorm.DB, id uuid.UUID) (*auth.APIKey, error) {
+	var m KeyModel
+	if err := db.First(&m, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, auth.ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+	return m.toKey(), nil
+}
+
+// ListAPIKeys ключи тенанта, новые первыми
+func (s *GormStore) ListAPIKeys(ctx context.Context) ([]*auth.APIKey, error) {
+	var models []KeyModel
+	err := persistance.InTenant(ctx, s.db, func(tx *gorm.DB) error {
+		return tx.Order("created_at DESC, id").Find(&models).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]*auth.APIKey, len(models))
+	for i := range models {
+		keys[i] = models[i].toKey()
+	}
+	return keys, nil
+}
+
+// RotateAPIKey заменяет хэш ключа, старый ключ сразу перестает действовать.
+// Отозванный ключ не перевыпускается
+func (s *GormStore) RotateAPIKey(ctx context.Context, id uuid.UUID, hash, hint string) (*auth.APIKey, error) {
+	var key *auth.APIKey
+	err := persistance.InTenant(ctx, s.db, func(tx *gorm.DB) error {
+		res := tx.
+			Model(&KeyModel{}).
+			Where("id = ? AND revoked_at IS NULL", id).
+			Updates(map[string]interface{}{"hash": hash, "hint": hint})
+		if res.Error != nil {
+			return res.Error
+		}
+
+		var err error
+		key, err = getAPIKey(tx, id)
+		if err != nil {
+			return err
+		}
+		if res.RowsAffected == 0 {
+			return auth.ErrAPIKeyRevoked
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// RevokeAPIKey отзывает ключ, повторный отзыв не меняет время отзыва
+func (s *GormStore) RevokeAPIKey(ctx context.Context, id uuid.UUID, at time.Time) error {
+	return persistance.InTenant(ctx, s.db, func(tx *gorm.DB) error {
+		res := tx.
+			Model(&KeyModel{}).
+			Where("id = ? AND revoked_at IS NULL", id).
+			Update("revoked_at", at)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			// ключа нет или он уже отозван
+			if _, err := getAPIKey(tx, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *GormStore) TouchAPIKey(ctx context.Context, id uuid.UUID, at time.Time) error {
+	return persistance.CrossTenant(ctx, s.db, func(tx *gorm.DB) error {
+		return tx.
+			Model(&KeyModel{}).
+			Where("id = ?", id).
+			Update("last_used_at", at).Error
+	})
+}
@@ -0,0 +1,150 @@
+//go:build integration
+// +build integration
+
+package apikeys
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/auth"
+	"github.com/end1essrage/efmob-tz/pkg/common/tenant"
+	common_test "github.com/end1essrage/efmob-tz/pkg/common/testing"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestGormStore_APIKeys(t *testing.T) {
+	ctx := context.Background()
+	container, dsn, err := common_test.SetupPostgresContainer(ctx)
+	if err != nil {
+		t.Fatalf("failed to start postgres container: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+
+	store := NewGormStore(db)
+	require.NoError(t, store.Migrate())
+
+	verifier := auth.NewAPIKeyVerifier(store)
+
+	issue := func(owner string, scopes ...string) (*auth.APIKey, string) {
+		key := &auth.APIKey{ID: uuid.New(), Name: "job", Owner: owner, Scopes: scopes}
+		secret, err := auth.NewAPIKeySecret(key.ID)
+		require.NoError(t, err)
+		key.Hash = auth.HashAPIKey(secret)
+		key.Hint = auth.APIKeyHint(secret)
+		require.NoError(t, store.CreateAPIKey(ctx, key))
+		return key, secret
+	}
+
+	userID := uuid.New()
+	key, secret := issue(userID.String(), "subs:read:own", "subs:write:own")
+	require.False(t, key.CreatedAt.IsZero())
+
+	// CREATE / GET
+	got, err := store.GetAPIKey(ctx, key.ID)
+	require.NoError(t, err)
+	require.Equal(t, []string{"subs:read:own", "subs:write:own"}, got.Scopes)
+	require.Equal(t, key.Hash, got.Hash)
+	require.Nil(t, got.LastUsedAt)
+
+	_, err = store.GetAPIKey(ctx, uuid.New())
+	require.ErrorIs(t, err, auth.ErrAPIKeyNotFound)
+
+	// проверка ключа отмечает использование
+	p, err := verifier.Verify(ctx, secret)
+	require.NoError(t, err)
+	require.Equal(t, userID, p.UserID)
+	got, err = store.GetAPIKey(ctx, key.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got.LastUsedAt)
+
+	// LIST - новые первыми
+	second, _ := issue("billing-job", "subs:read:any")
+	keys, err := store.ListAPIKeys(ctx)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	require.Equal(t, second.ID, keys[0].ID)
+
+	// ROTATE - старый ключ перестает действовать
+	newSecret, err := auth.NewAPIKeySecret(key.ID)
+	require.NoError(t, err)
+	rotated, err := store.RotateAPIKey(ctx, key.ID, auth.HashAPIKey(newSecret), auth.APIKeyHint(newSecret))
+	require.NoError(t, err)
+	require.Equal(t, key.Owner, rotated.Owner)
+	require.Equal(t, key.Scopes, rotated.Scopes)
+
+	_, err = verifier.Verify(ctx, secret)
+	require.ErrorIs(t, err, auth.ErrInvalidToken)
+	_, err = verifier.Verify(ctx, newSecret)
+	require.NoError(t, err)
+
+	_, err = store.RotateAPIKey(ctx, uuid.New(), "hash", "hint")
+	require.ErrorIs(t, err, auth.ErrAPIKeyNotFound)
+
+	// REVOKE - повторный отзыв не меняет время
+	revokedAt := time.Now().Truncate(time.Millisecond)
+	require.NoError(t, store.RevokeAPIKey(ctx, key.ID, revokedAt))
+	require.NoError(t, store.RevokeAPIKey(ctx, key.ID, revokedAt.Add(time.Hour)))
+	got, err = store.GetAPIKey(ctx, key.ID)
+	require.NoError(t, err)
+	require.WithinDuration(t, revokedAt, *got.RevokedAt, time.Millisecond)
+
+	_, err = verifier.Verify(ctx, newSecret)
+	require.ErrorIs(t, err, auth.ErrInvalidToken)
+
+	_, err = store.RotateAPIKey(ctx, key.ID, "hash", "hint")
+	require.ErrorIs(t, err, auth.ErrAPIKeyRevoked)
+
+	require.ErrorIs(t, store.RevokeAPIKey(ctx, uuid.New(), revokedAt), auth.ErrAPIKeyNotFound)
+}
+
+func TestGormStore_TenantIsolation(t *testing.T) {
+	ctx := context.Background()
+	container, dsn, err := common_test.SetupPostgresContainer(ctx)
+	if err != nil {
+		t.Fatalf("failed to start postgres container: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+
+	store := NewGormStore(db)
+	require.NoError(t, store.Migrate())
+
+	acme := tenant.WithTenant(ctx, "acme")
+	globex := tenant.WithTenant(ctx, "globex")
+
+	key := &auth.APIKey{ID: uuid.New(), Name: "job", Owner: "billing-job", Scopes: []string{"subs:read:any"}}
+	secret, err := auth.NewAPIKeySecret(key.ID)
+	require.NoError(t, err)
+	key.Hash = auth.HashAPIKey(secret)
+	key.Hint = auth.APIKeyHint(secret)
+	require.NoError(t, store.CreateAPIKey(acme, key))
+
+	// ключ работает в тенанте, где выпущен
+	p, err := auth.NewAPIKeyVerifier(store).Verify(ctx, secret)
+	require.NoError(t, err)
+	require.Equal(t, "acme", p.TenantID)
+
+	// администратор другого тенанта ключ не видит и не может им управлять
+	keys, err := store.ListAPIKeys(globex)
+	require.NoError(t, err)
+	require.Empty(t, keys)
+
+	_, err = store.RotateAPIKey(globex, key.ID, "hash", "hint")
+	require.ErrorIs(t, err, auth.ErrAPIKeyNotFound)
+	require.ErrorIs(t, store.RevokeAPIKey(globex, key.ID, time.Now()), auth.ErrAPIKeyNotFound)
+
+	keys, err = store.ListAPIKeys(acme)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	require.Nil(t, keys[0].RevokedAt)
+}
@@ -0,0 +1,50 @@
+package persistance
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor позиция keyset пагинации - значение ключа сортировки и ID последней отданной строки.
+// Клиенту отдаётся в закодированном виде, содержимое не является частью API
+type Cursor struct {
+	// Sort сортировка, для которой выдан курсор
+	Sort string `json:"s,omitempty"`
+	// Values значения ключей сортировки по порядку, nil - NULL
+	Values []*string `json:"v,omitempty"`
+	ID     uuid.UUID `json:"id"`
+}
+
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeCursor(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == uuid.Nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &c, nil
+}
+
+// Matches курсор выдан для той же сортировки
+func (c Cursor) Matches(sort string) bool {
+	return c.Sort == sort
+}
+
+// IsDesc всё, кроме desc - по возрастанию
+func (d SortingDirection) IsDesc() bool {
+	return d == Descending
+}
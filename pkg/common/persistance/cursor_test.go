@@ -0,0 +1,26 @@
+package persistance
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursor_EncodeDecode(t *testing.T) {
+	price := "400"
+	c := Cursor{Sort: "-price,end_date", Values: []*string{&price, nil}, ID: uuid.New()}
+
+	decoded, err := DecodeCursor(c.Encode())
+	require.NoError(t, err)
+	require.Equal(t, c, *decoded)
+	require.True(t, decoded.Matches("-price,end_date"))
+	require.False(t, decoded.Matches("price,end_date"))
+}
+
+func TestDecodeCursor_Invalid(t *testing.T) {
+	for _, s := range []string{"", "not base64!", "bnVsbA", Cursor{}.Encode()} {
+		_, err := DecodeCursor(s)
+		require.ErrorIs(t, err, ErrInvalidCursor, s)
+	}
+}
@@ -0,0 +1,175 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/idempotency"
+	"github.com/end1essrage/efmob-tz/pkg/common/logger"
+	"github.com/end1essrage/efmob-tz/pkg/common/persistance"
+	"github.com/end1essrage/efmob-tz/pkg/common/tenant"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type KeyModel struct {
+	Key         string    `gorm:"type:text;primaryKey"`
+	TenantID    string    `gorm:"type:text;not null;default:'default';index"`
+	RequestHash string    `gorm:"type:text;not null"`
+	Completed   bool      `gorm:"not null;default:false"`
+	StatusCode  int       `gorm:"not null;default:0"`
+	ContentType string    `gorm:"type:text"`
+	Body        []byte    `gorm:"type:bytea"`
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+	ExpiresAt   time.Time `gorm:"not null;index"`
+	// LockedUntil до этого времени незавершенный ключ занят запросом
+	LockedUntil time.Time `gorm:"not null;default:now()"`
+}
+
+func (KeyModel) TableName() string {
+	return "idempotency_keys"
+}
+
+// GormStore хранит ключи идемпотентности в postgres
+type GormStore struct {
+	db *gorm.DB
+}
+
+var _ idempotency.Store = (*GormStore)(nil)
+
+func NewGormStore(db *gorm.DB) *GormStore {
+	return &GormStore{db: db}
+}
+
+// Migrate создаёт таблицу
+func (s *GormStore) Migrate() error {
+	if err := s.db.AutoMigrate(&KeyModel{}); err != nil {
+		return err
+	}
+	return persistance.EnableTenantRLS(s.db, "idempotency_keys")
+}
+
+func (s *GormStore) Acquire(ctx context.Context, key, requestHash string, ttl, lease time.Duration) (*idempotency.Record, error) {
+	var existing *idempotency.Record
+
+	err := persistance.InTenant(ctx, s.db, func(tx *gorm.DB) error {
+		// истёкший ключ можно переиспользовать
+		now := time.Now()
+		if err := tx.Where("key = ? AND expires_at < ?", key, now).Delete(&KeyModel{}).Error; err != nil {
+			return err
+		}
+
+		model := KeyModel{
+			Key:         key,
+			TenantID:    tenant.Current(ctx),
+			RequestHash: requestHash,
+			ExpiresAt:   now.Add(ttl),
+			LockedUntil: now.Add(lease),
+		}
+
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&model)
+		if res.Error != nil {
+			return res.Error
+		}
+
+		// ключ наш
+		if res.RowsAffected == 1 {
+			return nil
+		}
+
+		// запрос, занявший ключ, упал и не освободил его - повтор того же запроса забирает ключ
+		res = tx.Model(&KeyModel{}).
+			Where("key = ? AND completed = false AND request_hash = ? AND locked_until < ?", key, requestHash, now).
+			Updates(map[string]interface{}{
+				"locked_until": now.Add(lease),
+				"expires_at":   now.Add(ttl),
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 1 {
+			return nil
+		}
+
+		var m KeyModel
+		if err := tx.First(&m, "key = ?", key).Error; err != nil {
+			return err
+		}
+
+		existing = &idempotency.Record{
+			Key:         m.Key,
+			RequestHash: m.RequestHash,
+			Completed:   m.Completed,
+			StatusCode:  m.StatusCode,
+			ContentType: m.ContentType,
+			Body:        m.Body,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return existing, nil
+}
+
+func (s *GormStore) Complete(ctx context.Context, key string, status int, contentType string, body []byte) error {
+	return persistance.InTenant(ctx, s.db, func(tx *gorm.DB) error {
+		res := tx.
+			Model(&KeyModel{}).
+			Where("key = ?", key).
+			Updates(map[string]interface{}{
+				"completed":    true,
+				"status_code":  status,
+				"content_type": contentType,
+				"body":         body,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errors.New("idempotency key not found")
+		}
+		return nil
+	})
+}
+
+func (s *GormStore) Release(ctx context.Context, key string) error {
+	return persistance.InTenant(ctx, s.db, func(tx *gorm.DB) error {
+		return tx.Delete(&KeyModel{}, "key = ? AND completed = false", key).Error
+	})
+}
+
+// RunJanitor периодически удаляет истёкшие ключи всех тенантов
+func (s *GormStore) RunJanitor(ctx context.Context, interval time.Duration) {
+	log := logger.Logger().WithFields(logger.LogOptions{
+		Pkg:  "IdempotencyStore",
+		Func: "RunJanitor",
+		Ctx:  ctx,
+	})
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			var purged int64
+			err := persistance.CrossTenant(ctx, s.db, func(tx *gorm.DB) error {
+				res := tx.Where("expires_at < ?", time.Now()).Delete(&KeyModel{})
+				purged = res.RowsAffected
+				return res.Error
+			})
+			if err != nil {
+				log.Errorf("failed to purge expired idempotency keys: %v", err)
+				continue
+			}
+			if purged > 0 {
+				log.Debugf("purged %d expired idempotency keys", purged)
+			}
+		}
+	}
+}
@@ -0,0 +1,130 @@
+//go:build integration
+// +build integration
+
+package idempotency
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/tenant"
+	common_test "github.com/end1essrage/efmob-tz/pkg/common/testing"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func newStore(t *testing.T) (*GormStore, *gorm.DB) {
+	t.Helper()
+	ctx := context.Background()
+	container, dsn, err := common_test.SetupPostgresContainer(ctx)
+	if err != nil {
+		t.Fatalf("failed to start postgres container: %v", err)
+	}
+	t.Cleanup(func() { container.Terminate(ctx) })
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+
+	store := NewGormStore(db)
+	require.NoError(t, store.Migrate())
+	return store, db
+}
+
+func TestGormStore_AcquireCompleteRelease(t *testing.T) {
+	ctx := tenant.WithTenant(context.Background(), "acme")
+	store, _ := newStore(t)
+
+	// первый запрос занимает ключ
+	rec, err := store.Acquire(ctx, "k1", "hash", time.Hour, time.Minute)
+	require.NoError(t, err)
+	require.Nil(t, rec)
+
+	// пока запрос выполняется, повтор видит незавершенную запись
+	rec, err = store.Acquire(ctx, "k1", "hash", time.Hour, time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, rec)
+	require.False(t, rec.Completed)
+	require.Equal(t, "hash", rec.RequestHash)
+
+	// сохраненный ответ возвращается повтору
+	require.NoError(t, store.Complete(ctx, "k1", 201, "application/json", []byte(`{"id":1}`)))
+	rec, err = store.Acquire(ctx, "k1", "other", time.Hour, time.Minute)
+	require.NoError(t, err)
+	require.True(t, rec.Completed)
+	require.Equal(t, 201, rec.StatusCode)
+	require.Equal(t, "application/json", rec.ContentType)
+	require.Equal(t, []byte(`{"id":1}`), rec.Body)
+	require.Equal(t, "hash", rec.RequestHash)
+
+	// завершенный ключ не освобождается
+	require.NoError(t, store.Release(ctx, "k1"))
+	rec, err = store.Acquire(ctx, "k1", "hash", time.Hour, time.Minute)
+	require.NoError(t, err)
+	require.True(t, rec.Completed)
+
+	// незавершенный освобождается и снова доступен
+	_, err = store.Acquire(ctx, "k2", "hash", time.Hour, time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, store.Release(ctx, "k2"))
+	rec, err = store.Acquire(ctx, "k2", "hash", time.Hour, time.Minute)
+	require.NoError(t, err)
+	require.Nil(t, rec)
+
+	require.ErrorContains(t, store.Complete(ctx, "missing", 200, "", nil), "not found")
+}
+
+func TestGormStore_ExpiredLeaseIsTakenOver(t *testing.T) {
+	ctx := tenant.WithTenant(context.Background(), "acme")
+	store, db := newStore(t)
+
+	// запрос занял ключ и упал, не освободив его
+	rec, err := store.Acquire(ctx, "k1", "hash", time.Hour, time.Minute)
+	require.NoError(t, err)
+	require.Nil(t, rec)
+	require.NoError(t, db.Model(&KeyModel{}).Where("key = ?", "k1").
+		Update("locked_until", time.Now().Add(-time.Second)).Error)
+
+	// другой запрос с тем же ключом ключ не забирает
+	rec, err = store.Acquire(ctx, "k1", "other", time.Hour, time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, rec)
+	require.Equal(t, "hash", rec.RequestHash)
+
+	// повтор того же запроса забирает ключ, дальше он снова занят
+	rec, err = store.Acquire(ctx, "k1", "hash", time.Hour, time.Minute)
+	require.NoError(t, err)
+	require.Nil(t, rec)
+
+	rec, err = store.Acquire(ctx, "k1", "hash", time.Hour, time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, rec)
+	require.False(t, rec.Completed)
+
+	// истекший ключ доступен с любым запросом
+	require.NoError(t, store.Complete(ctx, "k1", 201, "", nil))
+	require.NoError(t, db.Model(&KeyModel{}).Where("key = ?", "k1").
+		Update("expires_at", time.Now().Add(-time.Second)).Error)
+	rec, err = store.Acquire(ctx, "k1", "new", time.Hour, time.Minute)
+	require.NoError(t, err)
+	require.Nil(t, rec)
+}
+
+func TestGormStore_KeysArePerTenant(t *testing.T) {
+	store, _ := newStore(t)
+	acme := tenant.WithTenant(context.Background(), "acme")
+	globex := tenant.WithTenant(context.Background(), "globex")
+
+	_, err := store.Acquire(acme, "acme:k1", "hash", time.Hour, time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, store.Complete(acme, "acme:k1", 201, "", []byte("acme")))
+
+	// чужой тенант запись не видит и не может ее завершить
+	require.Error(t, store.Complete(globex, "acme:k1", 500, "", nil))
+	require.NoError(t, store.Release(globex, "acme:k1"))
+
+	rec, err := store.Acquire(acme, "acme:k1", "hash", time.Hour, time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, []byte("acme"), rec.Body)
+}
@@ -0,0 +1,334 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/logger"
+)
+
+// lockKey ключ pg_advisory_lock: реплики, стартующие одновременно, применяют миграции по очереди
+const lockKey int64 = 0x7375627363686d61 // "subschma"
+
+// Migration версия схемы из пары файлов <version>_<name>.up.sql и <version>_<name>.down.sql
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status состояние миграции в базе
+type Status struct {
+	Version int64
+	Name    string
+	// AppliedAt nil - миграция не применена
+	AppliedAt *time.Time
+	// Unknown применена, но ее нет среди файлов - база новее бинарника
+	Unknown bool
+}
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Load читает миграции из корня fsys, отсортированные по версии
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		m := fileName.FindStringSubmatch(e.Name())
+		if m == nil {
+			return nil, fmt.Errorf("migration %q: want <version>_<name>.up.sql or .down.sql", e.Name())
+		}
+		version, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %q: version must be positive", e.Name())
+		}
+		body, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		}
+		if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %d: names %q and %q differ", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(body)
+		} else {
+			mig.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" || mig.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s: both up and down files are required", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrator применяет и откатывает миграции, учет ведется в таблице schema_migrations
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+func New(db *sql.DB, migrations []Migration) *Migrator {
+	return &Migrator{db: db, migrations: migrations}
+}
+
+// Latest последняя известная версия, 0 - миграций нет
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Up применяет все неприменённые миграции. Применённые миграции из более нового бинарника
+// не трогает - старая реплика при раскатке стартует на уже обновленной схеме
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		return m.migrate(ctx, conn, applied, m.Latest(), false)
+	})
+}
+
+// Down откатывает последнюю применённую миграцию
+func (m *Migrator) Down(ctx context.Context) error {
+	return m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			return nil
+		}
+
+		var last int64
+		for v := range applied {
+			last = max(last, v)
+		}
+		var target int64
+		for v := range applied {
+			if v < last {
+				target = max(target, v)
+			}
+		}
+		return m.migrate(ctx, conn, applied, target, true)
+	})
+}
+
+// To приводит схему к версии: применяет миграции до нее включительно и откатывает более новые.
+// 0 - откатить все
+func (m *Migrator) To(ctx context.Context, version int64) error {
+	if version != 0 && !m.known(version) {
+		return fmt.Errorf("unknown migration version %d", version)
+	}
+	return m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		return m.migrate(ctx, conn, applied, version, true)
+	})
+}
+
+// Status состояние всех известных миграций и применённых, но неизвестных бинарнику
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	// без таблицы все миграции не применены, статус ничего не создает
+	var exists bool
+	if err := m.db.QueryRowContext(ctx, "SELECT to_regclass('schema_migrations') IS NOT NULL").Scan(&exists); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int64]time.Time)
+	if exists {
+		rows, err := m.db.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var v int64
+			var at time.Time
+			if err := rows.Scan(&v, &at); err != nil {
+				return nil, err
+			}
+			applied[v] = at
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		s := Status{Version: mig.Version, Name: mig.Name}
+		if at, ok := applied[mig.Version]; ok {
+			s.AppliedAt = &at
+			delete(applied, mig.Version)
+		}
+		statuses = append(statuses, s)
+	}
+	for v, at := range applied {
+		statuses = append(statuses, Status{Version: v, AppliedAt: &at, Unknown: true})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+func (m *Migrator) known(version int64) bool {
+	for _, mig := range m.migrations {
+		if mig.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// plan миграции для перехода к target: применить по возрастанию, откатить по убыванию.
+// Без rollback только применяет
+func (m *Migrator) plan(applied map[int64]bool, target int64, rollback bool) (up, down []Migration, err error) {
+	for _, mig := range m.migrations {
+		if mig.Version <= target && !applied[mig.Version] {
+			up = append(up, mig)
+		}
+	}
+	if !rollback {
+		return up, nil, nil
+	}
+
+	for v := range applied {
+		if v > target && !m.known(v) {
+			return nil, nil, fmt.Errorf("migration %d is applied but unknown to this binary, cannot roll it back", v)
+		}
+	}
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		if mig := m.migrations[i]; mig.Version > target && applied[mig.Version] {
+			down = append(down, mig)
+		}
+	}
+	return up, down, nil
+}
+
+func (m *Migrator) migrate(ctx context.Context, conn *sql.Conn, applied map[int64]bool, target int64, rollback bool) error {
+	log := logger.Logger().WithFields(logger.LogOptions{Pkg: "migrate", Func: "migrate", Ctx: ctx})
+
+	up, down, err := m.plan(applied, target, rollback)
+	if err != nil {
+		return err
+	}
+
+	for _, mig := range down {
+		err := inTx(ctx, conn, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, mig.Down); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = $1", mig.Version)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("rollback %d_%s: %w", mig.Version, mig.Name, err)
+		}
+		log.Infof("миграция %d_%s откачена", mig.Version, mig.Name)
+	}
+
+	for _, mig := range up {
+		err := inTx(ctx, conn, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, mig.Up); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", mig.Version, mig.Name)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("apply %d_%s: %w", mig.Version, mig.Name, err)
+		}
+		log.Infof("миграция %d_%s применена", mig.Version, mig.Name)
+	}
+
+	return nil
+}
+
+// locked выполняет fn на одном соединении под advisory lock. Блокировка сессионная,
+// поэтому и она, и миграции идут через conn, а не через пул
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockKey); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer func() {
+		// контекст мог быть отменен, снимаем блокировку в любом случае
+		_, _ = conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", lockKey)
+	}()
+
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version bigint PRIMARY KEY,
+		name text NOT NULL,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	)`); err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int64]bool, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]bool)
+	for rows.Next() {
+		var v int64
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		applied[v] = true
+	}
+	return applied, rows.Err()
+}
+
+// inTx миграция и запись о ней фиксируются вместе: упавшая миграция не оставляет схему наполовину
+func inTx(ctx context.Context, conn *sql.Conn, fn func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,75 @@
+package migrate
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func file(s string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(s)} }
+
+func TestLoad(t *testing.T) {
+	migrations, err := Load(fstest.MapFS{
+		"0002_trgm.up.sql":   file("CREATE INDEX"),
+		"0002_trgm.down.sql": file("DROP INDEX"),
+		"0001_init.up.sql":   file("CREATE TABLE"),
+		"0001_init.down.sql": file("DROP TABLE"),
+	})
+	require.NoError(t, err)
+	require.Equal(t, []Migration{
+		{Version: 1, Name: "init", Up: "CREATE TABLE", Down: "DROP TABLE"},
+		{Version: 2, Name: "trgm", Up: "CREATE INDEX", Down: "DROP INDEX"},
+	}, migrations)
+
+	for name, fsys := range map[string]fstest.MapFS{
+		"bad name":     {"init.up.sql": file("x"), "init.down.sql": file("x")},
+		"zero version": {"0_init.up.sql": file("x"), "0_init.down.sql": file("x")},
+		"no down":      {"0001_init.up.sql": file("x")},
+		"names differ": {"0001_init.up.sql": file("x"), "0001_other.down.sql": file("x")},
+	} {
+		_, err := Load(fsys)
+		require.Error(t, err, name)
+	}
+}
+
+func TestPlan(t *testing.T) {
+	m := New(nil, []Migration{{Version: 1}, {Version: 2}, {Version: 3}})
+	versions := func(ms []Migration) []int64 {
+		var out []int64
+		for _, mig := range ms {
+			out = append(out, mig.Version)
+		}
+		return out
+	}
+
+	up, down, err := m.plan(map[int64]bool{1: true}, 3, true)
+	require.NoError(t, err)
+	require.Equal(t, []int64{2, 3}, versions(up))
+	require.Empty(t, down)
+
+	// откат идет от новых к старым
+	up, down, err = m.plan(map[int64]bool{1: true, 2: true, 3: true}, 1, true)
+	require.NoError(t, err)
+	require.Empty(t, up)
+	require.Equal(t, []int64{3, 2}, versions(down))
+
+	// пропущенная старая миграция догоняется
+	up, _, err = m.plan(map[int64]bool{1: true, 3: true}, 3, true)
+	require.NoError(t, err)
+	require.Equal(t, []int64{2}, versions(up))
+
+	// миграцию из более нового бинарника откатить нечем
+	_, _, err = m.plan(map[int64]bool{1: true, 4: true}, 3, true)
+	require.ErrorContains(t, err, "unknown")
+	_, _, err = m.plan(map[int64]bool{1: true, 4: true}, 0, true)
+	require.Error(t, err)
+
+	// up на базе новее бинарника догоняет свое и не откатывает чужое
+	up, down, err = m.plan(map[int64]bool{1: true, 4: true}, 3, false)
+	require.NoError(t, err)
+	require.Equal(t, []int64{2, 3}, versions(up))
+	require.Empty(t, down)
+
+	require.Equal(t, int64(3), m.Latest())
+}
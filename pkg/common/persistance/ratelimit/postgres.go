@@ -0,0 +1,95 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/logger"
+	"github.com/end1essrage/efmob-tz/pkg/common/ratelimit"
+	"gorm.io/gorm"
+)
+
+// BucketModel ведро клиента, хранится только теоретическое время прихода GCRA.
+// Таблица служебная: лимит проверяется и для запросов без тенанта, тенант уже входит в ключ
+type BucketModel struct {
+	Key string    `gorm:"type:text;primaryKey"`
+	TAT time.Time `gorm:"column:tat;not null;index"`
+}
+
+func (BucketModel) TableName() string {
+	return "rate_limits"
+}
+
+// GormStore общие для реплик ведра в postgres
+type GormStore struct {
+	db *gorm.DB
+}
+
+var _ ratelimit.Store = (*GormStore)(nil)
+
+func NewGormStore(db *gorm.DB) *GormStore {
+	return &GormStore{db: db}
+}
+
+// Migrate создаёт таблицу
+func (s *GormStore) Migrate() error {
+	return s.db.AutoMigrate(&BucketModel{})
+}
+
+// Take решает по GCRA под блокировкой строки ведра - параллельные запросы реплик к одному ключу идут по очереди.
+// now - часы реплики, расхождение часов между репликами сдвигает лимит на ту же величину
+func (s *GormStore) Take(ctx context.Context, key string, lim ratelimit.Limit, now time.Time) (ratelimit.Decision, error) {
+	var d ratelimit.Decision
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// новое ведро - полное. Пустой update существующего ведра блокирует строку до конца транзакции
+		// и возвращает его tat одним запросом - очистка не удалит ведро между чтением и записью
+		var m BucketModel
+		err := tx.Raw(`INSERT INTO rate_limits (key, tat) VALUES (?, ?)
+			ON CONFLICT (key) DO UPDATE SET tat = rate_limits.tat
+			RETURNING key, tat`, key, now).Scan(&m).Error
+		if err != nil {
+			return err
+		}
+
+		var tat time.Time
+		tat, d = ratelimit.GCRA(m.TAT, lim, now)
+		if !d.Allowed {
+			return nil
+		}
+		return tx.Model(&BucketModel{}).Where("key = ?", key).Update("tat", tat).Error
+	})
+	if err != nil {
+		return ratelimit.Decision{}, err
+	}
+
+	return d, nil
+}
+
+// RunJanitor периодически удаляет полные ведра - новое ведро для такого клиента будет таким же
+func (s *GormStore) RunJanitor(ctx context.Context, interval time.Duration) {
+	log := logger.Logger().WithFields(logger.LogOptions{
+		Pkg:  "RateLimitStore",
+		Func: "RunJanitor",
+		Ctx:  ctx,
+	})
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			res := s.db.WithContext(ctx).Where("tat < ?", time.Now()).Delete(&BucketModel{})
+			if res.Error != nil {
+				log.Errorf("failed to purge full rate limit buckets: %v", res.Error)
+				continue
+			}
+			if res.RowsAffected > 0 {
+				log.Debugf("purged %d full rate limit buckets", res.RowsAffected)
+			}
+		}
+	}
+}
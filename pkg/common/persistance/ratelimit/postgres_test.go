@@ -0,0 +1,135 @@
+//go:build integration
+// +build integration
+
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/ratelimit"
+	common_test "github.com/end1essrage/efmob-tz/pkg/common/testing"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestGormStore_Take(t *testing.T) {
+	ctx := context.Background()
+	container, dsn, err := common_test.SetupPostgresContainer(ctx)
+	if err != nil {
+		t.Fatalf("failed to start postgres container: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+
+	store := NewGormStore(db)
+	require.NoError(t, store.Migrate())
+
+	// 60 в минуту - токен в секунду, ведро на 3
+	lim, err := ratelimit.ParseLimit("60/1m:3")
+	require.NoError(t, err)
+	now := time.Now().Truncate(time.Millisecond)
+
+	for i := 2; i >= 0; i-- {
+		d, err := store.Take(ctx, "a", lim, now)
+		require.NoError(t, err)
+		require.True(t, d.Allowed)
+		require.Equal(t, i, d.Remaining)
+	}
+
+	d, err := store.Take(ctx, "a", lim, now)
+	require.NoError(t, err)
+	require.False(t, d.Allowed)
+	require.Equal(t, time.Second, d.RetryAfter)
+
+	d, err = store.Take(ctx, "a", lim, now.Add(time.Second))
+	require.NoError(t, err)
+	require.True(t, d.Allowed)
+
+	d, err = store.Take(ctx, "b", lim, now)
+	require.NoError(t, err)
+	require.Equal(t, 2, d.Remaining)
+}
+
+func TestGormStore_SharedBetweenReplicas(t *testing.T) {
+	ctx := context.Background()
+	container, dsn, err := common_test.SetupPostgresContainer(ctx)
+	if err != nil {
+		t.Fatalf("failed to start postgres container: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	// каждая реплика со своим подключением
+	var replicas []*GormStore
+	for i := 0; i < 3; i++ {
+		db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+		require.NoError(t, err)
+		replicas = append(replicas, NewGormStore(db))
+	}
+	require.NoError(t, replicas[0].Migrate())
+
+	lim, err := ratelimit.ParseLimit("10/1h")
+	require.NoError(t, err)
+	now := time.Now()
+
+	var allowed atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 60; i++ {
+		wg.Add(1)
+		go func(store *GormStore) {
+			defer wg.Done()
+			d, err := store.Take(ctx, "client", lim, now)
+			if err != nil {
+				t.Errorf("take: %v", err)
+				return
+			}
+			if d.Allowed {
+				allowed.Add(1)
+			}
+		}(replicas[i%len(replicas)])
+	}
+	wg.Wait()
+
+	// лимит общий, а не по 10 на реплику
+	require.Equal(t, int32(10), allowed.Load())
+}
+
+func TestGormStore_Janitor(t *testing.T) {
+	ctx := context.Background()
+	container, dsn, err := common_test.SetupPostgresContainer(ctx)
+	if err != nil {
+		t.Fatalf("failed to start postgres container: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+
+	store := NewGormStore(db)
+	require.NoError(t, store.Migrate())
+
+	lim, err := ratelimit.ParseLimit("1/1h")
+	require.NoError(t, err)
+
+	// ведро "full" уже заполнилось, "busy" - нет
+	_, err = store.Take(ctx, "full", lim, time.Now().Add(-2*time.Hour))
+	require.NoError(t, err)
+	_, err = store.Take(ctx, "busy", lim, time.Now())
+	require.NoError(t, err)
+
+	janitorCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go store.RunJanitor(janitorCtx, 50*time.Millisecond)
+
+	require.Eventually(t, func() bool {
+		var keys []string
+		require.NoError(t, db.Model(&BucketModel{}).Pluck("key", &keys).Error)
+		return len(keys) == 1 && keys[0] == "busy"
+	}, 5*time.Second, 50*time.Millisecond)
+}
@@ -1,8 +1,13 @@
 package persistance
 
+// MaxPageLimit максимальный размер страницы
+const MaxPageLimit = 1000
+
 type Pagination struct {
 	Limit  int
 	Offset int
+	// After курсор keyset пагинации, если задан - Offset не используется
+	After *Cursor
 }
 
 type SortingDirection string
@@ -0,0 +1,89 @@
+package persistance
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/tenant"
+	"gorm.io/gorm"
+)
+
+// TenantRole роль запросов тенанта. Суперпользователь обходит RLS,
+// поэтому транзакции тенанта переключаются на эту роль без привилегий
+const TenantRole = "app_tenant"
+
+// crossTenantPolicy условие политики cross_tenant: строки всех тенантов видны
+// только транзакциям CrossTenant, роль тенанта его не выполнит
+var crossTenantPolicy = fmt.Sprintf("current_user <> '%s' AND current_setting('app.cross_tenant', true) = 'on'", TenantRole)
+
+// EnableTenantRLS включает изоляцию таблиц: роль тенанта видит и пишет только строки
+// с tenant_id из app.tenant_id. RLS принудительная - владелец таблиц тоже не видит строк,
+// кроме транзакций CrossTenant. Вызывается из Migrate после создания таблиц.
+// Колонка в моделях: TenantID `gorm:"type:text;not null;default:'default';index"`,
+// default заполняет строки, созданные до разделения по тенантам
+func EnableTenantRLS(db *gorm.DB, tables ...string) error {
+	stmts := []string{
+		fmt.Sprintf(`DO $$ BEGIN
+			IF NOT EXISTS (SELECT FROM pg_roles WHERE rolname = '%s') THEN
+				CREATE ROLE %s NOLOGIN;
+			END IF;
+		END $$`, TenantRole, TenantRole),
+		// без членства в роли SET ROLE недоступен обычному пользователю
+		fmt.Sprintf("GRANT %s TO CURRENT_USER", TenantRole),
+	}
+	for _, t := range tables {
+		stmts = append(stmts,
+			fmt.Sprintf("GRANT SELECT, INSERT, UPDATE, DELETE ON %s TO %s", t, TenantRole),
+			fmt.Sprintf("ALTER TABLE %s ENABLE ROW LEVEL SECURITY", t),
+			fmt.Sprintf("ALTER TABLE %s FORCE ROW LEVEL SECURITY", t),
+			fmt.Sprintf("DROP POLICY IF EXISTS tenant_isolation ON %s", t),
+			fmt.Sprintf(`CREATE POLICY tenant_isolation ON %s TO %s
+				USING (tenant_id = current_setting('app.tenant_id', true))
+				WITH CHECK (tenant_id = current_setting('app.tenant_id', true))`, t, TenantRole),
+			fmt.Sprintf("DROP POLICY IF EXISTS cross_tenant ON %s", t),
+			fmt.Sprintf("CREATE POLICY cross_tenant ON %s USING (%s) WITH CHECK (%s)", t, crossTenantPolicy, crossTenantPolicy),
+		)
+	}
+
+	for _, s := range stmts {
+		if err := db.Exec(s).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetTenant переключает транзакцию на роль тенанта из контекста.
+// set_config(..., true) - это SET LOCAL app.tenant_id с параметром вместо подстановки в SQL
+func SetTenant(ctx context.Context, tx *gorm.DB) error {
+	id := tenant.Current(ctx)
+	if !tenant.Valid(id) {
+		return tenant.ErrInvalidTenant
+	}
+	if err := tx.Exec("SET LOCAL ROLE " + TenantRole).Error; err != nil {
+		return err
+	}
+	return tx.Exec("SELECT set_config('app.tenant_id', ?, true)", id).Error
+}
+
+// InTenant выполняет fn в транзакции тенанта из контекста
+func InTenant(ctx context.Context, db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := SetTenant(ctx, tx); err != nil {
+			return err
+		}
+		return fn(tx)
+	})
+}
+
+// CrossTenant выполняет fn в транзакции, которой видны строки всех тенантов.
+// Нужна фоновым задачам и поиску ключа API до определения тенанта - остальные запросы
+// владельца таблиц RLS не пропускает, обход только явный
+func CrossTenant(ctx context.Context, db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT set_config('app.cross_tenant', 'on', true)").Error; err != nil {
+			return err
+		}
+		return fn(tx)
+	})
+}
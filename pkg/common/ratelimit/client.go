@@ -0,0 +1,38 @@
+package ratelimit
+
+import (
+	"context"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/auth"
+	"github.com/end1essrage/efmob-tz/pkg/common/tenant"
+	"github.com/google/uuid"
+)
+
+const (
+	// AnyRoute маршрут без своего правила - общее ведро клиента
+	AnyRoute = "*"
+	// PreAuthPrefix ведра лимита по IP до аутентификации, отдельные от ведер клиентов даже в общем store
+	PreAuthPrefix = "preauth|"
+)
+
+// Key ведро клиента client на маршруте route
+func Key(route, client string) string {
+	return route + "|" + client
+}
+
+// ClientKey клиент вызова: ключ API, пользователь тенанта или addr без аутентификации.
+// Общий для http и grpc - у клиента одни ведра на оба транспорта
+func ClientKey(ctx context.Context, addr string) string {
+	if p, ok := auth.FromContext(ctx); ok {
+		if p.APIKeyID != uuid.Nil {
+			return "key:" + p.APIKeyID.String()
+		}
+		return "user:" + tenant.Current(ctx) + ":" + p.Subject
+	}
+	return IPKey(addr)
+}
+
+// IPKey клиент по адресу
+func IPKey(addr string) string {
+	return "ip:" + addr
+}
@@ -0,0 +1,65 @@
+// Package ratelimit лимиты запросов: ведра токенов, алгоритм GCRA и контракт хранилища ведер
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit ведро токенов: Burst запросов подряд, пополняется на Requests токенов за Period
+type Limit struct {
+	Requests int
+	Period   time.Duration
+	// Burst емкость ведра, 0 - Requests
+	Burst int
+}
+
+// Capacity емкость ведра
+func (lim Limit) Capacity() int {
+	if lim.Burst > 0 {
+		return lim.Burst
+	}
+	return lim.Requests
+}
+
+// Interval время пополнения одного токена
+func (lim Limit) Interval() time.Duration {
+	return lim.Period / time.Duration(lim.Requests)
+}
+
+func (lim Limit) Validate() error {
+	if lim.Requests <= 0 || lim.Period < time.Duration(lim.Requests) || lim.Burst < 0 {
+		return fmt.Errorf("invalid rate limit %d/%s burst %d", lim.Requests, lim.Period, lim.Burst)
+	}
+	return nil
+}
+
+func (lim Limit) String() string {
+	return fmt.Sprintf("%d/%s:%d", lim.Requests, lim.Period, lim.Capacity())
+}
+
+// ParseLimit разбирает лимит вида 100/1m или 100/1m:30, где 30 - burst
+func ParseLimit(s string) (Limit, error) {
+	rate, burst, hasBurst := strings.Cut(strings.TrimSpace(s), ":")
+	n, period, ok := strings.Cut(rate, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("rate limit %q: want <requests>/<period>[:<burst>]", s)
+	}
+
+	var lim Limit
+	var err error
+	if lim.Requests, err = strconv.Atoi(n); err != nil {
+		return Limit{}, fmt.Errorf("rate limit %q: %w", s, err)
+	}
+	if lim.Period, err = time.ParseDuration(period); err != nil {
+		return Limit{}, fmt.Errorf("rate limit %q: %w", s, err)
+	}
+	if hasBurst {
+		if lim.Burst, err = strconv.Atoi(burst); err != nil {
+			return Limit{}, fmt.Errorf("rate limit %q: %w", s, err)
+		}
+	}
+	return lim, lim.Validate()
+}
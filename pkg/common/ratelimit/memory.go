@@ -0,0 +1,51 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore ведра в памяти процесса: у каждой реплики свой лимит
+type MemoryStore struct {
+	sweepInterval time.Duration
+
+	mu        sync.Mutex
+	tats      map[string]time.Time
+	lastSweep time.Time
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore sweepInterval - как часто удалять полные ведра неактивных клиентов
+func NewMemoryStore(sweepInterval time.Duration) *MemoryStore {
+	return &MemoryStore{
+		sweepInterval: sweepInterval,
+		tats:          make(map[string]time.Time),
+	}
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, lim Limit, now time.Time) (Decision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+
+	tat, d := GCRA(s.tats[key], lim, now)
+	s.tats[key] = tat
+	return d, nil
+}
+
+// sweep удаляет полные ведра: новое ведро для такого клиента будет таким же
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < s.sweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, tat := range s.tats {
+		if !tat.After(now) {
+			delete(s.tats, key)
+		}
+	}
+}
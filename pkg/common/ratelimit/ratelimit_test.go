@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func mustLimit(t *testing.T, s string) Limit {
+	lim, err := ParseLimit(s)
+	require.NoError(t, err)
+	return lim
+}
+
+func TestParseLimit(t *testing.T) {
+	require.Equal(t, Limit{Requests: 100, Period: time.Minute, Burst: 30}, mustLimit(t, "100/1m:30"))
+	require.Equal(t, Limit{Requests: 5, Period: time.Second}, mustLimit(t, " 5/1s "))
+
+	for _, s := range []string{"", "100", "100/", "x/1m", "100/1m:x", "0/1m", "100/0s", "100/1m:-1"} {
+		_, err := ParseLimit(s)
+		require.Error(t, err, s)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(time.Minute)
+	now := time.Unix(1_700_000_000, 0)
+
+	// 60 в минуту - токен в секунду, ведро на 3
+	lim := mustLimit(t, "60/1m:3")
+
+	take := func(key string) Decision {
+		d, err := store.Take(ctx, key, lim, now)
+		require.NoError(t, err)
+		return d
+	}
+
+	for i := 2; i >= 0; i-- {
+		d := take("a")
+		require.True(t, d.Allowed)
+		require.Equal(t, i, d.Remaining)
+	}
+	require.Equal(t, 2, take("b").Remaining, "другой ключ - свое ведро")
+
+	d := take("a")
+	require.False(t, d.Allowed)
+	require.Equal(t, 0, d.Remaining)
+	require.Equal(t, time.Second, d.RetryAfter)
+	require.Equal(t, 3*time.Second, d.Reset)
+
+	now = now.Add(1500 * time.Millisecond)
+	d = take("a")
+	require.True(t, d.Allowed)
+	require.Equal(t, 0, d.Remaining)
+	require.Equal(t, 2500*time.Millisecond, d.Reset)
+
+	// ведро не переполняется
+	now = now.Add(time.Hour)
+	require.Equal(t, 2, take("a").Remaining)
+}
+
+func TestMemoryStore_EvictsFullBuckets(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(time.Minute)
+	now := time.Unix(1_700_000_000, 0)
+	lim := mustLimit(t, "1/1h")
+
+	take := func(key string) Decision {
+		d, err := store.Take(ctx, key, lim, now)
+		require.NoError(t, err)
+		return d
+	}
+
+	take("a")
+	now = now.Add(time.Minute)
+	take("b")
+	// "a" простоял минуту, но еще не заполнился - удалять нельзя, иначе лимит обнулится
+	require.Len(t, store.tats, 2)
+	require.False(t, take("a").Allowed)
+
+	now = now.Add(2 * time.Hour)
+	take("c")
+	require.Len(t, store.tats, 1)
+	require.Contains(t, store.tats, "c")
+}
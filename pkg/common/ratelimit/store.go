@@ -0,0 +1,52 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Decision результат запроса токена
+type Decision struct {
+	Allowed bool
+	Limit   Limit
+	// Remaining токенов в ведре после запроса
+	Remaining int
+	// Reset до полного ведра
+	Reset time.Duration
+	// RetryAfter до следующего токена, если запрос отклонен
+	RetryAfter time.Duration
+}
+
+// Store состояние ведер. Общий для реплик store делит между ними один лимит
+type Store interface {
+	// Take забирает токен из ведра key на момент now
+	Take(ctx context.Context, key string, lim Limit, now time.Time) (Decision, error)
+}
+
+// GCRA решение по ведру с теоретическим временем прихода tat (generic cell rate algorithm):
+// ведро полное, когда tat <= now, каждый запрос сдвигает tat на интервал пополнения.
+// Вместо счетчика токенов хранится одно время - удобно держать в общем хранилище.
+// Возвращает новый tat, при отказе он не меняется
+func GCRA(tat time.Time, lim Limit, now time.Time) (time.Time, Decision) {
+	interval := lim.Interval()
+	window := interval * time.Duration(lim.Capacity())
+
+	if tat.Before(now) {
+		tat = now
+	}
+	// раньше allowAt запрос в ведро не помещается
+	allowAt := tat.Add(interval - window)
+
+	d := Decision{Limit: lim}
+	if now.Before(allowAt) {
+		d.RetryAfter = allowAt.Sub(now)
+		d.Reset = tat.Sub(now)
+		return tat, d
+	}
+
+	tat = tat.Add(interval)
+	d.Allowed = true
+	d.Remaining = int(now.Sub(allowAt) / interval)
+	d.Reset = tat.Sub(now)
+	return tat, d
+}
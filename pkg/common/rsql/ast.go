@@ -0,0 +1,47 @@
+package rsql
+
+// Node узел дерева выражения: *Logical или *Comparison
+type Node interface {
+	node()
+}
+
+type LogicalOp string
+
+const (
+	And LogicalOp = ";"
+	Or  LogicalOp = ","
+)
+
+// Logical группа условий, объединённых через AND или OR
+type Logical struct {
+	Op       LogicalOp
+	Children []Node
+}
+
+// Comparison условие вида selector=op=args
+type Comparison struct {
+	Selector string
+	// Operator в каноническом виде: "==", "!=" или "=name=", алиасы <, <=, >, >= приводятся к =lt=, =le=, =gt=, =ge=
+	Operator string
+	Args     []string
+	// Pos позиция селектора в выражении, с 1
+	Pos int
+}
+
+func (*Logical) node()    {}
+func (*Comparison) node() {}
+
+// Walk обходит все сравнения дерева слева направо
+func Walk(n Node, fn func(*Comparison) error) error {
+	switch v := n.(type) {
+	case *Comparison:
+		return fn(v)
+	case *Logical:
+		for _, c := range v.Children {
+			if err := Walk(c, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
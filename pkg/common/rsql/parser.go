@@ -0,0 +1,316 @@
+// Package rsql разбор выражений фильтрации RSQL/FIQL в дерево.
+//
+//	or         = and { "," and }
+//	and        = constraint { ";" constraint }
+//	constraint = "(" or ")" | comparison
+//	comparison = selector operator arguments
+//	operator   = "==" | "!=" | "=" name "=" | "<" | "<=" | ">" | ">="
+//	arguments  = "(" value { "," value } ")" | value
+//	value      = unreserved | '"' ... '"' | "'" ... "'"
+//
+// Значения в дереве остаются строками, проверка полей, операторов и типов - на стороне потребителя
+package rsql
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+const (
+	// MaxLength максимальная длина выражения в символах
+	MaxLength = 4096
+	// MaxDepth максимальная вложенность скобок
+	MaxDepth = 16
+	// MaxComparisons максимальное кол-во сравнений в выражении
+	MaxComparisons = 64
+)
+
+var ErrSyntax = errors.New("invalid filter expression")
+
+// ParseError ошибка разбора с позицией символа (с 1)
+type ParseError struct {
+	Pos int
+	Msg string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Msg, e.Pos)
+}
+
+func (e *ParseError) Unwrap() error { return ErrSyntax }
+
+var operatorAliases = map[string]string{
+	"<":  "=lt=",
+	"<=": "=le=",
+	">":  "=gt=",
+	">=": "=ge=",
+}
+
+// Parse разбирает выражение в дерево. Логические узлы с одним потомком схлопываются
+func Parse(s string) (Node, error) {
+	p := &parser{src: []rune(s)}
+	if len(p.src) > MaxLength {
+		return nil, p.errorf(MaxLength, "expression longer than %d characters", MaxLength)
+	}
+
+	p.skipSpaces()
+	if p.eof() {
+		return nil, p.errorf(p.pos, "empty expression")
+	}
+
+	n, err := p.parseOr(0)
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpaces()
+	if !p.eof() {
+		return nil, p.errorf(p.pos, "unexpected %q", p.peek())
+	}
+
+	return n, nil
+}
+
+type parser struct {
+	src         []rune
+	pos         int
+	comparisons int
+}
+
+func (p *parser) eof() bool  { return p.pos >= len(p.src) }
+func (p *parser) peek() rune { return p.src[p.pos] }
+
+func (p *parser) errorf(pos int, format string, args ...interface{}) error {
+	return &ParseError{Pos: pos + 1, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) skipSpaces() {
+	for !p.eof() && unicode.IsSpace(p.peek()) {
+		p.pos++
+	}
+}
+
+// consume пропускает пробелы и символ r, если он следующий
+func (p *parser) consume(r rune) bool {
+	p.skipSpaces()
+	if !p.eof() && p.peek() == r {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) parseOr(depth int) (Node, error) {
+	return p.parseLogical(depth, Or, p.parseAnd)
+}
+
+func (p *parser) parseAnd(depth int) (Node, error) {
+	return p.parseLogical(depth, And, p.parseConstraint)
+}
+
+func (p *parser) parseLogical(depth int, op LogicalOp, next func(int) (Node, error)) (Node, error) {
+	first, err := next(depth)
+	if err != nil {
+		return nil, err
+	}
+
+	children := []Node{first}
+	for p.consume(rune(op[0])) {
+		n, err := next(depth)
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, n)
+	}
+
+	if len(children) == 1 {
+		return first, nil
+	}
+	return &Logical{Op: op, Children: children}, nil
+}
+
+func (p *parser) parseConstraint(depth int) (Node, error) {
+	p.skipSpaces()
+	if p.eof() {
+		return nil, p.errorf(p.pos, "unexpected end of expression")
+	}
+
+	if p.peek() != '(' {
+		return p.parseComparison()
+	}
+
+	open := p.pos
+	if depth+1 > MaxDepth {
+		return nil, p.errorf(open, "nesting deeper than %d", MaxDepth)
+	}
+	p.pos++
+
+	n, err := p.parseOr(depth + 1)
+	if err != nil {
+		return nil, err
+	}
+	if !p.consume(')') {
+		return nil, p.errorf(p.pos, "expected ')' to close '(' from position %d", open+1)
+	}
+	return n, nil
+}
+
+func (p *parser) parseComparison() (Node, error) {
+	start := p.pos
+	selector := p.readUnreserved()
+	if selector == "" {
+		return nil, p.errorf(p.pos, "expected selector")
+	}
+
+	p.comparisons++
+	if p.comparisons > MaxComparisons {
+		return nil, p.errorf(start, "more than %d comparisons", MaxComparisons)
+	}
+
+	p.skipSpaces()
+	op, err := p.readOperator()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpaces()
+	args, err := p.readArguments()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Comparison{Selector: selector, Operator: op, Args: args, Pos: start + 1}, nil
+}
+
+func (p *parser) readOperator() (string, error) {
+	start := p.pos
+	if p.eof() {
+		return "", p.errorf(start, "expected operator")
+	}
+
+	switch p.peek() {
+	case '<', '>':
+		op := string(p.peek())
+		p.pos++
+		if !p.eof() && p.peek() == '=' {
+			op += "="
+			p.pos++
+		}
+		return operatorAliases[op], nil
+	case '!':
+		p.pos++
+		if !p.eof() && p.peek() == '=' {
+			p.pos++
+			return "!=", nil
+		}
+	case '=':
+		p.pos++
+		if !p.eof() && p.peek() == '=' {
+			p.pos++
+			return "==", nil
+		}
+
+		// =name=
+		nameStart := p.pos
+		for !p.eof() && (p.peek() >= 'a' && p.peek() <= 'z' || p.peek() >= 'A' && p.peek() <= 'Z') {
+			p.pos++
+		}
+		if p.pos > nameStart && !p.eof() && p.peek() == '=' {
+			p.pos++
+			return strings.ToLower(string(p.src[start:p.pos])), nil
+		}
+	}
+
+	return "", p.errorf(start, "expected operator")
+}
+
+func (p *parser) readArguments() ([]string, error) {
+	if p.eof() {
+		return nil, p.errorf(p.pos, "expected argument")
+	}
+
+	if p.peek() != '(' {
+		v, err := p.readValue()
+		if err != nil {
+			return nil, err
+		}
+		return []string{v}, nil
+	}
+
+	open := p.pos
+	p.pos++
+
+	var args []string
+	for {
+		p.skipSpaces()
+		v, err := p.readValue()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, v)
+
+		if p.consume(',') {
+			continue
+		}
+		if p.consume(')') {
+			return args, nil
+		}
+		return nil, p.errorf(p.pos, "expected ')' to close '(' from position %d", open+1)
+	}
+}
+
+func (p *parser) readValue() (string, error) {
+	if p.eof() {
+		return "", p.errorf(p.pos, "expected argument")
+	}
+
+	if q := p.peek(); q == '"' || q == '\'' {
+		return p.readQuoted(q)
+	}
+
+	v := p.readUnreserved()
+	if v == "" {
+		return "", p.errorf(p.pos, "expected argument")
+	}
+	return v, nil
+}
+
+// readQuoted строка в кавычках, \ экранирует следующий символ
+func (p *parser) readQuoted(quote rune) (string, error) {
+	start := p.pos
+	p.pos++
+
+	var b strings.Builder
+	for !p.eof() {
+		r := p.peek()
+		p.pos++
+		switch {
+		case r == '\\':
+			if p.eof() {
+				return "", p.errorf(p.pos, "unterminated escape")
+			}
+			b.WriteRune(p.peek())
+			p.pos++
+		case r == quote:
+			return b.String(), nil
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return "", p.errorf(start, "unterminated quoted value")
+}
+
+func (p *parser) readUnreserved() string {
+	start := p.pos
+	for !p.eof() && !isReserved(p.peek()) {
+		p.pos++
+	}
+	return string(p.src[start:p.pos])
+}
+
+func isReserved(r rune) bool {
+	return unicode.IsSpace(r) || strings.ContainsRune(`"'();,=!~<>`, r)
+}
@@ -0,0 +1,117 @@
+package rsql
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse_Precedence(t *testing.T) {
+	n, err := Parse("price=gt=500;(service_name==Netflix,service_name==Spotify)")
+	require.NoError(t, err)
+
+	require.Equal(t, &Logical{Op: And, Children: []Node{
+		&Comparison{Selector: "price", Operator: "=gt=", Args: []string{"500"}, Pos: 1},
+		&Logical{Op: Or, Children: []Node{
+			&Comparison{Selector: "service_name", Operator: "==", Args: []string{"Netflix"}, Pos: 15},
+			&Comparison{Selector: "service_name", Operator: "==", Args: []string{"Spotify"}, Pos: 37},
+		}},
+	}}, n)
+}
+
+func TestParse_AndBindsTighter(t *testing.T) {
+	n, err := Parse("a==1,b==2;c==3")
+	require.NoError(t, err)
+
+	or, ok := n.(*Logical)
+	require.True(t, ok)
+	require.Equal(t, Or, or.Op)
+	require.Len(t, or.Children, 2)
+	require.Equal(t, And, or.Children[1].(*Logical).Op)
+}
+
+func TestParse_OperatorsAndArguments(t *testing.T) {
+	tests := []struct {
+		expr string
+		op   string
+		args []string
+	}{
+		{"price<500", "=lt=", []string{"500"}},
+		{"price<=500", "=le=", []string{"500"}},
+		{"price>500", "=gt=", []string{"500"}},
+		{"price >= 500", "=ge=", []string{"500"}},
+		{"price!=500", "!=", []string{"500"}},
+		{"price=IN=(1, 2,3)", "=in=", []string{"1", "2", "3"}},
+		{`service_name=="Yandex Plus"`, "==", []string{"Yandex Plus"}},
+		{`service_name=='it\'s'`, "==", []string{"it's"}},
+		{"service_name=out=(Okko,'a;b')", "=out=", []string{"Okko", "a;b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			n, err := Parse(tt.expr)
+			require.NoError(t, err)
+
+			c, ok := n.(*Comparison)
+			require.True(t, ok)
+			require.Equal(t, tt.op, c.Operator)
+			require.Equal(t, tt.args, c.Args)
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		expr string
+		pos  int
+	}{
+		{"", 1},
+		{"price", 6},
+		{"price=500", 6},
+		{"price==", 8},
+		{"price==1;", 10},
+		{"(price==1", 10},
+		{"price==1)", 9},
+		{"price=in=(1,2", 14},
+		{`service_name=="abc`, 15},
+		{"==1", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := Parse(tt.expr)
+			require.Error(t, err)
+			require.True(t, errors.Is(err, ErrSyntax))
+
+			var perr *ParseError
+			require.ErrorAs(t, err, &perr)
+			require.Equal(t, tt.pos, perr.Pos, perr.Error())
+			require.Contains(t, perr.Error(), "at position")
+		})
+	}
+}
+
+func TestParse_Limits(t *testing.T) {
+	_, err := Parse(strings.Repeat("(", MaxDepth+1) + "a==1" + strings.Repeat(")", MaxDepth+1))
+	require.ErrorIs(t, err, ErrSyntax)
+
+	_, err = Parse(strings.TrimSuffix(strings.Repeat("a==1;", MaxComparisons+1), ";"))
+	require.ErrorIs(t, err, ErrSyntax)
+
+	_, err = Parse("a==" + strings.Repeat("x", MaxLength))
+	require.ErrorIs(t, err, ErrSyntax)
+}
+
+func TestWalk(t *testing.T) {
+	n, err := Parse("a==1;(b==2,c==3)")
+	require.NoError(t, err)
+
+	var selectors []string
+	require.NoError(t, Walk(n, func(c *Comparison) error {
+		selectors = append(selectors, c.Selector)
+		return nil
+	}))
+	require.Equal(t, []string{"a", "b", "c"}, selectors)
+}
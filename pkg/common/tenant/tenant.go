@@ -0,0 +1,56 @@
+// Package tenant тенант запроса: клиент, данные которого изолированы от остальных
+package tenant
+
+import (
+	"context"
+	"errors"
+	"regexp"
+)
+
+// Default тенант однотенантной установки и токенов без тенанта
+const Default = "default"
+
+var (
+	ErrInvalidTenant  = errors.New("invalid tenant id")
+	ErrTenantMismatch = errors.New("tenant mismatch")
+)
+
+var idPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// Valid id тенанта: строчные латинские буквы, цифры, '-' и '_', до 63 символов
+func Valid(id string) bool {
+	return idPattern.MatchString(id)
+}
+
+type tenantKey struct{}
+
+func WithTenant(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, id)
+}
+
+// FromContext тенант, выбранный для запроса, false - не выбран
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(tenantKey{}).(string)
+	return id, ok && id != ""
+}
+
+// Current тенант запроса, без выбранного - Default
+func Current(ctx context.Context) string {
+	if id, ok := FromContext(ctx); ok {
+		return id
+	}
+	return Default
+}
+
+// Bind закрепляет за запросом тенанта аутентифицированного вызывающего.
+// Вызывающий без тенанта принадлежит Default. Запрошенный заголовком другой тенант - ErrTenantMismatch
+func Bind(ctx context.Context, principalTenant string) (context.Context, error) {
+	want := principalTenant
+	if want == "" {
+		want = Default
+	}
+	if requested, ok := FromContext(ctx); ok && requested != want {
+		return ctx, ErrTenantMismatch
+	}
+	return WithTenant(ctx, want), nil
+}
@@ -0,0 +1,44 @@
+package tenant
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValid(t *testing.T) {
+	for _, id := range []string{"default", "acme", "acme-corp_2", "0", strings.Repeat("a", 63)} {
+		require.True(t, Valid(id), id)
+	}
+	for _, id := range []string{"", "Acme", "-acme", "acme corp", "acme'; --", strings.Repeat("a", 64)} {
+		require.False(t, Valid(id), id)
+	}
+}
+
+func TestBind(t *testing.T) {
+	ctx := context.Background()
+	require.Equal(t, Default, Current(ctx))
+
+	// без заголовка - тенант вызывающего
+	bound, err := Bind(ctx, "acme")
+	require.NoError(t, err)
+	require.Equal(t, "acme", Current(bound))
+
+	// токен без тенанта - Default
+	bound, err = Bind(ctx, "")
+	require.NoError(t, err)
+	require.Equal(t, Default, Current(bound))
+
+	// заголовок совпадает с тенантом вызывающего
+	bound, err = Bind(WithTenant(ctx, "acme"), "acme")
+	require.NoError(t, err)
+	require.Equal(t, "acme", Current(bound))
+
+	// заголовком нельзя перейти в чужого тенанта
+	_, err = Bind(WithTenant(ctx, "globex"), "acme")
+	require.ErrorIs(t, err, ErrTenantMismatch)
+	_, err = Bind(WithTenant(ctx, "globex"), "")
+	require.ErrorIs(t, err, ErrTenantMismatch)
+}
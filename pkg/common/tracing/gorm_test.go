@@ -0,0 +1,72 @@
+//go:build integration
+// +build integration
+
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	common_test "github.com/end1essrage/efmob-tz/pkg/common/testing"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+type widget struct {
+	ID   int
+	Name string
+}
+
+func TestGormPlugin(t *testing.T) {
+	ctx := context.Background()
+	container, dsn, err := common_test.SetupPostgresContainer(ctx)
+	if err != nil {
+		t.Fatalf("failed to start postgres container: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.Use(GormPlugin{}))
+	require.NoError(t, db.AutoMigrate(&widget{}))
+
+	rec := record(t)
+
+	// вне трассы спаны не создаются
+	require.NoError(t, db.WithContext(ctx).Create(&widget{Name: "a"}).Error)
+	require.Empty(t, rec.Ended())
+
+	reqCtx, span := Start(ctx, "request")
+	require.NoError(t, db.WithContext(reqCtx).Create(&widget{Name: "b"}).Error)
+	var got widget
+	require.NoError(t, db.WithContext(reqCtx).Where("name = ?", "b").First(&got).Error)
+	// запись не найдена - не ошибка спана
+	require.ErrorIs(t, db.WithContext(reqCtx).Where("name = ?", "x").First(&got).Error, gorm.ErrRecordNotFound)
+	require.Error(t, db.WithContext(reqCtx).Exec("SELECT * FROM missing").Error)
+	span.End()
+
+	spans := rec.Ended()
+	require.Len(t, spans, 5)
+	insert, query, notFound, raw := spans[0], spans[1], spans[2], spans[3]
+
+	require.Equal(t, "INSERT widgets", insert.Name())
+	require.Equal(t, trace.SpanKindClient, insert.SpanKind())
+	require.Equal(t, span.SpanContext().SpanID(), insert.Parent().SpanID())
+
+	require.Equal(t, "SELECT widgets", query.Name())
+	require.Contains(t, query.Attributes(), semconv.DBSystemNamePostgreSQL)
+	// значения параметров в спан не попадают
+	for _, kv := range query.Attributes() {
+		if kv.Key == semconv.DBQueryTextKey {
+			require.Contains(t, kv.Value.AsString(), "$1")
+			require.NotContains(t, kv.Value.AsString(), "'b'")
+		}
+	}
+
+	require.Equal(t, codes.Unset, notFound.Status().Code)
+	require.Equal(t, codes.Error, raw.Status().Code)
+}
@@ -0,0 +1,91 @@
+// Package tracing трассировка OpenTelemetry: провайдер с экспортом OTLP и спаны http, gorm и обработчиков
+package tracing
+
+import (
+	"context"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/logger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentation имя трейсера сервиса
+const instrumentation = "github.com/end1essrage/efmob-tz"
+
+type Config struct {
+	ServiceName string
+	// Endpoint адрес OTLP gRPC коллектора, например http://alloy:4317. Пусто - спаны не экспортируются
+	Endpoint string
+	// SampleRatio доля записываемых трасс, решение родителя сохраняется
+	SampleRatio float64
+}
+
+// Init настраивает глобальный провайдер и W3C propagation.
+// Возвращает shutdown, который досылает накопленные спаны
+func Init(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	if cfg.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracegrpc.New(ctx, otlptracegrpc.WithEndpointURL(cfg.Endpoint))
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(cfg.ServiceName),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(tp)
+
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		logger.Logger().Log("tracing", "otel").Warnf("ошибка экспорта трасс: %v", err)
+	}))
+
+	return tp.Shutdown, nil
+}
+
+// Tracer трейсер сервиса из глобального провайдера
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentation)
+}
+
+// Start начинает внутренний спан операции
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// Traceparent заголовок W3C traceparent спана из ctx, пусто - спана нет
+func Traceparent(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	return carrier.Get("traceparent")
+}
+
+// WithTraceparent контекст с удаленным спаном из traceparent, некорректный заголовок игнорируется
+func WithTraceparent(ctx context.Context, traceparent string) context.Context {
+	if traceparent == "" {
+		return ctx
+	}
+	return propagation.TraceContext{}.Extract(ctx, propagation.MapCarrier{"traceparent": traceparent})
+}
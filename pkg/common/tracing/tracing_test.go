@@ -0,0 +1,100 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// record подменяет глобальный провайдер записывающим спаны
+func record(t *testing.T) *tracetest.SpanRecorder {
+	rec := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+	return rec
+}
+
+func TestTraceparent(t *testing.T) {
+	record(t)
+
+	require.Empty(t, Traceparent(context.Background()))
+
+	ctx, span := Start(context.Background(), "op")
+	defer span.End()
+
+	tp := Traceparent(ctx)
+	require.Regexp(t, `^00-[0-9a-f]{32}-[0-9a-f]{16}-01$`, tp)
+
+	remote := trace.SpanContextFromContext(WithTraceparent(context.Background(), tp))
+	require.True(t, remote.IsRemote())
+	require.Equal(t, span.SpanContext().TraceID(), remote.TraceID())
+	require.Equal(t, span.SpanContext().SpanID(), remote.SpanID())
+
+	// мусор и пустая строка не ломают контекст
+	require.False(t, trace.SpanContextFromContext(WithTraceparent(context.Background(), "junk")).IsValid())
+	require.False(t, trace.SpanContextFromContext(WithTraceparent(context.Background(), "")).IsValid())
+}
+
+func TestMiddleware(t *testing.T) {
+	rec := record(t)
+
+	r := chi.NewRouter()
+	r.Use(Middleware)
+	r.Get("/subscriptions/{id}", func(w http.ResponseWriter, r *http.Request) {
+		// спан обработчика - дочерний к спану запроса
+		_, span := Start(r.Context(), "GetSubscriptionHandler.Handle")
+		span.End()
+		w.WriteHeader(http.StatusNotFound)
+	})
+	r.Post("/fail", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	// трасса продолжается из traceparent клиента
+	parent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	req := httptest.NewRequest(http.MethodGet, "/subscriptions/42", nil)
+	req.Header.Set("traceparent", parent)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := rec.Ended()
+	require.Len(t, spans, 2)
+	handler, server := spans[0], spans[1]
+
+	require.Equal(t, "GET /subscriptions/{id}", server.Name())
+	require.Equal(t, trace.SpanKindServer, server.SpanKind())
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext().TraceID().String())
+	require.Equal(t, "00f067aa0ba902b7", server.Parent().SpanID().String())
+	require.Contains(t, server.Attributes(), semconv.HTTPRoute("/subscriptions/{id}"))
+	require.Contains(t, server.Attributes(), semconv.HTTPResponseStatusCode(http.StatusNotFound))
+	// 404 - ошибка клиента
+	require.Equal(t, codes.Unset, server.Status().Code)
+
+	require.Equal(t, server.SpanContext().SpanID(), handler.Parent().SpanID())
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/fail", nil))
+	spans = rec.Ended()
+	require.Len(t, spans, 3)
+	require.Equal(t, "POST /fail", spans[2].Name())
+	require.Equal(t, codes.Error, spans[2].Status().Code)
+	require.False(t, spans[2].Parent().IsValid(), "без traceparent - новая трасса")
+}
+
+func TestOperation(t *testing.T) {
+	require.Equal(t, "SELECT", operation("SELECT * FROM subscriptions"))
+	require.Equal(t, "INSERT", operation(" INSERT INTO x"))
+	require.Equal(t, "COMMIT", operation("COMMIT"))
+	require.Equal(t, "db", operation(""))
+}
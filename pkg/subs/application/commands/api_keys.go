@@ -0,0 +1,225 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/auth"
+	"github.com/end1essrage/efmob-tz/pkg/common/logger"
+	"github.com/end1essrage/efmob-tz/pkg/common/tracing"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application"
+	"github.com/google/uuid"
+)
+
+const (
+	maxAPIKeyNameLen  = 100
+	maxAPIKeyOwnerLen = 255
+)
+
+type IssueAPIKeyCommand struct {
+	Name string
+	// Owner субъект запросов ключа: id пользователя или имя сервиса
+	Owner  string
+	Scopes []string
+	// ExpiresAt nil - бессрочный ключ
+	ExpiresAt *time.Time
+}
+
+type IssueAPIKeyHandler struct {
+	repo application.APIKeyRepository
+}
+
+func NewIssueAPIKeyHandler(repo application.APIKeyRepository) *IssueAPIKeyHandler {
+	return &IssueAPIKeyHandler{repo: repo}
+}
+
+// Handle выпускает ключ API. Ключ возвращается только здесь - в хранилище остаётся хэш
+func (h *IssueAPIKeyHandler) Handle(ctx context.Context, cmd IssueAPIKeyCommand) (*auth.APIKey, string, error) {
+	ctx, span := tracing.Start(ctx, "IssueAPIKeyHandler.Handle")
+	defer span.End()
+
+	log := logger.Logger().WithFields(logger.LogOptions{
+		Pkg:  "IssueAPIKeyHandler",
+		Func: "Handle",
+		Ctx:  ctx,
+	})
+
+	if _, err := application.Authorize(ctx, application.ActionManageAPIKeys); err != nil {
+		log.Warn(err)
+		return nil, "", err
+	}
+
+	key, err := cmd.validate(time.Now())
+	if err != nil {
+		log.Warnf("невалидная команда: %v", err)
+		return nil, "", err
+	}
+
+	if err := application.CheckGrant(ctx, key.Scopes); err != nil {
+		log.Warn(err)
+		return nil, "", err
+	}
+
+	secret, err := issueSecret(key)
+	if err != nil {
+		log.Errorf("ошибка генерации ключа: %v", err)
+		return nil, "", err
+	}
+
+	if err := h.repo.CreateAPIKey(ctx, key); err != nil {
+		log.Errorf("ошибка сохранения ключа: %v", err)
+		return nil, "", err
+	}
+
+	log.Infof("выпущен ключ API %s для %s, скоупы %v", key.ID, key.Owner, key.Scopes)
+	return key, secret, nil
+}
+
+func (cmd IssueAPIKeyCommand) validate(now time.Time) (*auth.APIKey, error) {
+	name := strings.TrimSpace(cmd.Name)
+	if name == "" || len(name) > maxAPIKeyNameLen {
+		return nil, application.NewErrorValidationCommand(fmt.Sprintf("name: от 1 до %d символов", maxAPIKeyNameLen))
+	}
+	owner := strings.TrimSpace(cmd.Owner)
+	if owner == "" || len(owner) > maxAPIKeyOwnerLen || strings.ContainsAny(owner, " \t\n") {
+		return nil, application.NewErrorValidationCommand(fmt.Sprintf("owner: от 1 до %d символов без пробелов", maxAPIKeyOwnerLen))
+	}
+
+	// ключ без скоупов получил бы права роли user по умолчанию
+	if len(cmd.Scopes) == 0 {
+		return nil, application.NewErrorValidationCommand("scopes: нужен хотя бы один скоуп")
+	}
+	scopes := make([]string, 0, len(cmd.Scopes))
+	for _, s := range cmd.Scopes {
+		if !application.KnownPermission(application.Permission(s)) {
+			return nil, application.NewErrorValidationCommand(fmt.Sprintf("scopes: неизвестный скоуп %q", s))
+		}
+		if !slices.Contains(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+
+	if cmd.ExpiresAt != nil && !cmd.ExpiresAt.After(now) {
+		return nil, application.NewErrorValidationCommand("expires_at: срок должен быть в будущем")
+	}
+
+	return &auth.APIKey{
+		ID:        uuid.New(),
+		Name:      name,
+		Owner:     owner,
+		Scopes:    scopes,
+		ExpiresAt: cmd.ExpiresAt,
+	}, nil
+}
+
+// issueSecret генерирует ключ и заполняет его хэш
+func issueSecret(key *auth.APIKey) (string, error) {
+	secret, err := auth.NewAPIKeySecret(key.ID)
+	if err != nil {
+		return "", err
+	}
+	key.Hash = auth.HashAPIKey(secret)
+	key.Hint = auth.APIKeyHint(secret)
+	return secret, nil
+}
+
+type RotateAPIKeyCommand struct {
+	ID uuid.UUID
+}
+
+type RotateAPIKeyHandler struct {
+	repo application.APIKeyRepository
+}
+
+func NewRotateAPIKeyHandler(repo application.APIKeyRepository) *RotateAPIKeyHandler {
+	return &RotateAPIKeyHandler{repo: repo}
+}
+
+// Handle выпускает новый секрет ключа с теми же владельцем и скоупами, старый сразу перестает действовать
+func (h *RotateAPIKeyHandler) Handle(ctx context.Context, cmd RotateAPIKeyCommand) (*auth.APIKey, string, error) {
+	ctx, span := tracing.Start(ctx, "RotateAPIKeyHandler.Handle")
+	defer span.End()
+
+	log := logger.Logger().WithFields(logger.LogOptions{
+		Pkg:  "RotateAPIKeyHandler",
+		Func: "Handle",
+		Ctx:  ctx,
+	})
+
+	if _, err := application.Authorize(ctx, application.ActionManageAPIKeys); err != nil {
+		log.Warn(err)
+		return nil, "", err
+	}
+
+	// перевыпуск отдает новый секрет - скоупы ключа проверяются как при выпуске
+	keys, err := h.repo.ListAPIKeys(ctx)
+	if err != nil {
+		log.Errorf("ошибка получения ключей: %v", err)
+		return nil, "", err
+	}
+	i := slices.IndexFunc(keys, func(k *auth.APIKey) bool { return k.ID == cmd.ID })
+	if i < 0 {
+		log.Warnf("ключ %s не найден", cmd.ID)
+		return nil, "", auth.ErrAPIKeyNotFound
+	}
+	if err := application.CheckGrant(ctx, keys[i].Scopes); err != nil {
+		log.Warn(err)
+		return nil, "", err
+	}
+
+	tmp := &auth.APIKey{ID: cmd.ID}
+	secret, err := issueSecret(tmp)
+	if err != nil {
+		log.Errorf("ошибка генерации ключа: %v", err)
+		return nil, "", err
+	}
+
+	key, err := h.repo.RotateAPIKey(ctx, cmd.ID, tmp.Hash, tmp.Hint)
+	if err != nil {
+		log.Warnf("ошибка перевыпуска ключа %s: %v", cmd.ID, err)
+		return nil, "", err
+	}
+
+	log.Infof("перевыпущен ключ API %s", key.ID)
+	return key, secret, nil
+}
+
+type RevokeAPIKeyCommand struct {
+	ID uuid.UUID
+}
+
+type RevokeAPIKeyHandler struct {
+	repo application.APIKeyRepository
+}
+
+func NewRevokeAPIKeyHandler(repo application.APIKeyRepository) *RevokeAPIKeyHandler {
+	return &RevokeAPIKeyHandler{repo: repo}
+}
+
+// Handle отзывает ключ навсегда, запросы с ним получают 401
+func (h *RevokeAPIKeyHandler) Handle(ctx context.Context, cmd RevokeAPIKeyCommand) error {
+	ctx, span := tracing.Start(ctx, "RevokeAPIKeyHandler.Handle")
+	defer span.End()
+
+	log := logger.Logger().WithFields(logger.LogOptions{
+		Pkg:  "RevokeAPIKeyHandler",
+		Func: "Handle",
+		Ctx:  ctx,
+	})
+
+	if _, err := application.Authorize(ctx, application.ActionManageAPIKeys); err != nil {
+		log.Warn(err)
+		return err
+	}
+
+	if err := h.repo.RevokeAPIKey(ctx, cmd.ID, time.Now()); err != nil {
+		log.Warnf("ошибка отзыва ключа %s: %v", cmd.ID, err)
+		return err
+	}
+
+	log.Infof("отозван ключ API %s", cmd.ID)
+	return nil
+}
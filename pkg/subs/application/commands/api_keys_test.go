@@ -0,0 +1,170 @@
+package commands
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/auth"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockAPIKeyRepository реализация для тестов
+type MockAPIKeyRepository struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyRepository) CreateAPIKey(ctx context.Context, key *auth.APIKey) error {
+	return m.Called(ctx, key).Error(0)
+}
+
+func (m *MockAPIKeyRepository) ListAPIKeys(ctx context.Context) ([]*auth.APIKey, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]*auth.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) RotateAPIKey(ctx context.Context, id uuid.UUID, hash, hint string) (*auth.APIKey, error) {
+	args := m.Called(ctx, id, hash, hint)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*auth.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) RevokeAPIKey(ctx context.Context, id uuid.UUID, at time.Time) error {
+	return m.Called(ctx, id, at).Error(0)
+}
+
+func adminCtx() context.Context {
+	return auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "root", Roles: []string{application.RoleAdmin}})
+}
+
+func TestIssueAPIKeyHandler(t *testing.T) {
+	repo := &MockAPIKeyRepository{}
+	repo.On("CreateAPIKey", mock.Anything, mock.Anything).Return(nil)
+	h := NewIssueAPIKeyHandler(repo)
+
+	expires := time.Now().Add(time.Hour)
+	key, secret, err := h.Handle(adminCtx(), IssueAPIKeyCommand{
+		Name:      " billing ",
+		Owner:     "billing-job",
+		Scopes:    []string{"subs:read:any", "subs:read:any"},
+		ExpiresAt: &expires,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "billing", key.Name)
+	assert.Equal(t, []string{"subs:read:any"}, key.Scopes)
+	// в хранилище уходит только хэш
+	assert.Equal(t, auth.HashAPIKey(secret), key.Hash)
+	assert.Equal(t, auth.APIKeyHint(secret), key.Hint)
+	id, ok := auth.ParseAPIKeyID(secret)
+	require.True(t, ok)
+	assert.Equal(t, key.ID, id)
+	repo.AssertNumberOfCalls(t, "CreateAPIKey", 1)
+}
+
+func TestIssueAPIKeyHandler_Validate(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	valid := IssueAPIKeyCommand{Name: "job", Owner: uuid.NewString(), Scopes: []string{"subs:write:own"}}
+
+	tests := []struct {
+		name   string
+		modify func(*IssueAPIKeyCommand)
+	}{
+		{"empty name", func(c *IssueAPIKeyCommand) { c.Name = "  " }},
+		{"empty owner", func(c *IssueAPIKeyCommand) { c.Owner = "" }},
+		{"owner with spaces", func(c *IssueAPIKeyCommand) { c.Owner = "billing job" }},
+		{"no scopes", func(c *IssueAPIKeyCommand) { c.Scopes = nil }},
+		{"unknown scope", func(c *IssueAPIKeyCommand) { c.Scopes = []string{"subs:delete:all"} }},
+		{"expired", func(c *IssueAPIKeyCommand) { c.ExpiresAt = &past }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &MockAPIKeyRepository{}
+			cmd := valid
+			tt.modify(&cmd)
+
+			_, _, err := NewIssueAPIKeyHandler(repo).Handle(adminCtx(), cmd)
+			require.Error(t, err)
+			assert.Equal(t, "INVALID_COMMAND", application.MapError(err).Code)
+			repo.AssertNotCalled(t, "CreateAPIKey", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestAPIKeyHandlers_Forbidden(t *testing.T) {
+	repo := &MockAPIKeyRepository{}
+	userID := uuid.New()
+	ctx := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: userID.String(), UserID: userID})
+
+	_, _, err := NewIssueAPIKeyHandler(repo).Handle(ctx, IssueAPIKeyCommand{Name: "job", Owner: "job", Scopes: []string{"subs:read:any"}})
+	assert.ErrorIs(t, err, application.ErrForbidden)
+	_, _, err = NewRotateAPIKeyHandler(repo).Handle(ctx, RotateAPIKeyCommand{ID: uuid.New()})
+	assert.ErrorIs(t, err, application.ErrForbidden)
+	err = NewRevokeAPIKeyHandler(repo).Handle(ctx, RevokeAPIKeyCommand{ID: uuid.New()})
+	assert.ErrorIs(t, err, application.ErrForbidden)
+
+	repo.AssertExpectations(t)
+}
+
+func TestRotateAPIKeyHandler(t *testing.T) {
+	id := uuid.New()
+	var hash string
+	revoked := uuid.New()
+	repo := &MockAPIKeyRepository{}
+	repo.On("ListAPIKeys", mock.Anything).Return([]*auth.APIKey{
+		{ID: id, Scopes: []string{"subs:read:any"}},
+		{ID: revoked, Scopes: []string{"subs:read:any"}},
+	}, nil)
+	repo.On("RotateAPIKey", mock.Anything, id, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { hash = args.String(2) }).
+		Return(&auth.APIKey{ID: id}, nil)
+
+	key, secret, err := NewRotateAPIKeyHandler(repo).Handle(adminCtx(), RotateAPIKeyCommand{ID: id})
+	require.NoError(t, err)
+	assert.Equal(t, id, key.ID)
+	assert.Equal(t, auth.HashAPIKey(secret), hash)
+	got, ok := auth.ParseAPIKeyID(secret)
+	require.True(t, ok)
+	assert.Equal(t, id, got)
+
+	repo.On("RotateAPIKey", mock.Anything, revoked, mock.Anything, mock.Anything).Return(nil, auth.ErrAPIKeyRevoked)
+	_, _, err = NewRotateAPIKeyHandler(repo).Handle(adminCtx(), RotateAPIKeyCommand{ID: revoked})
+	assert.Equal(t, "API_KEY_REVOKED", application.MapError(err).Code)
+
+	_, _, err = NewRotateAPIKeyHandler(repo).Handle(adminCtx(), RotateAPIKeyCommand{ID: uuid.New()})
+	assert.ErrorIs(t, err, auth.ErrAPIKeyNotFound)
+}
+
+// TestAPIKeyHandlers_ScopeEscalation ключ не дает больше прав, чем у выдающего
+func TestAPIKeyHandlers_ScopeEscalation(t *testing.T) {
+	id := uuid.New()
+	repo := &MockAPIKeyRepository{}
+	repo.On("CreateAPIKey", mock.Anything, mock.Anything).Return(nil)
+	repo.On("ListAPIKeys", mock.Anything).Return([]*auth.APIKey{{ID: id, Scopes: []string{"subs:write:any"}}}, nil)
+
+	// менеджер ключей без subs:write:any
+	ctx := auth.WithPrincipal(context.Background(), &auth.Principal{
+		Subject: "keys-manager",
+		Scopes:  []string{string(application.PermManageAPIKeys), string(application.PermReadAny)},
+	})
+
+	_, _, err := NewIssueAPIKeyHandler(repo).Handle(ctx, IssueAPIKeyCommand{Name: "job", Owner: "job", Scopes: []string{"subs:write:any"}})
+	assert.ErrorIs(t, err, application.ErrForbidden)
+	assert.Equal(t, "FORBIDDEN", application.MapError(err).Code)
+
+	_, _, err = NewRotateAPIKeyHandler(repo).Handle(ctx, RotateAPIKeyCommand{ID: id})
+	assert.ErrorIs(t, err, application.ErrForbidden)
+
+	// свои права выдавать можно
+	_, _, err = NewIssueAPIKeyHandler(repo).Handle(ctx, IssueAPIKeyCommand{Name: "job", Owner: "job", Scopes: []string{"subs:read:any"}})
+	require.NoError(t, err)
+
+	repo.AssertNotCalled(t, "RotateAPIKey", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	repo.AssertNumberOfCalls(t, "CreateAPIKey", 1)
+}
@@ -5,6 +5,8 @@ import (
 	"time"
 
 	"github.com/end1essrage/efmob-tz/pkg/common/logger"
+	"github.com/end1essrage/efmob-tz/pkg/common/tracing"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application"
 	domain "github.com/end1essrage/efmob-tz/pkg/subs/domain"
 	subsMetrics "github.com/end1essrage/efmob-tz/pkg/subs/metrics"
 	"github.com/google/uuid"
@@ -27,12 +29,22 @@ func NewCreateSubscriptionHandler(repo domain.SubscriptionRepositoryWithTx) *Cre
 }
 
 func (h *CreateSubscriptionHandler) Handle(ctx context.Context, cmd CreateSubscriptionCommand) (*domain.Subscription, error) {
+	ctx, span := tracing.Start(ctx, "CreateSubscriptionHandler.Handle")
+	defer span.End()
+
 	log := logger.Logger().WithFields(logger.LogOptions{
 		Pkg:  "CreateSubscriptionHandler",
 		Func: "Handle",
 		Ctx:  ctx,
 	})
 
+	access, err := application.Authorize(ctx, application.ActionCreateSubscription)
+	if err != nil {
+		log.Warn(err)
+		return nil, err
+	}
+	cmd = ownCommand(access, cmd)
+
 	sub, err := domain.NewSubscription(
 		uuid.Nil,
 		cmd.UserID,
@@ -47,22 +59,7 @@ func (h *CreateSubscriptionHandler) Handle(ctx context.Context, cmd CreateSubscr
 	}
 
 	err = h.repo.RunInTransaction(ctx, func(tx domain.TxSubscriptionRepository) error {
-		// создаём подписку
-		uid, err := tx.Create(ctx, sub)
-		if err != nil {
-			return err
-		}
-
-		// создаем событие
-		event := domain.SubCreatedEvent{
-			Id:     uid,
-			UserID: sub.UserID(),
-		}
-		if err := tx.CreateEvent(ctx, event); err != nil {
-			return err
-		}
-
-		return nil
+		return createWithEvent(ctx, tx, sub)
 	})
 	if err != nil {
 		log.Errorf("creating error: %v", err)
@@ -73,3 +70,27 @@ func (h *CreateSubscriptionHandler) Handle(ctx context.Context, cmd CreateSubscr
 
 	return sub, nil
 }
+
+// ownCommand пользователь без прав на чужие подписки создает их только себе
+func ownCommand(access application.Access, cmd CreateSubscriptionCommand) CreateSubscriptionCommand {
+	if owner, ok := access.Owner(); ok {
+		cmd.UserID = owner
+	}
+	return cmd
+}
+
+// createWithEvent сохраняет подписку и событие о создании в рамках транзакции
+func createWithEvent(ctx context.Context, tx domain.TxSubscriptionRepository, sub *domain.Subscription) error {
+	// создаём подписку
+	uid, err := tx.Create(ctx, sub)
+	if err != nil {
+		return err
+	}
+
+	// создаем событие
+	event := domain.SubCreatedEvent{
+		Id:     uid,
+		UserID: sub.UserID(),
+	}
+	return tx.CreateEvent(ctx, event)
+}
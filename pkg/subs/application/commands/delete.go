@@ -2,14 +2,21 @@ package commands
 
 import (
 	"context"
+	"errors"
 
 	"github.com/end1essrage/efmob-tz/pkg/common/logger"
+	"github.com/end1essrage/efmob-tz/pkg/common/tracing"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application"
 	domain "github.com/end1essrage/efmob-tz/pkg/subs/domain"
+	subsMetrics "github.com/end1essrage/efmob-tz/pkg/subs/metrics"
 	"github.com/google/uuid"
 )
 
 type DeleteSubscriptionCommand struct {
 	ID uuid.UUID
+
+	// ExpectedVersion - версия, которую видел клиент (If-Match), nil - без проверки
+	ExpectedVersion *int
 }
 
 type DeleteSubscriptionHandler struct {
@@ -21,15 +28,42 @@ func NewDeleteSubscriptionHandler(repo domain.SubscriptionRepositoryWithTx) *Del
 }
 
 func (h *DeleteSubscriptionHandler) Handle(ctx context.Context, cmd DeleteSubscriptionCommand) error {
+	ctx, span := tracing.Start(ctx, "DeleteSubscriptionHandler.Handle")
+	defer span.End()
+
 	log := logger.Logger().WithFields(logger.LogOptions{
 		Pkg:  "DeleteSubscriptionHandler",
 		Func: "Handle",
 		Ctx:  ctx,
 	})
 
-	err := h.repo.RunInTransaction(ctx, func(tx domain.TxSubscriptionRepository) error {
-		// создаём подписку
-		if err := tx.Delete(ctx, cmd.ID); err != nil {
+	access, err := application.Authorize(ctx, application.ActionDeleteSubscription)
+	if err != nil {
+		log.Warn(err)
+		return err
+	}
+
+	err = h.repo.RunInTransaction(ctx, func(tx domain.TxSubscriptionRepository) error {
+		// чужую подписку ограниченный пользователь не видит
+		if _, scoped := access.Owner(); scoped {
+			sub, err := tx.GetByID(ctx, cmd.ID)
+			if err != nil {
+				return err
+			}
+			if err := access.CheckOwner(sub.UserID()); err != nil {
+				return err
+			}
+		}
+
+		// удаляем подписку
+		if cmd.ExpectedVersion != nil {
+			if err := tx.DeleteWithVersion(ctx, cmd.ID, *cmd.ExpectedVersion); err != nil {
+				if errors.Is(err, application.ErrConcurrentModification) {
+					return application.ErrPreconditionFailed
+				}
+				return err
+			}
+		} else if err := tx.Delete(ctx, cmd.ID); err != nil {
 			return err
 		}
 
@@ -45,6 +79,9 @@ func (h *DeleteSubscriptionHandler) Handle(ctx context.Context, cmd DeleteSubscr
 	})
 	if err != nil {
 		log.Errorf("deleting error: %v", err)
+		return err
 	}
-	return err
+
+	subsMetrics.SubscriptionsDeletedTotal.Inc()
+	return nil
 }
@@ -0,0 +1,63 @@
+package commands
+
+import (
+	"context"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/logger"
+	"github.com/end1essrage/efmob-tz/pkg/common/tracing"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application"
+	domain "github.com/end1essrage/efmob-tz/pkg/subs/domain"
+	"github.com/google/uuid"
+)
+
+type IssueFeedTokenCommand struct {
+	UserID uuid.UUID
+}
+
+type IssueFeedTokenHandler struct {
+	repo domain.FeedTokenRepository
+}
+
+func NewIssueFeedTokenHandler(repo domain.FeedTokenRepository) *IssueFeedTokenHandler {
+	return &IssueFeedTokenHandler{repo: repo}
+}
+
+// Handle выпускает новый токен календарного фида, старый токен отзывается.
+// Токен возвращается только здесь - в хранилище остаётся хэш
+func (h *IssueFeedTokenHandler) Handle(ctx context.Context, cmd IssueFeedTokenCommand) (string, error) {
+	ctx, span := tracing.Start(ctx, "IssueFeedTokenHandler.Handle")
+	defer span.End()
+
+	log := logger.Logger().WithFields(logger.LogOptions{
+		Pkg:  "IssueFeedTokenHandler",
+		Func: "Handle",
+		Ctx:  ctx,
+	})
+
+	access, err := application.Authorize(ctx, application.ActionIssueFeedToken)
+	if err != nil {
+		log.Warn(err)
+		return "", err
+	}
+
+	if cmd.UserID == uuid.Nil {
+		return "", domain.ErrInvalidUserID
+	}
+	if err := access.CheckOwner(cmd.UserID); err != nil {
+		return "", err
+	}
+
+	token, err := application.NewFeedToken()
+	if err != nil {
+		log.Errorf("ошибка генерации токена: %v", err)
+		return "", err
+	}
+
+	if err := h.repo.SaveFeedToken(ctx, cmd.UserID, application.HashFeedToken(token)); err != nil {
+		log.Errorf("ошибка сохранения токена: %v", err)
+		return "", err
+	}
+
+	log.Infof("выпущен токен календарного фида для %s", cmd.UserID)
+	return token, nil
+}
@@ -0,0 +1,214 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/logger"
+	"github.com/end1essrage/efmob-tz/pkg/common/tracing"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application"
+	domain "github.com/end1essrage/efmob-tz/pkg/subs/domain"
+	subsMetrics "github.com/end1essrage/efmob-tz/pkg/subs/metrics"
+	"github.com/google/uuid"
+)
+
+type ImportMode string
+
+const (
+	// ImportAtomic - все строки в одной транзакции, при любой ошибке ничего не создаётся
+	ImportAtomic ImportMode = "atomic"
+	// ImportBestEffort - каждая строка в своей транзакции, ошибочные строки пропускаются
+	ImportBestEffort ImportMode = "best_effort"
+)
+
+// ImportRow строка импорта. Err - ошибка разбора строки источником
+type ImportRow struct {
+	Line int
+	Cmd  CreateSubscriptionCommand
+	Err  error
+}
+
+// ImportSource потоково отдаёт строки импорта, io.EOF - строки закончились
+type ImportSource interface {
+	Next() (*ImportRow, error)
+}
+
+type ImportRowResult struct {
+	Line int
+	ID   *uuid.UUID
+	Err  error
+}
+
+type ImportResult struct {
+	Rows    []ImportRowResult
+	Created int
+	Failed  int
+}
+
+type ImportSubscriptionsCommand struct {
+	Mode   ImportMode
+	Source ImportSource
+}
+
+type ImportSubscriptionsHandler struct {
+	repo domain.SubscriptionRepositoryWithTx
+}
+
+func NewImportSubscriptionsHandler(repo domain.SubscriptionRepositoryWithTx) *ImportSubscriptionsHandler {
+	return &ImportSubscriptionsHandler{repo: repo}
+}
+
+// errImportRejected откатывает atomic импорт, если хотя бы одна строка невалидна
+var errImportRejected = errors.New("import rejected")
+
+// Handle возвращает ошибку только если импорт не удалось выполнить целиком
+// (ошибка чтения источника, инфраструктуры в atomic режиме).
+// Ошибки отдельных строк - в результате
+func (h *ImportSubscriptionsHandler) Handle(ctx context.Context, cmd ImportSubscriptionsCommand) (*ImportResult, error) {
+	ctx, span := tracing.Start(ctx, "ImportSubscriptionsHandler.Handle")
+	defer span.End()
+
+	log := logger.Logger().WithFields(logger.LogOptions{
+		Pkg:  "ImportSubscriptionsHandler",
+		Func: "Handle",
+		Ctx:  ctx,
+	}).WithField("mode", cmd.Mode)
+
+	access, err := application.Authorize(ctx, application.ActionImportSubscriptions)
+	if err != nil {
+		log.Warn(err)
+		return nil, err
+	}
+
+	var result *ImportResult
+
+	switch cmd.Mode {
+	case ImportAtomic:
+		result, err = h.importAtomic(ctx, access, cmd.Source)
+	case ImportBestEffort:
+		result, err = h.importBestEffort(ctx, access, cmd.Source)
+	default:
+		return nil, application.NewErrorValidationCommand("неизвестный режим импорта: " + string(cmd.Mode))
+	}
+	if err != nil {
+		log.Errorf("import error: %v", err)
+		return nil, err
+	}
+
+	subsMetrics.SubscriptionsCreatedTotal.Add(float64(result.Created))
+	log.Infof("импорт завершен: создано %d, ошибок %d", result.Created, result.Failed)
+
+	return result, nil
+}
+
+func (h *ImportSubscriptionsHandler) importBestEffort(ctx context.Context, access application.Access, source ImportSource) (*ImportResult, error) {
+	result := &ImportResult{}
+
+	for {
+		row, err := source.Next()
+		if errors.Is(err, io.EOF) {
+			return result, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		sub, err := rowToSubscription(access, row)
+		if err == nil {
+			err = h.repo.RunInTransaction(ctx, func(tx domain.TxSubscriptionRepository) error {
+				return createWithEvent(ctx, tx, sub)
+			})
+		}
+
+		result.add(row.Line, sub, err)
+	}
+}
+
+// importAtomic сначала читает и валидирует все строки, потом пишет их в одной короткой транзакции:
+// медленная загрузка тела не держит транзакцию и соединение пула
+func (h *ImportSubscriptionsHandler) importAtomic(ctx context.Context, access application.Access, source ImportSource) (*ImportResult, error) {
+	result := &ImportResult{}
+
+	// строки без ошибок, по индексу совпадают с result.Rows, пока result.Failed == 0
+	var subs []*domain.Subscription
+	for {
+		row, err := source.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// после первой ошибки импорт всё равно отклонён -
+		// остальные строки только валидируем, чтобы вернуть полный отчёт
+		sub, err := rowToSubscription(access, row)
+		if err == nil && result.Failed == 0 {
+			subs = append(subs, sub)
+		}
+		result.add(row.Line, sub, err)
+	}
+
+	if result.Failed > 0 {
+		return result.reject(), nil
+	}
+
+	err := h.repo.RunInTransaction(ctx, func(tx domain.TxSubscriptionRepository) error {
+		for i, sub := range subs {
+			if err := createWithEvent(ctx, tx, sub); err != nil {
+				result.Rows[i].ID = nil
+				result.Rows[i].Err = err
+				result.Failed++
+				return errImportRejected
+			}
+		}
+		return nil
+	})
+
+	if errors.Is(err, errImportRejected) {
+		return result.reject(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func rowToSubscription(access application.Access, row *ImportRow) (*domain.Subscription, error) {
+	if row.Err != nil {
+		return nil, row.Err
+	}
+
+	cmd := ownCommand(access, row.Cmd)
+	return domain.NewSubscription(
+		uuid.Nil,
+		cmd.UserID,
+		cmd.ServiceName,
+		cmd.Price,
+		cmd.StartDate,
+		cmd.EndDate,
+	)
+}
+
+func (r *ImportResult) add(line int, sub *domain.Subscription, err error) {
+	if err != nil {
+		r.Failed++
+		r.Rows = append(r.Rows, ImportRowResult{Line: line, Err: err})
+		return
+	}
+
+	id := sub.ID()
+	r.Created++
+	r.Rows = append(r.Rows, ImportRowResult{Line: line, ID: &id})
+}
+
+// reject отчёт отклонённого atomic импорта: ничего не создано
+func (r *ImportResult) reject() *ImportResult {
+	for i := range r.Rows {
+		r.Rows[i].ID = nil
+	}
+	r.Created = 0
+	return r
+}
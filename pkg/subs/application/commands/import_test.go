@@ -0,0 +1,120 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	domain "github.com/end1essrage/efmob-tz/pkg/subs/domain"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// importRepo считает записи и отмечает, открыта ли транзакция
+type importRepo struct {
+	MockRepository
+	inTx    bool
+	txCount int
+	created int
+	events  int
+	failOn  int // номер записи, на которой Create вернет ошибку, 0 - без ошибок
+}
+
+func (r *importRepo) RunInTransaction(ctx context.Context, fn func(tx domain.TxSubscriptionRepository) error) error {
+	r.inTx = true
+	r.txCount++
+	defer func() { r.inTx = false }()
+
+	created, events := r.created, r.events
+	if err := fn(r); err != nil {
+		r.created, r.events = created, events
+		return err
+	}
+	return nil
+}
+
+func (r *importRepo) Create(ctx context.Context, sub *domain.Subscription) (uuid.UUID, error) {
+	if r.failOn > 0 && r.created+1 == r.failOn {
+		return uuid.Nil, errors.New("db is down")
+	}
+	r.created++
+	return sub.ID(), nil
+}
+
+func (r *importRepo) CreateEvent(ctx context.Context, event domain.Event) error {
+	r.events++
+	return nil
+}
+
+// importRows источник, который проверяет, что строки читаются вне транзакции
+type importRows struct {
+	t    *testing.T
+	repo *importRepo
+	rows []*ImportRow
+}
+
+func (s *importRows) Next() (*ImportRow, error) {
+	require.False(s.t, s.repo.inTx, "тело импорта читается внутри транзакции")
+	if len(s.rows) == 0 {
+		return nil, io.EOF
+	}
+	row := s.rows[0]
+	s.rows = s.rows[1:]
+	return row, nil
+}
+
+func importRow(line int, price int) *ImportRow {
+	return &ImportRow{Line: line, Cmd: CreateSubscriptionCommand{
+		UserID:      uuid.New(),
+		ServiceName: "Netflix",
+		Price:       price,
+		StartDate:   time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC),
+	}}
+}
+
+func TestImportAtomic_ReadsSourceBeforeTransaction(t *testing.T) {
+	repo := &importRepo{}
+	source := &importRows{t: t, repo: repo, rows: []*ImportRow{importRow(2, 100), importRow(3, 200), importRow(4, 300)}}
+
+	result, err := NewImportSubscriptionsHandler(repo).Handle(adminCtx(), ImportSubscriptionsCommand{Mode: ImportAtomic, Source: source})
+	require.NoError(t, err)
+	require.Equal(t, 3, result.Created)
+	require.Equal(t, 0, result.Failed)
+	require.Equal(t, 1, repo.txCount)
+	require.Equal(t, 3, repo.created)
+	require.Equal(t, 3, repo.events)
+	for _, row := range result.Rows {
+		require.NotNil(t, row.ID)
+	}
+}
+
+func TestImportAtomic_InvalidRowSkipsTransaction(t *testing.T) {
+	repo := &importRepo{}
+	source := &importRows{t: t, repo: repo, rows: []*ImportRow{importRow(2, 100), importRow(3, -1), importRow(4, 300)}}
+
+	result, err := NewImportSubscriptionsHandler(repo).Handle(adminCtx(), ImportSubscriptionsCommand{Mode: ImportAtomic, Source: source})
+	require.NoError(t, err)
+	require.Equal(t, 0, result.Created)
+	require.Equal(t, 1, result.Failed)
+	require.Len(t, result.Rows, 3)
+	require.Error(t, result.Rows[1].Err)
+	require.Equal(t, 0, repo.txCount)
+	for _, row := range result.Rows {
+		require.Nil(t, row.ID)
+	}
+}
+
+func TestImportAtomic_WriteErrorRollsBack(t *testing.T) {
+	repo := &importRepo{failOn: 2}
+	source := &importRows{t: t, repo: repo, rows: []*ImportRow{importRow(2, 100), importRow(3, 200), importRow(4, 300)}}
+
+	result, err := NewImportSubscriptionsHandler(repo).Handle(adminCtx(), ImportSubscriptionsCommand{Mode: ImportAtomic, Source: source})
+	require.NoError(t, err)
+	require.Equal(t, 0, result.Created)
+	require.Equal(t, 1, result.Failed)
+	require.Equal(t, 3, result.Rows[1].Line)
+	require.Error(t, result.Rows[1].Err)
+	require.Equal(t, 0, repo.created)
+}
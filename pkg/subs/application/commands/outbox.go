@@ -0,0 +1,55 @@
+package commands
+
+import (
+	"context"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/logger"
+	"github.com/end1essrage/efmob-tz/pkg/common/tracing"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application"
+	"github.com/google/uuid"
+)
+
+type RequeueOutboxCommand struct {
+	IDs []uuid.UUID
+	// All все отложенные события тенанта, IDs должен быть пустым
+	All bool
+}
+
+type RequeueOutboxHandler struct {
+	repo application.OutboxRepository
+}
+
+func NewRequeueOutboxHandler(repo application.OutboxRepository) *RequeueOutboxHandler {
+	return &RequeueOutboxHandler{repo: repo}
+}
+
+// Handle возвращает отложенные после ошибок события в очередь без ожидания отсрочки
+func (h *RequeueOutboxHandler) Handle(ctx context.Context, cmd RequeueOutboxCommand) (int64, error) {
+	ctx, span := tracing.Start(ctx, "RequeueOutboxHandler.Handle")
+	defer span.End()
+
+	log := logger.Logger().WithFields(logger.LogOptions{
+		Pkg:  "RequeueOutboxHandler",
+		Func: "Handle",
+		Ctx:  ctx,
+	})
+
+	if _, err := application.Authorize(ctx, application.ActionManageOutbox); err != nil {
+		log.Warn(err)
+		return 0, err
+	}
+
+	// случайный запрос без ids не должен трогать всю очередь
+	if cmd.All == (len(cmd.IDs) > 0) {
+		return 0, application.NewErrorValidationCommand("укажите ids событий или all")
+	}
+
+	n, err := h.repo.RequeueOutboxEvents(ctx, cmd.IDs)
+	if err != nil {
+		log.Errorf("ошибка возврата событий в очередь: %v", err)
+		return 0, err
+	}
+
+	log.Infof("в очередь возвращено событий: %d", n)
+	return n, nil
+}
@@ -0,0 +1,60 @@
+package commands
+
+import (
+	"context"
+	"testing"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/auth"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockOutboxRepository реализация для тестов
+type MockOutboxRepository struct {
+	mock.Mock
+}
+
+func (m *MockOutboxRepository) ListOutboxEvents(ctx context.Context, filter application.OutboxFilter) ([]application.OutboxEvent, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]application.OutboxEvent), args.Error(1)
+}
+
+func (m *MockOutboxRepository) OutboxStats(ctx context.Context) (application.OutboxStats, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(application.OutboxStats), args.Error(1)
+}
+
+func (m *MockOutboxRepository) RequeueOutboxEvents(ctx context.Context, ids []uuid.UUID) (int64, error) {
+	args := m.Called(ctx, ids)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func TestRequeueOutboxHandler(t *testing.T) {
+	repo := &MockOutboxRepository{}
+	h := NewRequeueOutboxHandler(repo)
+	ids := []uuid.UUID{uuid.New()}
+
+	// нужно ровно одно: ids или all
+	var valErr *application.ErrorValidationCommand
+	_, err := h.Handle(adminCtx(), RequeueOutboxCommand{})
+	require.ErrorAs(t, err, &valErr)
+	_, err = h.Handle(adminCtx(), RequeueOutboxCommand{IDs: ids, All: true})
+	require.ErrorAs(t, err, &valErr)
+
+	user := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "u", UserID: uuid.New()})
+	_, err = h.Handle(user, RequeueOutboxCommand{All: true})
+	require.ErrorIs(t, err, application.ErrForbidden)
+
+	repo.On("RequeueOutboxEvents", mock.Anything, ids).Return(int64(1), nil).Once()
+	n, err := h.Handle(adminCtx(), RequeueOutboxCommand{IDs: ids})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+
+	repo.On("RequeueOutboxEvents", mock.Anything, []uuid.UUID(nil)).Return(int64(3), nil).Once()
+	n, err = h.Handle(adminCtx(), RequeueOutboxCommand{All: true})
+	require.NoError(t, err)
+	require.Equal(t, int64(3), n)
+	repo.AssertExpectations(t)
+}
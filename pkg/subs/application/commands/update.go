@@ -2,11 +2,14 @@ package commands
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/end1essrage/efmob-tz/pkg/common/logger"
+	"github.com/end1essrage/efmob-tz/pkg/common/tracing"
 	"github.com/end1essrage/efmob-tz/pkg/subs/application"
 	domain "github.com/end1essrage/efmob-tz/pkg/subs/domain"
+	subsMetrics "github.com/end1essrage/efmob-tz/pkg/subs/metrics"
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 )
@@ -17,6 +20,9 @@ type UpdateSubscriptionCommand struct {
 	StartDate      *time.Time
 	EndDate        *time.Time
 	SetEndDateNull bool
+
+	// ExpectedVersion - версия, которую видел клиент (If-Match), nil - без проверки
+	ExpectedVersion *int
 }
 
 type UpdateSubscriptionHandler struct {
@@ -38,17 +44,29 @@ func (h *UpdateSubscriptionHandler) Validate(sub *domain.Subscription, cmd Updat
 	return nil
 }
 
-func (h *UpdateSubscriptionHandler) Handle(ctx context.Context, cmd UpdateSubscriptionCommand) error {
+func (h *UpdateSubscriptionHandler) Handle(ctx context.Context, cmd UpdateSubscriptionCommand) (*domain.Subscription, error) {
+	ctx, span := tracing.Start(ctx, "UpdateSubscriptionHandler.Handle")
+	defer span.End()
+
 	log := logger.Logger().WithFields(logger.LogOptions{
 		Pkg:  "UpdateSubscriptionHandler",
 		Func: "Handle",
 		Ctx:  ctx,
 	})
 
+	access, err := application.Authorize(ctx, application.ActionUpdateSubscription)
+	if err != nil {
+		log.Warn(err)
+		return nil, err
+	}
+
 	sub, err := h.repo.GetByID(ctx, cmd.ID)
+	if err == nil {
+		err = access.CheckOwner(sub.UserID())
+	}
 	if err != nil {
 		log.Errorf("getting error: %v", err)
-		return err
+		return nil, err
 	}
 
 	// обогащаем лог
@@ -56,9 +74,14 @@ func (h *UpdateSubscriptionHandler) Handle(ctx context.Context, cmd UpdateSubscr
 
 	log.Info("запись успешно найдена")
 
+	if cmd.ExpectedVersion != nil && *cmd.ExpectedVersion != sub.Version() {
+		log.Warnf("версия не совпадает: ожидалась %d, текущая %d", *cmd.ExpectedVersion, sub.Version())
+		return nil, application.ErrPreconditionFailed
+	}
+
 	if err := h.Validate(sub, cmd); err != nil {
 		log.Errorf("validation error: %v", err)
-		return err
+		return nil, err
 	}
 
 	if cmd.Price != nil {
@@ -66,7 +89,7 @@ func (h *UpdateSubscriptionHandler) Handle(ctx context.Context, cmd UpdateSubscr
 
 		if err := sub.ChangePrice(*cmd.Price); err != nil {
 			log.Errorf("price changing error: %v", err)
-			return err
+			return nil, err
 		}
 
 		log.WithFields(logrus.Fields{
@@ -98,8 +121,14 @@ func (h *UpdateSubscriptionHandler) Handle(ctx context.Context, cmd UpdateSubscr
 
 	if err := h.repo.Update(ctx, sub); err != nil {
 		log.Errorf("updating error: %v", err)
-		return err
+		// клиент ждал конкретную версию - запись изменилась между чтением и записью
+		if cmd.ExpectedVersion != nil && errors.Is(err, application.ErrConcurrentModification) {
+			return nil, application.ErrPreconditionFailed
+		}
+		return nil, err
 	}
 
-	return nil
+	subsMetrics.SubscriptionsUpdatedTotal.Inc()
+
+	return sub, nil
 }
@@ -32,7 +32,7 @@ func (m *MockRepository) Update(ctx context.Context, sub *domain.Subscription) e
 	return args.Error(0)
 }
 
-func (m *MockRepository) Find(ctx context.Context, q domain.SubscriptionQuery, pagination p.Pagination, sorting *p.Sorting) ([]*domain.Subscription, error) {
+func (m *MockRepository) Find(ctx context.Context, q domain.SubscriptionQuery, pagination p.Pagination) ([]*domain.Subscription, error) {
 	return nil, nil
 }
 
@@ -44,6 +44,10 @@ func (m *MockRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	return nil
 }
 
+func (m *MockRepository) DeleteWithVersion(ctx context.Context, id uuid.UUID, version int) error {
+	return nil
+}
+
 // / TestUpdateSubscriptionHandler_Validate тесты для валидации
 func TestUpdateSubscriptionHandler_Validate(t *testing.T) {
 	t.Parallel()
@@ -146,3 +150,65 @@ func TestUpdateSubscriptionHandler_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestUpdateSubscriptionHandler_ExpectedVersion(t *testing.T) {
+	t.Parallel()
+
+	price := 200
+
+	tests := []struct {
+		name      string
+		expected  int
+		updateErr error
+		wantErr   error
+		updated   bool
+	}{
+		{
+			name:     "version matches",
+			expected: 1,
+			updated:  true,
+		},
+		{
+			name:     "stale version",
+			expected: 5,
+			wantErr:  application.ErrPreconditionFailed,
+		},
+		{
+			name:      "modified between read and write",
+			expected:  1,
+			updateErr: application.ErrConcurrentModification,
+			wantErr:   application.ErrPreconditionFailed,
+			updated:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, err := domain.NewSubscription(uuid.New(), uuid.New(), "service", 100, time.Now(), nil)
+			assert.NoError(t, err)
+
+			repo := new(MockRepository)
+			repo.On("GetByID", mock.Anything, sub.ID()).Return(sub, nil)
+			repo.On("Update", mock.Anything, sub).Return(tt.updateErr)
+
+			handler := NewUpdateSubscriptionHandler(repo)
+			_, err = handler.Handle(context.Background(), UpdateSubscriptionCommand{
+				ID:              sub.ID(),
+				Price:           &price,
+				ExpectedVersion: &tt.expected,
+			})
+
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+
+			if tt.updated {
+				repo.AssertCalled(t, "Update", mock.Anything, sub)
+			} else {
+				repo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
@@ -1,33 +1,58 @@
 package container
 
 import (
+	"github.com/end1essrage/efmob-tz/pkg/subs/application"
 	cmd "github.com/end1essrage/efmob-tz/pkg/subs/application/commands"
 	quer "github.com/end1essrage/efmob-tz/pkg/subs/application/queries"
 	domain "github.com/end1essrage/efmob-tz/pkg/subs/domain"
 )
 
 type Container struct {
-	CreateSubscriptionHandler *cmd.CreateSubscriptionHandler
-	UpdateSubscriptionHandler *cmd.UpdateSubscriptionHandler
-	DeleteSubscriptionHandler *cmd.DeleteSubscriptionHandler
+	CreateSubscriptionHandler  *cmd.CreateSubscriptionHandler
+	UpdateSubscriptionHandler  *cmd.UpdateSubscriptionHandler
+	DeleteSubscriptionHandler  *cmd.DeleteSubscriptionHandler
+	ImportSubscriptionsHandler *cmd.ImportSubscriptionsHandler
+	IssueFeedTokenHandler      *cmd.IssueFeedTokenHandler
+	IssueAPIKeyHandler         *cmd.IssueAPIKeyHandler
+	RotateAPIKeyHandler        *cmd.RotateAPIKeyHandler
+	RevokeAPIKeyHandler        *cmd.RevokeAPIKeyHandler
+	RequeueOutboxHandler       *cmd.RequeueOutboxHandler
 
-	GetSubscriptionHandler   *quer.GetSubscriptionHandler
-	ListSubscriptionsHandler *quer.ListSubscriptionsHandler
-	TotalCostHandler         *quer.TotalCostHandler
+	GetSubscriptionHandler     *quer.GetSubscriptionHandler
+	ListSubscriptionsHandler   *quer.ListSubscriptionsHandler
+	TotalCostHandler           *quer.TotalCostHandler
+	ExportSubscriptionsHandler *quer.ExportSubscriptionsHandler
+	RenewalsHandler            *quer.RenewalsHandler
+	ListAPIKeysHandler         *quer.ListAPIKeysHandler
+	ListOutboxHandler          *quer.ListOutboxHandler
 }
 
 func NewContainer(
 	subRepo domain.SubscriptionRepository, // для queries
 	subRepoTx domain.SubscriptionRepositoryWithTx, // для commands с транзакциями
 	statsRepo domain.SubscriptionStatsRepository,
+	streamRepo domain.SubscriptionStreamRepository,
+	feedRepo domain.FeedTokenRepository,
+	apiKeyRepo application.APIKeyRepository,
+	outboxRepo application.OutboxRepository,
 ) *Container {
 	return &Container{
-		CreateSubscriptionHandler: cmd.NewCreateSubscriptionHandler(subRepoTx),
-		UpdateSubscriptionHandler: cmd.NewUpdateSubscriptionHandler(subRepo),
-		DeleteSubscriptionHandler: cmd.NewDeleteSubscriptionHandler(subRepoTx),
+		CreateSubscriptionHandler:  cmd.NewCreateSubscriptionHandler(subRepoTx),
+		UpdateSubscriptionHandler:  cmd.NewUpdateSubscriptionHandler(subRepo),
+		DeleteSubscriptionHandler:  cmd.NewDeleteSubscriptionHandler(subRepoTx),
+		ImportSubscriptionsHandler: cmd.NewImportSubscriptionsHandler(subRepoTx),
+		IssueFeedTokenHandler:      cmd.NewIssueFeedTokenHandler(feedRepo),
+		IssueAPIKeyHandler:         cmd.NewIssueAPIKeyHandler(apiKeyRepo),
+		RotateAPIKeyHandler:        cmd.NewRotateAPIKeyHandler(apiKeyRepo),
+		RevokeAPIKeyHandler:        cmd.NewRevokeAPIKeyHandler(apiKeyRepo),
+		RequeueOutboxHandler:       cmd.NewRequeueOutboxHandler(outboxRepo),
 
-		GetSubscriptionHandler:   quer.NewGetSubscriptionHandler(subRepo),
-		ListSubscriptionsHandler: quer.NewListSubscriptionsHandler(subRepo),
-		TotalCostHandler:         quer.NewTotalCostHandler(statsRepo),
+		GetSubscriptionHandler:     quer.NewGetSubscriptionHandler(subRepo),
+		ListSubscriptionsHandler:   quer.NewListSubscriptionsHandler(subRepo, statsRepo),
+		TotalCostHandler:           quer.NewTotalCostHandler(statsRepo),
+		ExportSubscriptionsHandler: quer.NewExportSubscriptionsHandler(streamRepo),
+		RenewalsHandler:            quer.NewRenewalsHandler(feedRepo, streamRepo),
+		ListAPIKeysHandler:         quer.NewListAPIKeysHandler(apiKeyRepo),
+		ListOutboxHandler:          quer.NewListOutboxHandler(outboxRepo),
 	}
 }
@@ -5,12 +5,13 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/end1essrage/efmob-tz/pkg/common/auth"
 	"github.com/end1essrage/efmob-tz/pkg/subs/domain"
 )
 
 // infra errors map
 var ErrConcurrentModification = errors.New("concurrent modification")
-var ErrInvalidSortingField = errors.New("invalid sorting field")
+var ErrPreconditionFailed = errors.New("precondition failed")
 
 type ErrorRetriesExceeded struct {
 	err error
@@ -84,19 +85,38 @@ func MapError(err error) *AppError {
 	// Infra errors
 	case errors.Is(err, ErrConcurrentModification):
 		return &AppError{Err: err, HTTPStatus: http.StatusConflict, Code: "CONCURRENT_MODIFICATION"}
-	case errors.Is(err, ErrInvalidSortingField):
-		return &AppError{Err: err, HTTPStatus: http.StatusBadRequest, Code: "INVALID_SORTING_FIELD"}
+	case errors.Is(err, ErrPreconditionFailed):
+		return &AppError{Err: err, HTTPStatus: http.StatusPreconditionFailed, Code: "PRECONDITION_FAILED"}
+	case errors.Is(err, ErrForbidden):
+		return &AppError{Err: err, HTTPStatus: http.StatusForbidden, Code: "FORBIDDEN"}
 	// Subscription domain errors
+	case errors.Is(err, domain.ErrInvalidUserID):
+		return &AppError{Err: err, HTTPStatus: http.StatusBadRequest, Code: "INVALID_USER_ID"}
 	case errors.Is(err, domain.ErrInvalidServiceName):
 		return &AppError{Err: err, HTTPStatus: http.StatusBadRequest, Code: "INVALID_SERVICE_NAME"}
 	case errors.Is(err, domain.ErrInvalidPrice):
 		return &AppError{Err: err, HTTPStatus: http.StatusBadRequest, Code: "INVALID_PRICE"}
 	case errors.Is(err, domain.ErrInvalidDates):
 		return &AppError{Err: err, HTTPStatus: http.StatusBadRequest, Code: "INVALID_DATES"}
+	case errors.Is(err, domain.ErrInvalidDateFormat):
+		return &AppError{Err: err, HTTPStatus: http.StatusBadRequest, Code: "INVALID_DATE"}
 	case errors.Is(err, domain.ErrInvalidPeriod):
 		return &AppError{Err: err, HTTPStatus: http.StatusBadRequest, Code: "INVALID_PERIOD"}
 	case errors.Is(err, domain.ErrSubscriptionNotFound):
 		return &AppError{Err: err, HTTPStatus: http.StatusNotFound, Code: "NOT_FOUND"}
+	case errors.Is(err, domain.ErrInvalidSortField):
+		return &AppError{Err: err, HTTPStatus: http.StatusBadRequest, Code: "INVALID_SORTING_FIELD"}
+	case errors.Is(err, domain.ErrInvalidPriceRange):
+		return &AppError{Err: err, HTTPStatus: http.StatusBadRequest, Code: "INVALID_PRICE_RANGE"}
+	case errors.Is(err, domain.ErrInvalidFilter):
+		return &AppError{Err: err, HTTPStatus: http.StatusBadRequest, Code: "INVALID_FILTER"}
+	case errors.Is(err, domain.ErrFeedTokenNotFound):
+		return &AppError{Err: err, HTTPStatus: http.StatusNotFound, Code: "FEED_NOT_FOUND"}
+	// API keys errors
+	case errors.Is(err, auth.ErrAPIKeyNotFound):
+		return &AppError{Err: err, HTTPStatus: http.StatusNotFound, Code: "API_KEY_NOT_FOUND"}
+	case errors.Is(err, auth.ErrAPIKeyRevoked):
+		return &AppError{Err: err, HTTPStatus: http.StatusConflict, Code: "API_KEY_REVOKED"}
 	// Default - 500 Internal Server Error
 	default:
 		return &AppError{Err: err, HTTPStatus: http.StatusInternalServerError, Code: "INTERNAL_ERROR"}
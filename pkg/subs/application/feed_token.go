@@ -0,0 +1,32 @@
+package application
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// длина токена календарного фида в байтах до кодирования
+const feedTokenSize = 32
+
+// NewFeedToken генерирует случайный токен календарного фида
+func NewFeedToken() (string, error) {
+	buf := make([]byte, feedTokenSize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// HashFeedToken в хранилище лежит только хэш токена
+func HashFeedToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// FeedTokenMatches сравнивает токен с хэшем за постоянное время
+func FeedTokenMatches(token, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashFeedToken(token)), []byte(hash)) == 1
+}
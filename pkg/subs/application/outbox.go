@@ -0,0 +1,49 @@
+package application
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OutboxEvent событие outbox, ожидающее публикации
+type OutboxEvent struct {
+	ID          uuid.UUID
+	AggregateID uuid.UUID
+	Type        string
+	Payload     []byte
+	CreatedAt   time.Time
+	// Attempts неудачных публикаций, LastError - ошибка последней
+	Attempts  int
+	LastError string
+	// NextAttemptAt повтор отложен до этого времени, nil - в очереди
+	NextAttemptAt *time.Time
+}
+
+// OutboxFilter фильтр событий, пустые поля не фильтруют
+type OutboxFilter struct {
+	Type        string
+	AggregateID *uuid.UUID
+	// Failed только события с неудачными публикациями
+	Failed bool
+	Limit  int
+}
+
+// OutboxStats сводка по очереди тенанта
+type OutboxStats struct {
+	Pending int64
+	// Failed события с неудачными публикациями
+	Failed int64
+	Oldest *time.Time
+}
+
+// OutboxRepository просмотр и повтор событий outbox тенанта из контекста
+type OutboxRepository interface {
+	// ListOutboxEvents события от старых к новым
+	ListOutboxEvents(ctx context.Context, filter OutboxFilter) ([]OutboxEvent, error)
+	OutboxStats(ctx context.Context) (OutboxStats, error)
+	// RequeueOutboxEvents сбрасывает отсрочку: события публикуются в следующем проходе воркера.
+	// Пустой ids - все отложенные. Возвращает число событий в очереди
+	RequeueOutboxEvents(ctx context.Context, ids []uuid.UUID) (int64, error)
+}
@@ -0,0 +1,215 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/auth"
+	"github.com/end1essrage/efmob-tz/pkg/common/logger"
+	"github.com/end1essrage/efmob-tz/pkg/subs/domain"
+	"github.com/google/uuid"
+)
+
+var ErrForbidden = errors.New("forbidden")
+
+// Permission право вида "<ресурс>:<действие>:<чьи>", совпадает со скоупами токена
+type Permission string
+
+const (
+	PermReadOwn  Permission = "subs:read:own"
+	PermReadAny  Permission = "subs:read:any"
+	PermWriteOwn Permission = "subs:write:own"
+	PermWriteAny Permission = "subs:write:any"
+	// PermManageAPIKeys выпуск и отзыв ключей API, права на чужие данные не дает
+	PermManageAPIKeys Permission = "apikeys:manage"
+	// PermManageOutbox просмотр и повтор событий outbox
+	PermManageOutbox Permission = "outbox:manage"
+)
+
+// Action операция приложения, для каждой есть правило в policy
+type Action string
+
+const (
+	ActionCreateSubscription  Action = "CreateSubscription"
+	ActionImportSubscriptions Action = "ImportSubscriptions"
+	ActionUpdateSubscription  Action = "UpdateSubscription"
+	ActionDeleteSubscription  Action = "DeleteSubscription"
+	ActionIssueFeedToken      Action = "IssueFeedToken"
+	ActionGetSubscription     Action = "GetSubscription"
+	ActionListSubscriptions   Action = "ListSubscriptions"
+	ActionExportSubscriptions Action = "ExportSubscriptions"
+	ActionTotalCost           Action = "TotalCost"
+	ActionManageAPIKeys       Action = "ManageAPIKeys"
+	ActionManageOutbox        Action = "ManageOutbox"
+)
+
+// Rule Any дает доступ к данным всех пользователей, Own - только к своим.
+// Пустой Own - операция без данных владельца, только Any
+type Rule struct {
+	Any Permission
+	Own Permission
+}
+
+// Роли из токена
+const (
+	RoleUser    = "user"
+	RoleSupport = "support"
+	RoleAdmin   = "admin"
+)
+
+var (
+	readRule  = Rule{Any: PermReadAny, Own: PermReadOwn}
+	writeRule = Rule{Any: PermWriteAny, Own: PermWriteOwn}
+
+	// policy права на операции - единственное место, где они задаются
+	policy = map[Action]Rule{
+		ActionCreateSubscription:  writeRule,
+		ActionImportSubscriptions: writeRule,
+		ActionUpdateSubscription:  writeRule,
+		ActionDeleteSubscription:  writeRule,
+		ActionIssueFeedToken:      writeRule,
+		ActionGetSubscription:     readRule,
+		ActionListSubscriptions:   readRule,
+		ActionExportSubscriptions: readRule,
+		ActionTotalCost:           readRule,
+		ActionManageAPIKeys:       {Any: PermManageAPIKeys},
+		ActionManageOutbox:        {Any: PermManageOutbox},
+	}
+
+	// rolePermissions права ролей, скоупы токена добавляются к ним
+	rolePermissions = map[string][]Permission{
+		RoleUser:    {PermReadOwn, PermWriteOwn},
+		RoleSupport: {PermReadAny, PermWriteOwn},
+		RoleAdmin:   {PermReadAny, PermWriteAny, PermManageAPIKeys, PermManageOutbox},
+	}
+)
+
+// Policy копия таблицы прав для аудита
+func Policy() map[Action]Rule {
+	res := make(map[Action]Rule, len(policy))
+	for a, r := range policy {
+		res[a] = r
+	}
+	return res
+}
+
+// KnownPermission право из policy, только такие скоупы выдаются ключам API
+func KnownPermission(perm Permission) bool {
+	for _, r := range policy {
+		if perm == r.Any || (perm == r.Own && r.Own != "") {
+			return true
+		}
+	}
+	return false
+}
+
+// Permissions права principal: права ролей и скоупы.
+// Без ролей и скоупов - права роли user
+func Permissions(p *auth.Principal) []Permission {
+	var perms []Permission
+	for _, role := range p.Roles {
+		perms = append(perms, rolePermissions[role]...)
+	}
+	for _, s := range p.Scopes {
+		perms = append(perms, Permission(s))
+	}
+	if len(p.Roles) == 0 && len(p.Scopes) == 0 {
+		perms = rolePermissions[RoleUser]
+	}
+	return perms
+}
+
+// Access результат проверки прав: ограничен ли вызывающий своими подписками
+type Access struct {
+	owner      uuid.UUID
+	restricted bool
+}
+
+// Authorize проверяет право principal на операцию, вызывается первым в каждом хендлере.
+// Вызов без principal - внутренний, без ограничений
+func Authorize(ctx context.Context, action Action) (Access, error) {
+	p, ok := auth.FromContext(ctx)
+	if !ok {
+		return Access{}, nil
+	}
+
+	rule, ok := policy[action]
+	if !ok {
+		// операция без правила недоступна никому
+		return Access{}, deny(ctx, p, action)
+	}
+
+	perms := Permissions(p)
+	switch {
+	case slices.Contains(perms, rule.Any):
+		return Access{}, nil
+	case rule.Own != "" && slices.Contains(perms, rule.Own) && p.UserID != uuid.Nil:
+		return Access{owner: p.UserID, restricted: true}, nil
+	default:
+		return Access{}, deny(ctx, p, action)
+	}
+}
+
+func deny(ctx context.Context, p *auth.Principal, action Action) error {
+	logger.Logger().WithFields(logger.LogOptions{
+		Pkg:  "application",
+		Func: "Authorize",
+		Ctx:  ctx,
+	}).Warnf("доступ запрещен: %s для %s (роли %v, скоупы %v)", action, p.Subject, p.Roles, p.Scopes)
+	return ErrForbidden
+}
+
+// CheckGrant выдаваемые скоупы должны быть у самого principal - ключ API не дает больше прав, чем у выдающего.
+// Вызов без principal - внутренний, без ограничений
+func CheckGrant(ctx context.Context, scopes []string) error {
+	p, ok := auth.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	perms := Permissions(p)
+	for _, s := range scopes {
+		if !slices.Contains(perms, Permission(s)) {
+			logger.Logger().WithFields(logger.LogOptions{
+				Pkg:  "application",
+				Func: "CheckGrant",
+				Ctx:  ctx,
+			}).Warnf("доступ запрещен: выдача скоупа %s для %s (роли %v, скоупы %v)", s, p.Subject, p.Roles, p.Scopes)
+			return ErrForbidden
+		}
+	}
+	return nil
+}
+
+// Owner пользователь, которым ограничен доступ, false - ограничения нет
+func (a Access) Owner() (uuid.UUID, bool) {
+	return a.owner, a.restricted
+}
+
+// CheckOwner чужие подписки для ограниченного вызывающего не существуют
+func (a Access) CheckOwner(userID uuid.UUID) error {
+	if a.restricted && a.owner != userID {
+		return domain.ErrSubscriptionNotFound
+	}
+	return nil
+}
+
+// ScopeUsers сужает фильтр по пользователям до владельца.
+// Запрос только чужих пользователей - ErrSubscriptionNotFound
+func (a Access) ScopeUsers(userID *uuid.UUID, userIDs []uuid.UUID) (*uuid.UUID, []uuid.UUID, error) {
+	if !a.restricted {
+		return userID, userIDs, nil
+	}
+
+	requested := userIDs
+	if userID != nil {
+		requested = append([]uuid.UUID{*userID}, userIDs...)
+	}
+	if len(requested) > 0 && !slices.Contains(requested, a.owner) {
+		return nil, nil, domain.ErrSubscriptionNotFound
+	}
+
+	owner := a.owner
+	return &owner, nil, nil
+}
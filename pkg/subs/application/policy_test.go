@@ -0,0 +1,148 @@
+package application
+
+import (
+	"context"
+	"testing"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/auth"
+	"github.com/end1essrage/efmob-tz/pkg/subs/domain"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+var allActions = []Action{
+	ActionCreateSubscription,
+	ActionImportSubscriptions,
+	ActionUpdateSubscription,
+	ActionDeleteSubscription,
+	ActionIssueFeedToken,
+	ActionGetSubscription,
+	ActionListSubscriptions,
+	ActionExportSubscriptions,
+	ActionTotalCost,
+	ActionManageAPIKeys,
+	ActionManageOutbox,
+}
+
+func TestPolicy_EveryActionHasRule(t *testing.T) {
+	table := Policy()
+	require.Len(t, table, len(allActions))
+	for _, a := range allActions {
+		rule, ok := table[a]
+		require.True(t, ok, a)
+		require.NotEmpty(t, rule.Any, a)
+		if a != ActionManageAPIKeys && a != ActionManageOutbox {
+			require.NotEmpty(t, rule.Own, a)
+		}
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	userID := uuid.New()
+	principal := func(roles []string, scopes ...string) context.Context {
+		return auth.WithPrincipal(context.Background(), &auth.Principal{
+			Subject: userID.String(), UserID: userID, Roles: roles, Scopes: scopes,
+		})
+	}
+
+	const (
+		accessAny  = "any"
+		accessOwn  = "own"
+		accessNone = "forbidden"
+	)
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		action Action
+		want   string
+	}{
+		{"internal call", context.Background(), ActionDeleteSubscription, accessAny},
+		{"default role reads own", principal(nil), ActionListSubscriptions, accessOwn},
+		{"user writes own", principal([]string{RoleUser}), ActionCreateSubscription, accessOwn},
+		{"support lists all", principal([]string{RoleSupport}), ActionListSubscriptions, accessAny},
+		{"support totals all", principal([]string{RoleSupport}), ActionTotalCost, accessAny},
+		{"support writes own", principal([]string{RoleSupport}), ActionUpdateSubscription, accessOwn},
+		{"admin writes any", principal([]string{RoleAdmin}), ActionDeleteSubscription, accessAny},
+		{"read scope only", principal(nil, string(PermReadOwn)), ActionCreateSubscription, accessNone},
+		{"scope extends role", principal([]string{RoleUser}, string(PermReadAny)), ActionExportSubscriptions, accessAny},
+		{"unknown role", principal([]string{"guest"}), ActionGetSubscription, accessNone},
+		{"unknown action", principal([]string{RoleAdmin}), Action("Unknown"), accessNone},
+		{"admin manages api keys", principal([]string{RoleAdmin}), ActionManageAPIKeys, accessAny},
+		{"user can't manage api keys", principal([]string{RoleUser}), ActionManageAPIKeys, accessNone},
+		{"manage scope", principal(nil, string(PermManageAPIKeys)), ActionManageAPIKeys, accessAny},
+		{"admin manages outbox", principal([]string{RoleAdmin}), ActionManageOutbox, accessAny},
+		{"support can't manage outbox", principal([]string{RoleSupport}), ActionManageOutbox, accessNone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			access, err := Authorize(tt.ctx, tt.action)
+			if tt.want == accessNone {
+				require.ErrorIs(t, err, ErrForbidden)
+				require.Equal(t, "FORBIDDEN", MapError(err).Code)
+				return
+			}
+			require.NoError(t, err)
+			owner, restricted := access.Owner()
+			require.Equal(t, tt.want == accessOwn, restricted)
+			if restricted {
+				require.Equal(t, userID, owner)
+			}
+		})
+	}
+}
+
+func TestAuthorize_OwnNeedsUser(t *testing.T) {
+	// сервисный субъект без id пользователя не получает "свои" подписки
+	ctx := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "billing-job"})
+	_, err := Authorize(ctx, ActionGetSubscription)
+	require.ErrorIs(t, err, ErrForbidden)
+}
+
+func TestAccess_ScopeUsers(t *testing.T) {
+	owner, other := uuid.New(), uuid.New()
+	restricted := Access{owner: owner, restricted: true}
+
+	id, ids, err := Access{}.ScopeUsers(&other, []uuid.UUID{owner})
+	require.NoError(t, err)
+	require.Equal(t, &other, id)
+	require.Equal(t, []uuid.UUID{owner}, ids)
+
+	tests := []struct {
+		name    string
+		userID  *uuid.UUID
+		userIDs []uuid.UUID
+		err     error
+	}{
+		{name: "no filter"},
+		{name: "own id", userID: &owner},
+		{name: "own among others", userIDs: []uuid.UUID{other, owner}},
+		{name: "other user", userID: &other, err: domain.ErrSubscriptionNotFound},
+		{name: "other users", userIDs: []uuid.UUID{other}, err: domain.ErrSubscriptionNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, ids, err := restricted.ScopeUsers(tt.userID, tt.userIDs)
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, owner, *id)
+			require.Empty(t, ids)
+		})
+	}
+
+	require.NoError(t, restricted.CheckOwner(owner))
+	require.ErrorIs(t, restricted.CheckOwner(other), domain.ErrSubscriptionNotFound)
+	require.NoError(t, Access{}.CheckOwner(other))
+}
+
+func TestKnownPermission(t *testing.T) {
+	for _, p := range []Permission{PermReadOwn, PermReadAny, PermWriteOwn, PermWriteAny, PermManageAPIKeys} {
+		require.True(t, KnownPermission(p), p)
+	}
+	require.False(t, KnownPermission(""))
+	require.False(t, KnownPermission("subs:delete:any"))
+}
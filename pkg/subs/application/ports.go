@@ -1,7 +1,23 @@
 package application
 
-import "context"
+import (
+	"context"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/auth"
+	"github.com/google/uuid"
+)
 
 type EventPublisher interface {
 	Publish(ctx context.Context, topic string, payload []byte) error
 }
+
+// APIKeyRepository управление ключами API, ошибки auth.ErrAPIKeyNotFound и auth.ErrAPIKeyRevoked
+type APIKeyRepository interface {
+	CreateAPIKey(ctx context.Context, key *auth.APIKey) error
+	ListAPIKeys(ctx context.Context) ([]*auth.APIKey, error)
+	// RotateAPIKey заменяет хэш ключа, старый ключ перестает действовать
+	RotateAPIKey(ctx context.Context, id uuid.UUID, hash, hint string) (*auth.APIKey, error)
+	// RevokeAPIKey повторный отзыв не ошибка
+	RevokeAPIKey(ctx context.Context, id uuid.UUID, at time.Time) error
+}
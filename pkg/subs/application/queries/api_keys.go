@@ -0,0 +1,44 @@
+package queries
+
+import (
+	"context"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/auth"
+	"github.com/end1essrage/efmob-tz/pkg/common/logger"
+	"github.com/end1essrage/efmob-tz/pkg/common/tracing"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application"
+)
+
+type ListAPIKeysQuery struct{}
+
+type ListAPIKeysHandler struct {
+	repo application.APIKeyRepository
+}
+
+func NewListAPIKeysHandler(repo application.APIKeyRepository) *ListAPIKeysHandler {
+	return &ListAPIKeysHandler{repo: repo}
+}
+
+// Handle все ключи, включая отозванные. Секретов в ответе нет - только подсказка начала ключа
+func (h *ListAPIKeysHandler) Handle(ctx context.Context, _ ListAPIKeysQuery) ([]*auth.APIKey, error) {
+	ctx, span := tracing.Start(ctx, "ListAPIKeysHandler.Handle")
+	defer span.End()
+
+	log := logger.Logger().WithFields(logger.LogOptions{
+		Pkg:  "ListAPIKeysHandler",
+		Func: "Handle",
+		Ctx:  ctx,
+	})
+
+	if _, err := application.Authorize(ctx, application.ActionManageAPIKeys); err != nil {
+		log.Warn(err)
+		return nil, err
+	}
+
+	keys, err := h.repo.ListAPIKeys(ctx)
+	if err != nil {
+		log.Errorf("ошибка получения ключей: %v", err)
+		return nil, err
+	}
+	return keys, nil
+}
@@ -0,0 +1,68 @@
+package queries
+
+import (
+	"context"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/logger"
+	p "github.com/end1essrage/efmob-tz/pkg/common/persistance"
+	"github.com/end1essrage/efmob-tz/pkg/common/tracing"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application"
+	domain "github.com/end1essrage/efmob-tz/pkg/subs/domain"
+)
+
+type ExportSubscriptionsQuery struct {
+	StartFrom  *time.Time
+	StartTo    *time.Time
+	EndFrom    *time.Time
+	EndTo      *time.Time
+	WithNilEnd *bool
+
+	Filters SubscriptionFilters
+	Sorting *p.Sorting
+}
+
+type ExportSubscriptionsHandler struct {
+	repo domain.SubscriptionStreamRepository
+}
+
+func NewExportSubscriptionsHandler(repo domain.SubscriptionStreamRepository) *ExportSubscriptionsHandler {
+	return &ExportSubscriptionsHandler{repo: repo}
+}
+
+// Handle передаёт подписки в fn по мере чтения из хранилища
+func (h *ExportSubscriptionsHandler) Handle(ctx context.Context, q ExportSubscriptionsQuery, fn func(*domain.Subscription) error) error {
+	ctx, span := tracing.Start(ctx, "ExportSubscriptionsHandler.Handle")
+	defer span.End()
+
+	log := logger.Logger().WithFields(logger.LogOptions{
+		Pkg:  "ExportSubscriptionsHandler",
+		Func: "Handle",
+		Ctx:  ctx,
+	})
+
+	access, err := application.Authorize(ctx, application.ActionExportSubscriptions)
+	if err != nil {
+		log.Warn(err)
+		return err
+	}
+
+	query, err := buildSubscriptionQuery(access, nil, nil, q.StartFrom, q.StartTo, q.EndFrom, q.EndTo, q.WithNilEnd, q.Filters, q.Sorting)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	var count int
+	err = h.repo.Stream(ctx, query, func(sub *domain.Subscription) error {
+		count++
+		return fn(sub)
+	})
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	log.Infof("выгружено подписок: %d", count)
+	return nil
+}
@@ -0,0 +1,82 @@
+package queries
+
+import (
+	"time"
+
+	p "github.com/end1essrage/efmob-tz/pkg/common/persistance"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application"
+	domain "github.com/end1essrage/efmob-tz/pkg/subs/domain"
+	"github.com/google/uuid"
+)
+
+// SubscriptionFilters расширенные фильтры, общие для списка, выгрузки и суммы
+type SubscriptionFilters struct {
+	UserIDs          []uuid.UUID
+	ServiceNames     []string
+	ServiceNameMatch string
+	PriceMin         *int
+	PriceMax         *int
+	CreatedFrom      *time.Time
+	CreatedTo        *time.Time
+	// Expression разобранное выражение filter=
+	Expression domain.FilterExpr
+	// Sort сортировка вида "-price,start_date", приоритетнее Sorting
+	Sort *string
+}
+
+// buildSubscriptionQuery собирает и валидирует доменную квери,
+// пользователь без прав на чужие подписки видит только свои
+func buildSubscriptionQuery(
+	access application.Access,
+	userID *uuid.UUID,
+	serviceName *string,
+	sf, st, ef, et *time.Time,
+	withNilEnd *bool,
+	filters SubscriptionFilters,
+	sorting *p.Sorting,
+) (domain.SubscriptionQuery, error) {
+	var err error
+	userID, filters.UserIDs, err = access.ScopeUsers(userID, filters.UserIDs)
+	if err != nil {
+		return domain.SubscriptionQuery{}, err
+	}
+
+	startPeriod, endPeriod, err := application.Periods(sf, st, ef, et)
+	if err != nil {
+		return domain.SubscriptionQuery{}, err
+	}
+
+	query := domain.NewSubscriptionQuery(userID, serviceName, startPeriod, endPeriod, withNilEnd)
+
+	var createdPeriod *domain.Period
+	if filters.CreatedFrom != nil || filters.CreatedTo != nil {
+		createdPeriod, err = domain.NewPeriod(filters.CreatedFrom, filters.CreatedTo)
+		if err != nil {
+			return query, err
+		}
+	}
+
+	query, err = query.WithFilter(domain.SubscriptionFilter{
+		UserIDs:          filters.UserIDs,
+		ServiceNames:     filters.ServiceNames,
+		ServiceNameMatch: domain.ServiceNameMatch(filters.ServiceNameMatch),
+		PriceMin:         filters.PriceMin,
+		PriceMax:         filters.PriceMax,
+		CreatedPeriod:    createdPeriod,
+		Expression:       filters.Expression,
+	})
+	if err != nil {
+		return query, err
+	}
+
+	var keys []domain.SortKey
+	switch {
+	case filters.Sort != nil:
+		keys = domain.ParseSort(*filters.Sort)
+	case sorting != nil:
+		// order_by/direction - одно поле
+		keys = []domain.SortKey{{Field: domain.SortField(sorting.OrderBy), Desc: sorting.Direction.IsDesc()}}
+	}
+
+	return query.WithSort(keys)
+}
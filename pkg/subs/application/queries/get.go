@@ -4,6 +4,8 @@ import (
 	"context"
 
 	"github.com/end1essrage/efmob-tz/pkg/common/logger"
+	"github.com/end1essrage/efmob-tz/pkg/common/tracing"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application"
 	domain "github.com/end1essrage/efmob-tz/pkg/subs/domain"
 	"github.com/google/uuid"
 )
@@ -21,16 +23,29 @@ func NewGetSubscriptionHandler(repo domain.SubscriptionRepository) *GetSubscript
 }
 
 func (h *GetSubscriptionHandler) Handle(ctx context.Context, q GetSubscriptionQuery) (*domain.Subscription, error) {
+	ctx, span := tracing.Start(ctx, "GetSubscriptionHandler.Handle")
+	defer span.End()
+
 	log := logger.Logger().WithFields(logger.LogOptions{
 		Pkg:  "GetSubscriptionHandler",
 		Func: "Handle",
 		Ctx:  ctx,
 	})
 
+	access, err := application.Authorize(ctx, application.ActionGetSubscription)
+	if err != nil {
+		log.Warn(err)
+		return nil, err
+	}
+
 	r, err := h.repo.GetByID(ctx, q.ID)
+	if err == nil {
+		err = access.CheckOwner(r.UserID())
+	}
 	if err != nil {
 		log.Error(err)
+		return nil, err
 	}
 
-	return r, err
+	return r, nil
 }
@@ -2,10 +2,13 @@ package queries
 
 import (
 	"context"
+	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/end1essrage/efmob-tz/pkg/common/logger"
 	p "github.com/end1essrage/efmob-tz/pkg/common/persistance"
+	"github.com/end1essrage/efmob-tz/pkg/common/tracing"
 	"github.com/end1essrage/efmob-tz/pkg/subs/application"
 	domain "github.com/end1essrage/efmob-tz/pkg/subs/domain"
 	"github.com/google/uuid"
@@ -20,37 +23,118 @@ type ListSubscriptionsQuery struct {
 	EndTo       *time.Time
 	WithNilEnd  *bool
 
+	Filters SubscriptionFilters
+
 	Pagination p.Pagination
 	Sorting    *p.Sorting
+	// WithTotal считать общее кол-во подписок по фильтрам - отдельный запрос
+	WithTotal bool
+}
+
+type ListSubscriptionsResult struct {
+	Items []*domain.Subscription
+	// NextCursor курсор следующей страницы, nil - страница последняя
+	NextCursor *p.Cursor
+	// Total только при WithTotal
+	Total *int
 }
 
 type ListSubscriptionsHandler struct {
-	repo domain.SubscriptionRepository
+	repo  domain.SubscriptionRepository
+	stats domain.SubscriptionStatsRepository
 }
 
-func NewListSubscriptionsHandler(repo domain.SubscriptionRepository) *ListSubscriptionsHandler {
-	return &ListSubscriptionsHandler{repo: repo}
+func NewListSubscriptionsHandler(repo domain.SubscriptionRepository, stats domain.SubscriptionStatsRepository) *ListSubscriptionsHandler {
+	return &ListSubscriptionsHandler{repo: repo, stats: stats}
 }
 
-func (h *ListSubscriptionsHandler) Handle(ctx context.Context, q ListSubscriptionsQuery) ([]*domain.Subscription, error) {
+func (h *ListSubscriptionsHandler) Handle(ctx context.Context, q ListSubscriptionsQuery) (*ListSubscriptionsResult, error) {
+	ctx, span := tracing.Start(ctx, "ListSubscriptionsHandler.Handle")
+	defer span.End()
+
 	log := logger.Logger().WithFields(logger.LogOptions{
 		Pkg:  "ListSubscriptionsHandler",
 		Func: "Handle",
 		Ctx:  ctx,
 	})
 
-	startPeriod, endPeriod, err := application.Periods(q.StartFrom, q.StartTo, q.EndFrom, q.EndTo)
+	access, err := application.Authorize(ctx, application.ActionListSubscriptions)
+	if err != nil {
+		log.Warn(err)
+		return nil, err
+	}
+
+	if q.Pagination.Limit <= 0 || q.Pagination.Limit > p.MaxPageLimit {
+		return nil, application.NewErrorValidationQuery(fmt.Sprintf("page_size should be between 1 and %d", p.MaxPageLimit))
+	}
+
+	query, err := buildSubscriptionQuery(access, q.UserID, q.ServiceName, q.StartFrom, q.StartTo, q.EndFrom, q.EndTo, q.WithNilEnd, q.Filters, q.Sorting)
 	if err != nil {
 		log.Error(err)
 		return nil, err
 	}
 
-	query := domain.NewSubscriptionQuery(q.UserID, q.ServiceName, startPeriod, endPeriod, q.WithNilEnd)
+	sort := domain.FormatSort(query.Sort())
+	if q.Pagination.After != nil && !q.Pagination.After.Matches(sort) {
+		return nil, application.NewErrorValidationQuery("cursor was issued for another sorting")
+	}
+
+	// читаем на одну строку больше - так понятно, есть ли следующая страница
+	pagination := q.Pagination
+	pagination.Limit++
 
-	r, err := h.repo.Find(ctx, query, q.Pagination, q.Sorting)
+	items, err := h.repo.Find(ctx, query, pagination)
 	if err != nil {
 		log.Error(err)
+		return nil, err
+	}
+
+	result := &ListSubscriptionsResult{Items: items}
+	if len(items) > q.Pagination.Limit {
+		result.Items = items[:q.Pagination.Limit]
+		cursor := cursorAfter(result.Items[len(result.Items)-1], query.Sort())
+		result.NextCursor = &cursor
+	}
+
+	if q.WithTotal {
+		total, err := h.stats.CountSubscriptions(ctx, query)
+		if err != nil {
+			log.Error(err)
+			return nil, err
+		}
+		result.Total = &total
+	}
+
+	return result, nil
+}
+
+// cursorAfter курсор, указывающий на строку после sub при заданной сортировке
+func cursorAfter(sub *domain.Subscription, keys []domain.SortKey) p.Cursor {
+	cursor := p.Cursor{
+		Sort:   domain.FormatSort(keys),
+		Values: make([]*string, len(keys)),
+		ID:     sub.ID(),
+	}
+
+	for i, k := range keys {
+		var value string
+		switch k.Field {
+		case domain.SortByPrice:
+			value = strconv.Itoa(sub.Price())
+		case domain.SortByServiceName:
+			value = sub.ServiceName()
+		case domain.SortByStartDate:
+			value = sub.StartDate().Format(time.RFC3339Nano)
+		case domain.SortByEndDate:
+			if sub.EndDate() == nil {
+				continue
+			}
+			value = sub.EndDate().Format(time.RFC3339Nano)
+		case domain.SortByCreatedAt:
+			value = sub.CreatedAt().Format(time.RFC3339Nano)
+		}
+		cursor.Values[i] = &value
 	}
 
-	return r, err
+	return cursor
 }
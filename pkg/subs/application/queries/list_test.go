@@ -0,0 +1,111 @@
+package queries
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	p "github.com/end1essrage/efmob-tz/pkg/common/persistance"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application"
+	domain "github.com/end1essrage/efmob-tz/pkg/subs/domain"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeListRepo отдаёт заранее заданные подписки и запоминает пагинацию
+type fakeListRepo struct {
+	domain.SubscriptionRepository
+	items      []*domain.Subscription
+	pagination p.Pagination
+	count      int
+}
+
+func (f *fakeListRepo) Find(_ context.Context, _ domain.SubscriptionQuery, pagination p.Pagination) ([]*domain.Subscription, error) {
+	f.pagination = pagination
+	if len(f.items) > pagination.Limit {
+		return f.items[:pagination.Limit], nil
+	}
+	return f.items, nil
+}
+
+func (f *fakeListRepo) CalculateTotalCost(context.Context, domain.SubscriptionQuery) (int, error) {
+	return 0, nil
+}
+
+func (f *fakeListRepo) CountSubscriptions(context.Context, domain.SubscriptionQuery) (int, error) {
+	return f.count, nil
+}
+
+func testSubscriptions(t *testing.T, n int) []*domain.Subscription {
+	t.Helper()
+
+	items := make([]*domain.Subscription, n)
+	for i := range items {
+		sub, err := domain.NewSubscription(uuid.New(), uuid.New(), "service", (i+1)*100, time.Now(), nil)
+		require.NoError(t, err)
+		items[i] = sub
+	}
+	return items
+}
+
+func TestListSubscriptionsHandler_NextCursor(t *testing.T) {
+	repo := &fakeListRepo{items: testSubscriptions(t, 3), count: 3}
+	h := NewListSubscriptionsHandler(repo, repo)
+	sorting := &p.Sorting{OrderBy: "price", Direction: p.Ascending}
+
+	result, err := h.Handle(context.Background(), ListSubscriptionsQuery{
+		Pagination: p.Pagination{Limit: 2},
+		Sorting:    sorting,
+		WithTotal:  true,
+	})
+	require.NoError(t, err)
+
+	// читается на строку больше
+	require.Equal(t, 3, repo.pagination.Limit)
+	require.Len(t, result.Items, 2)
+	require.Equal(t, 3, *result.Total)
+
+	require.NotNil(t, result.NextCursor)
+	require.Equal(t, repo.items[1].ID(), result.NextCursor.ID)
+	require.Equal(t, "200", *result.NextCursor.Values[0])
+	require.True(t, result.NextCursor.Matches("price"))
+}
+
+func TestListSubscriptionsHandler_LastPage(t *testing.T) {
+	repo := &fakeListRepo{items: testSubscriptions(t, 2)}
+	h := NewListSubscriptionsHandler(repo, repo)
+
+	result, err := h.Handle(context.Background(), ListSubscriptionsQuery{Pagination: p.Pagination{Limit: 2}})
+	require.NoError(t, err)
+
+	require.Len(t, result.Items, 2)
+	require.Nil(t, result.NextCursor)
+	require.Nil(t, result.Total)
+}
+
+func TestListSubscriptionsHandler_Validation(t *testing.T) {
+	repo := &fakeListRepo{}
+	h := NewListSubscriptionsHandler(repo, repo)
+
+	tests := []struct {
+		name string
+		q    ListSubscriptionsQuery
+	}{
+		{"zero page size", ListSubscriptionsQuery{Pagination: p.Pagination{Limit: 0}}},
+		{"too big page size", ListSubscriptionsQuery{Pagination: p.Pagination{Limit: p.MaxPageLimit + 1}}},
+		{"cursor for another sorting", ListSubscriptionsQuery{
+			Pagination: p.Pagination{Limit: 10, After: &p.Cursor{Sort: "price", ID: uuid.New()}},
+			Sorting:    &p.Sorting{OrderBy: "start_date"},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := h.Handle(context.Background(), tt.q)
+
+			var valErr *application.ErrorValidationQuery
+			require.True(t, errors.As(err, &valErr))
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/logger"
+	"github.com/end1essrage/efmob-tz/pkg/common/tracing"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultOutboxLimit = 100
+	maxOutboxLimit     = 1000
+)
+
+type ListOutboxQuery struct {
+	Type        string
+	AggregateID *uuid.UUID
+	Failed      bool
+	// Limit 0 - defaultOutboxLimit
+	Limit int
+}
+
+// OutboxReport сводка и первые события очереди
+type OutboxReport struct {
+	Stats  application.OutboxStats
+	Events []application.OutboxEvent
+}
+
+type ListOutboxHandler struct {
+	repo application.OutboxRepository
+}
+
+func NewListOutboxHandler(repo application.OutboxRepository) *ListOutboxHandler {
+	return &ListOutboxHandler{repo: repo}
+}
+
+// Handle сводка по outbox тенанта и события по фильтру
+func (h *ListOutboxHandler) Handle(ctx context.Context, q ListOutboxQuery) (*OutboxReport, error) {
+	ctx, span := tracing.Start(ctx, "ListOutboxHandler.Handle")
+	defer span.End()
+
+	log := logger.Logger().WithFields(logger.LogOptions{
+		Pkg:  "ListOutboxHandler",
+		Func: "Handle",
+		Ctx:  ctx,
+	})
+
+	if _, err := application.Authorize(ctx, application.ActionManageOutbox); err != nil {
+		log.Warn(err)
+		return nil, err
+	}
+
+	if q.Limit == 0 {
+		q.Limit = defaultOutboxLimit
+	}
+	if q.Limit < 0 || q.Limit > maxOutboxLimit {
+		return nil, application.NewErrorValidationQuery(fmt.Sprintf("limit: от 1 до %d", maxOutboxLimit))
+	}
+
+	stats, err := h.repo.OutboxStats(ctx)
+	if err != nil {
+		log.Errorf("ошибка получения сводки outbox: %v", err)
+		return nil, err
+	}
+
+	events, err := h.repo.ListOutboxEvents(ctx, application.OutboxFilter{
+		Type:        q.Type,
+		AggregateID: q.AggregateID,
+		Failed:      q.Failed,
+		Limit:       q.Limit,
+	})
+	if err != nil {
+		log.Errorf("ошибка получения событий outbox: %v", err)
+		return nil, err
+	}
+
+	return &OutboxReport{Stats: stats, Events: events}, nil
+}
@@ -0,0 +1,75 @@
+package queries
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/logger"
+	"github.com/end1essrage/efmob-tz/pkg/common/tracing"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application"
+	domain "github.com/end1essrage/efmob-tz/pkg/subs/domain"
+	"github.com/google/uuid"
+)
+
+type RenewalsQuery struct {
+	UserID uuid.UUID
+	Token  string
+	// At момент, относительно которого подписка считается активной
+	At time.Time
+}
+
+type RenewalsHandler struct {
+	tokens domain.FeedTokenRepository
+	repo   domain.SubscriptionStreamRepository
+}
+
+func NewRenewalsHandler(tokens domain.FeedTokenRepository, repo domain.SubscriptionStreamRepository) *RenewalsHandler {
+	return &RenewalsHandler{tokens: tokens, repo: repo}
+}
+
+// Handle возвращает активные подписки пользователя, если токен фида верный.
+// Неверный токен неотличим от невыпущенного - ErrFeedTokenNotFound
+func (h *RenewalsHandler) Handle(ctx context.Context, q RenewalsQuery) ([]*domain.Subscription, error) {
+	ctx, span := tracing.Start(ctx, "RenewalsHandler.Handle")
+	defer span.End()
+
+	log := logger.Logger().WithFields(logger.LogOptions{
+		Pkg:  "RenewalsHandler",
+		Func: "Handle",
+		Ctx:  ctx,
+	})
+
+	hash, err := h.tokens.GetFeedTokenHash(ctx, q.UserID)
+	if err != nil {
+		if !errors.Is(err, domain.ErrFeedTokenNotFound) {
+			log.Error(err)
+		}
+		return nil, err
+	}
+	if !application.FeedTokenMatches(q.Token, hash) {
+		log.Warnf("неверный токен фида для %s", q.UserID)
+		return nil, domain.ErrFeedTokenNotFound
+	}
+
+	// активные - без даты окончания или заканчивающиеся не раньше текущего месяца
+	month := time.Date(q.At.Year(), q.At.Month(), 1, 0, 0, 0, 0, time.UTC)
+	endPeriod, err := domain.NewPeriod(&month, nil)
+	if err != nil {
+		return nil, err
+	}
+	includeNull := true
+	query := domain.NewSubscriptionQuery(&q.UserID, nil, nil, endPeriod, &includeNull)
+
+	var result []*domain.Subscription
+	err = h.repo.Stream(ctx, query, func(sub *domain.Subscription) error {
+		result = append(result, sub)
+		return nil
+	})
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return result, nil
+}
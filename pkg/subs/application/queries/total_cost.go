@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/end1essrage/efmob-tz/pkg/common/logger"
+	"github.com/end1essrage/efmob-tz/pkg/common/tracing"
 	"github.com/end1essrage/efmob-tz/pkg/subs/application"
 	domain "github.com/end1essrage/efmob-tz/pkg/subs/domain"
 	"github.com/google/uuid"
@@ -18,6 +19,9 @@ type TotalCostQuery struct {
 	EndFrom     *time.Time
 	EndTo       *time.Time
 	WithNilEnd  *bool
+
+	// Filters сортировка не используется
+	Filters SubscriptionFilters
 }
 
 type TotalCostHandler struct {
@@ -29,19 +33,26 @@ func NewTotalCostHandler(repo domain.SubscriptionStatsRepository) *TotalCostHand
 }
 
 func (h *TotalCostHandler) Handle(ctx context.Context, q TotalCostQuery) (int, error) {
+	ctx, span := tracing.Start(ctx, "TotalCostHandler.Handle")
+	defer span.End()
+
 	log := logger.Logger().WithFields(logger.LogOptions{
 		Pkg:  "TotalCostHandler",
 		Func: "Handle",
 		Ctx:  ctx,
 	})
 
-	startPeriod, endPeriod, err := application.Periods(q.StartFrom, q.StartTo, q.EndFrom, q.EndTo)
+	access, err := application.Authorize(ctx, application.ActionTotalCost)
 	if err != nil {
-		log.Error(err)
+		log.Warn(err)
 		return 0, err
 	}
 
-	query := domain.NewSubscriptionQuery(q.UserID, q.ServiceName, startPeriod, endPeriod, q.WithNilEnd)
+	query, err := buildSubscriptionQuery(access, q.UserID, q.ServiceName, q.StartFrom, q.StartTo, q.EndFrom, q.EndTo, q.WithNilEnd, q.Filters, nil)
+	if err != nil {
+		log.Error(err)
+		return 0, err
+	}
 
 	r, err := h.repo.CalculateTotalCost(ctx, query)
 	if err != nil {
@@ -0,0 +1,101 @@
+//go:build integration
+// +build integration
+
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/auth"
+	p "github.com/end1essrage/efmob-tz/pkg/common/persistance"
+	"github.com/end1essrage/efmob-tz/pkg/common/rsql"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application/commands"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application/queries"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application/tests/testapp"
+	"github.com/end1essrage/efmob-tz/pkg/subs/domain"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func userContext(id uuid.UUID) context.Context {
+	return auth.WithPrincipal(context.Background(), &auth.Principal{Subject: id.String(), UserID: id})
+}
+
+func TestOwnerScope(t *testing.T) {
+	app := testapp.NewTestApp(t)
+
+	alice, bob := uuid.New(), uuid.New()
+	aliceCtx, bobCtx := userContext(alice), userContext(bob)
+
+	// user_id из команды игнорируется - подписка создается владельцу токена
+	sub, err := app.Di.CreateSubscriptionHandler.Handle(aliceCtx, commands.CreateSubscriptionCommand{
+		UserID:      bob,
+		ServiceName: "Spotify",
+		Price:       200,
+		StartDate:   time.Now(),
+	})
+	require.NoError(t, err)
+	require.Equal(t, alice, sub.UserID())
+
+	_, err = app.Di.GetSubscriptionHandler.Handle(aliceCtx, queries.GetSubscriptionQuery{ID: sub.ID()})
+	require.NoError(t, err)
+
+	// чужая подписка не существует
+	_, err = app.Di.GetSubscriptionHandler.Handle(bobCtx, queries.GetSubscriptionQuery{ID: sub.ID()})
+	require.ErrorIs(t, err, domain.ErrSubscriptionNotFound)
+
+	price := 100
+	_, err = app.Di.UpdateSubscriptionHandler.Handle(bobCtx, commands.UpdateSubscriptionCommand{ID: sub.ID(), Price: &price})
+	require.ErrorIs(t, err, domain.ErrSubscriptionNotFound)
+
+	err = app.Di.DeleteSubscriptionHandler.Handle(bobCtx, commands.DeleteSubscriptionCommand{ID: sub.ID()})
+	require.ErrorIs(t, err, domain.ErrSubscriptionNotFound)
+
+	// список и сумма ограничены своими подписками
+	list, err := app.Di.ListSubscriptionsHandler.Handle(bobCtx, queries.ListSubscriptionsQuery{Pagination: p.DefaultPagination()})
+	require.NoError(t, err)
+	require.Empty(t, list.Items)
+
+	_, err = app.Di.ListSubscriptionsHandler.Handle(bobCtx, queries.ListSubscriptionsQuery{
+		UserID:     &alice,
+		Pagination: p.DefaultPagination(),
+	})
+	require.ErrorIs(t, err, domain.ErrSubscriptionNotFound)
+
+	total, err := app.Di.TotalCostHandler.Handle(aliceCtx, queries.TotalCostQuery{})
+	require.NoError(t, err)
+	require.Equal(t, 200, total)
+
+	// RSQL не обходит ограничение
+	node, err := rsql.Parse("user_id==" + alice.String())
+	require.NoError(t, err)
+	expr, err := domain.NewFilterExpr(node)
+	require.NoError(t, err)
+	total, err = app.Di.TotalCostHandler.Handle(bobCtx, queries.TotalCostQuery{
+		Filters: queries.SubscriptionFilters{Expression: expr},
+	})
+	require.NoError(t, err)
+	require.Zero(t, total)
+
+	_, err = app.Di.IssueFeedTokenHandler.Handle(bobCtx, commands.IssueFeedTokenCommand{UserID: alice})
+	require.ErrorIs(t, err, domain.ErrSubscriptionNotFound)
+
+	// поддержка видит подписки всех, но чужие не меняет
+	supportCtx := auth.WithPrincipal(context.Background(), &auth.Principal{
+		Subject: "support@example.com",
+		Roles:   []string{application.RoleSupport},
+	})
+	total, err = app.Di.TotalCostHandler.Handle(supportCtx, queries.TotalCostQuery{})
+	require.NoError(t, err)
+	require.Equal(t, 200, total)
+
+	_, err = app.Di.UpdateSubscriptionHandler.Handle(supportCtx, commands.UpdateSubscriptionCommand{ID: sub.ID(), Price: &price})
+	require.ErrorIs(t, err, application.ErrForbidden)
+
+	// владелец удаляет свою подписку
+	err = app.Di.DeleteSubscriptionHandler.Handle(aliceCtx, commands.DeleteSubscriptionCommand{ID: sub.ID()})
+	require.NoError(t, err)
+}
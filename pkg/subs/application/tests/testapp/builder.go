@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/end1essrage/efmob-tz/pkg/common/persistance/apikeys"
 	common_test "github.com/end1essrage/efmob-tz/pkg/common/testing"
 	di "github.com/end1essrage/efmob-tz/pkg/subs/application/container"
 	subs_repo "github.com/end1essrage/efmob-tz/pkg/subs/infrastructure/persistance/subs"
@@ -19,6 +20,7 @@ import (
 
 type TestApp struct {
 	Repo        *subs_repo.GormSubscriptionRepo
+	APIKeys     *apikeys.GormStore
 	Publisher   *SpyEventPublisher
 	Worker      *subs_repo.EventWorker
 	Di          *di.Container
@@ -52,16 +54,20 @@ func NewTestApp(t *testing.T) *TestApp {
 	err = repo.Migrate()
 	require.NoError(t, err)
 
+	keys := apikeys.NewGormStore(db)
+	require.NoError(t, keys.Migrate())
+
 	// spy publisher
 	spy := &SpyEventPublisher{}
 
 	// воркер событий с маленьким интервалом
-	worker := subs_repo.NewEventWorker(db, spy, 10*time.Millisecond, 10)
+	worker := subs_repo.NewEventWorker(db, spy, 10*time.Millisecond, 10, 4)
 
-	di := di.NewContainer(repo, repo, repo)
+	di := di.NewContainer(repo, repo, repo, repo, repo, keys, repo)
 
 	return &TestApp{
 		Repo:      repo,
+		APIKeys:   keys,
 		Publisher: spy,
 		Worker:    worker,
 		Di:        di,
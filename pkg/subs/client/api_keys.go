@@ -0,0 +1,47 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// IssueAPIKey выпускает ключ API, секрет есть только в ответе
+func (c *Client) IssueAPIKey(ctx context.Context, in APIKeyRequest, ro ...RequestOption) (*IssuedAPIKey, error) {
+	r, err := jsonRequest(http.MethodPost, "/admin/api-keys", in, ro)
+	if err != nil {
+		return nil, err
+	}
+	var key IssuedAPIKey
+	if _, err := c.do(ctx, r, &key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// ListAPIKeys ключи тенанта без секретов
+func (c *Client) ListAPIKeys(ctx context.Context, ro ...RequestOption) ([]APIKey, error) {
+	var out struct {
+		Items []APIKey `json:"items"`
+	}
+	if _, err := c.do(ctx, newRequest(http.MethodGet, "/admin/api-keys", nil, ro), &out); err != nil {
+		return nil, err
+	}
+	return out.Items, nil
+}
+
+// RotateAPIKey новый секрет ключа, старый сразу недействителен
+func (c *Client) RotateAPIKey(ctx context.Context, id uuid.UUID, ro ...RequestOption) (*IssuedAPIKey, error) {
+	var key IssuedAPIKey
+	if _, err := c.do(ctx, newRequest(http.MethodPost, "/admin/api-keys/"+id.String()+"/rotate", nil, ro), &key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// RevokeAPIKey отзывает ключ, повторный отзыв не ошибка
+func (c *Client) RevokeAPIKey(ctx context.Context, id uuid.UUID, ro ...RequestOption) error {
+	_, err := c.do(ctx, newRequest(http.MethodDelete, "/admin/api-keys/"+id.String(), nil, ro), nil)
+	return err
+}
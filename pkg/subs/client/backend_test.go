@@ -0,0 +1,342 @@
+package client_test
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/auth"
+	"github.com/end1essrage/efmob-tz/pkg/common/idempotency"
+	p "github.com/end1essrage/efmob-tz/pkg/common/persistance"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application"
+	"github.com/end1essrage/efmob-tz/pkg/subs/domain"
+	"github.com/google/uuid"
+)
+
+// memoryBackend хранилища приложения в памяти, подписки в порядке создания
+type memoryBackend struct {
+	mu     sync.Mutex
+	subs   []domain.Subscription
+	feeds  map[uuid.UUID]string
+	keys   map[uuid.UUID]auth.APIKey
+	events []application.OutboxEvent
+	idem   map[string]*idempotency.Record
+	// fail ошибка следующей операции с подписками, один раз
+	fail error
+}
+
+func newMemoryBackend() *memoryBackend {
+	return &memoryBackend{
+		feeds: map[uuid.UUID]string{},
+		keys:  map[uuid.UUID]auth.APIKey{},
+		idem:  map[string]*idempotency.Record{},
+	}
+}
+
+func (b *memoryBackend) takeFail() error {
+	err := b.fail
+	b.fail = nil
+	return err
+}
+
+// RunInTransaction при ошибке восстанавливает подписки, как откат транзакции
+func (b *memoryBackend) RunInTransaction(ctx context.Context, fn func(tx domain.TxSubscriptionRepository) error) error {
+	b.mu.Lock()
+	snapshot := slices.Clone(b.subs)
+	b.mu.Unlock()
+
+	err := fn(b)
+	if err != nil {
+		b.mu.Lock()
+		b.subs = snapshot
+		b.mu.Unlock()
+	}
+	return err
+}
+
+func (b *memoryBackend) CreateEvent(context.Context, domain.Event) error { return nil }
+
+func (b *memoryBackend) Create(_ context.Context, sub *domain.Subscription) (uuid.UUID, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.takeFail(); err != nil {
+		return uuid.Nil, err
+	}
+	b.subs = append(b.subs, *sub)
+	return sub.ID(), nil
+}
+
+func (b *memoryBackend) index(id uuid.UUID) int {
+	return slices.IndexFunc(b.subs, func(s domain.Subscription) bool { return s.ID() == id })
+}
+
+func (b *memoryBackend) GetByID(_ context.Context, id uuid.UUID) (*domain.Subscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.takeFail(); err != nil {
+		return nil, err
+	}
+	i := b.index(id)
+	if i < 0 {
+		return nil, domain.ErrSubscriptionNotFound
+	}
+	sub := b.subs[i]
+	return &sub, nil
+}
+
+func (b *memoryBackend) Update(_ context.Context, sub *domain.Subscription) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	i := b.index(sub.ID())
+	if i < 0 {
+		return domain.ErrSubscriptionNotFound
+	}
+	if b.subs[i].Version() != sub.Version() {
+		return application.ErrConcurrentModification
+	}
+	sub.IncrementVersion()
+	b.subs[i] = *sub
+	return nil
+}
+
+func (b *memoryBackend) Delete(ctx context.Context, id uuid.UUID) error {
+	return b.DeleteWithVersion(ctx, id, -1)
+}
+
+// DeleteWithVersion version -1 - без проверки
+func (b *memoryBackend) DeleteWithVersion(_ context.Context, id uuid.UUID, version int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	i := b.index(id)
+	if i < 0 {
+		return domain.ErrSubscriptionNotFound
+	}
+	if version >= 0 && b.subs[i].Version() != version {
+		return application.ErrConcurrentModification
+	}
+	b.subs = slices.Delete(b.subs, i, i+1)
+	return nil
+}
+
+// match фильтры, которыми пользуются тесты: пользователи, сервисы, цена
+func match(q domain.SubscriptionQuery, s domain.Subscription) bool {
+	if ids := q.UserIDs(); len(ids) > 0 && !slices.Contains(ids, s.UserID()) {
+		return false
+	}
+	if names := q.ServiceNames(); len(names) > 0 && !slices.Contains(names, s.ServiceName()) {
+		return false
+	}
+	if q.PriceMin() != nil && s.Price() < *q.PriceMin() {
+		return false
+	}
+	return q.PriceMax() == nil || s.Price() <= *q.PriceMax()
+}
+
+func (b *memoryBackend) filtered(q domain.SubscriptionQuery) []*domain.Subscription {
+	var res []*domain.Subscription
+	for _, s := range b.subs {
+		if match(q, s) {
+			res = append(res, &s)
+		}
+	}
+	return res
+}
+
+func (b *memoryBackend) Find(_ context.Context, q domain.SubscriptionQuery, pagination p.Pagination) ([]*domain.Subscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.takeFail(); err != nil {
+		return nil, err
+	}
+
+	res := b.filtered(q)
+	start := pagination.Offset
+	if pagination.After != nil {
+		start = slices.IndexFunc(res, func(s *domain.Subscription) bool { return s.ID() == pagination.After.ID }) + 1
+	}
+	res = res[min(max(start, 0), len(res)):]
+	return res[:min(pagination.Limit, len(res))], nil
+}
+
+func (b *memoryBackend) Stream(_ context.Context, q domain.SubscriptionQuery, fn func(*domain.Subscription) error) error {
+	b.mu.Lock()
+	res := b.filtered(q)
+	b.mu.Unlock()
+
+	for _, s := range res {
+		if err := fn(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *memoryBackend) CalculateTotalCost(_ context.Context, q domain.SubscriptionQuery) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	total := 0
+	for _, s := range b.filtered(q) {
+		total += s.Price()
+	}
+	return total, nil
+}
+
+func (b *memoryBackend) CountSubscriptions(_ context.Context, q domain.SubscriptionQuery) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.filtered(q)), nil
+}
+
+func (b *memoryBackend) SaveFeedToken(_ context.Context, userID uuid.UUID, hash string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.feeds[userID] = hash
+	return nil
+}
+
+func (b *memoryBackend) GetFeedTokenHash(_ context.Context, userID uuid.UUID) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	hash, ok := b.feeds[userID]
+	if !ok {
+		return "", domain.ErrFeedTokenNotFound
+	}
+	return hash, nil
+}
+
+func (b *memoryBackend) CreateAPIKey(_ context.Context, key *auth.APIKey) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.keys[key.ID] = *key
+	return nil
+}
+
+func (b *memoryBackend) ListAPIKeys(context.Context) ([]*auth.APIKey, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var res []*auth.APIKey
+	for _, k := range b.keys {
+		res = append(res, &k)
+	}
+	return res, nil
+}
+
+func (b *memoryBackend) RotateAPIKey(_ context.Context, id uuid.UUID, hash, hint string) (*auth.APIKey, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	k, ok := b.keys[id]
+	switch {
+	case !ok:
+		return nil, auth.ErrAPIKeyNotFound
+	case k.RevokedAt != nil:
+		return nil, auth.ErrAPIKeyRevoked
+	}
+	k.Hash, k.Hint = hash, hint
+	b.keys[id] = k
+	return &k, nil
+}
+
+func (b *memoryBackend) RevokeAPIKey(_ context.Context, id uuid.UUID, at time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	k, ok := b.keys[id]
+	if !ok {
+		return auth.ErrAPIKeyNotFound
+	}
+	if k.RevokedAt == nil {
+		k.RevokedAt = &at
+		b.keys[id] = k
+	}
+	return nil
+}
+
+func (b *memoryBackend) GetAPIKey(_ context.Context, id uuid.UUID) (*auth.APIKey, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	k, ok := b.keys[id]
+	if !ok {
+		return nil, auth.ErrAPIKeyNotFound
+	}
+	return &k, nil
+}
+
+func (b *memoryBackend) TouchAPIKey(_ context.Context, id uuid.UUID, at time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if k, ok := b.keys[id]; ok {
+		k.LastUsedAt = &at
+		b.keys[id] = k
+	}
+	return nil
+}
+
+func (b *memoryBackend) ListOutboxEvents(_ context.Context, f application.OutboxFilter) ([]application.OutboxEvent, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var res []application.OutboxEvent
+	for _, ev := range b.events {
+		if f.Type != "" && ev.Type != f.Type || f.AggregateID != nil && ev.AggregateID != *f.AggregateID || f.Failed && ev.Attempts == 0 {
+			continue
+		}
+		res = append(res, ev)
+	}
+	return res[:min(f.Limit, len(res))], nil
+}
+
+func (b *memoryBackend) OutboxStats(context.Context) (application.OutboxStats, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var stats application.OutboxStats
+	for _, ev := range b.events {
+		stats.Pending++
+		if ev.Attempts > 0 {
+			stats.Failed++
+		}
+		if stats.Oldest == nil || ev.CreatedAt.Before(*stats.Oldest) {
+			stats.Oldest = &ev.CreatedAt
+		}
+	}
+	return stats, nil
+}
+
+func (b *memoryBackend) RequeueOutboxEvents(_ context.Context, ids []uuid.UUID) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var n int64
+	for i, ev := range b.events {
+		if ev.NextAttemptAt != nil && (ids == nil || slices.Contains(ids, ev.ID)) {
+			b.events[i].NextAttemptAt, b.events[i].Attempts = nil, 0
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (b *memoryBackend) Acquire(_ context.Context, key, hash string, _, _ time.Duration) (*idempotency.Record, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if rec, ok := b.idem[key]; ok {
+		copied := *rec
+		return &copied, nil
+	}
+	b.idem[key] = &idempotency.Record{Key: key, RequestHash: hash}
+	return nil, nil
+}
+
+func (b *memoryBackend) Complete(_ context.Context, key string, status int, contentType string, body []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	rec := b.idem[key]
+	rec.Completed, rec.StatusCode, rec.ContentType, rec.Body = true, status, contentType, append([]byte(nil), body...)
+	return nil
+}
+
+func (b *memoryBackend) Release(_ context.Context, key string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if rec, ok := b.idem[key]; ok && !rec.Completed {
+		delete(b.idem, key)
+	}
+	return nil
+}
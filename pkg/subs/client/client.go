@@ -0,0 +1,311 @@
+// Package client типизированный клиент HTTP API сервиса подписок.
+// Покрывает все маршруты subs_http.AddRoutes, повторяет запросы при 429 и 5xx
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryPolicy повторы запросов с экспоненциальной задержкой и jitter.
+// 429 повторяется всегда - лимит отклоняет запрос до обработки.
+// 5xx и сетевые ошибки - только для GET, HEAD, DELETE и запросов с Idempotency-Key:
+// PUT и PATCH после потерянного ответа могли затереть чужое изменение.
+// Retry-After сервера заменяет расчетную задержку. Дольше MaxWait или срока контекста
+// клиент не ждет - запрос не повторяется
+type RetryPolicy struct {
+	// MaxAttempts попыток всего, 1 - без повторов
+	MaxAttempts int
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+	// MaxWait наибольший Retry-After, который клиент готов ждать, 0 - MaxBackoff
+	MaxWait time.Duration
+}
+
+// DefaultRetryPolicy по умолчанию три попытки
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 3, MinBackoff: 200 * time.Millisecond, MaxBackoff: 5 * time.Second, MaxWait: time.Minute}
+
+// canWait хватит ли лимита ожидания и срока контекста на паузу d
+func (p RetryPolicy) canWait(ctx context.Context, d time.Duration) bool {
+	maxWait := p.MaxWait
+	if maxWait <= 0 {
+		maxWait = p.MaxBackoff
+	}
+	if d > maxWait {
+		return false
+	}
+	deadline, ok := ctx.Deadline()
+	return !ok || time.Until(deadline) > d
+}
+
+// backoff задержка перед попыткой attempt+1: половина фиксирована, половина случайна
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.MinBackoff
+	for i := 1; i < attempt && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	d = min(d, p.MaxBackoff)
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// Client ходит в API от имени токена или ключа API. Безопасен для параллельного использования
+type Client struct {
+	baseURL *url.URL
+	http    *http.Client
+	auth    string
+	tenant  string
+	retry   RetryPolicy
+}
+
+// Option настройка клиента
+type Option func(*Client)
+
+// defaultHeaderTimeout сколько клиент по умолчанию ждет заголовки ответа на попытку
+const defaultHeaderTimeout = 30 * time.Second
+
+// WithHTTPClient свой http.Client. По умолчанию заголовки ответа ждутся не дольше 30s на попытку,
+// чтение тела не ограничено - потоковая выгрузка идет сколько нужно, общий срок задает контекст
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.http = hc }
+}
+
+// WithToken аутентификация bearer токеном
+func WithToken(token string) Option {
+	return func(c *Client) { c.auth = "Bearer " + token }
+}
+
+// WithAPIKey аутентификация ключом API, выданным /admin/api-keys
+func WithAPIKey(key string) Option {
+	return func(c *Client) { c.auth = "ApiKey " + key }
+}
+
+// WithTenant тенант в X-Tenant-ID, по умолчанию - тенант вызывающего
+func WithTenant(id string) Option {
+	return func(c *Client) { c.tenant = id }
+}
+
+// WithRetry политика повторов, RetryPolicy{MaxAttempts: 1} выключает повторы
+func WithRetry(p RetryPolicy) Option {
+	return func(c *Client) { c.retry = p }
+}
+
+// New клиент к API по адресу вида http://host:port
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("client: base url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("client: base url %q: want http(s)://host", baseURL)
+	}
+
+	c := &Client{
+		baseURL: u,
+		http:    defaultHTTPClient(),
+		retry:   DefaultRetryPolicy,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.retry.MaxAttempts < 1 {
+		c.retry.MaxAttempts = 1
+	}
+	return c, nil
+}
+
+// defaultHTTPClient без http.Client.Timeout - он обрывал бы и долгое чтение тела
+func defaultHTTPClient() *http.Client {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.ResponseHeaderTimeout = defaultHeaderTimeout
+	return &http.Client{Transport: tr}
+}
+
+// RequestOption настройка одного запроса
+type RequestOption func(*request)
+
+// WithIdempotencyKey ключ идемпотентности, повтор с тем же ключом получает сохраненный ответ.
+// CreateSubscription без ключа генерирует свой, чтобы повторы не создали дубликатов
+func WithIdempotencyKey(key string) RequestOption {
+	return func(r *request) { r.header.Set("Idempotency-Key", key) }
+}
+
+// WithIfMatch изменение только если версия подписки не менялась, иначе ErrPreconditionFailed
+func WithIfMatch(version int) RequestOption {
+	return func(r *request) { r.header.Set("If-Match", `"`+strconv.Itoa(version)+`"`) }
+}
+
+// WithHeader произвольный заголовок, например traceparent
+func WithHeader(key, value string) RequestOption {
+	return func(r *request) { r.header.Set(key, value) }
+}
+
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	header http.Header
+	// body тело целиком, повторяется без ограничений
+	body []byte
+	// stream тело потоком, повторяется только io.Seeker
+	stream io.Reader
+	offset int64
+	// accept статусы кроме 2xx, ответ на которые не ошибка
+	accept []int
+}
+
+func newRequest(method, path string, query url.Values, opts []RequestOption) *request {
+	r := &request{method: method, path: path, query: query, header: http.Header{}}
+	r.header.Set("Accept", "application/json")
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// jsonRequest запрос с телом in в JSON
+func jsonRequest(method, path string, in any, opts []RequestOption) (*request, error) {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+	r := newRequest(method, path, nil, opts)
+	r.body = body
+	r.header.Set("Content-Type", "application/json")
+	return r, nil
+}
+
+// idempotent повтор после 5xx не выполнит операцию дважды
+func (r *request) idempotent() bool {
+	switch r.method {
+	case http.MethodGet, http.MethodHead, http.MethodDelete:
+		return true
+	}
+	return r.header.Get("Idempotency-Key") != ""
+}
+
+// rewind готовит тело к повтору, false - тело не перечитать
+func (r *request) rewind() bool {
+	if r.stream == nil {
+		return true
+	}
+	s, ok := r.stream.(io.Seeker)
+	if !ok {
+		return false
+	}
+	_, err := s.Seek(r.offset, io.SeekStart)
+	return err == nil
+}
+
+func (c *Client) build(ctx context.Context, r *request) (*http.Request, error) {
+	u := *c.baseURL
+	u.Path += r.path
+	u.RawQuery = r.query.Encode()
+
+	var body io.Reader
+	switch {
+	case r.body != nil:
+		body = bytes.NewReader(r.body)
+	case r.stream != nil:
+		// обертка прячет io.Seeker и WriterTo - http.Client не должен закрывать или читать тело сам
+		body = io.NopCloser(r.stream)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, r.method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = r.header.Clone()
+	if c.auth != "" {
+		req.Header.Set("Authorization", c.auth)
+	}
+	if c.tenant != "" {
+		req.Header.Set("X-Tenant-ID", c.tenant)
+	}
+	return req, nil
+}
+
+// send выполняет запрос с повторами. Тело успешного ответа закрывает вызывающий
+func (c *Client) send(ctx context.Context, r *request) (*http.Response, error) {
+	if s, ok := r.stream.(io.Seeker); ok {
+		r.offset, _ = s.Seek(0, io.SeekCurrent)
+	}
+
+	for attempt := 1; ; attempt++ {
+		req, err := c.build(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := c.http.Do(req)
+		if err == nil && (resp.StatusCode < 300 || slices.Contains(r.accept, resp.StatusCode)) {
+			return resp, nil
+		}
+
+		var apiErr *APIError
+		if err == nil {
+			apiErr = decodeError(resp)
+			err = apiErr
+		}
+		if attempt >= c.retry.MaxAttempts || ctx.Err() != nil || !retryable(r, apiErr) || !r.rewind() {
+			return nil, err
+		}
+
+		delay := c.retry.backoff(attempt)
+		if apiErr != nil && apiErr.RetryAfter > 0 {
+			// раньше срока сервер снова откажет, а ждать, чтобы упереться в срок контекста, бессмысленно
+			if !c.retry.canWait(ctx, apiErr.RetryAfter) {
+				return nil, err
+			}
+			delay = apiErr.RetryAfter
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, errors.Join(err, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// retryable apiErr nil - сетевая ошибка
+func retryable(r *request, apiErr *APIError) bool {
+	if apiErr != nil && apiErr.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	if apiErr != nil && apiErr.StatusCode < 500 {
+		return false
+	}
+	return r.idempotent()
+}
+
+// do выполняет запрос, ответ 2xx декодируется из JSON в out
+func (c *Client) do(ctx context.Context, r *request, out any) (*http.Response, error) {
+	resp, err := c.send(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if out != nil && resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotModified {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp, fmt.Errorf("api: decode %s %s: %w", r.method, r.path, err)
+		}
+	}
+	return resp, nil
+}
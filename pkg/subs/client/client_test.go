@@ -0,0 +1,164 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew_InvalidBaseURL(t *testing.T) {
+	for _, u := range []string{"", "localhost:8080", "ftp://host", "http://"} {
+		_, err := New(u)
+		require.Error(t, err, u)
+	}
+}
+
+func TestNew_DefaultTimeouts(t *testing.T) {
+	c, err := New("http://localhost:8080")
+	require.NoError(t, err)
+
+	// таймаут только на заголовки ответа, долгое тело выгрузки не обрывается
+	require.Zero(t, c.http.Timeout)
+	tr, ok := c.http.Transport.(*http.Transport)
+	require.True(t, ok)
+	require.Equal(t, defaultHeaderTimeout, tr.ResponseHeaderTimeout)
+}
+
+func TestClient_ListSubscriptions(t *testing.T) {
+	user := uuid.New()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/subscriptions", r.URL.Path)
+		require.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		require.Equal(t, "acme", r.Header.Get("X-Tenant-ID"))
+
+		q := r.URL.Query()
+		require.Equal(t, []string{user.String()}, q["user_id"])
+		require.Equal(t, []string{"Netflix", "Spotify"}, q["service_name"])
+		require.Equal(t, "100", q.Get("price_min"))
+		require.Equal(t, "-price", q.Get("sort"))
+		require.Equal(t, "10", q.Get("page_size"))
+		require.Equal(t, "true", q.Get("with_total"))
+		require.False(t, q.Has("price_max"))
+		require.False(t, q.Has("cursor"))
+
+		w.Header().Set("X-Next-Cursor", "next")
+		w.Header().Set("X-Total-Count", "11")
+		json.NewEncoder(w).Encode([]Subscription{{ID: uuid.New(), UserID: user, ServiceName: "Netflix", Price: 400}})
+	}))
+	defer srv.Close()
+
+	c, err := New(srv.URL+"/api/", WithToken("secret"), WithTenant("acme"))
+	require.NoError(t, err)
+
+	min := 100
+	page, err := c.ListSubscriptions(context.Background(), ListOptions{
+		Filter:    Filter{UserIDs: []uuid.UUID{user}, ServiceNames: []string{"Netflix", "Spotify"}, PriceMin: &min},
+		PageSize:  10,
+		WithTotal: true,
+		Sort:      "-price",
+	})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	require.Equal(t, 400, page.Items[0].Price)
+	require.Equal(t, "next", page.NextCursor)
+	require.Equal(t, 11, *page.Total)
+}
+
+func TestClient_UpdateSubscription(t *testing.T) {
+	noop := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPatch, r.Method)
+		require.Equal(t, "ApiKey k", r.Header.Get("Authorization"))
+		body, _ := io.ReadAll(r.Body)
+		if noop {
+			require.JSONEq(t, `{}`, string(body))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		require.JSONEq(t, `{"price":500,"end_date":null}`, string(body))
+		json.NewEncoder(w).Encode(Subscription{Price: 500, Version: 2})
+	}))
+	defer srv.Close()
+
+	c, err := New(srv.URL, WithAPIKey("k"))
+	require.NoError(t, err)
+
+	price := 500
+	sub, err := c.UpdateSubscription(context.Background(), uuid.New(), UpdateSubscription{Price: &price, EndDate: &[]string{"12-2025"}[0], ClearEndDate: true})
+	require.NoError(t, err)
+	require.Equal(t, 2, sub.Version)
+
+	noop = true
+	sub, err = c.UpdateSubscription(context.Background(), uuid.New(), UpdateSubscription{})
+	require.NoError(t, err)
+	require.Nil(t, sub)
+}
+
+func TestClient_APIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/subscriptions/total" {
+			http.Error(w, "upstream down", http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":"subscription not found","code":"NOT_FOUND"}`))
+	}))
+	defer srv.Close()
+
+	c, err := New(srv.URL, WithRetry(RetryPolicy{MaxAttempts: 1}))
+	require.NoError(t, err)
+
+	_, err = c.GetSubscription(context.Background(), uuid.New())
+	require.ErrorIs(t, err, ErrNotFound)
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	require.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	require.Equal(t, "NOT_FOUND", apiErr.Code)
+	require.Equal(t, "subscription not found", apiErr.Message)
+
+	_, err = c.TotalCost(context.Background(), Filter{})
+	require.True(t, errors.As(err, &apiErr))
+	require.Equal(t, http.StatusBadGateway, apiErr.StatusCode)
+	require.Empty(t, apiErr.Code)
+	require.Equal(t, "upstream down", apiErr.Message)
+}
+
+func TestClient_Outbox(t *testing.T) {
+	agg := uuid.New()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/admin/outbox":
+			require.Equal(t, agg.String(), r.URL.Query().Get("aggregate_id"))
+			require.Equal(t, "true", r.URL.Query().Get("failed"))
+			w.Write([]byte(`{"stats":{"pending":2,"failed":1},"items":[{"id":"` + uuid.NewString() +
+				`","aggregate_id":"` + agg.String() + `","type":"subscription_updated","payload":{"price":1},"attempts":3,"last_error":"broker unavailable"}]}`))
+		case "/admin/outbox/requeue":
+			body, _ := io.ReadAll(r.Body)
+			require.JSONEq(t, `{"all":true}`, string(body))
+			w.Write([]byte(`{"requeued":4}`))
+		}
+	}))
+	defer srv.Close()
+
+	c, err := New(srv.URL)
+	require.NoError(t, err)
+
+	report, err := c.ListOutbox(context.Background(), OutboxOptions{AggregateID: &agg, Failed: true})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), report.Stats.Pending)
+	require.Len(t, report.Items, 1)
+	require.Equal(t, 3, report.Items[0].Attempts)
+	require.JSONEq(t, `{"price":1}`, string(report.Items[0].Payload))
+
+	n, err := c.RequeueOutbox(context.Background(), nil, true)
+	require.NoError(t, err)
+	require.Equal(t, int64(4), n)
+}
@@ -0,0 +1,100 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Коды ErrorResponse.Code, которые обычно обрабатывают отдельно
+const (
+	CodeUnauthorized           = "UNAUTHORIZED"
+	CodeForbidden              = "FORBIDDEN"
+	CodeNotFound               = "NOT_FOUND"
+	CodeConcurrentModification = "CONCURRENT_MODIFICATION"
+	CodePreconditionFailed     = "PRECONDITION_FAILED"
+	CodeRateLimited            = "RATE_LIMITED"
+	CodeIdempotencyKeyReused   = "IDEMPOTENCY_KEY_REUSED"
+	CodeTenantMismatch         = "TENANT_MISMATCH"
+	CodeAPIKeyNotFound         = "API_KEY_NOT_FOUND"
+	CodeAPIKeyRevoked          = "API_KEY_REVOKED"
+	CodeFeedNotFound           = "FEED_NOT_FOUND"
+	CodeInvalidCommand         = "INVALID_COMMAND"
+	CodeInvalidQuery           = "INVALID_QUERY"
+)
+
+// Ошибки для errors.Is, сравниваются по коду: errors.Is(err, client.ErrNotFound)
+var (
+	ErrUnauthorized           = &APIError{Code: CodeUnauthorized}
+	ErrForbidden              = &APIError{Code: CodeForbidden}
+	ErrNotFound               = &APIError{Code: CodeNotFound}
+	ErrConcurrentModification = &APIError{Code: CodeConcurrentModification}
+	ErrPreconditionFailed     = &APIError{Code: CodePreconditionFailed}
+	ErrRateLimited            = &APIError{Code: CodeRateLimited}
+	ErrIdempotencyKeyReused   = &APIError{Code: CodeIdempotencyKeyReused}
+)
+
+// APIError ответ API с кодом не 2xx
+type APIError struct {
+	StatusCode int
+	// Code код из ErrorResponse, например NOT_FOUND, пустой - ответ не от API (прокси, неизвестный маршрут)
+	Code    string
+	Message string
+	// RetryAfter из заголовка Retry-After
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("api: %d %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("api: %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// Is совпадение по коду, статус сравнивается, если задан в target
+func (e *APIError) Is(target error) bool {
+	t, ok := target.(*APIError)
+	if !ok || t.Code == "" || t.Code != e.Code {
+		return false
+	}
+	return t.StatusCode == 0 || t.StatusCode == e.StatusCode
+}
+
+// decodeError читает и закрывает тело ответа
+func decodeError(resp *http.Response) *APIError {
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	apiErr := &APIError{StatusCode: resp.StatusCode, RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+
+	var body struct {
+		Error string `json:"error"`
+		Code  string `json:"code"`
+	}
+	if json.Unmarshal(data, &body) == nil && body.Error != "" {
+		apiErr.Code, apiErr.Message = body.Code, body.Error
+	} else {
+		apiErr.Message = strings.TrimSpace(string(data))
+		if apiErr.Message == "" {
+			apiErr.Message = http.StatusText(resp.StatusCode)
+		}
+	}
+	return apiErr
+}
+
+// parseRetryAfter секунды или HTTP дата (RFC 9110)
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if s, err := strconv.Atoi(v); err == nil && s >= 0 {
+		return time.Duration(s) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0)
+	}
+	return 0
+}
@@ -0,0 +1,312 @@
+package client
+
+import (
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Subscription подписка, даты в формате MM-YYYY
+type Subscription struct {
+	ID          uuid.UUID `json:"id"`
+	UserID      uuid.UUID `json:"user_id"`
+	ServiceName string    `json:"service_name"`
+	Price       int       `json:"price"`
+	StartDate   string    `json:"start_date"`
+	EndDate     string    `json:"end_date"`
+	Version     int       `json:"version"`
+}
+
+// CreateSubscription тело создания подписки
+type CreateSubscription struct {
+	UserID      uuid.UUID `json:"user_id"`
+	ServiceName string    `json:"service_name"`
+	Price       int       `json:"price"`
+	StartDate   string    `json:"start_date"`
+	EndDate     *string   `json:"end_date,omitempty"`
+}
+
+// UpdateSubscription частичное обновление, nil поля не меняются.
+// ClearEndDate убирает дату окончания, EndDate при этом игнорируется
+type UpdateSubscription struct {
+	Price        *int
+	StartDate    *string
+	EndDate      *string
+	ClearEndDate bool
+}
+
+func (u UpdateSubscription) MarshalJSON() ([]byte, error) {
+	body := make(map[string]any, 3)
+	if u.Price != nil {
+		body["price"] = *u.Price
+	}
+	if u.StartDate != nil {
+		body["start_date"] = *u.StartDate
+	}
+	switch {
+	case u.ClearEndDate:
+		body["end_date"] = nil
+	case u.EndDate != nil:
+		body["end_date"] = *u.EndDate
+	}
+	return json.Marshal(body)
+}
+
+// Filter фильтры списка и суммы, как параметры запроса API
+type Filter struct {
+	UserIDs      []uuid.UUID
+	ServiceNames []string
+	// ServiceMatch exact, icase или fuzzy
+	ServiceMatch string
+	PriceMin     *int
+	PriceMax     *int
+	// CreatedFrom, CreatedTo в формате YYYY-MM-DD
+	CreatedFrom string
+	CreatedTo   string
+	// Filter выражение RSQL
+	Filter string
+	// StartFrom, StartTo, EndFrom, EndTo в формате MM-YYYY
+	StartFrom string
+	StartTo   string
+	EndFrom   string
+	EndTo     string
+	NilEnd    *bool
+}
+
+func (f Filter) values() url.Values {
+	q := url.Values{}
+	for _, id := range f.UserIDs {
+		q.Add("user_id", id.String())
+	}
+	for _, name := range f.ServiceNames {
+		q.Add("service_name", name)
+	}
+	if f.PriceMin != nil {
+		q.Set("price_min", strconv.Itoa(*f.PriceMin))
+	}
+	if f.PriceMax != nil {
+		q.Set("price_max", strconv.Itoa(*f.PriceMax))
+	}
+	if f.NilEnd != nil {
+		q.Set("nil_end", strconv.FormatBool(*f.NilEnd))
+	}
+	setNonEmpty(q, map[string]string{
+		"service_match": f.ServiceMatch,
+		"created_from":  f.CreatedFrom,
+		"created_to":    f.CreatedTo,
+		"filter":        f.Filter,
+		"start_from":    f.StartFrom,
+		"start_to":      f.StartTo,
+		"end_from":      f.EndFrom,
+		"end_to":        f.EndTo,
+	})
+	return q
+}
+
+// ListOptions фильтры, пагинация и сортировка списка подписок
+type ListOptions struct {
+	Filter
+
+	// Page с 1, игнорируется при Cursor
+	Page     int
+	PageSize int
+	// Cursor из Page.NextCursor предыдущей страницы
+	Cursor    string
+	WithTotal bool
+
+	// Sort сортировка по нескольким полям, например -price,start_date
+	Sort      string
+	OrderBy   string
+	Direction string
+}
+
+func (o ListOptions) values() url.Values {
+	q := o.Filter.values()
+	if o.Page > 0 {
+		q.Set("page", strconv.Itoa(o.Page))
+	}
+	if o.PageSize > 0 {
+		q.Set("page_size", strconv.Itoa(o.PageSize))
+	}
+	if o.WithTotal {
+		q.Set("with_total", "true")
+	}
+	setNonEmpty(q, map[string]string{
+		"cursor":    o.Cursor,
+		"sort":      o.Sort,
+		"order_by":  o.OrderBy,
+		"direction": o.Direction,
+	})
+	return q
+}
+
+// Page страница подписок
+type Page struct {
+	Items []Subscription
+	// NextCursor пустой на последней странице
+	NextCursor string
+	// Total при ListOptions.WithTotal
+	Total *int
+}
+
+// OutboxOptions фильтры очереди событий
+type OutboxOptions struct {
+	Type        string
+	AggregateID *uuid.UUID
+	// Failed только события с неудачными попытками публикации
+	Failed bool
+	Limit  int
+}
+
+func (o OutboxOptions) values() url.Values {
+	q := url.Values{}
+	if o.AggregateID != nil {
+		q.Set("aggregate_id", o.AggregateID.String())
+	}
+	if o.Failed {
+		q.Set("failed", "true")
+	}
+	if o.Limit > 0 {
+		q.Set("limit", strconv.Itoa(o.Limit))
+	}
+	setNonEmpty(q, map[string]string{"type": o.Type})
+	return q
+}
+
+// OutboxEvent неопубликованное событие
+type OutboxEvent struct {
+	ID            uuid.UUID       `json:"id"`
+	AggregateID   uuid.UUID       `json:"aggregate_id"`
+	Type          string          `json:"type"`
+	Payload       json.RawMessage `json:"payload"`
+	CreatedAt     time.Time       `json:"created_at"`
+	Attempts      int             `json:"attempts"`
+	LastError     string          `json:"last_error,omitempty"`
+	NextAttemptAt *time.Time      `json:"next_attempt_at,omitempty"`
+}
+
+// OutboxStats размер очереди тенанта
+type OutboxStats struct {
+	Pending int64      `json:"pending"`
+	Failed  int64      `json:"failed"`
+	Oldest  *time.Time `json:"oldest,omitempty"`
+}
+
+// OutboxReport состояние очереди и выбранные события
+type OutboxReport struct {
+	Stats OutboxStats   `json:"stats"`
+	Items []OutboxEvent `json:"items"`
+}
+
+func setNonEmpty(q url.Values, params map[string]string) {
+	for k, v := range params {
+		if v = strings.TrimSpace(v); v != "" {
+			q.Set(k, v)
+		}
+	}
+}
+
+// Форматы выгрузки
+const (
+	ExportCSV    = "csv"
+	ExportNDJSON = "ndjson"
+	ExportXLSX   = "xlsx"
+)
+
+// ExportOptions фильтры и формат выгрузки
+type ExportOptions struct {
+	Filter
+
+	// Format csv (по умолчанию), ndjson или xlsx
+	Format string
+	// Computed добавить колонки months_active и total_paid
+	Computed bool
+
+	Sort      string
+	OrderBy   string
+	Direction string
+}
+
+func (o ExportOptions) values() url.Values {
+	q := o.Filter.values()
+	if o.Computed {
+		q.Set("computed", "true")
+	}
+	setNonEmpty(q, map[string]string{
+		"format":    o.Format,
+		"sort":      o.Sort,
+		"order_by":  o.OrderBy,
+		"direction": o.Direction,
+	})
+	return q
+}
+
+// Типы тела импорта
+const (
+	ImportCSV    = "text/csv"
+	ImportNDJSON = "application/x-ndjson"
+)
+
+// Режимы импорта
+const (
+	// ImportAtomic все строки или ничего
+	ImportAtomic = "atomic"
+	// ImportBestEffort невалидные строки пропускаются
+	ImportBestEffort = "best_effort"
+)
+
+// ImportRow результат строки импорта
+type ImportRow struct {
+	Line  int        `json:"line"`
+	ID    *uuid.UUID `json:"id,omitempty"`
+	Code  string     `json:"code,omitempty"`
+	Error string     `json:"error,omitempty"`
+}
+
+// ImportResult итог импорта, атомарный импорт с ошибками ничего не создает
+type ImportResult struct {
+	Mode    string      `json:"mode"`
+	Total   int         `json:"total"`
+	Created int         `json:"created"`
+	Failed  int         `json:"failed"`
+	Rows    []ImportRow `json:"rows"`
+}
+
+// FeedToken токен календаря продлений
+type FeedToken struct {
+	Token string `json:"token"`
+	// URL путь календаря от корня API с токеном и тенантом
+	URL string `json:"url"`
+}
+
+// APIKeyRequest выпуск ключа API
+type APIKeyRequest struct {
+	Name string `json:"name"`
+	// Owner uuid пользователя или имя сервиса
+	Owner     string     `json:"owner"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// APIKey ключ API без секрета
+type APIKey struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Owner      string     `json:"owner"`
+	Scopes     []string   `json:"scopes"`
+	Hint       string     `json:"hint"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// IssuedAPIKey выпущенный ключ, Key показывается только один раз
+type IssuedAPIKey struct {
+	APIKey APIKey `json:"api_key"`
+	Key    string `json:"key"`
+}
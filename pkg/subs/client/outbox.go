@@ -0,0 +1,37 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// ListOutbox неопубликованные события тенанта, старые первыми
+func (c *Client) ListOutbox(ctx context.Context, opts OutboxOptions, ro ...RequestOption) (*OutboxReport, error) {
+	var report OutboxReport
+	if _, err := c.do(ctx, newRequest(http.MethodGet, "/admin/outbox", opts.values(), ro), &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// RequeueOutbox возвращает в очередь отложенные события по ids или все при all
+func (c *Client) RequeueOutbox(ctx context.Context, ids []uuid.UUID, all bool, ro ...RequestOption) (int64, error) {
+	in := struct {
+		IDs []uuid.UUID `json:"ids,omitempty"`
+		All bool        `json:"all,omitempty"`
+	}{ids, all}
+	r, err := jsonRequest(http.MethodPost, "/admin/outbox/requeue", in, ro)
+	if err != nil {
+		return 0, err
+	}
+
+	var out struct {
+		Requeued int64 `json:"requeued"`
+	}
+	if _, err := c.do(ctx, r, &out); err != nil {
+		return 0, err
+	}
+	return out.Requeued, nil
+}
@@ -0,0 +1,234 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+var testRetry = RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+
+// failingServer отвечает status первые fails раз, потом 200 с body
+func failingServer(t *testing.T, fails int32, status int, header http.Header, body string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= fails {
+			for k, v := range header {
+				w.Header()[k] = v
+			}
+			code := "TEMPORARY"
+			if status == http.StatusTooManyRequests {
+				code = CodeRateLimited
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			w.Write([]byte(`{"error":"try later","code":"` + code + `"}`))
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 10, MinBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	for attempt := 1; attempt <= 10; attempt++ {
+		d := p.backoff(attempt)
+		require.LessOrEqual(t, d, time.Second, attempt)
+		require.Greater(t, d, time.Duration(0), attempt)
+	}
+}
+
+func TestClient_RetryIdempotent(t *testing.T) {
+	srv, calls := failingServer(t, 2, http.StatusServiceUnavailable, nil, `{"total":10}`)
+	c, err := New(srv.URL, WithRetry(testRetry))
+	require.NoError(t, err)
+
+	total, err := c.TotalCost(context.Background(), Filter{})
+	require.NoError(t, err)
+	require.Equal(t, 10, total)
+	require.Equal(t, int32(3), calls.Load())
+}
+
+func TestClient_RetryExhausted(t *testing.T) {
+	srv, calls := failingServer(t, 10, http.StatusServiceUnavailable, nil, `{}`)
+	c, err := New(srv.URL, WithRetry(testRetry))
+	require.NoError(t, err)
+
+	_, err = c.TotalCost(context.Background(), Filter{})
+	require.ErrorIs(t, err, &APIError{Code: "TEMPORARY", StatusCode: http.StatusServiceUnavailable})
+	require.Equal(t, int32(3), calls.Load())
+}
+
+func TestClient_NoRetryUnsafePost(t *testing.T) {
+	srv, calls := failingServer(t, 1, http.StatusInternalServerError, nil, `{"requeued":1}`)
+	c, err := New(srv.URL, WithRetry(testRetry))
+	require.NoError(t, err)
+
+	// POST без Idempotency-Key мог выполниться - не повторяем
+	_, err = c.RequeueOutbox(context.Background(), nil, true)
+	require.Error(t, err)
+	require.Equal(t, int32(1), calls.Load())
+
+	// с ключом повтор безопасен
+	calls.Store(0)
+	n, err := c.RequeueOutbox(context.Background(), nil, true, WithIdempotencyKey("requeue-1"))
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+	require.Equal(t, int32(2), calls.Load())
+}
+
+func TestClient_NoRetryPutPatch(t *testing.T) {
+	srv, calls := failingServer(t, 1, http.StatusServiceUnavailable, nil, `{}`)
+	c, err := New(srv.URL, WithRetry(testRetry))
+	require.NoError(t, err)
+
+	// PUT и PATCH без Idempotency-Key после 5xx не повторяются, как и POST
+	_, err = c.send(context.Background(), newRequest(http.MethodPut, "/x", nil, nil))
+	require.ErrorIs(t, err, &APIError{Code: "TEMPORARY"})
+	require.Equal(t, int32(1), calls.Load())
+
+	calls.Store(0)
+	price := 500
+	_, err = c.UpdateSubscription(context.Background(), uuid.New(), UpdateSubscription{Price: &price}, WithIfMatch(3))
+	require.ErrorIs(t, err, &APIError{Code: "TEMPORARY"})
+	require.Equal(t, int32(1), calls.Load())
+}
+
+func TestClient_RetryCreateSameKey(t *testing.T) {
+	var keys []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		if len(keys) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"` + uuid.NewString() + `","price":1}`))
+	}))
+	defer srv.Close()
+
+	c, err := New(srv.URL, WithRetry(testRetry))
+	require.NoError(t, err)
+
+	_, err = c.CreateSubscription(context.Background(), CreateSubscription{UserID: uuid.New(), ServiceName: "x", Price: 1, StartDate: "01-2025"})
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	require.NotEmpty(t, keys[0])
+	require.Equal(t, keys[0], keys[1])
+}
+
+func TestClient_RetryRateLimited(t *testing.T) {
+	header := http.Header{"Retry-After": []string{"0"}}
+	srv, calls := failingServer(t, 1, http.StatusTooManyRequests, header, `{"requeued":2}`)
+	c, err := New(srv.URL, WithRetry(testRetry))
+	require.NoError(t, err)
+
+	// 429 отклонен до обработчика - повторяется и для POST
+	n, err := c.RequeueOutbox(context.Background(), nil, true)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), n)
+	require.Equal(t, int32(2), calls.Load())
+}
+
+func TestClient_RetryAfterTooLong(t *testing.T) {
+	header := http.Header{"Retry-After": []string{"120"}}
+	srv, calls := failingServer(t, 1, http.StatusTooManyRequests, header, `{"total":1}`)
+	c, err := New(srv.URL, WithRetry(testRetry))
+	require.NoError(t, err)
+
+	_, err = c.TotalCost(context.Background(), Filter{})
+	require.ErrorIs(t, err, ErrRateLimited)
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, 2*time.Minute, apiErr.RetryAfter)
+	require.Equal(t, int32(1), calls.Load())
+}
+
+func TestClient_RetryAfterLongerThanBackoff(t *testing.T) {
+	header := http.Header{"Retry-After": []string{"1"}}
+	srv, calls := failingServer(t, 1, http.StatusTooManyRequests, header, `{"total":1}`)
+	c, err := New(srv.URL, WithRetry(RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: 50 * time.Millisecond, MaxWait: 2 * time.Second}))
+	require.NoError(t, err)
+
+	// пауза сервера дольше MaxBackoff, но в пределах MaxWait - клиент ее выжидает
+	start := time.Now()
+	total, err := c.TotalCost(context.Background(), Filter{})
+	require.NoError(t, err)
+	require.Equal(t, 1, total)
+	require.GreaterOrEqual(t, time.Since(start), 900*time.Millisecond)
+	require.Equal(t, int32(2), calls.Load())
+}
+
+func TestClient_RetryAfterBeyondDeadline(t *testing.T) {
+	header := http.Header{"Retry-After": []string{"1"}}
+	srv, calls := failingServer(t, 1, http.StatusTooManyRequests, header, `{"total":1}`)
+	c, err := New(srv.URL, WithRetry(RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: 50 * time.Millisecond, MaxWait: time.Minute}))
+	require.NoError(t, err)
+
+	// срок контекста наступит раньше Retry-After - ошибка сразу, без ожидания
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = c.TotalCost(ctx, Filter{})
+	require.ErrorIs(t, err, ErrRateLimited)
+	require.NotErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(start), 400*time.Millisecond)
+	require.Equal(t, int32(1), calls.Load())
+}
+
+func TestClient_RetryContextCanceled(t *testing.T) {
+	srv, _ := failingServer(t, 10, http.StatusServiceUnavailable, nil, `{}`)
+	c, err := New(srv.URL, WithRetry(RetryPolicy{MaxAttempts: 5, MinBackoff: time.Minute, MaxBackoff: time.Minute}))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = c.TotalCost(ctx, Filter{})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.ErrorIs(t, err, &APIError{Code: "TEMPORARY"})
+}
+
+func TestClient_RetryStreamBody(t *testing.T) {
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		if len(bodies) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error":"rate limit exceeded","code":"RATE_LIMITED"}`))
+			return
+		}
+		w.Write([]byte(`{"created":1}`))
+	}))
+	defer srv.Close()
+
+	c, err := New(srv.URL, WithRetry(testRetry))
+	require.NoError(t, err)
+
+	// io.Seeker перематывается к исходной позиции
+	src := strings.NewReader("skip|user_id,service_name\n")
+	src.Seek(5, io.SeekStart)
+	res, err := c.ImportSubscriptions(context.Background(), src, ImportCSV, ImportAtomic)
+	require.NoError(t, err)
+	require.Equal(t, 1, res.Created)
+	require.Equal(t, []string{"user_id,service_name\n", "user_id,service_name\n"}, bodies)
+
+	// обычный io.Reader не перечитать - ошибка без повтора
+	bodies = nil
+	_, err = c.ImportSubscriptions(context.Background(), io.MultiReader(bytes.NewBufferString("a,b\n")), ImportCSV, ImportAtomic)
+	require.ErrorIs(t, err, ErrRateLimited)
+	require.Len(t, bodies, 1)
+}
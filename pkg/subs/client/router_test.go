@@ -0,0 +1,474 @@
+package client_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/auth"
+	common "github.com/end1essrage/efmob-tz/pkg/common/cmd"
+	"github.com/end1essrage/efmob-tz/pkg/common/health"
+	m "github.com/end1essrage/efmob-tz/pkg/common/interfaces/http/middleware"
+	"github.com/end1essrage/efmob-tz/pkg/common/ratelimit"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application/container"
+	"github.com/end1essrage/efmob-tz/pkg/subs/client"
+	subs_http "github.com/end1essrage/efmob-tz/pkg/subs/interfaces/http"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// tokenVerifier токен вида <роль>:<id пользователя>
+type tokenVerifier struct{}
+
+func (tokenVerifier) Verify(_ context.Context, token string) (*auth.Principal, error) {
+	role, subject, _ := strings.Cut(token, ":")
+	id, err := uuid.Parse(subject)
+	if err != nil {
+		return nil, auth.ErrInvalidToken
+	}
+	return &auth.Principal{Subject: subject, UserID: id, Roles: []string{role}}, nil
+}
+
+// fastRetry повторы без долгих пауз
+var fastRetry = client.RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: 2 * time.Second}
+
+type testServer struct {
+	backend *memoryBackend
+	url     string
+}
+
+// newTestServer роутер сервиса как в main: аутентификация, лимиты, идемпотентность, хранилища в памяти
+func newTestServer(t *testing.T, limits string) *testServer {
+	t.Helper()
+	return newTestServerIPLimit(t, limits, "1000/1s")
+}
+
+// newTestServerIPLimit newTestServer со своим лимитом по IP до аутентификации
+func newTestServerIPLimit(t *testing.T, limits, ipLimit string) *testServer {
+	t.Helper()
+	backend := newMemoryBackend()
+	di := container.NewContainer(backend, backend, backend, backend, backend, backend, backend)
+
+	authenticator := auth.NewAuthenticator(
+		auth.Scheme{Name: auth.SchemeBearer, Verifier: tokenVerifier{}},
+		auth.Scheme{Name: auth.SchemeAPIKey, Verifier: auth.NewAPIKeyVerifier(backend)},
+	)
+	routes, err := m.ParseRateLimitRules(limits)
+	require.NoError(t, err)
+	limiter, err := m.NewRateLimiter(m.RateLimiterConfig{
+		Default: ratelimit.Limit{Requests: 1000, Period: time.Second},
+		Routes:  routes,
+	})
+	require.NoError(t, err)
+
+	ipLim, err := ratelimit.ParseLimit(ipLimit)
+	require.NoError(t, err)
+	ipLimiter, err := m.NewRateLimiter(m.RateLimiterConfig{Default: ipLim})
+	require.NoError(t, err)
+
+	r := common.CreateRouter(health.NewRegistry(health.Config{}), m.TimeoutConfig{Default: 5 * time.Second})
+	r.Group(func(api chi.Router) {
+		api.Use(m.IPRateLimit(ipLimiter))
+		subs_http.AddRoutes(api, subs_http.NewSubsHandler(common.ENV_TEST, di),
+			m.Authenticate(authenticator, "subs"), m.RateLimit(limiter), m.Idempotency(backend, m.IdempotencyConfig{TTL: time.Hour}))
+	})
+
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return &testServer{backend: backend, url: srv.URL}
+}
+
+func (s *testServer) client(t *testing.T, opts ...client.Option) *client.Client {
+	t.Helper()
+	c, err := client.New(s.url, append([]client.Option{client.WithRetry(fastRetry)}, opts...)...)
+	require.NoError(t, err)
+	return c
+}
+
+func (s *testServer) admin(t *testing.T) *client.Client {
+	return s.client(t, client.WithToken("admin:"+uuid.NewString()))
+}
+
+func TestSDK_SubscriptionLifecycle(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t, "")
+	c := s.admin(t)
+	user := uuid.New()
+
+	end := "12-2025"
+	created, err := c.CreateSubscription(ctx, client.CreateSubscription{
+		UserID: user, ServiceName: "Netflix", Price: 400, StartDate: "07-2025", EndDate: &end,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "12-2025", created.EndDate)
+
+	got, err := c.GetSubscription(ctx, created.ID)
+	require.NoError(t, err)
+	require.Equal(t, *created, *got)
+
+	price := 500
+	updated, err := c.UpdateSubscription(ctx, created.ID, client.UpdateSubscription{Price: &price, ClearEndDate: true},
+		client.WithIfMatch(got.Version))
+	require.NoError(t, err)
+	require.Equal(t, 500, updated.Price)
+	require.Empty(t, updated.EndDate)
+	require.Equal(t, got.Version+1, updated.Version)
+
+	// устаревшая версия
+	_, err = c.UpdateSubscription(ctx, created.ID, client.UpdateSubscription{Price: &price}, client.WithIfMatch(got.Version))
+	require.ErrorIs(t, err, client.ErrPreconditionFailed)
+	err = c.DeleteSubscription(ctx, created.ID, client.WithIfMatch(got.Version))
+	require.ErrorIs(t, err, client.ErrPreconditionFailed)
+
+	noop, err := c.UpdateSubscription(ctx, created.ID, client.UpdateSubscription{})
+	require.NoError(t, err)
+	require.Nil(t, noop)
+
+	require.NoError(t, c.DeleteSubscription(ctx, created.ID, client.WithIfMatch(updated.Version)))
+	_, err = c.GetSubscription(ctx, created.ID)
+	require.ErrorIs(t, err, client.ErrNotFound)
+
+	var apiErr *client.APIError
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, 404, apiErr.StatusCode)
+}
+
+func TestSDK_Pagination(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t, "")
+	c := s.admin(t)
+	user, other := uuid.New(), uuid.New()
+
+	for i := range 7 {
+		_, err := c.CreateSubscription(ctx, client.CreateSubscription{UserID: user, ServiceName: "Netflix", Price: 100 * (i + 1), StartDate: "01-2025"})
+		require.NoError(t, err)
+	}
+	_, err := c.CreateSubscription(ctx, client.CreateSubscription{UserID: other, ServiceName: "Spotify", Price: 1, StartDate: "01-2025"})
+	require.NoError(t, err)
+
+	opts := client.ListOptions{Filter: client.Filter{UserIDs: []uuid.UUID{user}}, PageSize: 3, WithTotal: true}
+
+	var sizes []int
+	for page, err := range c.Pages(ctx, opts) {
+		require.NoError(t, err)
+		sizes = append(sizes, len(page.Items))
+		if len(sizes) == 1 {
+			require.Equal(t, 7, *page.Total)
+		} else {
+			require.Nil(t, page.Total)
+		}
+	}
+	require.Equal(t, []int{3, 3, 1}, sizes)
+
+	var prices []int
+	for sub, err := range c.Subscriptions(ctx, opts) {
+		require.NoError(t, err)
+		require.Equal(t, user, sub.UserID)
+		prices = append(prices, sub.Price)
+	}
+	require.Equal(t, []int{100, 200, 300, 400, 500, 600, 700}, prices)
+
+	// ранний выход не запрашивает следующие страницы
+	n := 0
+	for range c.Subscriptions(ctx, opts) {
+		if n++; n == 4 {
+			break
+		}
+	}
+	require.Equal(t, 4, n)
+
+	min := 500
+	total, err := c.TotalCost(ctx, client.Filter{UserIDs: []uuid.UUID{user}, PriceMin: &min})
+	require.NoError(t, err)
+	require.Equal(t, 500+600+700, total)
+
+	// ошибка завершает обход
+	for _, err := range c.Subscriptions(ctx, client.ListOptions{PageSize: 5000}) {
+		require.ErrorIs(t, err, &client.APIError{Code: client.CodeInvalidQuery})
+	}
+}
+
+func TestSDK_Errors(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t, "")
+
+	_, err := s.client(t).ListSubscriptions(ctx, client.ListOptions{})
+	require.ErrorIs(t, err, client.ErrUnauthorized)
+
+	_, err = s.client(t, client.WithToken("admin:not-a-uuid")).ListSubscriptions(ctx, client.ListOptions{})
+	require.ErrorIs(t, err, client.ErrUnauthorized)
+
+	user := uuid.New()
+	userClient := s.client(t, client.WithToken("user:"+user.String()))
+	_, err = userClient.ListOutbox(ctx, client.OutboxOptions{})
+	require.ErrorIs(t, err, client.ErrForbidden)
+
+	_, err = userClient.CreateSubscription(ctx, client.CreateSubscription{UserID: user, ServiceName: "Netflix", Price: 400, StartDate: "2025-07"})
+	var apiErr *client.APIError
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, 400, apiErr.StatusCode)
+	require.Equal(t, "INVALID_DATE", apiErr.Code)
+
+	// статус в target тоже сравнивается
+	require.NotErrorIs(t, err, &client.APIError{Code: "INVALID_DATE", StatusCode: 422})
+}
+
+// TestRouter_BadCredentialsRateLimited перебор ключей и токенов упирается в лимит по IP до аутентификации
+func TestRouter_BadCredentialsRateLimited(t *testing.T) {
+	s := newTestServerIPLimit(t, "", "5/1m")
+
+	do := func(path, authorization string) int {
+		req, err := http.NewRequest(http.MethodGet, s.url+path, nil)
+		require.NoError(t, err)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	for i := 0; i < 3; i++ {
+		require.Equal(t, http.StatusUnauthorized, do("/subscriptions", "ApiKey efm_"+uuid.NewString()))
+	}
+	require.Equal(t, http.StatusUnauthorized, do("/subscriptions", "Bearer admin:not-a-uuid"))
+	require.Equal(t, http.StatusUnauthorized, do("/admin/api-keys", "Bearer admin:not-a-uuid"))
+
+	// лимит IP исчерпан - 429 до проверки ключа
+	require.Equal(t, http.StatusTooManyRequests, do("/subscriptions", "ApiKey efm_"+uuid.NewString()))
+	require.Equal(t, http.StatusTooManyRequests, do("/subscriptions", "Bearer admin:"+uuid.NewString()))
+}
+
+// TestRouter_ProbesNotRateLimited пробы балансировщика и scrape метрик не тратят и не упираются в лимит по IP
+func TestRouter_ProbesNotRateLimited(t *testing.T) {
+	s := newTestServerIPLimit(t, "", "1/1m")
+
+	get := func(path string) int {
+		resp, err := http.Get(s.url + path)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	for i := 0; i < 20; i++ {
+		for _, path := range []string{"/livez", "/readyz", "/health", "/metrics"} {
+			require.Equal(t, http.StatusOK, get(path), path)
+		}
+	}
+
+	// ведро API не тронуто пробами
+	require.Equal(t, http.StatusUnauthorized, get("/subscriptions"))
+	require.Equal(t, http.StatusTooManyRequests, get("/subscriptions"))
+}
+
+func TestSDK_RetryServerErrors(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t, "")
+	c := s.admin(t)
+	user := uuid.New()
+
+	// создание повторяется с тем же Idempotency-Key, подписка одна
+	s.backend.fail = errors.New("db is down")
+	sub, err := c.CreateSubscription(ctx, client.CreateSubscription{UserID: user, ServiceName: "Netflix", Price: 400, StartDate: "07-2025"})
+	require.NoError(t, err)
+	require.Len(t, s.backend.subs, 1)
+
+	s.backend.fail = errors.New("db is down")
+	got, err := c.GetSubscription(ctx, sub.ID)
+	require.NoError(t, err)
+	require.Equal(t, sub.ID, got.ID)
+
+	// без повторов ошибка видна вызывающему
+	once := s.client(t, client.WithToken("admin:"+uuid.NewString()), client.WithRetry(client.RetryPolicy{MaxAttempts: 1}))
+	s.backend.fail = errors.New("db is down")
+	_, err = once.GetSubscription(ctx, sub.ID)
+	var apiErr *client.APIError
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, 500, apiErr.StatusCode)
+	require.Equal(t, "INTERNAL_ERROR", apiErr.Code)
+}
+
+func TestSDK_RateLimitRetryAfter(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t, "GET /subscriptions/total=1/1s:1")
+	user := uuid.New()
+	filter := client.Filter{UserIDs: []uuid.UUID{user}}
+
+	c := s.admin(t)
+	_, err := c.TotalCost(ctx, filter)
+	require.NoError(t, err)
+
+	// второй запрос получает 429 с Retry-After: 1 и ждет его
+	start := time.Now()
+	_, err = c.TotalCost(ctx, filter)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, time.Since(start), 900*time.Millisecond)
+
+	// Retry-After дольше MaxWait (по умолчанию MaxBackoff) - ошибка сразу
+	impatient := s.client(t, client.WithToken("admin:"+uuid.NewString()),
+		client.WithRetry(client.RetryPolicy{MaxAttempts: 5, MinBackoff: time.Millisecond, MaxBackoff: 100 * time.Millisecond}))
+	_, err = impatient.TotalCost(ctx, filter)
+	require.NoError(t, err)
+	_, err = impatient.TotalCost(ctx, filter)
+	require.ErrorIs(t, err, client.ErrRateLimited)
+	var apiErr *client.APIError
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, time.Second, apiErr.RetryAfter)
+
+	// Retry-After не укладывается в срок контекста - ошибка сразу, без ожидания
+	cctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	_, err = c.TotalCost(cctx, filter)
+	require.ErrorIs(t, err, client.ErrRateLimited)
+	require.Less(t, time.Since(start), 400*time.Millisecond)
+}
+
+func TestSDK_ImportExport(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t, "")
+	c := s.admin(t)
+	user := uuid.New()
+
+	csv := "user_id,service_name,price,start_date,end_date\n" +
+		user.String() + ",Netflix,400,07-2025,\n" +
+		user.String() + ",Spotify,-1,07-2025,\n"
+
+	// атомарный импорт с ошибкой ничего не создает и не считается ошибкой запроса
+	result, err := c.ImportSubscriptions(ctx, strings.NewReader(csv), client.ImportCSV, client.ImportAtomic)
+	require.NoError(t, err)
+	require.Equal(t, 0, result.Created)
+	require.Equal(t, 1, result.Failed)
+	require.Empty(t, s.backend.subs)
+
+	result, err = c.ImportSubscriptions(ctx, strings.NewReader(csv), client.ImportCSV, client.ImportBestEffort)
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Created)
+	require.Equal(t, "INVALID_PRICE", result.Rows[1].Code)
+
+	_, err = c.ImportSubscriptions(ctx, strings.NewReader(csv), "text/plain", client.ImportAtomic)
+	require.ErrorIs(t, err, &client.APIError{Code: "UNSUPPORTED_MEDIA_TYPE"})
+
+	body, err := c.ExportSubscriptions(ctx, client.ExportOptions{Filter: client.Filter{UserIDs: []uuid.UUID{user}}, Format: client.ExportNDJSON})
+	require.NoError(t, err)
+	defer body.Close()
+
+	var rows []subs_http.ExportRow
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		var row subs_http.ExportRow
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &row))
+		rows = append(rows, row)
+	}
+	require.NoError(t, scanner.Err())
+	require.Len(t, rows, 1)
+	require.Equal(t, "Netflix", rows[0].ServiceName)
+
+	_, err = c.ExportSubscriptions(ctx, client.ExportOptions{Format: "pdf"})
+	require.ErrorIs(t, err, &client.APIError{Code: "VALIDATION_ERROR"})
+}
+
+func TestSDK_RenewalsFeed(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t, "")
+	user := uuid.New()
+	c := s.client(t, client.WithToken("user:"+user.String()))
+
+	_, err := c.CreateSubscription(ctx, client.CreateSubscription{UserID: user, ServiceName: "Netflix", Price: 400, StartDate: "01-2025"})
+	require.NoError(t, err)
+
+	token, err := c.IssueFeedToken(ctx, user)
+	require.NoError(t, err)
+	require.Contains(t, token.URL, token.Token)
+
+	// календарь открывается без аутентификации
+	ics, err := s.client(t).RenewalsCalendar(ctx, user, token.Token)
+	require.NoError(t, err)
+	require.Contains(t, string(ics), "BEGIN:VCALENDAR")
+	require.Contains(t, string(ics), "Netflix")
+
+	_, err = s.client(t).RenewalsCalendar(ctx, user, "wrong")
+	require.ErrorIs(t, err, &client.APIError{Code: client.CodeFeedNotFound})
+
+	// чужой календарь
+	_, err = c.IssueFeedToken(ctx, uuid.New())
+	var apiErr *client.APIError
+	require.ErrorAs(t, err, &apiErr)
+	require.Contains(t, []int{403, 404}, apiErr.StatusCode)
+}
+
+func TestSDK_APIKeys(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t, "")
+	admin := s.admin(t)
+
+	issued, err := admin.IssueAPIKey(ctx, client.APIKeyRequest{Name: "billing export", Owner: "billing-job", Scopes: []string{string(application.PermReadAny)}})
+	require.NoError(t, err)
+	require.NotEmpty(t, issued.Key)
+
+	_, err = admin.IssueAPIKey(ctx, client.APIKeyRequest{Name: "bad", Owner: "billing-job", Scopes: []string{"subs:everything"}})
+	require.ErrorIs(t, err, &client.APIError{Code: client.CodeInvalidCommand})
+
+	job := s.client(t, client.WithAPIKey(issued.Key))
+	_, err = job.ListSubscriptions(ctx, client.ListOptions{})
+	require.NoError(t, err)
+	_, err = job.ListOutbox(ctx, client.OutboxOptions{})
+	require.ErrorIs(t, err, client.ErrForbidden)
+
+	rotated, err := admin.RotateAPIKey(ctx, issued.APIKey.ID)
+	require.NoError(t, err)
+	require.NotEqual(t, issued.Key, rotated.Key)
+	_, err = job.ListSubscriptions(ctx, client.ListOptions{})
+	require.ErrorIs(t, err, client.ErrUnauthorized)
+
+	require.NoError(t, admin.RevokeAPIKey(ctx, issued.APIKey.ID))
+	require.NoError(t, admin.RevokeAPIKey(ctx, issued.APIKey.ID))
+
+	keys, err := admin.ListAPIKeys(ctx)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	require.NotNil(t, keys[0].RevokedAt)
+
+	_, err = admin.RotateAPIKey(ctx, issued.APIKey.ID)
+	require.ErrorIs(t, err, &client.APIError{Code: client.CodeAPIKeyRevoked})
+	_, err = admin.RotateAPIKey(ctx, uuid.New())
+	require.ErrorIs(t, err, &client.APIError{Code: client.CodeAPIKeyNotFound})
+}
+
+func TestSDK_Outbox(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t, "")
+	c := s.admin(t)
+
+	next := time.Now().Add(time.Minute)
+	agg := uuid.New()
+	s.backend.events = []application.OutboxEvent{
+		{ID: uuid.New(), AggregateID: agg, Type: "subscription_created", Payload: []byte(`{"price":400}`), CreatedAt: time.Now().Add(-time.Hour),
+			Attempts: 2, LastError: "broker unavailable", NextAttemptAt: &next},
+		{ID: uuid.New(), AggregateID: agg, Type: "subscription_updated", Payload: []byte(`{}`), CreatedAt: time.Now()},
+	}
+
+	report, err := c.ListOutbox(ctx, client.OutboxOptions{Failed: true})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), report.Stats.Pending)
+	require.Equal(t, int64(1), report.Stats.Failed)
+	require.Len(t, report.Items, 1)
+	require.Equal(t, "broker unavailable", report.Items[0].LastError)
+	require.JSONEq(t, `{"price":400}`, string(report.Items[0].Payload))
+
+	_, err = c.RequeueOutbox(ctx, nil, false)
+	require.ErrorIs(t, err, &client.APIError{Code: client.CodeInvalidCommand})
+
+	n, err := c.RequeueOutbox(ctx, nil, true)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+}
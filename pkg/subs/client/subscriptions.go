@@ -0,0 +1,161 @@
+package client
+
+import (
+	"context"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+// ListSubscriptions одна страница подписок
+func (c *Client) ListSubscriptions(ctx context.Context, opts ListOptions, ro ...RequestOption) (*Page, error) {
+	var items []Subscription
+	resp, err := c.do(ctx, newRequest(http.MethodGet, "/subscriptions", opts.values(), ro), &items)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &Page{Items: items, NextCursor: resp.Header.Get("X-Next-Cursor")}
+	if v := resp.Header.Get("X-Total-Count"); v != "" {
+		total, err := strconv.Atoi(v)
+		if err == nil {
+			page.Total = &total
+		}
+	}
+	return page, nil
+}
+
+// Pages страницы подписок по X-Next-Cursor, начиная с opts. Ошибка завершает обход
+func (c *Client) Pages(ctx context.Context, opts ListOptions, ro ...RequestOption) iter.Seq2[*Page, error] {
+	return func(yield func(*Page, error) bool) {
+		for {
+			page, err := c.ListSubscriptions(ctx, opts, ro...)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(page, nil) || page.NextCursor == "" {
+				return
+			}
+			// курсор заменяет номер страницы, общее число считается один раз
+			opts.Cursor, opts.Page, opts.WithTotal = page.NextCursor, 0, false
+		}
+	}
+}
+
+// Subscriptions все подписки по фильтрам постранично:
+//
+//	for sub, err := range c.Subscriptions(ctx, opts) {
+//		if err != nil { ... }
+//	}
+func (c *Client) Subscriptions(ctx context.Context, opts ListOptions, ro ...RequestOption) iter.Seq2[Subscription, error] {
+	return func(yield func(Subscription, error) bool) {
+		for page, err := range c.Pages(ctx, opts, ro...) {
+			if err != nil {
+				yield(Subscription{}, err)
+				return
+			}
+			for _, sub := range page.Items {
+				if !yield(sub, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// GetSubscription подписка по ID
+func (c *Client) GetSubscription(ctx context.Context, id uuid.UUID, ro ...RequestOption) (*Subscription, error) {
+	var sub Subscription
+	if _, err := c.do(ctx, newRequest(http.MethodGet, "/subscriptions/"+id.String(), nil, ro), &sub); err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// CreateSubscription создает подписку. Без WithIdempotencyKey ключ генерируется -
+// повтор после 5xx вернет уже созданную подписку
+func (c *Client) CreateSubscription(ctx context.Context, in CreateSubscription, ro ...RequestOption) (*Subscription, error) {
+	r, err := jsonRequest(http.MethodPost, "/subscriptions", in, ro)
+	if err != nil {
+		return nil, err
+	}
+	if r.header.Get("Idempotency-Key") == "" {
+		r.header.Set("Idempotency-Key", uuid.NewString())
+	}
+
+	var sub Subscription
+	if _, err := c.do(ctx, r, &sub); err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// UpdateSubscription обновляет подписку, nil без ошибки - изменять нечего
+func (c *Client) UpdateSubscription(ctx context.Context, id uuid.UUID, in UpdateSubscription, ro ...RequestOption) (*Subscription, error) {
+	r, err := jsonRequest(http.MethodPatch, "/subscriptions/"+id.String(), in, ro)
+	if err != nil {
+		return nil, err
+	}
+
+	var sub Subscription
+	resp, err := c.do(ctx, r, &sub)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNoContent {
+		return nil, nil
+	}
+	return &sub, nil
+}
+
+// DeleteSubscription удаляет подписку
+func (c *Client) DeleteSubscription(ctx context.Context, id uuid.UUID, ro ...RequestOption) error {
+	_, err := c.do(ctx, newRequest(http.MethodDelete, "/subscriptions/"+id.String(), nil, ro), nil)
+	return err
+}
+
+// TotalCost сумма подписок по фильтрам, user_id обязателен
+func (c *Client) TotalCost(ctx context.Context, f Filter, ro ...RequestOption) (int, error) {
+	var out struct {
+		Total int `json:"total"`
+	}
+	if _, err := c.do(ctx, newRequest(http.MethodGet, "/subscriptions/total", f.values(), ro), &out); err != nil {
+		return 0, err
+	}
+	return out.Total, nil
+}
+
+// ImportSubscriptions загружает CSV или NDJSON (contentType ImportCSV, ImportNDJSON), mode - ImportAtomic или ImportBestEffort.
+// Отклоненный атомарный импорт не ошибка - строки с ошибками в ImportResult.Rows.
+// Тело повторяется, только если body - io.Seeker (файл, bytes.Reader)
+func (c *Client) ImportSubscriptions(ctx context.Context, body io.Reader, contentType, mode string, ro ...RequestOption) (*ImportResult, error) {
+	q := url.Values{}
+	setNonEmpty(q, map[string]string{"mode": mode})
+	r := newRequest(http.MethodPost, "/subscriptions/import", q, ro)
+	r.stream = body
+	r.header.Set("Content-Type", contentType)
+	r.accept = []int{http.StatusUnprocessableEntity}
+
+	var result ImportResult
+	if _, err := c.do(ctx, r, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ExportSubscriptions файл выгрузки потоком, закрывает вызывающий
+func (c *Client) ExportSubscriptions(ctx context.Context, opts ExportOptions, ro ...RequestOption) (io.ReadCloser, error) {
+	r := newRequest(http.MethodGet, "/subscriptions/export", opts.values(), ro)
+	r.header.Set("Accept", "*/*")
+
+	resp, err := c.send(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,32 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/google/uuid"
+)
+
+// IssueFeedToken новый токен календаря продлений, прежний перестает действовать
+func (c *Client) IssueFeedToken(ctx context.Context, userID uuid.UUID, ro ...RequestOption) (*FeedToken, error) {
+	var token FeedToken
+	if _, err := c.do(ctx, newRequest(http.MethodPost, "/users/"+userID.String()+"/renewals/token", nil, ro), &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// RenewalsCalendar календарь продлений в iCalendar, доступ по токену фида
+func (c *Client) RenewalsCalendar(ctx context.Context, userID uuid.UUID, token string, ro ...RequestOption) ([]byte, error) {
+	r := newRequest(http.MethodGet, "/users/"+userID.String()+"/renewals.ics", url.Values{"token": {token}}, ro)
+	r.header.Set("Accept", "text/calendar")
+
+	resp, err := c.send(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
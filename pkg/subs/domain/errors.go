@@ -3,10 +3,15 @@ package domain
 import "errors"
 
 var (
+	ErrInvalidUserID        = errors.New("user id is required")
 	ErrInvalidServiceName   = errors.New("service name cannot be empty")
 	ErrInvalidPrice         = errors.New("price must be positive")
 	ErrInvalidDates         = errors.New("end date must be after start date")
 	ErrInvalidDateFormat    = errors.New("invalid date format, should be mm-yyyy")
 	ErrInvalidPeriod        = errors.New("invalid period")
 	ErrSubscriptionNotFound = errors.New("subscription not found")
+	ErrFeedTokenNotFound    = errors.New("feed token not found")
+	ErrInvalidFilter        = errors.New("invalid filter")
+	ErrInvalidPriceRange    = errors.New("price_min must not exceed price_max")
+	ErrInvalidSortField     = errors.New("invalid sort field")
 )
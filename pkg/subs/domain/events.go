@@ -8,6 +8,8 @@ import (
 
 type Event interface {
 	Type() string
+	// AggregateID - id подписки, порядок событий гарантируется в его рамках
+	AggregateID() uuid.UUID
 	MarshalJSON() ([]byte, error)
 }
 
@@ -20,6 +22,10 @@ func (s SubCreatedEvent) Type() string {
 	return "subscription_created"
 }
 
+func (s SubCreatedEvent) AggregateID() uuid.UUID {
+	return s.Id
+}
+
 func (s SubCreatedEvent) MarshalJSON() ([]byte, error) {
 	return json.Marshal(struct {
 		ID     uuid.UUID `json:"id"`
@@ -38,6 +44,10 @@ func (s SubDeletedEvent) Type() string {
 	return "subscription_deleted"
 }
 
+func (s SubDeletedEvent) AggregateID() uuid.UUID {
+	return s.Id
+}
+
 func (s SubDeletedEvent) MarshalJSON() ([]byte, error) {
 	return json.Marshal(struct {
 		ID uuid.UUID `json:"id"`
@@ -0,0 +1,189 @@
+package domain
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/rsql"
+	"github.com/google/uuid"
+)
+
+type FilterField string
+
+const (
+	FilterByUserID      FilterField = "user_id"
+	FilterByServiceName FilterField = "service_name"
+	FilterByPrice       FilterField = "price"
+	FilterByStartDate   FilterField = "start_date"
+	FilterByEndDate     FilterField = "end_date"
+	FilterByCreatedAt   FilterField = "created_at"
+)
+
+type FilterOperator string
+
+const (
+	FilterEq  FilterOperator = "=="
+	FilterNe  FilterOperator = "!="
+	FilterLt  FilterOperator = "=lt="
+	FilterLe  FilterOperator = "=le="
+	FilterGt  FilterOperator = "=gt="
+	FilterGe  FilterOperator = "=ge="
+	FilterIn  FilterOperator = "=in="
+	FilterOut FilterOperator = "=out="
+	// FilterNull аргумент true - поле пустое, false - заполнено
+	FilterNull FilterOperator = "=null="
+)
+
+// filterValueKind тип значений поля в выражении
+type filterValueKind int
+
+const (
+	filterUUID filterValueKind = iota
+	filterString
+	filterInt
+	// MM-YYYY, как в остальных фильтрах дат
+	filterMonth
+	// YYYY-MM-DD
+	filterDay
+)
+
+type filterFieldSpec struct {
+	kind filterValueKind
+	ops  []FilterOperator
+}
+
+var (
+	equalityOps   = []FilterOperator{FilterEq, FilterNe, FilterIn, FilterOut}
+	comparisonOps = []FilterOperator{FilterEq, FilterNe, FilterLt, FilterLe, FilterGt, FilterGe}
+)
+
+// filterFields белый список полей и операторов выражения
+var filterFields = map[FilterField]filterFieldSpec{
+	FilterByUserID:      {kind: filterUUID, ops: equalityOps},
+	FilterByServiceName: {kind: filterString, ops: equalityOps},
+	FilterByPrice:       {kind: filterInt, ops: append(append([]FilterOperator{}, comparisonOps...), FilterIn, FilterOut)},
+	FilterByStartDate:   {kind: filterMonth, ops: comparisonOps},
+	FilterByEndDate:     {kind: filterMonth, ops: append(append([]FilterOperator{}, comparisonOps...), FilterNull)},
+	FilterByCreatedAt:   {kind: filterDay, ops: comparisonOps},
+}
+
+// FilterExpr проверенное выражение фильтрации: FilterGroup или FilterCondition
+type FilterExpr interface {
+	filterExpr()
+}
+
+// FilterGroup условия через AND, или через OR при Or
+type FilterGroup struct {
+	Or    bool
+	Exprs []FilterExpr
+}
+
+// FilterCondition сравнение поля со значениями.
+// Values типизированы по полю: uuid.UUID, string, int, time.Time, для FilterNull - bool
+type FilterCondition struct {
+	Field  FilterField
+	Op     FilterOperator
+	Values []interface{}
+	// Wildcard для service_name с == и != значение содержит *, любое кол-во символов
+	Wildcard bool
+}
+
+func (FilterGroup) filterExpr()     {}
+func (FilterCondition) filterExpr() {}
+
+// NewFilterExpr проверяет дерево RSQL по белому списку полей и операторов и приводит значения к типам полей
+func NewFilterExpr(n rsql.Node) (FilterExpr, error) {
+	switch v := n.(type) {
+	case *rsql.Logical:
+		group := FilterGroup{Or: v.Op == rsql.Or, Exprs: make([]FilterExpr, len(v.Children))}
+		for i, c := range v.Children {
+			e, err := NewFilterExpr(c)
+			if err != nil {
+				return nil, err
+			}
+			group.Exprs[i] = e
+		}
+		return group, nil
+	case *rsql.Comparison:
+		return newFilterCondition(v)
+	default:
+		return nil, fmt.Errorf("%w: empty expression", ErrInvalidFilter)
+	}
+}
+
+func newFilterCondition(c *rsql.Comparison) (FilterExpr, error) {
+	field := FilterField(c.Selector)
+	spec, ok := filterFields[field]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown field %q at position %d", ErrInvalidFilter, c.Selector, c.Pos)
+	}
+
+	op := FilterOperator(c.Operator)
+	if !containsOp(spec.ops, op) {
+		return nil, fmt.Errorf("%w: operator %s not allowed for %s at position %d", ErrInvalidFilter, c.Operator, c.Selector, c.Pos)
+	}
+
+	multi := op == FilterIn || op == FilterOut
+	if !multi && len(c.Args) != 1 {
+		return nil, fmt.Errorf("%w: %s%s takes one value at position %d", ErrInvalidFilter, c.Selector, c.Operator, c.Pos)
+	}
+	if len(c.Args) > maxFilterValues {
+		return nil, fmt.Errorf("%w: no more than %d values at position %d", ErrInvalidFilter, maxFilterValues, c.Pos)
+	}
+
+	cond := FilterCondition{Field: field, Op: op, Values: make([]interface{}, len(c.Args))}
+	for i, arg := range c.Args {
+		var (
+			v   interface{}
+			err error
+		)
+		if op == FilterNull {
+			v, err = strconv.ParseBool(arg)
+		} else {
+			v, err = parseFilterValue(spec.kind, arg)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid value %q for %s at position %d", ErrInvalidFilter, arg, c.Selector, c.Pos)
+		}
+		cond.Values[i] = v
+	}
+
+	if spec.kind == filterString && !multi {
+		cond.Wildcard = strings.Contains(c.Args[0], "*")
+	}
+
+	return cond, nil
+}
+
+func parseFilterValue(kind filterValueKind, s string) (interface{}, error) {
+	switch kind {
+	case filterUUID:
+		id, err := uuid.Parse(s)
+		if err == nil && id == uuid.Nil {
+			return nil, ErrInvalidUserID
+		}
+		return id, err
+	case filterInt:
+		return strconv.Atoi(s)
+	case filterMonth:
+		return time.Parse("01-2006", s)
+	case filterDay:
+		return time.Parse(time.DateOnly, s)
+	default:
+		if strings.TrimSpace(s) == "" {
+			return nil, ErrInvalidServiceName
+		}
+		return s, nil
+	}
+}
+
+func containsOp(ops []FilterOperator, op FilterOperator) bool {
+	for _, o := range ops {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
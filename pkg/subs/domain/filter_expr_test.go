@@ -0,0 +1,54 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/rsql"
+	"github.com/stretchr/testify/require"
+)
+
+func parseFilterExpr(t *testing.T, s string) (FilterExpr, error) {
+	t.Helper()
+	n, err := rsql.Parse(s)
+	require.NoError(t, err)
+	return NewFilterExpr(n)
+}
+
+func TestNewFilterExpr_OK(t *testing.T) {
+	expr, err := parseFilterExpr(t, "price=gt=500;(service_name==Net*,end_date=null=true);start_date=ge=01-2025")
+	require.NoError(t, err)
+
+	require.Equal(t, FilterGroup{Exprs: []FilterExpr{
+		FilterCondition{Field: FilterByPrice, Op: FilterGt, Values: []interface{}{500}},
+		FilterGroup{Or: true, Exprs: []FilterExpr{
+			FilterCondition{Field: FilterByServiceName, Op: FilterEq, Values: []interface{}{"Net*"}, Wildcard: true},
+			FilterCondition{Field: FilterByEndDate, Op: FilterNull, Values: []interface{}{true}},
+		}},
+		FilterCondition{Field: FilterByStartDate, Op: FilterGe, Values: []interface{}{time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}},
+	}}, expr)
+}
+
+func TestNewFilterExpr_Invalid(t *testing.T) {
+	tests := []string{
+		"version==1",
+		"user_id=gt=3c1f9a7e-5b2d-4e8a-9c6f-1d7b3e5a9f20",
+		"user_id==not-a-uuid",
+		"user_id==00000000-0000-0000-0000-000000000000",
+		"price==abc",
+		"price==(1,2)",
+		"start_date==2025-01",
+		"start_date=null=true",
+		"end_date=null=maybe",
+		"created_at==01-2025",
+		"service_name=like=Net",
+	}
+
+	for _, expr := range tests {
+		t.Run(expr, func(t *testing.T) {
+			_, err := parseFilterExpr(t, expr)
+			require.ErrorIs(t, err, ErrInvalidFilter)
+			require.Contains(t, err.Error(), "at position 1")
+		})
+	}
+}
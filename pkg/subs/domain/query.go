@@ -1,6 +1,8 @@
 package domain
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -27,12 +29,100 @@ func NewPeriod(from, to *time.Time) (*Period, error) {
 func (p Period) From() *time.Time { return p.from }
 func (p Period) To() *time.Time   { return p.to }
 
+// ServiceNameMatch способ сравнения service_name
+type ServiceNameMatch string
+
+const (
+	ServiceNameExact      ServiceNameMatch = "exact"
+	ServiceNameIgnoreCase ServiceNameMatch = "icase"
+	// ServiceNameFuzzy подстрока без учёта регистра или триграммное сходство
+	ServiceNameFuzzy ServiceNameMatch = "fuzzy"
+)
+
+const (
+	// максимальное кол-во значений в IN фильтрах
+	maxFilterValues = 100
+	// короче триграммы нечёткий поиск бессмысленен
+	minFuzzyLength = 3
+)
+
+// SubscriptionFilter дополнительные фильтры квери
+type SubscriptionFilter struct {
+	UserIDs          []uuid.UUID
+	ServiceNames     []string
+	ServiceNameMatch ServiceNameMatch
+	PriceMin         *int
+	PriceMax         *int
+	CreatedPeriod    *Period
+	// Expression выражение из filter=, объединяется с остальными фильтрами через AND
+	Expression FilterExpr
+}
+
+type SortField string
+
+const (
+	SortByPrice       SortField = "price"
+	SortByStartDate   SortField = "start_date"
+	SortByEndDate     SortField = "end_date"
+	SortByCreatedAt   SortField = "created_at"
+	SortByServiceName SortField = "service_name"
+)
+
+var sortFields = map[SortField]bool{
+	SortByPrice:       true,
+	SortByStartDate:   true,
+	SortByEndDate:     true,
+	SortByCreatedAt:   true,
+	SortByServiceName: true,
+}
+
+type SortKey struct {
+	Field SortField
+	Desc  bool
+}
+
+// ParseSort разбирает сортировку вида "-price,start_date", минус - по убыванию.
+// Поля проверяются в WithSort
+func ParseSort(s string) []SortKey {
+	var keys []SortKey
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		key := SortKey{Field: SortField(part)}
+		if strings.HasPrefix(part, "-") {
+			key = SortKey{Field: SortField(part[1:]), Desc: true}
+		} else if strings.HasPrefix(part, "+") {
+			key.Field = SortField(part[1:])
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// FormatSort обратное к ParseSort представление
+func FormatSort(keys []SortKey) string {
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = string(k.Field)
+		if k.Desc {
+			parts[i] = "-" + parts[i]
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
 type SubscriptionQuery struct {
 	userID             *uuid.UUID
 	serviceName        *string
 	startPeriod        *Period
 	endPeriod          *Period
 	includeNullEndDate *bool
+
+	filter SubscriptionFilter
+	sort   []SortKey
 }
 
 func NewSubscriptionQuery(
@@ -56,3 +146,92 @@ func (q SubscriptionQuery) ServiceName() *string      { return q.serviceName }
 func (q SubscriptionQuery) StartPeriod() *Period      { return q.startPeriod }
 func (q SubscriptionQuery) EndPeriod() *Period        { return q.endPeriod }
 func (q SubscriptionQuery) IncludeNullEndDate() *bool { return q.includeNullEndDate }
+
+// WithFilter возвращает квери с дополнительными фильтрами
+func (q SubscriptionQuery) WithFilter(f SubscriptionFilter) (SubscriptionQuery, error) {
+	if len(f.UserIDs) > maxFilterValues || len(f.ServiceNames) > maxFilterValues {
+		return q, fmt.Errorf("%w: no more than %d values in list", ErrInvalidFilter, maxFilterValues)
+	}
+	for _, id := range f.UserIDs {
+		if id == uuid.Nil {
+			return q, ErrInvalidUserID
+		}
+	}
+
+	switch f.ServiceNameMatch {
+	case "":
+		f.ServiceNameMatch = ServiceNameExact
+	case ServiceNameExact, ServiceNameIgnoreCase:
+	case ServiceNameFuzzy:
+		for _, name := range f.ServiceNames {
+			if len([]rune(strings.TrimSpace(name))) < minFuzzyLength {
+				return q, fmt.Errorf("%w: fuzzy search needs at least %d characters", ErrInvalidFilter, minFuzzyLength)
+			}
+		}
+	default:
+		return q, fmt.Errorf("%w: unknown service name match %q", ErrInvalidFilter, f.ServiceNameMatch)
+	}
+	for _, name := range f.ServiceNames {
+		if strings.TrimSpace(name) == "" {
+			return q, ErrInvalidServiceName
+		}
+	}
+
+	if (f.PriceMin != nil && *f.PriceMin < 0) || (f.PriceMax != nil && *f.PriceMax < 0) {
+		return q, ErrInvalidPriceRange
+	}
+	if f.PriceMin != nil && f.PriceMax != nil && *f.PriceMin > *f.PriceMax {
+		return q, ErrInvalidPriceRange
+	}
+
+	q.filter = f
+	return q, nil
+}
+
+// WithSort возвращает квери с сортировкой, поле можно использовать один раз
+func (q SubscriptionQuery) WithSort(keys []SortKey) (SubscriptionQuery, error) {
+	seen := make(map[SortField]bool, len(keys))
+	for _, k := range keys {
+		if !sortFields[k.Field] {
+			return q, fmt.Errorf("%w: %q", ErrInvalidSortField, k.Field)
+		}
+		if seen[k.Field] {
+			return q, fmt.Errorf("%w: %q used twice", ErrInvalidSortField, k.Field)
+		}
+		seen[k.Field] = true
+	}
+
+	q.sort = keys
+	return q, nil
+}
+
+// UserIDs все пользователи из фильтров
+func (q SubscriptionQuery) UserIDs() []uuid.UUID {
+	ids := q.filter.UserIDs
+	if q.userID != nil {
+		ids = append([]uuid.UUID{*q.userID}, ids...)
+	}
+	return ids
+}
+
+// ServiceNames все названия сервисов из фильтров
+func (q SubscriptionQuery) ServiceNames() []string {
+	names := q.filter.ServiceNames
+	if q.serviceName != nil {
+		names = append([]string{*q.serviceName}, names...)
+	}
+	return names
+}
+
+func (q SubscriptionQuery) ServiceNameMatch() ServiceNameMatch {
+	if q.filter.ServiceNameMatch == "" {
+		return ServiceNameExact
+	}
+	return q.filter.ServiceNameMatch
+}
+
+func (q SubscriptionQuery) PriceMin() *int         { return q.filter.PriceMin }
+func (q SubscriptionQuery) PriceMax() *int         { return q.filter.PriceMax }
+func (q SubscriptionQuery) CreatedPeriod() *Period { return q.filter.CreatedPeriod }
+func (q SubscriptionQuery) Expression() FilterExpr { return q.filter.Expression }
+func (q SubscriptionQuery) Sort() []SortKey        { return q.sort }
@@ -4,6 +4,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
 )
 
@@ -17,3 +18,78 @@ func TestNewPeriod_OK(t *testing.T) {
 	require.Equal(t, from, *p.From())
 	require.Equal(t, to, *p.To())
 }
+
+func TestParseSort(t *testing.T) {
+	keys := ParseSort(" -price, start_date,+end_date,")
+
+	require.Equal(t, []SortKey{
+		{Field: SortByPrice, Desc: true},
+		{Field: SortByStartDate},
+		{Field: SortByEndDate},
+	}, keys)
+	require.Equal(t, "-price,start_date,end_date", FormatSort(keys))
+	require.Empty(t, ParseSort(""))
+}
+
+func TestSubscriptionQuery_WithSort(t *testing.T) {
+	q := NewSubscriptionQuery(nil, nil, nil, nil, nil)
+
+	q, err := q.WithSort(ParseSort("-price,service_name"))
+	require.NoError(t, err)
+	require.Len(t, q.Sort(), 2)
+
+	_, err = q.WithSort(ParseSort("user_id"))
+	require.ErrorIs(t, err, ErrInvalidSortField)
+
+	_, err = q.WithSort(ParseSort("price,-price"))
+	require.ErrorIs(t, err, ErrInvalidSortField)
+}
+
+func TestSubscriptionQuery_WithFilter(t *testing.T) {
+	userID := uuid.New()
+	name := "Netflix"
+	q := NewSubscriptionQuery(&userID, &name, nil, nil, nil)
+
+	other := uuid.New()
+	min, max := 100, 500
+	q, err := q.WithFilter(SubscriptionFilter{
+		UserIDs:      []uuid.UUID{other},
+		ServiceNames: []string{"Okko"},
+		PriceMin:     &min,
+		PriceMax:     &max,
+	})
+	require.NoError(t, err)
+
+	// одиночные значения объединяются со списками
+	require.Equal(t, []uuid.UUID{userID, other}, q.UserIDs())
+	require.Equal(t, []string{"Netflix", "Okko"}, q.ServiceNames())
+	require.Equal(t, ServiceNameExact, q.ServiceNameMatch())
+	require.Equal(t, 100, *q.PriceMin())
+	require.Equal(t, 500, *q.PriceMax())
+}
+
+func TestSubscriptionQuery_WithFilter_Invalid(t *testing.T) {
+	q := NewSubscriptionQuery(nil, nil, nil, nil, nil)
+	neg, low, high := -1, 100, 50
+
+	tests := []struct {
+		name   string
+		filter SubscriptionFilter
+		err    error
+	}{
+		{"nil user", SubscriptionFilter{UserIDs: []uuid.UUID{uuid.Nil}}, ErrInvalidUserID},
+		{"empty name", SubscriptionFilter{ServiceNames: []string{" "}}, ErrInvalidServiceName},
+		{"unknown match", SubscriptionFilter{ServiceNameMatch: "regex"}, ErrInvalidFilter},
+		{"short fuzzy", SubscriptionFilter{ServiceNames: []string{"ne"}, ServiceNameMatch: ServiceNameFuzzy}, ErrInvalidFilter},
+		{"too many values", SubscriptionFilter{ServiceNames: make([]string, maxFilterValues+1)}, ErrInvalidFilter},
+		{"negative price", SubscriptionFilter{PriceMin: &neg}, ErrInvalidPriceRange},
+		{"min over max", SubscriptionFilter{PriceMin: &low, PriceMax: &high}, ErrInvalidPriceRange},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := q.WithFilter(tt.filter)
+			require.ErrorIs(t, err, tt.err)
+		})
+	}
+}
@@ -21,11 +21,29 @@ type SubscriptionRepository interface {
 	GetByID(ctx context.Context, id uuid.UUID) (*Subscription, error)
 	Update(ctx context.Context, sub *Subscription) error
 	Delete(ctx context.Context, id uuid.UUID) error
-	Find(ctx context.Context, q SubscriptionQuery, p p.Pagination, s *p.Sorting) ([]*Subscription, error)
+	// DeleteWithVersion удаляет подписку, только если её версия совпадает
+	DeleteWithVersion(ctx context.Context, id uuid.UUID, version int) error
+	// Find возвращает страницу подписок в порядке q.Sort()
+	Find(ctx context.Context, q SubscriptionQuery, p p.Pagination) ([]*Subscription, error)
+}
+
+type SubscriptionStreamRepository interface {
+	// Stream построчно отдаёт подписки по квери в fn, не загружая выборку целиком.
+	// Ошибка fn прерывает чтение и возвращается
+	Stream(ctx context.Context, q SubscriptionQuery, fn func(*Subscription) error) error
 }
 
 type SubscriptionStatsRepository interface {
 	CalculateTotalCost(ctx context.Context, q SubscriptionQuery) (int, error)
+	CountSubscriptions(ctx context.Context, q SubscriptionQuery) (int, error)
+}
+
+// FeedTokenRepository хранит хэши токенов календарного фида, один токен на пользователя
+type FeedTokenRepository interface {
+	// SaveFeedToken сохраняет хэш токена, предыдущий токен пользователя перестаёт действовать
+	SaveFeedToken(ctx context.Context, userID uuid.UUID, tokenHash string) error
+	// GetFeedTokenHash возвращает ErrFeedTokenNotFound, если токен не выпускался
+	GetFeedTokenHash(ctx context.Context, userID uuid.UUID) (string, error)
 }
 
 type EventsRepository interface {
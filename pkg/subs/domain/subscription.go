@@ -1,7 +1,6 @@
 package domain
 
 import (
-	"errors"
 	"strings"
 	"time"
 
@@ -34,7 +33,7 @@ func NewSubscriptionWithVersion(
 ) (*Subscription, error) {
 	// Проверяем только бизнес-правила
 	if userID == uuid.Nil {
-		return nil, errors.New("user id is required")
+		return nil, ErrInvalidUserID
 	}
 	if strings.TrimSpace(serviceName) == "" {
 		return nil, ErrInvalidServiceName
@@ -78,7 +77,7 @@ func NewSubscription(
 	}
 
 	if userID == uuid.Nil {
-		return nil, errors.New("user id is required")
+		return nil, ErrInvalidUserID
 	}
 
 	if strings.TrimSpace(serviceName) == "" {
@@ -126,6 +125,10 @@ func (s Subscription) CreatedAt() time.Time { return s.createdAt }
 func (s Subscription) UpdatedAt() time.Time { return s.updatedAt }
 
 func (s Subscription) Version() int { return s.version }
+
+// IncrementVersion вызывается хранилищем после успешного сохранения изменений
+func (s *Subscription) IncrementVersion() { s.version++ }
+
 func (s Subscription) IsActive(at time.Time) bool {
 	if at.Before(s.startDate) {
 		return false
@@ -136,6 +139,25 @@ func (s Subscription) IsActive(at time.Time) bool {
 	return at.Before(*s.endDate) || at.Equal(*s.endDate)
 }
 
+// MonthsActive кол-во оплаченных месяцев на момент at, включая месяц начала.
+// Для бессрочной подписки считается до месяца at
+func (s Subscription) MonthsActive(at time.Time) int {
+	end := normalizeMonth(at)
+	if s.endDate != nil && s.endDate.Before(end) {
+		end = *s.endDate
+	}
+	if end.Before(s.startDate) {
+		return 0
+	}
+
+	return (end.Year()-s.startDate.Year())*12 + int(end.Month()-s.startDate.Month()) + 1
+}
+
+// TotalPaid сумма, оплаченная по подписке на момент at
+func (s Subscription) TotalPaid(at time.Time) int {
+	return s.MonthsActive(at) * s.price
+}
+
 func (s *Subscription) ChangePrice(price int) error {
 	if price <= 0 {
 		return ErrInvalidPrice
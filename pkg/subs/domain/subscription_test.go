@@ -91,3 +91,27 @@ func mustSubscription(t *testing.T) *Subscription {
 	require.NoError(t, err)
 	return sub
 }
+
+func TestSubscription_MonthsActive(t *testing.T) {
+	start := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC)
+	at := time.Date(2025, 10, 15, 0, 0, 0, 0, time.UTC)
+
+	limited, err := NewSubscription(uuid.Nil, uuid.New(), "Netflix", 400, start, &end)
+	require.NoError(t, err)
+
+	// до окончания считаем до текущего месяца
+	require.Equal(t, 4, limited.MonthsActive(at))
+	require.Equal(t, 1600, limited.TotalPaid(at))
+
+	// после окончания - до месяца окончания
+	require.Equal(t, 6, limited.MonthsActive(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)))
+
+	// ещё не началась
+	require.Equal(t, 0, limited.MonthsActive(time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)))
+
+	unlimited, err := NewSubscription(uuid.Nil, uuid.New(), "Ivi", 300, start, nil)
+	require.NoError(t, err)
+	require.Equal(t, 7, unlimited.MonthsActive(time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC)))
+	require.Equal(t, 2100, unlimited.TotalPaid(time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC)))
+}
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS event_models;
+DROP TABLE IF EXISTS subscriptions;
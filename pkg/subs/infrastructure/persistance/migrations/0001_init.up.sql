@@ -0,0 +1,28 @@
+-- Базовая схема - ровно то, что создавал AutoMigrate исходной версии сервиса.
+-- IF NOT EXISTS - чтобы миграция легла поверх такой базы, остальное досоздают следующие миграции
+
+CREATE TABLE IF NOT EXISTS subscriptions (
+    id uuid,
+    user_id uuid NOT NULL,
+    service_name text NOT NULL,
+    price bigint NOT NULL,
+    start_date timestamptz NOT NULL,
+    end_date timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz,
+    version bigint NOT NULL DEFAULT 1,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_user_id ON subscriptions (user_id);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_service_name ON subscriptions (service_name);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_start_date ON subscriptions (start_date);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_end_date ON subscriptions (end_date);
+
+-- outbox
+CREATE TABLE IF NOT EXISTS event_models (
+    id uuid,
+    type text NOT NULL,
+    payload bytea,
+    created_at timestamptz,
+    PRIMARY KEY (id)
+);
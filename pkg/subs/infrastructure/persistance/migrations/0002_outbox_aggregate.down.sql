@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_event_models_created_at;
+DROP INDEX IF EXISTS idx_event_models_aggregate_id;
+ALTER TABLE event_models DROP COLUMN IF EXISTS traceparent;
+ALTER TABLE event_models DROP COLUMN IF EXISTS aggregate_id;
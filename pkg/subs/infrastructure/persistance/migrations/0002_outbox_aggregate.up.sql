@@ -0,0 +1,12 @@
+-- Агрегат и контекст трассировки событий outbox
+ALTER TABLE event_models ADD COLUMN IF NOT EXISTS aggregate_id uuid;
+ALTER TABLE event_models ADD COLUMN IF NOT EXISTS traceparent text;
+
+-- у событий, записанных до колонки, id подписки есть только в payload
+UPDATE event_models
+SET aggregate_id = (convert_from(payload, 'UTF8')::jsonb ->> 'id')::uuid
+WHERE aggregate_id IS NULL AND payload IS NOT NULL
+    AND convert_from(payload, 'UTF8')::jsonb ->> 'id' ~* '^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$';
+
+CREATE INDEX IF NOT EXISTS idx_event_models_aggregate_id ON event_models (aggregate_id);
+CREATE INDEX IF NOT EXISTS idx_event_models_created_at ON event_models (created_at);
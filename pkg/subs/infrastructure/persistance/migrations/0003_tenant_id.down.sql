@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_event_models_tenant_id;
+DROP INDEX IF EXISTS idx_subscriptions_tenant_id;
+ALTER TABLE event_models DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS tenant_id;
@@ -0,0 +1,6 @@
+-- Арендатор записей. DEFAULT заполняет существующие строки
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS tenant_id text NOT NULL DEFAULT 'default';
+ALTER TABLE event_models ADD COLUMN IF NOT EXISTS tenant_id text NOT NULL DEFAULT 'default';
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_tenant_id ON subscriptions (tenant_id);
+CREATE INDEX IF NOT EXISTS idx_event_models_tenant_id ON event_models (tenant_id);
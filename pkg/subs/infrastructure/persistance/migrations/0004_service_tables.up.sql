@@ -0,0 +1,50 @@
+-- Служебные таблицы: токены ленты, ключи идемпотентности, ключи API и ведра rate limit
+
+CREATE TABLE IF NOT EXISTS feed_tokens (
+    tenant_id text DEFAULT 'default',
+    user_id uuid,
+    token_hash text NOT NULL,
+    created_at timestamptz NOT NULL,
+    PRIMARY KEY (tenant_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    key text,
+    tenant_id text NOT NULL DEFAULT 'default',
+    request_hash text NOT NULL,
+    completed boolean NOT NULL DEFAULT false,
+    status_code bigint NOT NULL DEFAULT 0,
+    content_type text,
+    body bytea,
+    created_at timestamptz,
+    expires_at timestamptz NOT NULL,
+    PRIMARY KEY (key)
+);
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_tenant_id ON idempotency_keys (tenant_id);
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id uuid,
+    tenant_id text NOT NULL DEFAULT 'default',
+    name text NOT NULL,
+    owner text NOT NULL,
+    scopes text NOT NULL,
+    hash text NOT NULL,
+    hint text NOT NULL,
+    created_at timestamptz,
+    expires_at timestamptz,
+    last_used_at timestamptz,
+    revoked_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_api_keys_tenant_id ON api_keys (tenant_id);
+CREATE INDEX IF NOT EXISTS idx_api_keys_owner ON api_keys (owner);
+CREATE INDEX IF NOT EXISTS idx_api_keys_expires_at ON api_keys (expires_at);
+
+-- ведра rate limit, общие для реплик
+CREATE TABLE IF NOT EXISTS rate_limits (
+    key text,
+    tat timestamptz NOT NULL,
+    PRIMARY KEY (key)
+);
+CREATE INDEX IF NOT EXISTS idx_rate_limits_tat ON rate_limits (tat);
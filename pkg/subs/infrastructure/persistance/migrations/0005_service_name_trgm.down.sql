@@ -0,0 +1,2 @@
+-- расширение остается: им могут пользоваться другие схемы базы
+DROP INDEX IF EXISTS idx_subscriptions_service_name_trgm;
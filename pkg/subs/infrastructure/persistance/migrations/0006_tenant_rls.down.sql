@@ -0,0 +1,13 @@
+-- роль app_tenant остается: она общая для баз кластера
+DROP POLICY IF EXISTS tenant_isolation ON api_keys;
+ALTER TABLE api_keys DISABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON idempotency_keys;
+ALTER TABLE idempotency_keys DISABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON feed_tokens;
+ALTER TABLE feed_tokens DISABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON event_models;
+ALTER TABLE event_models DISABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON subscriptions;
+ALTER TABLE subscriptions DISABLE ROW LEVEL SECURITY;
+
+REVOKE SELECT, INSERT, UPDATE, DELETE ON subscriptions, event_models, feed_tokens, idempotency_keys, api_keys FROM app_tenant;
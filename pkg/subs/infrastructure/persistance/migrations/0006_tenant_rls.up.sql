@@ -0,0 +1,40 @@
+-- изоляция тенантов на уровне бд, как persistance.EnableTenantRLS:
+-- роль app_tenant видит и пишет только строки с tenant_id из app.tenant_id
+DO $$ BEGIN
+    IF NOT EXISTS (SELECT FROM pg_roles WHERE rolname = 'app_tenant') THEN
+        CREATE ROLE app_tenant NOLOGIN;
+    END IF;
+END $$;
+GRANT app_tenant TO CURRENT_USER;
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON subscriptions, event_models, feed_tokens, idempotency_keys, api_keys TO app_tenant;
+
+ALTER TABLE subscriptions ENABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON subscriptions;
+CREATE POLICY tenant_isolation ON subscriptions TO app_tenant
+    USING (tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
+
+ALTER TABLE event_models ENABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON event_models;
+CREATE POLICY tenant_isolation ON event_models TO app_tenant
+    USING (tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
+
+ALTER TABLE feed_tokens ENABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON feed_tokens;
+CREATE POLICY tenant_isolation ON feed_tokens TO app_tenant
+    USING (tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
+
+ALTER TABLE idempotency_keys ENABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON idempotency_keys;
+CREATE POLICY tenant_isolation ON idempotency_keys TO app_tenant
+    USING (tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
+
+ALTER TABLE api_keys ENABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON api_keys;
+CREATE POLICY tenant_isolation ON api_keys TO app_tenant
+    USING (tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
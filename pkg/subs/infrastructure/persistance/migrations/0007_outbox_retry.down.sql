@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_event_models_next_attempt_at;
+ALTER TABLE event_models DROP COLUMN IF EXISTS next_attempt_at;
+ALTER TABLE event_models DROP COLUMN IF EXISTS last_error;
+ALTER TABLE event_models DROP COLUMN IF EXISTS attempts;
@@ -0,0 +1,5 @@
+-- попытки публикации события: воркер откладывает повтор после ошибки, subsctl outbox requeue сбрасывает отсрочку
+ALTER TABLE event_models ADD COLUMN IF NOT EXISTS attempts bigint NOT NULL DEFAULT 0;
+ALTER TABLE event_models ADD COLUMN IF NOT EXISTS last_error text;
+ALTER TABLE event_models ADD COLUMN IF NOT EXISTS next_attempt_at timestamptz;
+CREATE INDEX IF NOT EXISTS idx_event_models_next_attempt_at ON event_models (next_attempt_at);
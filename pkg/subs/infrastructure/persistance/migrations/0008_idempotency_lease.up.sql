@@ -0,0 +1,2 @@
+-- ключ занят запросом до locked_until: ключ упавшего запроса забирает повтор, а не ждет конца ttl
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS locked_until timestamptz NOT NULL DEFAULT now();
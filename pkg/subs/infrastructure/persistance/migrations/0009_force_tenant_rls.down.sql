@@ -0,0 +1,10 @@
+DROP POLICY IF EXISTS cross_tenant ON api_keys;
+ALTER TABLE api_keys NO FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS cross_tenant ON idempotency_keys;
+ALTER TABLE idempotency_keys NO FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS cross_tenant ON feed_tokens;
+ALTER TABLE feed_tokens NO FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS cross_tenant ON event_models;
+ALTER TABLE event_models NO FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS cross_tenant ON subscriptions;
+ALTER TABLE subscriptions NO FORCE ROW LEVEL SECURITY;
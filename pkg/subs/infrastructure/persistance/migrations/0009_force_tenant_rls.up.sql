@@ -0,0 +1,32 @@
+-- RLS принудительная, как persistance.EnableTenantRLS: владелец таблиц тоже не видит строк.
+-- Строки всех тенантов видны только транзакциям с app.cross_tenant = on (persistance.CrossTenant)
+
+ALTER TABLE subscriptions FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS cross_tenant ON subscriptions;
+CREATE POLICY cross_tenant ON subscriptions
+    USING (current_user <> 'app_tenant' AND current_setting('app.cross_tenant', true) = 'on')
+    WITH CHECK (current_user <> 'app_tenant' AND current_setting('app.cross_tenant', true) = 'on');
+
+ALTER TABLE event_models FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS cross_tenant ON event_models;
+CREATE POLICY cross_tenant ON event_models
+    USING (current_user <> 'app_tenant' AND current_setting('app.cross_tenant', true) = 'on')
+    WITH CHECK (current_user <> 'app_tenant' AND current_setting('app.cross_tenant', true) = 'on');
+
+ALTER TABLE feed_tokens FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS cross_tenant ON feed_tokens;
+CREATE POLICY cross_tenant ON feed_tokens
+    USING (current_user <> 'app_tenant' AND current_setting('app.cross_tenant', true) = 'on')
+    WITH CHECK (current_user <> 'app_tenant' AND current_setting('app.cross_tenant', true) = 'on');
+
+ALTER TABLE idempotency_keys FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS cross_tenant ON idempotency_keys;
+CREATE POLICY cross_tenant ON idempotency_keys
+    USING (current_user <> 'app_tenant' AND current_setting('app.cross_tenant', true) = 'on')
+    WITH CHECK (current_user <> 'app_tenant' AND current_setting('app.cross_tenant', true) = 'on');
+
+ALTER TABLE api_keys FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS cross_tenant ON api_keys;
+CREATE POLICY cross_tenant ON api_keys
+    USING (current_user <> 'app_tenant' AND current_setting('app.cross_tenant', true) = 'on')
+    WITH CHECK (current_user <> 'app_tenant' AND current_setting('app.cross_tenant', true) = 'on');
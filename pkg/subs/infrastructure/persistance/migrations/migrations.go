@@ -0,0 +1,17 @@
+// Package migrations версии схемы сервиса подписок. Файлы встраиваются в бинарник
+// и применяются migrate.Migrator: при старте (postgres.auto_migrate) или командой subs migrate
+package migrations
+
+import (
+	"embed"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/persistance/migrate"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// Load миграции сервиса по возрастанию версии
+func Load() ([]migrate.Migration, error) {
+	return migrate.Load(files)
+}
@@ -0,0 +1,20 @@
+package migrations
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	list, err := Load()
+	require.NoError(t, err)
+
+	// версии идут подряд - новая миграция получает следующий номер
+	for i, m := range list {
+		require.Equal(t, int64(i+1), m.Version, m.Name)
+		require.NotEmpty(t, m.Up, m.Name)
+		require.NotEmpty(t, m.Down, m.Name)
+	}
+	require.Equal(t, "init", list[0].Name)
+}
@@ -0,0 +1,234 @@
+//go:build integration
+// +build integration
+
+package migrations
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/persistance/apikeys"
+	"github.com/end1essrage/efmob-tz/pkg/common/persistance/idempotency"
+	"github.com/end1essrage/efmob-tz/pkg/common/persistance/migrate"
+	"github.com/end1essrage/efmob-tz/pkg/common/persistance/ratelimit"
+	common_test "github.com/end1essrage/efmob-tz/pkg/common/testing"
+	"github.com/end1essrage/efmob-tz/pkg/subs/infrastructure/persistance/subs"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func open(t *testing.T, dsn string) *gorm.DB {
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	return db
+}
+
+func newMigrator(t *testing.T, db *gorm.DB) *migrate.Migrator {
+	migrations, err := Load()
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	return migrate.New(sqlDB, migrations)
+}
+
+// schema описание схемы public без учета порядка колонок и таблицы миграций
+func schema(t *testing.T, db *gorm.DB) map[string][]string {
+	queries := map[string]string{
+		"columns": `SELECT concat_ws(' ', table_name, column_name, data_type, is_nullable, column_default)
+			FROM information_schema.columns
+			WHERE table_schema = 'public' AND table_name <> 'schema_migrations'`,
+		"indexes": `SELECT indexdef FROM pg_indexes
+			WHERE schemaname = 'public' AND tablename <> 'schema_migrations'`,
+		"rls": `SELECT concat_ws(' ', relname, relrowsecurity, relforcerowsecurity) FROM pg_class
+			WHERE relkind = 'r' AND relnamespace = 'public'::regnamespace AND relname <> 'schema_migrations'`,
+		"policies": `SELECT concat_ws(' ', tablename, policyname, roles::text, cmd, qual, with_check)
+			FROM pg_policies WHERE schemaname = 'public'`,
+		"grants": `SELECT concat_ws(' ', table_name, privilege_type)
+			FROM information_schema.role_table_grants WHERE grantee = 'app_tenant'`,
+	}
+
+	out := make(map[string][]string)
+	for name, q := range queries {
+		var rows []string
+		require.NoError(t, db.Raw(q+" ORDER BY 1").Scan(&rows).Error, name)
+		out[name] = rows
+	}
+	return out
+}
+
+func TestMigrations_MatchModels(t *testing.T) {
+	ctx := context.Background()
+	container, dsn, err := common_test.SetupPostgresContainer(ctx)
+	if err != nil {
+		t.Fatalf("failed to start postgres container: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	db := open(t, dsn)
+
+	// эталон - схема из GORM моделей в соседней базе
+	require.NoError(t, db.Exec("CREATE DATABASE models").Error)
+	models := open(t, strings.Replace(dsn, "dbname=testdb", "dbname=models", 1))
+	require.NoError(t, subs.NewGormSubscriptionRepo(models).Migrate())
+	require.NoError(t, idempotency.NewGormStore(models).Migrate())
+	require.NoError(t, apikeys.NewGormStore(models).Migrate())
+	require.NoError(t, ratelimit.NewGormStore(models).Migrate())
+	want := schema(t, models)
+	require.NotEmpty(t, want["columns"])
+
+	m := newMigrator(t, db)
+	require.NoError(t, m.Up(ctx))
+	require.Equal(t, want, schema(t, db))
+
+	statuses, err := m.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, 9)
+	for _, s := range statuses {
+		require.NotNil(t, s.AppliedAt, s.Name)
+	}
+
+	// повторный up ничего не делает
+	require.NoError(t, m.Up(ctx))
+
+	// down откатывает по одной, до нуля схема пустая
+	require.NoError(t, m.Down(ctx))
+	statuses, err = m.Status(ctx)
+	require.NoError(t, err)
+	require.Nil(t, statuses[8].AppliedAt)
+	require.NotNil(t, statuses[7].AppliedAt)
+
+	require.NoError(t, m.To(ctx, 0))
+	var tables []string
+	require.NoError(t, db.Raw(`SELECT tablename FROM pg_tables WHERE schemaname = 'public'`).Scan(&tables).Error)
+	require.Equal(t, []string{"schema_migrations"}, tables)
+
+	// up после полного отката дает ту же схему
+	require.NoError(t, m.To(ctx, 2))
+	require.NoError(t, m.Up(ctx))
+	require.Equal(t, want, schema(t, db))
+
+	// миграции ложатся поверх базы, созданной AutoMigrate
+	require.NoError(t, newMigrator(t, models).Up(ctx))
+	require.Equal(t, want, schema(t, models))
+}
+
+// baselineSubscription и baselineEvent - модели исходной версии сервиса, до миграций
+type baselineSubscription struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primaryKey"`
+	UserID      uuid.UUID  `gorm:"type:uuid;not null;index"`
+	ServiceName string     `gorm:"type:text;not null;index"`
+	Price       int        `gorm:"not null"`
+	StartDate   time.Time  `gorm:"not null;index"`
+	EndDate     *time.Time `gorm:"index"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime"`
+
+	Version int `gorm:"not null;default:1"`
+}
+
+func (baselineSubscription) TableName() string {
+	return "subscriptions"
+}
+
+type baselineEvent struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Type      string    `gorm:"type:text;not null"`
+	Payload   []byte
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+func (baselineEvent) TableName() string {
+	return "event_models"
+}
+
+func TestMigrations_FromBaseline(t *testing.T) {
+	ctx := context.Background()
+	container, dsn, err := common_test.SetupPostgresContainer(ctx)
+	if err != nil {
+		t.Fatalf("failed to start postgres container: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	db := open(t, dsn)
+
+	require.NoError(t, db.Exec("CREATE DATABASE models").Error)
+	models := open(t, strings.Replace(dsn, "dbname=testdb", "dbname=models", 1))
+	require.NoError(t, subs.NewGormSubscriptionRepo(models).Migrate())
+	require.NoError(t, idempotency.NewGormStore(models).Migrate())
+	require.NoError(t, apikeys.NewGormStore(models).Migrate())
+	require.NoError(t, ratelimit.NewGormStore(models).Migrate())
+	want := schema(t, models)
+
+	// база развернута исходной версией и уже содержит данные
+	require.NoError(t, db.AutoMigrate(&baselineSubscription{}, &baselineEvent{}))
+	sub := baselineSubscription{
+		ID:          uuid.New(),
+		UserID:      uuid.New(),
+		ServiceName: "Yandex Plus",
+		Price:       400,
+		StartDate:   time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC),
+	}
+	require.NoError(t, db.Create(&sub).Error)
+	event := baselineEvent{
+		ID:      uuid.New(),
+		Type:    "subscription.created",
+		Payload: []byte(`{"id":"` + sub.ID.String() + `","price":400}`),
+	}
+	require.NoError(t, db.Create(&event).Error)
+
+	m := newMigrator(t, db)
+	require.NoError(t, m.Up(ctx))
+	require.Equal(t, want, schema(t, db))
+
+	// новые колонки заполнены для старых строк
+	var tenant string
+	require.NoError(t, db.Raw("SELECT tenant_id FROM subscriptions WHERE id = ?", sub.ID).Scan(&tenant).Error)
+	require.Equal(t, "default", tenant)
+
+	var got struct {
+		TenantID    string
+		AggregateID uuid.UUID
+	}
+	require.NoError(t, db.Raw("SELECT tenant_id, aggregate_id FROM event_models WHERE id = ?", event.ID).Scan(&got).Error)
+	require.Equal(t, "default", got.TenantID)
+	require.Equal(t, sub.ID, got.AggregateID)
+}
+
+func TestMigrations_ConcurrentUp(t *testing.T) {
+	ctx := context.Background()
+	container, dsn, err := common_test.SetupPostgresContainer(ctx)
+	if err != nil {
+		t.Fatalf("failed to start postgres container: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	// реплики стартуют одновременно, каждая со своим пулом
+	migrators := make([]*migrate.Migrator, 5)
+	for i := range migrators {
+		migrators[i] = newMigrator(t, open(t, dsn))
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(migrators))
+	for _, m := range migrators {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- m.Up(ctx)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	var applied int64
+	require.NoError(t, open(t, dsn).Raw("SELECT count(*) FROM schema_migrations").Scan(&applied).Error)
+	require.Equal(t, int64(9), applied)
+}
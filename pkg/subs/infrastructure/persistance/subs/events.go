@@ -2,20 +2,40 @@ package subs
 
 import (
 	"context"
+	"fmt"
+	"hash/fnv"
+	"sync"
 	"time"
 
+	"github.com/end1essrage/efmob-tz/pkg/common/health"
 	"github.com/end1essrage/efmob-tz/pkg/common/logger"
+	p "github.com/end1essrage/efmob-tz/pkg/common/persistance"
+	"github.com/end1essrage/efmob-tz/pkg/common/tenant"
+	"github.com/end1essrage/efmob-tz/pkg/common/tracing"
 	"github.com/end1essrage/efmob-tz/pkg/subs/application"
 	domain "github.com/end1essrage/efmob-tz/pkg/subs/domain"
+	subsMetrics "github.com/end1essrage/efmob-tz/pkg/subs/metrics"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/gorm"
 )
 
 type EventModel struct {
-	ID        uuid.UUID `gorm:"type:uuid;primaryKey"`
-	Type      string    `gorm:"type:text;not null"`
-	Payload   []byte
-	CreatedAt time.Time `gorm:"autoCreateTime"`
+	ID          uuid.UUID `gorm:"type:uuid;primaryKey"`
+	TenantID    string    `gorm:"type:text;not null;default:'default';index"`
+	AggregateID uuid.UUID `gorm:"type:uuid;index"`
+	Type        string    `gorm:"type:text;not null"`
+	Payload     []byte
+	// Traceparent W3C контекст трассы запроса, породившего событие
+	Traceparent string    `gorm:"type:text"`
+	CreatedAt   time.Time `gorm:"autoCreateTime;index"`
+	// Attempts неудачных публикаций, LastError - ошибка последней
+	Attempts  int    `gorm:"not null;default:0"`
+	LastError string `gorm:"type:text"`
+	// NextAttemptAt до этого времени событие и следующие события агрегата не публикуются
+	NextAttemptAt *time.Time `gorm:"index"`
 }
 
 func (r *GormSubscriptionRepo) CreateEvent(ctx context.Context, event domain.Event) error {
@@ -25,34 +45,48 @@ func (r *GormSubscriptionRepo) CreateEvent(ctx context.Context, event domain.Eve
 	}
 
 	model := EventModel{
-		ID:        uuid.New(),
-		Type:      event.Type(),
-		Payload:   payload,
-		CreatedAt: time.Now(),
+		ID:          uuid.New(),
+		TenantID:    tenant.Current(ctx),
+		AggregateID: event.AggregateID(),
+		Type:        event.Type(),
+		Payload:     payload,
+		Traceparent: tracing.Traceparent(ctx),
+		CreatedAt:   time.Now(),
 	}
 
-	if err := r.db.WithContext(ctx).Create(&model).Error; err != nil {
-		return err
-	}
-
-	return nil
+	return r.scoped(ctx, func(db *gorm.DB) error {
+		return db.Create(&model).Error
+	})
 }
 
-// EventWorker читает события из базы и публикует их
+// maxRetryDelay предел отсрочки повтора неудачной публикации
+const maxRetryDelay = 10 * time.Minute
+
+// EventWorker читает события из базы и публикует их.
+// События раскладываются по воркерам по AggregateID,
+// поэтому события одной подписки публикуются строго по порядку
 type EventWorker struct {
 	db        *gorm.DB
 	publisher application.EventPublisher
 	interval  time.Duration
 	batchSize int
+	workers   int
+
+	heartbeat health.Heartbeat
 }
 
 // NewEventWorker создаёт нового воркера
-func NewEventWorker(db *gorm.DB, publisher application.EventPublisher, interval time.Duration, batchSize int) *EventWorker {
+func NewEventWorker(db *gorm.DB, publisher application.EventPublisher, interval time.Duration, batchSize int, workers int) *EventWorker {
+	if workers <= 0 {
+		workers = 1
+	}
+
 	return &EventWorker{
 		db:        db,
 		publisher: publisher,
 		interval:  interval,
 		batchSize: batchSize,
+		workers:   workers,
 	}
 }
 
@@ -64,10 +98,12 @@ func (w *EventWorker) Run(ctx context.Context) {
 		Ctx:  ctx,
 	})
 
-	log.Infof("starting event worker, interval=%s, batchSize=%d", w.interval, w.batchSize)
+	log.Infof("starting event worker, interval=%s, batchSize=%d, workers=%d", w.interval, w.batchSize, w.workers)
 	ticker := time.NewTicker(w.interval)
 	defer ticker.Stop()
 
+	w.heartbeat.Beat()
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -77,39 +113,202 @@ func (w *EventWorker) Run(ctx context.Context) {
 			if err := w.processBatch(ctx); err != nil {
 				log.Errorf("failed to process event batch: %v", err)
 			}
+			// цикл не завис - недоступная база видна по своей проверке
+			w.heartbeat.Beat()
 		}
 	}
 }
 
-// processBatch читает и публикует события
-func (w *EventWorker) processBatch(ctx context.Context) error {
-	log := logger.Logger().WithFields(logger.LogOptions{
-		Pkg:  "EventWorker",
-		Func: "processBatch",
-		Ctx:  ctx,
-	})
+// Heartbeat отметка последнего прохода цикла воркера
+func (w *EventWorker) Heartbeat() *health.Heartbeat {
+	return &w.heartbeat
+}
 
+// OutboxLagCheck проверка, что готовое к публикации событие ждет воркер не дольше threshold.
+// Отложенные после ошибки события и события за ними не учитываются - ядовитое событие не снимает реплики
+// с балансировки, его видно по efmob_outbox_oldest_event_age_seconds.
+// События агрегата после повтора ждут с наступления его срока, а не с создания
+func OutboxLagCheck(db *gorm.DB, threshold time.Duration) health.Check {
+	return func(ctx context.Context) error {
+		now := time.Now()
+		var oldest *time.Time
+		err := p.CrossTenant(ctx, db, func(tx *gorm.DB) error {
+			return eligibleEvents(tx, now).
+				Select(`MIN(GREATEST(created_at, (SELECT MAX(due.next_attempt_at) FROM event_models AS due
+					WHERE due.aggregate_id = event_models.aggregate_id AND due.next_attempt_at <= ?)))`, now).
+				Scan(&oldest).Error
+		})
+		if err != nil {
+			return err
+		}
+		if oldest == nil {
+			return nil
+		}
+		if lag := now.Sub(*oldest); lag > threshold {
+			return fmt.Errorf("oldest pending event waits %s, threshold %s", lag.Truncate(time.Second), threshold)
+		}
+		return nil
+	}
+}
+
+// eligibleEvents события, готовые к публикации: срок повтора наступил
+// и у агрегата нет отложенного события, иначе нарушится порядок
+func eligibleEvents(db *gorm.DB, now time.Time) *gorm.DB {
+	return db.Model(&EventModel{}).
+		Where("next_attempt_at IS NULL OR next_attempt_at <= ?", now).
+		Where("NOT EXISTS (?)", db.Session(&gorm.Session{NewDB: true}).Table("event_models AS deferred").Select("1").
+			Where("deferred.aggregate_id = event_models.aggregate_id AND deferred.next_attempt_at > ?", now))
+}
+
+// processBatch читает и публикует события всех тенантов в транзакциях CrossTenant.
+// Публикация идет вне транзакции, каждое событие удаляется или откладывается своей.
+// Агрегаты с отложенным после ошибки событием пропускаются целиком, иначе нарушится порядок
+func (w *EventWorker) processBatch(ctx context.Context) error {
 	var events []EventModel
 
-	tx := w.db.WithContext(ctx).Limit(w.batchSize).Order("created_at ASC").Find(&events)
-	if tx.Error != nil {
-		return tx.Error
+	err := p.CrossTenant(ctx, w.db, func(tx *gorm.DB) error {
+		return eligibleEvents(tx, time.Now()).
+			Limit(w.batchSize).Order("created_at ASC").Find(&events).Error
+	})
+	if err != nil {
+		return err
 	}
 
 	if len(events) == 0 {
 		return nil
 	}
 
+	w.publishEvents(ctx, events, w.deleteEvent, w.deferEvent)
+
+	return nil
+}
+
+// publishEvents публикует события пулом воркеров.
+// ack вызывается после успешной публикации события, nack - после неудачной
+func (w *EventWorker) publishEvents(ctx context.Context, events []EventModel, ack func(ctx context.Context, id uuid.UUID) error, nack func(ctx context.Context, ev EventModel, err error) error) {
+	partitions := partitionEvents(events, w.workers)
+
+	var wg sync.WaitGroup
+	for _, part := range partitions {
+		if len(part) == 0 {
+			continue
+		}
+
+		wg.Add(1)
+		go func(part []EventModel) {
+			defer wg.Done()
+			w.publishPartition(ctx, part, ack, nack)
+		}(part)
+	}
+	wg.Wait()
+}
+
+// publishPartition последовательно публикует события одной партиции
+func (w *EventWorker) publishPartition(ctx context.Context, events []EventModel, ack func(ctx context.Context, id uuid.UUID) error, nack func(ctx context.Context, ev EventModel, err error) error) {
+	log := logger.Logger().WithFields(logger.LogOptions{
+		Pkg:  "EventWorker",
+		Func: "publishPartition",
+		Ctx:  ctx,
+	})
+
+	// агрегаты, у которых публикация упала - их события дальше не публикуем,
+	// иначе нарушится порядок. Они будут опубликованы в следующем батче
+	failed := make(map[uuid.UUID]struct{})
+
 	for _, ev := range events {
-		if err := w.publisher.Publish(ctx, ev.Type, ev.Payload); err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+
+		if _, ok := failed[ev.AggregateID]; ok {
+			continue
+		}
+
+		if err := w.publish(ctx, ev); err != nil {
 			log.Errorf("failed to publish event %s: %v", ev.ID, err)
+			failed[ev.AggregateID] = struct{}{}
+			if err := nack(ctx, ev, err); err != nil {
+				log.Errorf("failed to defer event %s: %v", ev.ID, err)
+			}
 			continue
 		}
 
-		if err := w.db.WithContext(ctx).Delete(&EventModel{}, "id = ?", ev.ID).Error; err != nil {
+		if err := ack(ctx, ev.ID); err != nil {
 			log.Errorf("failed to delete published event %s: %v", ev.ID, err)
+			// событие опубликуется повторно, следующие придержим до того же момента
+			failed[ev.AggregateID] = struct{}{}
 		}
 	}
+}
 
+// publish публикует событие в спане producer, дочернем к спану запроса из traceparent события.
+// Тенант и контекст трассы доступны публикатору через ctx, traceparent уходит брокеру заголовком
+func (w *EventWorker) publish(ctx context.Context, ev EventModel) error {
+	ctx, span := tracing.Tracer().Start(tracing.WithTraceparent(ctx, ev.Traceparent), "publish "+ev.Type,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingOperationTypeSend,
+			semconv.MessagingDestinationName(ev.Type),
+			semconv.MessagingMessageID(ev.ID.String()),
+		),
+	)
+	defer span.End()
+
+	start := time.Now()
+	if err := w.publisher.Publish(tenant.WithTenant(ctx, ev.TenantID), ev.Type, ev.Payload); err != nil {
+		subsMetrics.OutboxPublishDuration.WithLabelValues(ev.Type, "error").Observe(time.Since(start).Seconds())
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	subsMetrics.OutboxPublishDuration.WithLabelValues(ev.Type, "ok").Observe(time.Since(start).Seconds())
 	return nil
 }
+
+func (w *EventWorker) deleteEvent(ctx context.Context, id uuid.UUID) error {
+	return p.CrossTenant(ctx, w.db, func(tx *gorm.DB) error {
+		return tx.Delete(&EventModel{}, "id = ?", id).Error
+	})
+}
+
+// deferEvent запоминает ошибку и откладывает повтор с экспоненциальной задержкой
+func (w *EventWorker) deferEvent(ctx context.Context, ev EventModel, err error) error {
+	next := time.Now().Add(retryDelay(w.interval, ev.Attempts+1))
+	return p.CrossTenant(ctx, w.db, func(tx *gorm.DB) error {
+		return tx.Model(&EventModel{}).Where("id = ?", ev.ID).Updates(map[string]any{
+			"attempts":        gorm.Expr("attempts + 1"),
+			"last_error":      err.Error(),
+			"next_attempt_at": next,
+		}).Error
+	})
+}
+
+// retryDelay отсрочка после attempts неудачных публикаций: interval, 2*interval, 4*interval... до maxRetryDelay
+func retryDelay(interval time.Duration, attempts int) time.Duration {
+	delay := interval
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryDelay)
+}
+
+// partitionEvents раскладывает события по партициям по AggregateID,
+// сохраняя исходный порядок внутри каждой партиции
+func partitionEvents(events []EventModel, n int) [][]EventModel {
+	partitions := make([][]EventModel, n)
+	for _, ev := range events {
+		i := partitionIndex(ev.AggregateID, n)
+		partitions[i] = append(partitions[i], ev)
+	}
+	return partitions
+}
+
+func partitionIndex(id uuid.UUID, n int) int {
+	if n <= 1 {
+		return 0
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write(id[:])
+	return int(h.Sum32() % uint32(n))
+}
@@ -0,0 +1,234 @@
+package subs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/tracing"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordingPublisher запоминает порядок публикации по агрегатам
+type recordingPublisher struct {
+	mu          sync.Mutex
+	delay       time.Duration
+	failOn      map[string]bool
+	byAggregate map[uuid.UUID][]string
+	payload     map[string]uuid.UUID
+}
+
+func newRecordingPublisher(delay time.Duration) *recordingPublisher {
+	return &recordingPublisher{
+		delay:       delay,
+		failOn:      map[string]bool{},
+		byAggregate: map[uuid.UUID][]string{},
+		payload:     map[string]uuid.UUID{},
+	}
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, topic string, payload []byte) error {
+	if p.delay > 0 {
+		time.Sleep(p.delay)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.failOn[string(payload)] {
+		return errors.New("broker unavailable")
+	}
+
+	agg := p.payload[string(payload)]
+	p.byAggregate[agg] = append(p.byAggregate[agg], string(payload))
+	return nil
+}
+
+func makeEvents(p *recordingPublisher, aggregates, perAggregate int) []EventModel {
+	ids := make([]uuid.UUID, aggregates)
+	for i := range ids {
+		ids[i] = uuid.New()
+	}
+
+	// события разных агрегатов перемешаны, как в реальном outbox
+	events := make([]EventModel, 0, aggregates*perAggregate)
+	for seq := 0; seq < perAggregate; seq++ {
+		for _, id := range ids {
+			payload := fmt.Sprintf("%s-%d", id, seq)
+			p.payload[payload] = id
+			events = append(events, EventModel{
+				ID:          uuid.New(),
+				AggregateID: id,
+				Type:        "subscription_updated",
+				Payload:     []byte(payload),
+			})
+		}
+	}
+	return events
+}
+
+func noopAck(context.Context, uuid.UUID) error { return nil }
+
+func noopNack(context.Context, EventModel, error) error { return nil }
+
+func TestEventWorker_PreservesAggregateOrder(t *testing.T) {
+	pub := newRecordingPublisher(0)
+	events := makeEvents(pub, 20, 10)
+
+	w := NewEventWorker(nil, pub, time.Second, len(events), 8)
+	w.publishEvents(context.Background(), events, noopAck, noopNack)
+
+	require.Len(t, pub.byAggregate, 20)
+	for id, got := range pub.byAggregate {
+		require.Len(t, got, 10)
+		for seq, payload := range got {
+			require.Equal(t, fmt.Sprintf("%s-%d", id, seq), payload)
+		}
+	}
+}
+
+func TestEventWorker_FailedPublishHoldsBackAggregate(t *testing.T) {
+	pub := newRecordingPublisher(0)
+	events := makeEvents(pub, 3, 3)
+
+	// второе событие первого агрегата не публикуется
+	broken := events[3]
+	pub.failOn[string(broken.Payload)] = true
+
+	var mu sync.Mutex
+	acked := map[uuid.UUID]bool{}
+	ack := func(_ context.Context, id uuid.UUID) error {
+		mu.Lock()
+		defer mu.Unlock()
+		acked[id] = true
+		return nil
+	}
+
+	nacked := map[uuid.UUID]string{}
+	nack := func(_ context.Context, ev EventModel, err error) error {
+		mu.Lock()
+		defer mu.Unlock()
+		nacked[ev.ID] = err.Error()
+		return nil
+	}
+
+	w := NewEventWorker(nil, pub, time.Second, len(events), 4)
+	w.publishEvents(context.Background(), events, ack, nack)
+
+	// у сломанного агрегата опубликовано только первое событие
+	require.Equal(t, []string{string(events[0].Payload)}, pub.byAggregate[broken.AggregateID])
+	require.True(t, acked[events[0].ID])
+	require.False(t, acked[broken.ID])
+	require.False(t, acked[events[6].ID])
+
+	// ошибка запоминается только у сломанного события, следующие его агрегата просто ждут
+	require.Equal(t, map[uuid.UUID]string{broken.ID: "broker unavailable"}, nacked)
+
+	// остальные агрегаты не пострадали
+	for _, ev := range events {
+		if ev.AggregateID != broken.AggregateID {
+			require.True(t, acked[ev.ID])
+		}
+	}
+}
+
+// traceparentPublisher запоминает контекст трассы, с которым публикуется событие
+type traceparentPublisher struct {
+	mu   sync.Mutex
+	sent map[string]trace.SpanContext
+}
+
+func (p *traceparentPublisher) Publish(ctx context.Context, topic string, payload []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sent[string(payload)] = trace.SpanContextFromContext(ctx)
+	return nil
+}
+
+func TestEventWorker_PublishContinuesRequestTrace(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	// спан запроса, создавшего событие
+	reqCtx, reqSpan := tracing.Start(context.Background(), "CreateSubscriptionHandler.Handle")
+	reqSpan.End()
+
+	events := []EventModel{
+		{ID: uuid.New(), AggregateID: uuid.New(), Type: "subscription_created", Payload: []byte("traced"), Traceparent: tracing.Traceparent(reqCtx)},
+		{ID: uuid.New(), AggregateID: uuid.New(), Type: "subscription_created", Payload: []byte("legacy")},
+	}
+
+	pub := &traceparentPublisher{sent: map[string]trace.SpanContext{}}
+	w := NewEventWorker(nil, pub, time.Second, len(events), 1)
+	w.publishEvents(context.Background(), events, noopAck, noopNack)
+
+	var publish []sdktrace.ReadOnlySpan
+	for _, s := range rec.Ended() {
+		if s.Name() == "publish subscription_created" {
+			publish = append(publish, s)
+		}
+	}
+	require.Len(t, publish, 2)
+
+	traced, legacy := publish[0], publish[1]
+	require.Equal(t, trace.SpanKindProducer, traced.SpanKind())
+	require.Equal(t, reqSpan.SpanContext().TraceID(), traced.SpanContext().TraceID())
+	require.Equal(t, reqSpan.SpanContext().SpanID(), traced.Parent().SpanID())
+
+	// событие без traceparent публикуется в новой трассе
+	require.False(t, legacy.Parent().IsValid())
+
+	// публикатор получает спан producer - его traceparent уходит брокеру
+	require.Equal(t, traced.SpanContext().SpanID(), pub.sent["traced"].SpanID())
+}
+
+func TestRetryDelay(t *testing.T) {
+	require.Equal(t, 5*time.Second, retryDelay(5*time.Second, 1))
+	require.Equal(t, 10*time.Second, retryDelay(5*time.Second, 2))
+	require.Equal(t, 40*time.Second, retryDelay(5*time.Second, 4))
+	require.Equal(t, maxRetryDelay, retryDelay(5*time.Second, 20))
+	require.Equal(t, maxRetryDelay, retryDelay(5*time.Second, 1000))
+}
+
+func TestPartitionEvents_SameAggregateSamePartition(t *testing.T) {
+	id := uuid.New()
+	events := []EventModel{
+		{ID: uuid.New(), AggregateID: id},
+		{ID: uuid.New(), AggregateID: uuid.New()},
+		{ID: uuid.New(), AggregateID: id},
+	}
+
+	partitions := partitionEvents(events, 4)
+
+	idx := partitionIndex(id, 4)
+	require.Equal(t, events[0].ID, partitions[idx][0].ID)
+	require.Equal(t, events[2].ID, partitions[idx][len(partitions[idx])-1].ID)
+}
+
+// BenchmarkEventWorker_Publish сравнивает последовательную публикацию (workers=1)
+// с пулом воркеров при медленном брокере
+func BenchmarkEventWorker_Publish(b *testing.B) {
+	for _, workers := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			pub := newRecordingPublisher(200 * time.Microsecond)
+			events := makeEvents(pub, 50, 2)
+			w := NewEventWorker(nil, pub, time.Second, len(events), workers)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				w.publishEvents(context.Background(), events, noopAck, noopNack)
+			}
+			b.ReportMetric(float64(len(events)*b.N)/b.Elapsed().Seconds(), "events/s")
+		})
+	}
+}
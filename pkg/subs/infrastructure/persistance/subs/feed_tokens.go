@@ -0,0 +1,62 @@
+package subs
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/tenant"
+	domain "github.com/end1essrage/efmob-tz/pkg/subs/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FeedTokenModel хэш токена календарного фида пользователя.
+// Один user_id может встречаться в разных тенантах - ключ по паре
+type FeedTokenModel struct {
+	TenantID  string    `gorm:"type:text;primaryKey;default:'default'"`
+	UserID    uuid.UUID `gorm:"type:uuid;primaryKey"`
+	TokenHash string    `gorm:"type:text;not null"`
+	CreatedAt time.Time `gorm:"not null"`
+}
+
+func (FeedTokenModel) TableName() string {
+	return "feed_tokens"
+}
+
+// SaveFeedToken сохраняет хэш токена, заменяя предыдущий
+func (r *GormSubscriptionRepo) SaveFeedToken(ctx context.Context, userID uuid.UUID, tokenHash string) error {
+	model := &FeedTokenModel{
+		UserID:    userID,
+		TenantID:  tenant.Current(ctx),
+		TokenHash: tokenHash,
+		CreatedAt: time.Now(),
+	}
+
+	return r.withRetry(ctx, func() error {
+		return r.scoped(ctx, func(db *gorm.DB) error {
+			return db.
+				Clauses(clause.OnConflict{
+					Columns:   []clause.Column{{Name: "tenant_id"}, {Name: "user_id"}},
+					DoUpdates: clause.AssignmentColumns([]string{"token_hash", "created_at"}),
+				}).
+				Create(model).Error
+		})
+	})
+}
+
+// GetFeedTokenHash возвращает хэш токена пользователя
+func (r *GormSubscriptionRepo) GetFeedTokenHash(ctx context.Context, userID uuid.UUID) (string, error) {
+	var m FeedTokenModel
+	err := r.scoped(ctx, func(db *gorm.DB) error {
+		return db.First(&m, "user_id = ?", userID).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", domain.ErrFeedTokenNotFound
+		}
+		return "", err
+	}
+	return m.TokenHash, nil
+}
@@ -0,0 +1,113 @@
+package subs
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/subs/domain"
+)
+
+// filterColumns выражения колонок для полей filter=, в SQL попадают только отсюда.
+// Пустой end_date сравнивается как бесконечность - так же, как при сортировке
+var filterColumns = map[domain.FilterField]string{
+	domain.FilterByUserID:      "user_id",
+	domain.FilterByServiceName: "service_name",
+	domain.FilterByPrice:       "price",
+	domain.FilterByStartDate:   "start_date",
+	domain.FilterByEndDate:     "COALESCE(end_date, 'infinity'::timestamptz)",
+	domain.FilterByCreatedAt:   "created_at",
+}
+
+var filterOperators = map[domain.FilterOperator]string{
+	domain.FilterEq:  "=",
+	domain.FilterNe:  "<>",
+	domain.FilterLt:  "<",
+	domain.FilterLe:  "<=",
+	domain.FilterGt:  ">",
+	domain.FilterGe:  ">=",
+	domain.FilterIn:  "IN",
+	domain.FilterOut: "NOT IN",
+}
+
+// filterExprSQL переводит выражение в условие WHERE, значения передаются только параметрами
+func filterExprSQL(e domain.FilterExpr) (string, []interface{}, error) {
+	switch v := e.(type) {
+	case domain.FilterGroup:
+		sep := " AND "
+		if v.Or {
+			sep = " OR "
+		}
+
+		conds := make([]string, len(v.Exprs))
+		var args []interface{}
+		for i, child := range v.Exprs {
+			cond, childArgs, err := filterExprSQL(child)
+			if err != nil {
+				return "", nil, err
+			}
+			conds[i] = cond
+			args = append(args, childArgs...)
+		}
+		return "(" + strings.Join(conds, sep) + ")", args, nil
+	case domain.FilterCondition:
+		return filterConditionSQL(v)
+	default:
+		return "", nil, fmt.Errorf("unknown filter expression %T", e)
+	}
+}
+
+func filterConditionSQL(c domain.FilterCondition) (string, []interface{}, error) {
+	column, ok := filterColumns[c.Field]
+	if !ok {
+		return "", nil, fmt.Errorf("unknown filter field %q", c.Field)
+	}
+
+	switch {
+	case c.Op == domain.FilterNull:
+		if c.Values[0].(bool) {
+			return "(end_date IS NULL)", nil, nil
+		}
+		return "(end_date IS NOT NULL)", nil, nil
+	case c.Wildcard:
+		pattern := strings.ReplaceAll(escapeLike(c.Values[0].(string)), "*", "%")
+		if c.Op == domain.FilterNe {
+			return "(" + column + " NOT LIKE ?)", []interface{}{pattern}, nil
+		}
+		return "(" + column + " LIKE ?)", []interface{}{pattern}, nil
+	case c.Field == domain.FilterByCreatedAt:
+		return createdAtDaySQL(c)
+	}
+
+	op, ok := filterOperators[c.Op]
+	if !ok {
+		return "", nil, fmt.Errorf("unknown filter operator %q", c.Op)
+	}
+	if c.Op == domain.FilterIn || c.Op == domain.FilterOut {
+		return "(" + column + " " + op + " ?)", []interface{}{c.Values}, nil
+	}
+	return "(" + column + " " + op + " ?)", []interface{}{c.Values[0]}, nil
+}
+
+// createdAtDaySQL created_at сравнивается с днём целиком: [day, day+1)
+func createdAtDaySQL(c domain.FilterCondition) (string, []interface{}, error) {
+	day := c.Values[0].(time.Time)
+	next := day.AddDate(0, 0, 1)
+
+	switch c.Op {
+	case domain.FilterEq:
+		return "(created_at >= ? AND created_at < ?)", []interface{}{day, next}, nil
+	case domain.FilterNe:
+		return "(created_at < ? OR created_at >= ?)", []interface{}{day, next}, nil
+	case domain.FilterLt:
+		return "(created_at < ?)", []interface{}{day}, nil
+	case domain.FilterLe:
+		return "(created_at < ?)", []interface{}{next}, nil
+	case domain.FilterGt:
+		return "(created_at >= ?)", []interface{}{next}, nil
+	case domain.FilterGe:
+		return "(created_at >= ?)", []interface{}{day}, nil
+	default:
+		return "", nil, fmt.Errorf("operator %q not supported for created_at", c.Op)
+	}
+}
@@ -0,0 +1,66 @@
+package subs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/rsql"
+	"github.com/end1essrage/efmob-tz/pkg/subs/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterExprSQL(t *testing.T) {
+	day := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		sql  string
+		args []interface{}
+	}{
+		{
+			expr: "price=gt=500;(service_name==Netflix,service_name==Spotify)",
+			sql:  "((price > ?) AND ((service_name = ?) OR (service_name = ?)))",
+			args: []interface{}{500, "Netflix", "Spotify"},
+		},
+		{
+			expr: "service_name!='50%*'",
+			sql:  "(service_name NOT LIKE ?)",
+			args: []interface{}{`50\%%`},
+		},
+		{
+			expr: "price=out=(1,2)",
+			sql:  "(price NOT IN ?)",
+			args: []interface{}{[]interface{}{1, 2}},
+		},
+		{
+			expr: "end_date=le=12-2025,end_date=null=false",
+			sql:  "((COALESCE(end_date, 'infinity'::timestamptz) <= ?) OR (end_date IS NOT NULL))",
+			args: []interface{}{time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			expr: "created_at=le=2025-03-10",
+			sql:  "(created_at < ?)",
+			args: []interface{}{day.AddDate(0, 0, 1)},
+		},
+		{
+			// значения не попадают в текст запроса
+			expr: `service_name=="x'); DROP TABLE subscriptions;--"`,
+			sql:  "(service_name = ?)",
+			args: []interface{}{"x'); DROP TABLE subscriptions;--"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			n, err := rsql.Parse(tt.expr)
+			require.NoError(t, err)
+			expr, err := domain.NewFilterExpr(n)
+			require.NoError(t, err)
+
+			sql, args, err := filterExprSQL(expr)
+			require.NoError(t, err)
+			require.Equal(t, tt.sql, sql)
+			require.Equal(t, tt.args, args)
+		})
+	}
+}
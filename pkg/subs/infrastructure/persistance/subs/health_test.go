@@ -0,0 +1,52 @@
+//go:build integration
+// +build integration
+
+package subs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	common_test "github.com/end1essrage/efmob-tz/pkg/common/testing"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestOutboxLagCheck(t *testing.T) {
+	ctx := context.Background()
+	container, dsn, err := common_test.SetupPostgresContainer(ctx)
+	if err != nil {
+		t.Fatalf("failed to start postgres container: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, NewGormSubscriptionRepo(db).Migrate())
+
+	check := OutboxLagCheck(db, time.Minute)
+
+	// пустой outbox
+	require.NoError(t, check(ctx))
+
+	require.NoError(t, db.Create(&EventModel{ID: uuid.New(), Type: "t", CreatedAt: time.Now()}).Error)
+	require.NoError(t, check(ctx))
+
+	// отложенное ядовитое событие и события его агрегата готовность не снимают
+	poisoned := uuid.New()
+	later := time.Now().Add(time.Hour)
+	require.NoError(t, db.Create(&EventModel{ID: uuid.New(), Type: "t", AggregateID: poisoned, CreatedAt: time.Now().Add(-time.Hour), Attempts: 5, NextAttemptAt: &later}).Error)
+	require.NoError(t, db.Create(&EventModel{ID: uuid.New(), Type: "t", AggregateID: poisoned, CreatedAt: time.Now().Add(-time.Hour)}).Error)
+	require.NoError(t, check(ctx))
+
+	// срок повтора наступил только что - ожидание считается с него
+	due := time.Now().Add(-time.Second)
+	require.NoError(t, db.Model(&EventModel{}).Where("next_attempt_at IS NOT NULL").Update("next_attempt_at", due).Error)
+	require.NoError(t, check(ctx))
+
+	require.NoError(t, db.Create(&EventModel{ID: uuid.New(), Type: "t", AggregateID: uuid.New(), CreatedAt: time.Now().Add(-2 * time.Minute)}).Error)
+	require.ErrorContains(t, check(ctx), "threshold 1m0s")
+}
@@ -0,0 +1,138 @@
+package subs
+
+import (
+	"context"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/logger"
+	p "github.com/end1essrage/efmob-tz/pkg/common/persistance"
+	subsMetrics "github.com/end1essrage/efmob-tz/pkg/subs/metrics"
+	"gorm.io/gorm"
+)
+
+// maxSpendServices сервисов с наибольшими тратами получают свою серию, остальные суммируются в other
+const maxSpendServices = 50
+
+// MetricsCollector периодически пересчитывает gauge метрики из базы.
+// Агрегаты считаются по таймеру, а не на каждый scrape, чтобы Prometheus не нагружал базу.
+// Считает по всем тенантам в транзакции CrossTenant, как EventWorker
+type MetricsCollector struct {
+	db       *gorm.DB
+	interval time.Duration
+	now      func() time.Time
+	// spendLabels серии MonthlySpend прошлого пересчета, пропавшие удаляются
+	spendLabels map[string]struct{}
+}
+
+func NewMetricsCollector(db *gorm.DB, interval time.Duration) *MetricsCollector {
+	return &MetricsCollector{
+		db:       db,
+		interval: interval,
+		now:      time.Now,
+	}
+}
+
+// Run пересчитывает метрики сразу и далее каждые interval до отмены ctx
+func (c *MetricsCollector) Run(ctx context.Context) {
+	log := logger.Logger().WithFields(logger.LogOptions{
+		Pkg:  "MetricsCollector",
+		Func: "Run",
+		Ctx:  ctx,
+	})
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.collect(ctx); err != nil && ctx.Err() == nil {
+			log.Errorf("failed to collect metrics: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+type serviceSpend struct {
+	ServiceName string
+	Active      int64
+	Spend       int64
+}
+
+type outboxStats struct {
+	Backlog int64
+	Oldest  *time.Time
+}
+
+func (c *MetricsCollector) collect(ctx context.Context) error {
+	now := c.now().UTC()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	var spends []serviceSpend
+	var stats outboxStats
+	err := p.CrossTenant(ctx, c.db, func(tx *gorm.DB) error {
+		// подписка активна, если началась и не закончилась до текущего месяца
+		err := tx.
+			Model(&SubscriptionModel{}).
+			Select("service_name, COUNT(*) AS active, COALESCE(SUM(price), 0) AS spend").
+			Where("start_date <= ? AND (end_date IS NULL OR end_date >= ?)", now, monthStart).
+			Group("service_name").
+			Order("spend DESC").
+			Scan(&spends).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.
+			Model(&EventModel{}).
+			Select("COUNT(*) AS backlog, MIN(created_at) AS oldest").
+			Scan(&stats).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	subsMetrics.ActiveSubscriptions.Set(float64(c.setSpend(spends)))
+
+	subsMetrics.OutboxBacklog.Set(float64(stats.Backlog))
+	age := 0.0
+	if stats.Oldest != nil {
+		age = max(c.now().Sub(*stats.Oldest).Seconds(), 0)
+	}
+	subsMetrics.OutboxOldestEventAge.Set(age)
+
+	return nil
+}
+
+// setSpend обновляет MonthlySpend без Reset: набор серий собирается целиком, пропавшие удаляются -
+// scrape между пересчетами не видит пустую или неполную метрику. Возвращает число активных подписок
+func (c *MetricsCollector) setSpend(spends []serviceSpend) int64 {
+	var active int64
+	spend := make(map[string]float64, min(len(spends), maxSpendServices+1))
+	for i, s := range spends {
+		active += s.Active
+		name := s.ServiceName
+		if i >= maxSpendServices {
+			name = "other"
+		}
+		spend[name] += float64(s.Spend)
+	}
+
+	for name, v := range spend {
+		subsMetrics.MonthlySpend.WithLabelValues(name).Set(v)
+	}
+	for name := range c.spendLabels {
+		if _, ok := spend[name]; !ok {
+			subsMetrics.MonthlySpend.DeleteLabelValues(name)
+		}
+	}
+
+	c.spendLabels = make(map[string]struct{}, len(spend))
+	for name := range spend {
+		c.spendLabels[name] = struct{}{}
+	}
+	return active
+}
@@ -0,0 +1,64 @@
+package subs
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	subsMetrics "github.com/end1essrage/efmob-tz/pkg/subs/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsCollector_SetSpend(t *testing.T) {
+	subsMetrics.MonthlySpend.Reset()
+	t.Cleanup(subsMetrics.MonthlySpend.Reset)
+	c := &MetricsCollector{}
+
+	spends := make([]serviceSpend, 0, maxSpendServices+2)
+	for i := 0; i < maxSpendServices+2; i++ {
+		spends = append(spends, serviceSpend{ServiceName: fmt.Sprintf("s%d", i), Active: 1, Spend: 10})
+	}
+	require.Equal(t, int64(maxSpendServices+2), c.setSpend(spends))
+	require.Equal(t, maxSpendServices+1, testutil.CollectAndCount(subsMetrics.MonthlySpend))
+	// хвост суммируется в other
+	require.Equal(t, 20.0, testutil.ToFloat64(subsMetrics.MonthlySpend.WithLabelValues("other")))
+
+	// пропавшие сервисы удаляются, оставшиеся перезаписываются
+	require.Equal(t, int64(3), c.setSpend([]serviceSpend{{ServiceName: "s1", Active: 3, Spend: 70}}))
+	require.Equal(t, 1, testutil.CollectAndCount(subsMetrics.MonthlySpend))
+	require.Equal(t, 70.0, testutil.ToFloat64(subsMetrics.MonthlySpend.WithLabelValues("s1")))
+}
+
+// TestMetricsCollector_SetSpendNoPartialScrape scrape во время пересчета видит полный набор серий
+func TestMetricsCollector_SetSpendNoPartialScrape(t *testing.T) {
+	subsMetrics.MonthlySpend.Reset()
+	t.Cleanup(subsMetrics.MonthlySpend.Reset)
+	c := &MetricsCollector{}
+	spends := []serviceSpend{
+		{ServiceName: "netflix", Active: 1, Spend: 400},
+		{ServiceName: "spotify", Active: 1, Spend: 200},
+	}
+	c.setSpend(spends)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	stop := make(chan struct{})
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				c.setSpend(spends)
+			}
+		}
+	}()
+
+	for i := 0; i < 200; i++ {
+		require.Equal(t, 2, testutil.CollectAndCount(subsMetrics.MonthlySpend))
+	}
+	close(stop)
+	wg.Wait()
+}
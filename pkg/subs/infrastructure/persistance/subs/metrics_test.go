@@ -0,0 +1,116 @@
+//go:build integration
+// +build integration
+
+package subs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/tenant"
+	common_test "github.com/end1essrage/efmob-tz/pkg/common/testing"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application"
+	domain "github.com/end1essrage/efmob-tz/pkg/subs/domain"
+	subsMetrics "github.com/end1essrage/efmob-tz/pkg/subs/metrics"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestMetricsCollector(t *testing.T) {
+	ctx := context.Background()
+	container, dsn, err := common_test.SetupPostgresContainer(ctx)
+	if err != nil {
+		t.Fatalf("failed to start postgres container: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+
+	repo := NewGormSubscriptionRepo(db)
+	require.NoError(t, repo.Migrate())
+
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	month := func(y int, m time.Month) time.Time { return time.Date(y, m, 1, 0, 0, 0, 0, time.UTC) }
+	ended := month(2025, 6)
+	expired := month(2025, 5)
+
+	create := func(ctx context.Context, service string, price int, start time.Time, end *time.Time) {
+		sub, err := domain.NewSubscription(uuid.Nil, uuid.New(), service, price, start, end)
+		require.NoError(t, err)
+		_, err = repo.Create(ctx, sub)
+		require.NoError(t, err)
+	}
+
+	// активны: бессрочная, заканчивающаяся в текущем месяце и подписка другого тенанта
+	create(ctx, "netflix", 400, month(2025, 1), nil)
+	create(ctx, "netflix", 600, month(2025, 3), &ended)
+	create(tenant.WithTenant(ctx, "acme"), "spotify", 200, month(2025, 2), nil)
+	// закончилась и еще не началась
+	create(ctx, "spotify", 1000, month(2024, 1), &expired)
+	create(ctx, "spotify", 1000, month(2025, 7), nil)
+
+	// два события в outbox, старшее - минуту назад
+	require.NoError(t, db.Create(&EventModel{ID: uuid.New(), Type: "t", CreatedAt: now.Add(-time.Minute)}).Error)
+	require.NoError(t, db.Create(&EventModel{ID: uuid.New(), Type: "t", CreatedAt: now}).Error)
+
+	c := NewMetricsCollector(db, time.Minute)
+	c.now = func() time.Time { return now }
+	require.NoError(t, c.collect(ctx))
+
+	require.Equal(t, 3.0, testutil.ToFloat64(subsMetrics.ActiveSubscriptions))
+	require.Equal(t, 1000.0, testutil.ToFloat64(subsMetrics.MonthlySpend.WithLabelValues("netflix")))
+	require.Equal(t, 200.0, testutil.ToFloat64(subsMetrics.MonthlySpend.WithLabelValues("spotify")))
+	require.Equal(t, 2.0, testutil.ToFloat64(subsMetrics.OutboxBacklog))
+	require.Equal(t, 60.0, testutil.ToFloat64(subsMetrics.OutboxOldestEventAge))
+
+	// пустой outbox и ушедший сервис не оставляют старых значений
+	require.NoError(t, db.Where("1 = 1").Delete(&EventModel{}).Error)
+	require.NoError(t, db.Where("service_name = ?", "spotify").Delete(&SubscriptionModel{}).Error)
+	require.NoError(t, c.collect(ctx))
+
+	require.Equal(t, 2.0, testutil.ToFloat64(subsMetrics.ActiveSubscriptions))
+	require.Equal(t, 1, testutil.CollectAndCount(subsMetrics.MonthlySpend))
+	require.Equal(t, 0.0, testutil.ToFloat64(subsMetrics.OutboxBacklog))
+	require.Equal(t, 0.0, testutil.ToFloat64(subsMetrics.OutboxOldestEventAge))
+}
+
+func TestOptimisticLockConflictsMetric(t *testing.T) {
+	ctx := context.Background()
+	container, dsn, err := common_test.SetupPostgresContainer(ctx)
+	if err != nil {
+		t.Fatalf("failed to start postgres container: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+
+	repo := NewGormSubscriptionRepo(db)
+	require.NoError(t, repo.Migrate())
+
+	sub, err := domain.NewSubscription(uuid.Nil, uuid.New(), "netflix", 100, time.Now(), nil)
+	require.NoError(t, err)
+	id, err := repo.Create(ctx, sub)
+	require.NoError(t, err)
+
+	updates := testutil.ToFloat64(subsMetrics.OptimisticLockConflictsTotal.WithLabelValues("update"))
+	deletes := testutil.ToFloat64(subsMetrics.OptimisticLockConflictsTotal.WithLabelValues("delete"))
+
+	// вторая копия устарела после первого обновления
+	stale, err := repo.GetByID(ctx, id)
+	require.NoError(t, err)
+	require.NoError(t, repo.Update(ctx, sub))
+	require.ErrorIs(t, repo.Update(ctx, stale), application.ErrConcurrentModification)
+	require.ErrorIs(t, repo.DeleteWithVersion(ctx, id, stale.Version()), application.ErrConcurrentModification)
+
+	// отсутствующая запись - не конфликт
+	require.ErrorIs(t, repo.DeleteWithVersion(ctx, uuid.New(), 1), domain.ErrSubscriptionNotFound)
+
+	require.Equal(t, updates+1, testutil.ToFloat64(subsMetrics.OptimisticLockConflictsTotal.WithLabelValues("update")))
+	require.Equal(t, deletes+1, testutil.ToFloat64(subsMetrics.OptimisticLockConflictsTotal.WithLabelValues("delete")))
+}
@@ -0,0 +1,87 @@
+package subs
+
+import (
+	"context"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/subs/application"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var _ application.OutboxRepository = (*GormSubscriptionRepo)(nil)
+
+func (m *EventModel) toOutboxEvent() application.OutboxEvent {
+	return application.OutboxEvent{
+		ID:            m.ID,
+		AggregateID:   m.AggregateID,
+		Type:          m.Type,
+		Payload:       m.Payload,
+		CreatedAt:     m.CreatedAt,
+		Attempts:      m.Attempts,
+		LastError:     m.LastError,
+		NextAttemptAt: m.NextAttemptAt,
+	}
+}
+
+// ListOutboxEvents события тенанта в порядке публикации
+func (r *GormSubscriptionRepo) ListOutboxEvents(ctx context.Context, filter application.OutboxFilter) ([]application.OutboxEvent, error) {
+	var models []EventModel
+	err := r.scoped(ctx, func(db *gorm.DB) error {
+		q := db.Model(&EventModel{})
+		if filter.Type != "" {
+			q = q.Where("type = ?", filter.Type)
+		}
+		if filter.AggregateID != nil {
+			q = q.Where("aggregate_id = ?", *filter.AggregateID)
+		}
+		if filter.Failed {
+			q = q.Where("attempts > 0")
+		}
+		return q.Order("created_at ASC").Limit(filter.Limit).Find(&models).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]application.OutboxEvent, 0, len(models))
+	for i := range models {
+		events = append(events, models[i].toOutboxEvent())
+	}
+	return events, nil
+}
+
+func (r *GormSubscriptionRepo) OutboxStats(ctx context.Context) (application.OutboxStats, error) {
+	var row struct {
+		Pending int64
+		Failed  int64
+		Oldest  *time.Time
+	}
+	err := r.scoped(ctx, func(db *gorm.DB) error {
+		return db.Model(&EventModel{}).
+			Select("COUNT(*) AS pending, COUNT(*) FILTER (WHERE attempts > 0) AS failed, MIN(created_at) AS oldest").
+			Scan(&row).Error
+	})
+	if err != nil {
+		return application.OutboxStats{}, err
+	}
+	return application.OutboxStats{Pending: row.Pending, Failed: row.Failed, Oldest: row.Oldest}, nil
+}
+
+// RequeueOutboxEvents снимает отсрочку и обнуляет попытки, чтобы отсрочка после новой ошибки считалась заново.
+// LastError остается для разбора
+func (r *GormSubscriptionRepo) RequeueOutboxEvents(ctx context.Context, ids []uuid.UUID) (int64, error) {
+	var n int64
+	err := r.withRetry(ctx, func() error {
+		return r.scoped(ctx, func(db *gorm.DB) error {
+			q := db.Model(&EventModel{}).Where("next_attempt_at IS NOT NULL")
+			if len(ids) > 0 {
+				q = q.Where("id IN ?", ids)
+			}
+			res := q.Updates(map[string]any{"attempts": 0, "next_attempt_at": nil})
+			n = res.RowsAffected
+			return res.Error
+		})
+	})
+	return n, err
+}
@@ -0,0 +1,116 @@
+//go:build integration
+// +build integration
+
+package subs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/tenant"
+	common_test "github.com/end1essrage/efmob-tz/pkg/common/testing"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// switchPublisher публикует или падает, пока down
+type switchPublisher struct {
+	mu        sync.Mutex
+	down      bool
+	published []string
+}
+
+func (p *switchPublisher) Publish(ctx context.Context, topic string, payload []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.down {
+		return errors.New("broker unavailable")
+	}
+	p.published = append(p.published, string(payload))
+	return nil
+}
+
+func TestOutbox_DeferInspectRequeue(t *testing.T) {
+	ctx := context.Background()
+	container, dsn, err := common_test.SetupPostgresContainer(ctx)
+	if err != nil {
+		t.Fatalf("failed to start postgres container: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	repo := NewGormSubscriptionRepo(db)
+	require.NoError(t, repo.Migrate())
+
+	agg := uuid.New()
+	base := time.Now().Add(-time.Minute)
+	for i, payload := range []string{"first", "second"} {
+		require.NoError(t, db.Create(&EventModel{
+			ID: uuid.New(), TenantID: "acme", AggregateID: agg, Type: "subscription_updated",
+			Payload: []byte(payload), CreatedAt: base.Add(time.Duration(i) * time.Second),
+		}).Error)
+	}
+
+	pub := &switchPublisher{down: true}
+	w := NewEventWorker(db, pub, time.Hour, 10, 2)
+
+	// ошибка откладывает первое событие, второе ждет за ним
+	require.NoError(t, w.processBatch(ctx))
+	require.NoError(t, w.processBatch(ctx))
+
+	acme := tenant.WithTenant(ctx, "acme")
+	stats, err := repo.OutboxStats(acme)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), stats.Pending)
+	require.Equal(t, int64(1), stats.Failed)
+	require.NotNil(t, stats.Oldest)
+
+	failed, err := repo.ListOutboxEvents(acme, application.OutboxFilter{Failed: true, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, failed, 1)
+	require.Equal(t, "first", string(failed[0].Payload))
+	require.Equal(t, 1, failed[0].Attempts)
+	require.Equal(t, "broker unavailable", failed[0].LastError)
+	require.NotNil(t, failed[0].NextAttemptAt)
+
+	all, err := repo.ListOutboxEvents(acme, application.OutboxFilter{AggregateID: &agg, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+
+	// чужой тенант очередь не видит
+	globex := tenant.WithTenant(ctx, "globex")
+	stats, err = repo.OutboxStats(globex)
+	require.NoError(t, err)
+	require.Zero(t, stats.Pending)
+	n, err := repo.RequeueOutboxEvents(globex, nil)
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	// брокер поднялся: до конца отсрочки агрегат ждет, другие агрегаты публикуются
+	pub.down = false
+	require.NoError(t, db.Create(&EventModel{
+		ID: uuid.New(), TenantID: "acme", AggregateID: uuid.New(), Type: "subscription_created",
+		Payload: []byte("other"), CreatedAt: base.Add(time.Hour),
+	}).Error)
+	require.NoError(t, w.processBatch(ctx))
+	require.Equal(t, []string{"other"}, pub.published)
+	pub.published = nil
+
+	n, err = repo.RequeueOutboxEvents(acme, []uuid.UUID{failed[0].ID})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+
+	require.NoError(t, w.processBatch(ctx))
+	require.Equal(t, []string{"first", "second"}, pub.published)
+
+	stats, err = repo.OutboxStats(acme)
+	require.NoError(t, err)
+	require.Zero(t, stats.Pending)
+}
@@ -5,34 +5,52 @@ import (
 	"crypto/rand"
 	"encoding/binary"
 	"errors"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/end1essrage/efmob-tz/pkg/common/logger"
 	p "github.com/end1essrage/efmob-tz/pkg/common/persistance"
+	"github.com/end1essrage/efmob-tz/pkg/common/tenant"
 	"github.com/end1essrage/efmob-tz/pkg/subs/application"
 	domain "github.com/end1essrage/efmob-tz/pkg/subs/domain"
+	subsMetrics "github.com/end1essrage/efmob-tz/pkg/subs/metrics"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
-	"gorm.io/gorm/clause"
 )
 
 type GormSubscriptionRepo struct {
 	db *gorm.DB
+	// inTx db - транзакция тенанта из RunInTransaction
+	inTx  bool
+	retry RetryPolicy
 }
 
-var allowed = map[string]bool{
-	"price":      true,
-	"start_date": true,
-	"end_date":   true,
-	"created_at": true,
+// RetryPolicy повторы изменяющих запросов при временных ошибках базы
+type RetryPolicy struct {
+	// Attempts попыток всего, включая первую
+	Attempts int
+	// Interval пауза перед второй попыткой, дальше удваивается
+	Interval time.Duration
+	// Jitter верхняя граница случайной добавки к паузе
+	Jitter time.Duration
 }
 
+// DefaultRetryPolicy повторы по умолчанию
+var DefaultRetryPolicy = RetryPolicy{Attempts: 3, Interval: 2 * time.Second, Jitter: 500 * time.Millisecond}
+
 func NewGormSubscriptionRepo(db *gorm.DB) *GormSubscriptionRepo {
-	return &GormSubscriptionRepo{db: db}
+	return &GormSubscriptionRepo{db: db, retry: DefaultRetryPolicy}
+}
+
+// WithRetryPolicy заменяет политику повторов
+func (r *GormSubscriptionRepo) WithRetryPolicy(policy RetryPolicy) *GormSubscriptionRepo {
+	r.retry = policy
+	return r
 }
 
-// AutoMigrate создаёт таблицу
+// Migrate создаёт таблицы по моделям. Сервис применяет версионированные миграции из пакета migrations,
+// Migrate нужна тестам, а схемы сверяет migrations_test
 func (r *GormSubscriptionRepo) Migrate() error {
 	if err := r.db.AutoMigrate(&SubscriptionModel{}); err != nil {
 		return err
@@ -40,80 +58,122 @@ func (r *GormSubscriptionRepo) Migrate() error {
 	if err := r.db.AutoMigrate(&EventModel{}); err != nil {
 		return err
 	}
-	return nil
+	if err := r.db.AutoMigrate(&FeedTokenModel{}); err != nil {
+		return err
+	}
+
+	// нечёткий поиск по названию сервиса
+	if err := r.db.Exec("CREATE EXTENSION IF NOT EXISTS pg_trgm").Error; err != nil {
+		return err
+	}
+	if err := r.db.Exec("CREATE INDEX IF NOT EXISTS idx_subscriptions_service_name_trgm ON subscriptions USING gin (service_name gin_trgm_ops)").Error; err != nil {
+		return err
+	}
+
+	// изоляция тенантов на уровне бд - ошибка в фильтрах запроса не покажет чужие строки
+	return p.EnableTenantRLS(r.db, "subscriptions", "event_models", "feed_tokens")
 }
 
 // поддержка транзакций
 func (r *GormSubscriptionRepo) RunInTransaction(ctx context.Context, fn func(tx domain.TxSubscriptionRepository) error) error {
-	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+	return p.InTenant(ctx, r.db, func(tx *gorm.DB) error {
 		// временный "транзакционный репозиторий" — это тот же объект, но с другим db
-		txRepo := &GormSubscriptionRepo{db: tx}
+		txRepo := &GormSubscriptionRepo{db: tx, inTx: true, retry: r.retry}
 		return fn(txRepo)
 	})
 }
 
+// scoped выполняет fn в транзакции тенанта, внутри RunInTransaction - в ее транзакции
+func (r *GormSubscriptionRepo) scoped(ctx context.Context, fn func(db *gorm.DB) error) error {
+	if r.inTx {
+		return fn(r.db.WithContext(ctx))
+	}
+	return p.InTenant(ctx, r.db, fn)
+}
+
 func (r *GormSubscriptionRepo) Create(ctx context.Context, sub *domain.Subscription) (uuid.UUID, error) {
 	model := FromDomain(sub)
 	// Version уже должен быть 1 из домена
+	model.TenantID = tenant.Current(ctx)
 
 	var id uuid.UUID
 	err := r.withRetry(ctx, func() error {
-		if err := r.db.WithContext(ctx).Create(model).Error; err != nil {
-			return err
-		}
-		id = model.ID
-		return nil
+		return r.scoped(ctx, func(db *gorm.DB) error {
+			if err := db.Create(model).Error; err != nil {
+				return err
+			}
+			id = model.ID
+			return nil
+		})
 	})
 	return id, err
 }
 
 // Update с оптимистичной блокировкой
 func (r *GormSubscriptionRepo) Update(ctx context.Context, sub *domain.Subscription) error {
-	return r.withRetry(ctx, func() error {
-		model := FromDomain(sub)
-
-		res := r.db.WithContext(ctx).
-			Model(&SubscriptionModel{}).
-			Where("id = ? AND version = ?", sub.ID(), sub.Version()).
-			Updates(map[string]interface{}{
-				"user_id":      model.UserID,
-				"service_name": model.ServiceName,
-				"price":        model.Price,
-				"start_date":   model.StartDate,
-				"end_date":     model.EndDate,
-				"updated_at":   time.Now(),
-				"version":      gorm.Expr("version + 1"),
-			})
-
-		if res.Error != nil {
-			return res.Error
-		}
+	err := r.withRetry(ctx, func() error {
+		return r.scoped(ctx, func(db *gorm.DB) error {
+			model := FromDomain(sub)
 
-		if res.RowsAffected == 0 {
-			var count int64
-			err := r.db.WithContext(ctx).
+			res := db.
 				Model(&SubscriptionModel{}).
-				Where("id = ?", sub.ID()).
-				Count(&count).Error
-			if err != nil {
-				return err
+				Where("id = ? AND version = ?", sub.ID(), sub.Version()).
+				Updates(map[string]interface{}{
+					"user_id":      model.UserID,
+					"service_name": model.ServiceName,
+					"price":        model.Price,
+					"start_date":   model.StartDate,
+					"end_date":     model.EndDate,
+					"updated_at":   time.Now(),
+					"version":      gorm.Expr("version + 1"),
+				})
+
+			if res.Error != nil {
+				return res.Error
 			}
 
-			if count == 0 {
-				return domain.ErrSubscriptionNotFound
+			if res.RowsAffected == 0 {
+				return missingOrModified(db, "update", sub.ID())
 			}
 
-			return application.ErrConcurrentModification
-		}
-
-		return nil
+			return nil
+		})
 	})
+	if err != nil {
+		return err
+	}
+
+	// версия в базе увеличилась - синхронизируем сущность
+	sub.IncrementVersion()
+	return nil
+}
+
+// missingOrModified причина, по которой запись с версией не изменилась, op - операция для метрики конфликтов
+func missingOrModified(db *gorm.DB, op string, id uuid.UUID) error {
+	var count int64
+	err := db.
+		Model(&SubscriptionModel{}).
+		Where("id = ?", id).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return domain.ErrSubscriptionNotFound
+	}
+
+	subsMetrics.OptimisticLockConflictsTotal.WithLabelValues(op).Inc()
+	return application.ErrConcurrentModification
 }
 
 // GetByID возвращает подписку по ID
 func (r *GormSubscriptionRepo) GetByID(ctx context.Context, id uuid.UUID) (*domain.Subscription, error) {
 	var m SubscriptionModel
-	if err := r.db.WithContext(ctx).First(&m, "id = ?", id).Error; err != nil {
+	err := r.scoped(ctx, func(db *gorm.DB) error {
+		return db.First(&m, "id = ?", id).Error
+	})
+	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, domain.ErrSubscriptionNotFound
 		}
@@ -125,45 +185,69 @@ func (r *GormSubscriptionRepo) GetByID(ctx context.Context, id uuid.UUID) (*doma
 // Delete удаляет подписку
 func (r *GormSubscriptionRepo) Delete(ctx context.Context, id uuid.UUID) error {
 	err := r.withRetry(ctx, func() error {
-		res := r.db.WithContext(ctx).Delete(&SubscriptionModel{}, "id = ?", id)
-		if res.RowsAffected == 0 {
-			return domain.ErrSubscriptionNotFound
-		}
-		return res.Error
+		return r.scoped(ctx, func(db *gorm.DB) error {
+			res := db.Delete(&SubscriptionModel{}, "id = ?", id)
+			if res.RowsAffected == 0 {
+				return domain.ErrSubscriptionNotFound
+			}
+			return res.Error
+		})
 	})
 
 	return err
 }
 
+// DeleteWithVersion удаляет подписку с оптимистичной блокировкой
+func (r *GormSubscriptionRepo) DeleteWithVersion(ctx context.Context, id uuid.UUID, version int) error {
+	return r.withRetry(ctx, func() error {
+		return r.scoped(ctx, func(db *gorm.DB) error {
+			res := db.Delete(&SubscriptionModel{}, "id = ? AND version = ?", id, version)
+			if res.Error != nil {
+				return res.Error
+			}
+
+			if res.RowsAffected == 0 {
+				return missingOrModified(db, "delete", id)
+			}
+
+			return nil
+		})
+	})
+}
+
 // Find возвращает список подписок
-func (r *GormSubscriptionRepo) Find(ctx context.Context, q domain.SubscriptionQuery, pagination p.Pagination, sorting *p.Sorting) ([]*domain.Subscription, error) {
+func (r *GormSubscriptionRepo) Find(ctx context.Context, q domain.SubscriptionQuery, pagination p.Pagination) ([]*domain.Subscription, error) {
 	log := logger.Logger().WithFields(logger.LogOptions{
 		Pkg:  "GormSubscriptionRepo",
 		Func: "Find",
 		Ctx:  ctx,
 	})
 
-	db := r.db.WithContext(ctx).Model(&SubscriptionModel{})
-
-	db = applySubscriptionQuery(ctx, db, q)
+	var models []SubscriptionModel
+	err := r.scoped(ctx, func(db *gorm.DB) error {
+		db = applySubscriptionQuery(ctx, db.Model(&SubscriptionModel{}), q)
 
-	//применяем сортировку
-	if sorting != nil {
-		log.Debugf("применяем сортировку %+v", *sorting)
-		if !allowed[sorting.OrderBy] {
-			return nil, application.ErrInvalidSortingField
+		//применяем сортировку
+		db, err := applySorting(ctx, db, q.Sort())
+		if err != nil {
+			return err
 		}
-		desc := sorting.Direction == p.Descending
 
-		db = db.Order(clause.OrderByColumn{Column: clause.Column{Name: sorting.OrderBy}, Desc: desc})
-	}
-
-	//применяем пагинацию
-	log.Debugf("применяем пагинацию %+v", pagination)
-	db = db.Limit(pagination.Limit).Offset(pagination.Offset)
+		//применяем пагинацию
+		log.Debugf("применяем пагинацию %+v", pagination)
+		if pagination.After != nil {
+			db, err = applyCursor(db, q.Sort(), pagination.After)
+			if err != nil {
+				return err
+			}
+			db = db.Limit(pagination.Limit)
+		} else {
+			db = db.Limit(pagination.Limit).Offset(pagination.Offset)
+		}
 
-	var models []SubscriptionModel
-	if err := db.Find(&models).Error; err != nil {
+		return db.Find(&models).Error
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -175,17 +259,46 @@ func (r *GormSubscriptionRepo) Find(ctx context.Context, q domain.SubscriptionQu
 	return result, nil
 }
 
-// CalculateTotalCost считает сумму стоимости подписок по квери
-func (r *GormSubscriptionRepo) CalculateTotalCost(ctx context.Context, q domain.SubscriptionQuery) (int, error) {
-	db := r.db.WithContext(ctx).Model(&SubscriptionModel{})
+// Stream построчно читает подписки курсором драйвера, без загрузки всей выборки в память.
+// Транзакция тенанта открыта, пока читаются строки
+func (r *GormSubscriptionRepo) Stream(ctx context.Context, q domain.SubscriptionQuery, fn func(*domain.Subscription) error) error {
+	return r.scoped(ctx, func(db *gorm.DB) error {
+		db = applySubscriptionQuery(ctx, db.Model(&SubscriptionModel{}), q)
+
+		db, err := applySorting(ctx, db, q.Sort())
+		if err != nil {
+			return err
+		}
+
+		rows, err := db.Rows()
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var m SubscriptionModel
+			if err := db.ScanRows(rows, &m); err != nil {
+				return err
+			}
+			if err := fn(m.ToDomain()); err != nil {
+				return err
+			}
+		}
 
-	db = applySubscriptionQuery(ctx, db, q)
+		return rows.Err()
+	})
+}
 
+// CalculateTotalCost считает сумму стоимости подписок по квери
+func (r *GormSubscriptionRepo) CalculateTotalCost(ctx context.Context, q domain.SubscriptionQuery) (int, error) {
 	var total int64
-	err := db.
-		Select("COALESCE(SUM(price), 0)").
-		Scan(&total).
-		Error
+	err := r.scoped(ctx, func(db *gorm.DB) error {
+		return applySubscriptionQuery(ctx, db.Model(&SubscriptionModel{}), q).
+			Select("COALESCE(SUM(price), 0)").
+			Scan(&total).
+			Error
+	})
 
 	if err != nil {
 		return 0, err
@@ -194,6 +307,19 @@ func (r *GormSubscriptionRepo) CalculateTotalCost(ctx context.Context, q domain.
 	return int(total), nil
 }
 
+// CountSubscriptions считает кол-во подписок по квери
+func (r *GormSubscriptionRepo) CountSubscriptions(ctx context.Context, q domain.SubscriptionQuery) (int, error) {
+	var count int64
+	err := r.scoped(ctx, func(db *gorm.DB) error {
+		return applySubscriptionQuery(ctx, db.Model(&SubscriptionModel{}), q).Count(&count).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return int(count), nil
+}
+
 // cryptoRandInt генерирует случайное число используя crypto/rand
 func cryptoRandInt(max int) (int, error) {
 	if max <= 0 {
@@ -212,6 +338,117 @@ func cryptoRandInt(max int) (int, error) {
 	return int(val % uint64(max)), nil
 }
 
+// applySorting сортирует по ключам и ID - порядок стабилен и подходит для keyset пагинации
+func applySorting(ctx context.Context, db *gorm.DB, keys []domain.SortKey) (*gorm.DB, error) {
+	log := logger.Logger().WithFields(logger.LogOptions{
+		Pkg:  "GormSubscriptionRepo",
+		Func: "applySorting",
+		Ctx:  ctx,
+	})
+
+	log.Debugf("применяем сортировку %s", domain.FormatSort(keys))
+	for _, k := range keys {
+		expr, err := sortExpr(k.Field)
+		if err != nil {
+			return nil, err
+		}
+		db = db.Order(expr + " " + sortDirection(k.Desc))
+	}
+
+	return db.Order("id " + sortDirection(idDesc(keys))), nil
+}
+
+// sortExpr выражение ключа сортировки. NULL в end_date - бессрочная подписка,
+// 'infinity' сохраняет порядок postgres (NULL последними по возрастанию) и позволяет сравнивать значения
+func sortExpr(field domain.SortField) (string, error) {
+	switch field {
+	case domain.SortByPrice, domain.SortByStartDate, domain.SortByCreatedAt, domain.SortByServiceName:
+		return string(field), nil
+	case domain.SortByEndDate:
+		return "COALESCE(end_date, 'infinity'::timestamptz)", nil
+	default:
+		return "", domain.ErrInvalidSortField
+	}
+}
+
+func sortDirection(desc bool) string {
+	if desc {
+		return "DESC"
+	}
+	return "ASC"
+}
+
+// idDesc ID сортируется в направлении последнего ключа
+func idDesc(keys []domain.SortKey) bool {
+	return len(keys) > 0 && keys[len(keys)-1].Desc
+}
+
+func compareOp(desc bool) string {
+	if desc {
+		return "<"
+	}
+	return ">"
+}
+
+// applyCursor оставляет строки после курсора в порядке сортировки:
+// (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ... OR (k1 = v1 AND ... AND id > cursor_id).
+// Кортежное сравнение не подходит - направления ключей могут отличаться
+func applyCursor(db *gorm.DB, keys []domain.SortKey, cursor *p.Cursor) (*gorm.DB, error) {
+	invalid := application.NewErrorValidationQuery(p.ErrInvalidCursor.Error())
+	if len(cursor.Values) != len(keys) {
+		return nil, invalid
+	}
+
+	var (
+		ors    []string
+		args   []interface{}
+		eqs    []string
+		eqArgs []interface{}
+	)
+
+	branch := func(cond string, value interface{}) {
+		ors = append(ors, "("+strings.Join(append(append([]string{}, eqs...), cond), " AND ")+")")
+		args = append(append(args, eqArgs...), value)
+	}
+
+	for i, k := range keys {
+		expr, err := sortExpr(k.Field)
+		if err != nil {
+			return nil, err
+		}
+		value, err := cursorValue(k.Field, cursor.Values[i])
+		if err != nil {
+			return nil, invalid
+		}
+
+		branch(expr+" "+compareOp(k.Desc)+" ?", value)
+		eqs = append(eqs, expr+" = ?")
+		eqArgs = append(eqArgs, value)
+	}
+	branch("id "+compareOp(idDesc(keys))+" ?", cursor.ID)
+
+	return db.Where("("+strings.Join(ors, " OR ")+")", args...), nil
+}
+
+// cursorValue значение ключа из курсора в типе колонки
+func cursorValue(field domain.SortField, value *string) (interface{}, error) {
+	if value == nil {
+		if field == domain.SortByEndDate {
+			return gorm.Expr("'infinity'::timestamptz"), nil
+		}
+		return nil, p.ErrInvalidCursor
+	}
+
+	switch field {
+	case domain.SortByPrice:
+		return strconv.Atoi(*value)
+	case domain.SortByServiceName:
+		return *value, nil
+	default:
+		return time.Parse(time.RFC3339Nano, *value)
+	}
+}
+
 func applySubscriptionQuery(ctx context.Context, db *gorm.DB, q domain.SubscriptionQuery) *gorm.DB {
 	log := logger.Logger().WithFields(logger.LogOptions{
 		Pkg:  "GormSubscriptionRepo",
@@ -219,11 +456,38 @@ func applySubscriptionQuery(ctx context.Context, db *gorm.DB, q domain.Subscript
 		Ctx:  ctx,
 	})
 
-	if q.UserID() != nil {
-		db = db.Where("user_id = ?", q.UserID())
+	if ids := q.UserIDs(); len(ids) == 1 {
+		db = db.Where("user_id = ?", ids[0])
+	} else if len(ids) > 1 {
+		db = db.Where("user_id IN ?", ids)
 	}
-	if q.ServiceName() != nil {
-		db = db.Where("service_name = ?", *q.ServiceName())
+	db = applyServiceNames(db, q.ServiceNames(), q.ServiceNameMatch())
+
+	if q.PriceMin() != nil {
+		db = db.Where("price >= ?", *q.PriceMin())
+	}
+	if q.PriceMax() != nil {
+		db = db.Where("price <= ?", *q.PriceMax())
+	}
+	if created := q.CreatedPeriod(); created != nil {
+		if created.From() != nil {
+			db = db.Where("created_at >= ?", *created.From())
+		}
+		if created.To() != nil {
+			db = db.Where("created_at <= ?", *created.To())
+		}
+	}
+
+	if expr := q.Expression(); expr != nil {
+		cond, args, err := filterExprSQL(expr)
+		if err != nil {
+			// ошибка вернётся при выполнении запроса
+			_ = db.AddError(err)
+			return db
+		}
+		db = db.Where(cond, args...)
+
+		log.Debugf("filter expression is: %s", cond)
 	}
 
 	var conds []string
@@ -298,21 +562,58 @@ func applySubscriptionQuery(ctx context.Context, db *gorm.DB, q domain.Subscript
 	return db
 }
 
-// TODO метрика
+// applyServiceNames фильтр по названиям сервисов, значения объединяются через OR
+func applyServiceNames(db *gorm.DB, names []string, match domain.ServiceNameMatch) *gorm.DB {
+	if len(names) == 0 {
+		return db
+	}
+
+	switch match {
+	case domain.ServiceNameIgnoreCase:
+		lowered := make([]string, len(names))
+		for i, n := range names {
+			lowered[i] = strings.ToLower(n)
+		}
+		return db.Where("lower(service_name) IN ?", lowered)
+	case domain.ServiceNameFuzzy:
+		// подстрока или триграммное сходство (pg_trgm, порог pg_trgm.similarity_threshold)
+		conds := make([]string, len(names))
+		args := make([]interface{}, 0, len(names)*2)
+		for i, n := range names {
+			conds[i] = "(service_name ILIKE ? OR service_name % ?)"
+			args = append(args, "%"+escapeLike(n)+"%", n)
+		}
+		return db.Where("("+strings.Join(conds, " OR ")+")", args...)
+	default:
+		if len(names) == 1 {
+			return db.Where("service_name = ?", names[0])
+		}
+		return db.Where("service_name IN ?", names)
+	}
+}
+
+// escapeLike экранирует спецсимволы LIKE
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
 func (r *GormSubscriptionRepo) withRetry(ctx context.Context, op func() error) error {
-	const retries = 3
-	var interval = 2 * time.Second
+	interval := r.retry.Interval
 
 	var lastErr error
-	for i := 0; i < retries; i++ {
+	for i := 0; i < max(r.retry.Attempts, 1); i++ {
 		//jitter
-		jitter, err := cryptoRandInt(500)
-		if err != nil {
-			// Fallback к фиксированному значению при ошибке
-			jitter = 250
+		var jitter time.Duration
+		if r.retry.Jitter > 0 {
+			ms, err := cryptoRandInt(int(r.retry.Jitter.Milliseconds()) + 1)
+			if err != nil {
+				// Fallback к фиксированному значению при ошибке
+				ms = int(r.retry.Jitter.Milliseconds() / 2)
+			}
+			jitter = time.Duration(ms) * time.Millisecond
 		}
 
-		sleep := interval + time.Duration(jitter)*time.Millisecond
+		sleep := interval + jitter
 		if i > 0 {
 			select {
 			case <-ctx.Done():
@@ -321,6 +622,7 @@ func (r *GormSubscriptionRepo) withRetry(ctx context.Context, op func() error) e
 			}
 			//exponent
 			interval *= 2
+			subsMetrics.RetryAttemptsTotal.Inc()
 		}
 
 		if err := op(); err != nil {
@@ -332,6 +634,7 @@ func (r *GormSubscriptionRepo) withRetry(ctx context.Context, op func() error) e
 		}
 		return nil
 	}
+	subsMetrics.RetriesExhaustedTotal.Inc()
 	return application.NewErrorRetriesExceeded(lastErr)
 }
 
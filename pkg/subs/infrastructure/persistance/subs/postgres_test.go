@@ -13,6 +13,7 @@ import (
 	"time"
 
 	p "github.com/end1essrage/efmob-tz/pkg/common/persistance"
+	"github.com/end1essrage/efmob-tz/pkg/common/rsql"
 	common_test "github.com/end1essrage/efmob-tz/pkg/common/testing"
 	"github.com/end1essrage/efmob-tz/pkg/subs/application"
 	domain "github.com/end1essrage/efmob-tz/pkg/subs/domain"
@@ -99,9 +100,10 @@ func TestSubscriptionRepo_FindAndCalculateTotal(t *testing.T) {
 	// --- Find с фильтром ---
 	query := domain.NewSubscriptionQuery(&userID, nil, nil, nil, nil)
 	pagination := p.Pagination{Limit: 2, Offset: 0}
-	sorting := &p.Sorting{OrderBy: "price", Direction: p.Descending}
+	sorted, err := query.WithSort([]domain.SortKey{{Field: domain.SortByPrice, Desc: true}})
+	assert.NoError(t, err)
 
-	results, err := repo.Find(ctx, query, pagination, sorting)
+	results, err := repo.Find(ctx, sorted, pagination)
 	assert.NoError(t, err)
 	assert.Len(t, results, 2) // лимит 2
 	assert.True(t, results[0].Price() > results[1].Price())
@@ -301,7 +303,7 @@ func TestSubscriptionRepo_Queries(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			results, err := repo.Find(ctx, tt.query, p.DefaultPagination(), nil)
+			results, err := repo.Find(ctx, tt.query, p.DefaultPagination())
 			assert.NoError(t, err)
 			assert.Len(t, results, tt.wantCount, "не совпадает количество подписок")
 		})
@@ -391,7 +393,7 @@ func TestSubscriptionRepo_BoundaryMonthFilters(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			results, err := repo.Find(ctx, tt.query, p.DefaultPagination(), nil)
+			results, err := repo.Find(ctx, tt.query, p.DefaultPagination())
 			assert.NoError(t, err)
 			assert.Len(t, results, tt.wantCount, "не совпадает количество подписок")
 		})
@@ -559,3 +561,292 @@ func TestSubscriptionRepo_ExplicitVersionConflict(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, 150, finalSub.Price(), "В БД должна быть цена из первой успешной операции")
 }
+
+func TestSubscriptionRepo_DeleteWithVersion(t *testing.T) {
+	ctx := context.Background()
+	container, dsn, err := common_test.SetupPostgresContainer(ctx)
+	if err != nil {
+		t.Fatalf("failed to start postgres container: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	assert.NoError(t, err)
+
+	repo := NewGormSubscriptionRepo(db)
+	assert.NoError(t, repo.Migrate())
+
+	sub, err := domain.NewSubscription(uuid.Nil, uuid.New(), "test-service", 100, time.Now(), nil)
+	assert.NoError(t, err)
+
+	id, err := repo.Create(ctx, sub)
+	assert.NoError(t, err)
+
+	// меняем подписку - версия становится 2
+	assert.NoError(t, sub.ChangePrice(150))
+	assert.NoError(t, repo.Update(ctx, sub))
+
+	// удаление по устаревшей версии
+	err = repo.DeleteWithVersion(ctx, id, 1)
+	assert.ErrorIs(t, err, application.ErrConcurrentModification)
+
+	// удаление по актуальной версии
+	assert.NoError(t, repo.DeleteWithVersion(ctx, id, 2))
+
+	err = repo.DeleteWithVersion(ctx, id, 2)
+	assert.ErrorIs(t, err, domain.ErrSubscriptionNotFound)
+}
+
+func TestSubscriptionRepo_Stream(t *testing.T) {
+	ctx := context.Background()
+	container, dsn, err := common_test.SetupPostgresContainer(ctx)
+	if err != nil {
+		t.Fatalf("failed to start postgres container: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to connect to db: %v", err)
+	}
+
+	repo := NewGormSubscriptionRepo(db)
+	if err := repo.Migrate(); err != nil {
+		t.Fatalf("failed to migrate schema: %v", err)
+	}
+
+	userID := uuid.New()
+	for i := 1; i <= 5; i++ {
+		sub, _ := domain.NewSubscription(uuid.Nil, userID, "service", i*100, time.Now(), nil)
+		_, err := repo.Create(ctx, sub)
+		assert.NoError(t, err)
+	}
+	other, _ := domain.NewSubscription(uuid.Nil, uuid.New(), "service", 1000, time.Now(), nil)
+	_, err = repo.Create(ctx, other)
+	assert.NoError(t, err)
+
+	q := domain.NewSubscriptionQuery(&userID, nil, nil, nil, nil)
+
+	// все строки по квери в порядке сортировки
+	sorted, err := q.WithSort([]domain.SortKey{{Field: domain.SortByPrice, Desc: true}})
+	assert.NoError(t, err)
+
+	var prices []int
+	err = repo.Stream(ctx, sorted, func(s *domain.Subscription) error {
+		prices = append(prices, s.Price())
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{500, 400, 300, 200, 100}, prices)
+
+	// ошибка колбэка прерывает чтение
+	stop := errors.New("stop")
+	var read int
+	err = repo.Stream(ctx, q, func(s *domain.Subscription) error {
+		read++
+		if read == 2 {
+			return stop
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 2, read)
+}
+
+func TestSubscriptionRepo_FeedTokens(t *testing.T) {
+	ctx := context.Background()
+	container, dsn, err := common_test.SetupPostgresContainer(ctx)
+	if err != nil {
+		t.Fatalf("failed to start postgres container: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to connect to db: %v", err)
+	}
+
+	repo := NewGormSubscriptionRepo(db)
+	if err := repo.Migrate(); err != nil {
+		t.Fatalf("failed to migrate schema: %v", err)
+	}
+
+	userID := uuid.New()
+
+	_, err = repo.GetFeedTokenHash(ctx, userID)
+	assert.ErrorIs(t, err, domain.ErrFeedTokenNotFound)
+
+	assert.NoError(t, repo.SaveFeedToken(ctx, userID, "hash-1"))
+	hash, err := repo.GetFeedTokenHash(ctx, userID)
+	assert.NoError(t, err)
+	assert.Equal(t, "hash-1", hash)
+
+	// повторный выпуск заменяет токен
+	assert.NoError(t, repo.SaveFeedToken(ctx, userID, "hash-2"))
+	hash, err = repo.GetFeedTokenHash(ctx, userID)
+	assert.NoError(t, err)
+	assert.Equal(t, "hash-2", hash)
+}
+
+func TestSubscriptionRepo_FindWithCursor(t *testing.T) {
+	ctx := context.Background()
+	container, dsn, err := common_test.SetupPostgresContainer(ctx)
+	if err != nil {
+		t.Fatalf("failed to start postgres container: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to connect to db: %v", err)
+	}
+
+	repo := NewGormSubscriptionRepo(db)
+	if err := repo.Migrate(); err != nil {
+		t.Fatalf("failed to migrate schema: %v", err)
+	}
+
+	userID := uuid.New()
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	// одинаковые цены и пустые end_date - проверяем разрешение по ID
+	for i, price := range []int{100, 200, 200, 200, 300, 400, 400} {
+		var end *time.Time
+		if i%2 == 0 {
+			e := start.AddDate(0, i+1, 0)
+			end = &e
+		}
+		sub, _ := domain.NewSubscription(uuid.Nil, userID, "service", price, start, end)
+		_, err := repo.Create(ctx, sub)
+		assert.NoError(t, err)
+	}
+
+	q := domain.NewSubscriptionQuery(&userID, nil, nil, nil, nil)
+
+	cursorValues := func(s *domain.Subscription, keys []domain.SortKey) []*string {
+		values := make([]*string, len(keys))
+		for i, k := range keys {
+			var v string
+			switch k.Field {
+			case domain.SortByPrice:
+				v = fmt.Sprint(s.Price())
+			case domain.SortByEndDate:
+				if s.EndDate() == nil {
+					continue
+				}
+				v = s.EndDate().Format(time.RFC3339Nano)
+			}
+			values[i] = &v
+		}
+		return values
+	}
+
+	walk := func(sorted domain.SubscriptionQuery) []uuid.UUID {
+		var ids []uuid.UUID
+		pagination := p.Pagination{Limit: 2}
+		for {
+			page, err := repo.Find(ctx, sorted, pagination)
+			assert.NoError(t, err)
+			for _, s := range page {
+				ids = append(ids, s.ID())
+			}
+			if len(page) < pagination.Limit {
+				return ids
+			}
+			last := page[len(page)-1]
+			pagination.After = &p.Cursor{Values: cursorValues(last, sorted.Sort()), ID: last.ID()}
+		}
+	}
+
+	for _, sort := range []string{"-price", "price", "end_date", "-end_date", "-price,end_date", "price,-end_date"} {
+		sorted, err := q.WithSort(domain.ParseSort(sort))
+		assert.NoError(t, err)
+
+		all, err := repo.Find(ctx, sorted, p.Pagination{Limit: 100})
+		assert.NoError(t, err)
+
+		var expected []uuid.UUID
+		for _, s := range all {
+			expected = append(expected, s.ID())
+		}
+
+		assert.Len(t, expected, 7)
+		assert.Equal(t, expected, walk(sorted), "sort %s", sort)
+	}
+}
+
+func TestSubscriptionRepo_RichFilters(t *testing.T) {
+	ctx := context.Background()
+	container, dsn, err := common_test.SetupPostgresContainer(ctx)
+	if err != nil {
+		t.Fatalf("failed to start postgres container: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to connect to db: %v", err)
+	}
+
+	repo := NewGormSubscriptionRepo(db)
+	if err := repo.Migrate(); err != nil {
+		t.Fatalf("failed to migrate schema: %v", err)
+	}
+
+	userA, userB, userC := uuid.New(), uuid.New(), uuid.New()
+	for _, s := range []struct {
+		user  uuid.UUID
+		name  string
+		price int
+	}{
+		{userA, "Netflix", 400},
+		{userA, "Yandex Plus", 300},
+		{userB, "netflix", 500},
+		{userB, "Spotify", 200},
+		{userC, "Okko", 100},
+	} {
+		sub, _ := domain.NewSubscription(uuid.Nil, s.user, s.name, s.price, time.Now(), nil)
+		_, err := repo.Create(ctx, sub)
+		assert.NoError(t, err)
+	}
+
+	names := func(f domain.SubscriptionFilter) []string {
+		q, err := domain.NewSubscriptionQuery(nil, nil, nil, nil, nil).WithFilter(f)
+		assert.NoError(t, err)
+		q, err = q.WithSort(domain.ParseSort("-price"))
+		assert.NoError(t, err)
+
+		res, err := repo.Find(ctx, q, p.DefaultPagination())
+		assert.NoError(t, err)
+
+		var out []string
+		for _, s := range res {
+			out = append(out, s.ServiceName())
+		}
+		return out
+	}
+
+	users := []uuid.UUID{userA, userB}
+
+	assert.Equal(t, []string{"netflix", "Netflix", "Yandex Plus", "Spotify"}, names(domain.SubscriptionFilter{UserIDs: users}))
+	assert.Equal(t, []string{"Netflix", "Spotify"}, names(domain.SubscriptionFilter{UserIDs: users, ServiceNames: []string{"Netflix", "Spotify"}}))
+	assert.Equal(t, []string{"netflix", "Netflix"}, names(domain.SubscriptionFilter{UserIDs: users, ServiceNames: []string{"NETFLIX"}, ServiceNameMatch: domain.ServiceNameIgnoreCase}))
+	assert.Equal(t, []string{"netflix", "Netflix"}, names(domain.SubscriptionFilter{UserIDs: users, ServiceNames: []string{"netflx"}, ServiceNameMatch: domain.ServiceNameFuzzy}))
+	assert.Equal(t, []string{"Yandex Plus"}, names(domain.SubscriptionFilter{UserIDs: users, ServiceNames: []string{"plus"}, ServiceNameMatch: domain.ServiceNameFuzzy}))
+
+	min, max := 200, 400
+	assert.Equal(t, []string{"Netflix", "Yandex Plus", "Spotify"}, names(domain.SubscriptionFilter{UserIDs: users, PriceMin: &min, PriceMax: &max}))
+
+	expr := func(s string) domain.FilterExpr {
+		n, err := rsql.Parse(s)
+		assert.NoError(t, err)
+		e, err := domain.NewFilterExpr(n)
+		assert.NoError(t, err)
+		return e
+	}
+	assert.Equal(t, []string{"netflix", "Netflix"}, names(domain.SubscriptionFilter{UserIDs: users, Expression: expr("price=gt=300;(service_name==Netflix,service_name==netflix)")}))
+	assert.Equal(t, []string{"Yandex Plus", "Okko"}, names(domain.SubscriptionFilter{Expression: expr("(service_name==*o*,service_name==*Plus);price=out=(200,500);end_date=null=true")}))
+
+	future := time.Now().Add(time.Hour)
+	created, _ := domain.NewPeriod(&future, nil)
+	assert.Empty(t, names(domain.SubscriptionFilter{CreatedPeriod: created}))
+}
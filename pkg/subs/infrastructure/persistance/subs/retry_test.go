@@ -0,0 +1,55 @@
+package subs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/subs/application"
+	domain "github.com/end1essrage/efmob-tz/pkg/subs/domain"
+	subsMetrics "github.com/end1essrage/efmob-tz/pkg/subs/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestWithRetry_Policy(t *testing.T) {
+	repo := NewGormSubscriptionRepo(nil).WithRetryPolicy(RetryPolicy{Attempts: 4, Interval: time.Millisecond})
+	ctx := context.Background()
+
+	attempts := testutil.ToFloat64(subsMetrics.RetryAttemptsTotal)
+	exhausted := testutil.ToFloat64(subsMetrics.RetriesExhaustedTotal)
+
+	// временная ошибка повторяется до исчерпания попыток
+	calls := 0
+	err := repo.withRetry(ctx, func() error {
+		calls++
+		return gorm.ErrInvalidTransaction
+	})
+	var exceeded *application.ErrorRetriesExceeded
+	require.True(t, errors.As(err, &exceeded), err)
+	require.Equal(t, 4, calls)
+	require.Equal(t, attempts+3, testutil.ToFloat64(subsMetrics.RetryAttemptsTotal))
+	require.Equal(t, exhausted+1, testutil.ToFloat64(subsMetrics.RetriesExhaustedTotal))
+
+	// успех со второй попытки
+	calls = 0
+	require.NoError(t, repo.withRetry(ctx, func() error {
+		calls++
+		if calls == 1 {
+			return gorm.ErrInvalidTransaction
+		}
+		return nil
+	}))
+	require.Equal(t, 2, calls)
+
+	// не временные ошибки не повторяются
+	calls = 0
+	err = repo.withRetry(ctx, func() error {
+		calls++
+		return domain.ErrSubscriptionNotFound
+	})
+	require.ErrorIs(t, err, domain.ErrSubscriptionNotFound)
+	require.Equal(t, 1, calls)
+}
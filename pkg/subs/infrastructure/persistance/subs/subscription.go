@@ -9,6 +9,7 @@ import (
 
 type SubscriptionModel struct {
 	ID          uuid.UUID  `gorm:"type:uuid;primaryKey"`
+	TenantID    string     `gorm:"type:text;not null;default:'default';index"`
 	UserID      uuid.UUID  `gorm:"type:uuid;not null;index"`
 	ServiceName string     `gorm:"type:text;not null;index"`
 	Price       int        `gorm:"not null"`
@@ -0,0 +1,225 @@
+//go:build integration
+// +build integration
+
+package subs
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	p "github.com/end1essrage/efmob-tz/pkg/common/persistance"
+	"github.com/end1essrage/efmob-tz/pkg/common/tenant"
+	common_test "github.com/end1essrage/efmob-tz/pkg/common/testing"
+	domain "github.com/end1essrage/efmob-tz/pkg/subs/domain"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// tenantPublisher запоминает тенанта каждого опубликованного события
+type tenantPublisher struct {
+	mu      sync.Mutex
+	tenants []string
+}
+
+func (p *tenantPublisher) Publish(ctx context.Context, topic string, payload []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.tenants = append(p.tenants, tenant.Current(ctx))
+	return nil
+}
+
+func TestTenantIsolation(t *testing.T) {
+	ctx := context.Background()
+	container, dsn, err := common_test.SetupPostgresContainer(ctx)
+	if err != nil {
+		t.Fatalf("failed to start postgres container: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+
+	repo := NewGormSubscriptionRepo(db)
+	require.NoError(t, repo.Migrate())
+
+	acme := tenant.WithTenant(ctx, "acme")
+	globex := tenant.WithTenant(ctx, "globex")
+
+	// один и тот же пользователь в обоих тенантах
+	userID := uuid.New()
+	create := func(ctx context.Context, price int) *domain.Subscription {
+		sub, err := domain.NewSubscription(uuid.Nil, userID, "service", price, time.Now(), nil)
+		require.NoError(t, err)
+		_, err = repo.Create(ctx, sub)
+		require.NoError(t, err)
+		return sub
+	}
+	acmeSub := create(acme, 100)
+	globexSub := create(globex, 1000)
+	create(globex, 2000)
+
+	all := domain.NewSubscriptionQuery(nil, nil, nil, nil, nil)
+	byUser := domain.NewSubscriptionQuery(&userID, nil, nil, nil, nil)
+
+	t.Run("read", func(t *testing.T) {
+		_, err := repo.GetByID(acme, globexSub.ID())
+		require.ErrorIs(t, err, domain.ErrSubscriptionNotFound)
+
+		got, err := repo.GetByID(globex, globexSub.ID())
+		require.NoError(t, err)
+		require.Equal(t, 1000, got.Price())
+
+		// запрос без фильтра по тенанту видит только своих
+		found, err := repo.Find(acme, all, p.Pagination{Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, found, 1)
+		require.Equal(t, acmeSub.ID(), found[0].ID())
+
+		count, err := repo.CountSubscriptions(acme, byUser)
+		require.NoError(t, err)
+		require.Equal(t, 1, count)
+
+		total, err := repo.CalculateTotalCost(globex, byUser)
+		require.NoError(t, err)
+		require.Equal(t, 3000, total)
+
+		var streamed int
+		require.NoError(t, repo.Stream(acme, all, func(*domain.Subscription) error {
+			streamed++
+			return nil
+		}))
+		require.Equal(t, 1, streamed)
+	})
+
+	t.Run("write", func(t *testing.T) {
+		foreign, err := repo.GetByID(globex, globexSub.ID())
+		require.NoError(t, err)
+		require.NoError(t, foreign.ChangePrice(1))
+
+		// чужая запись для тенанта не существует
+		require.ErrorIs(t, repo.Update(acme, foreign), domain.ErrSubscriptionNotFound)
+		require.ErrorIs(t, repo.Delete(acme, globexSub.ID()), domain.ErrSubscriptionNotFound)
+		require.ErrorIs(t, repo.DeleteWithVersion(acme, globexSub.ID(), foreign.Version()), domain.ErrSubscriptionNotFound)
+
+		err = repo.RunInTransaction(acme, func(tx domain.TxSubscriptionRepository) error {
+			_, err := tx.GetByID(acme, globexSub.ID())
+			return err
+		})
+		require.ErrorIs(t, err, domain.ErrSubscriptionNotFound)
+
+		got, err := repo.GetByID(globex, globexSub.ID())
+		require.NoError(t, err)
+		require.Equal(t, 1000, got.Price())
+	})
+
+	t.Run("sql", func(t *testing.T) {
+		// даже сырой запрос без where в транзакции тенанта не видит чужих строк
+		var count int64
+		require.NoError(t, p.InTenant(acme, db, func(tx *gorm.DB) error {
+			return tx.Raw("SELECT count(*) FROM subscriptions").Scan(&count).Error
+		}))
+		require.Equal(t, int64(1), count)
+
+		// запись с чужим tenant_id отклоняет WITH CHECK
+		err := p.InTenant(acme, db, func(tx *gorm.DB) error {
+			return tx.Create(&SubscriptionModel{
+				ID:          uuid.New(),
+				TenantID:    "globex",
+				UserID:      userID,
+				ServiceName: "service",
+				Price:       1,
+				StartDate:   time.Now(),
+				Version:     1,
+			}).Error
+		})
+		require.Error(t, err)
+
+		// перенос своей строки в чужой тенант тоже
+		err = p.InTenant(acme, db, func(tx *gorm.DB) error {
+			return tx.Exec("UPDATE subscriptions SET tenant_id = 'globex'").Error
+		})
+		require.Error(t, err)
+
+		// без выбранного тенанта - Default, строк acme и globex не видно
+		require.NoError(t, p.InTenant(ctx, db, func(tx *gorm.DB) error {
+			return tx.Raw("SELECT count(*) FROM subscriptions").Scan(&count).Error
+		}))
+		require.Equal(t, int64(0), count)
+
+		// некорректный id тенанта не доходит до бд
+		err = p.InTenant(tenant.WithTenant(ctx, "Acme'; --"), db, func(tx *gorm.DB) error { return nil })
+		require.ErrorIs(t, err, tenant.ErrInvalidTenant)
+	})
+
+	t.Run("feed tokens", func(t *testing.T) {
+		require.NoError(t, repo.SaveFeedToken(acme, userID, "acme-hash"))
+
+		_, err := repo.GetFeedTokenHash(globex, userID)
+		require.ErrorIs(t, err, domain.ErrFeedTokenNotFound)
+
+		// тот же пользователь в другом тенанте получает свой токен
+		require.NoError(t, repo.SaveFeedToken(globex, userID, "globex-hash"))
+		hash, err := repo.GetFeedTokenHash(acme, userID)
+		require.NoError(t, err)
+		require.Equal(t, "acme-hash", hash)
+	})
+
+	t.Run("outbox", func(t *testing.T) {
+		require.NoError(t, db.Exec("DELETE FROM event_models").Error)
+		require.NoError(t, repo.CreateEvent(acme, domain.SubCreatedEvent{Id: acmeSub.ID(), UserID: userID}))
+		require.NoError(t, repo.CreateEvent(globex, domain.SubCreatedEvent{Id: globexSub.ID(), UserID: userID}))
+
+		var count int64
+		require.NoError(t, p.InTenant(acme, db, func(tx *gorm.DB) error {
+			return tx.Model(&EventModel{}).Count(&count).Error
+		}))
+		require.Equal(t, int64(1), count)
+
+		// воркер публикует события всех тенантов с тенантом в контексте
+		pub := &tenantPublisher{}
+		worker := NewEventWorker(db, pub, time.Second, 10, 1)
+		require.NoError(t, worker.processBatch(ctx))
+		require.ElementsMatch(t, []string{"acme", "globex"}, pub.tenants)
+
+		require.NoError(t, db.Model(&EventModel{}).Count(&count).Error)
+		require.Equal(t, int64(0), count)
+	})
+
+	t.Run("owner", func(t *testing.T) {
+		// тестовый пользователь - суперпользователь и обходит RLS всегда, таблицу отдаем обычной роли
+		require.NoError(t, db.Exec("CREATE ROLE app_owner NOLOGIN").Error)
+		require.NoError(t, db.Exec("ALTER TABLE subscriptions OWNER TO app_owner").Error)
+
+		count := func(run func(ctx context.Context, db *gorm.DB, fn func(tx *gorm.DB) error) error) int64 {
+			var n int64
+			require.NoError(t, run(ctx, db, func(tx *gorm.DB) error {
+				if err := tx.Exec("SET LOCAL ROLE app_owner").Error; err != nil {
+					return err
+				}
+				return tx.Raw("SELECT count(*) FROM subscriptions").Scan(&n).Error
+			}))
+			return n
+		}
+		plain := func(ctx context.Context, db *gorm.DB, fn func(tx *gorm.DB) error) error {
+			return db.WithContext(ctx).Transaction(fn)
+		}
+
+		// владелец видит строки всех тенантов только явно
+		require.Equal(t, int64(0), count(plain))
+		require.Equal(t, int64(3), count(p.CrossTenant))
+
+		// роль тенанта не включает обход сама
+		var n int64
+		require.NoError(t, p.InTenant(acme, db, func(tx *gorm.DB) error {
+			if err := tx.Exec("SELECT set_config('app.cross_tenant', 'on', true)").Error; err != nil {
+				return err
+			}
+			return tx.Raw("SELECT count(*) FROM subscriptions").Scan(&n).Error
+		}))
+		require.Equal(t, int64(1), n)
+	})
+}
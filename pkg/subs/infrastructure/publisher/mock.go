@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/end1essrage/efmob-tz/pkg/common/logger"
+	"github.com/end1essrage/efmob-tz/pkg/common/tracing"
 )
 
 type MockPublisher struct {
@@ -20,6 +21,7 @@ func (*MockPublisher) Publish(ctx context.Context, topic string, payload []byte)
 		Ctx:  ctx,
 	})
 
-	log.Infof("topic=%s payload=%s", topic, string(payload))
+	// вместо заголовка сообщения брокера
+	log.Infof("topic=%s traceparent=%s payload=%s", topic, tracing.Traceparent(ctx), string(payload))
 	return nil
 }
@@ -0,0 +1,146 @@
+package gql
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	p "github.com/end1essrage/efmob-tz/pkg/common/persistance"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application/container"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application/queries"
+	"github.com/end1essrage/efmob-tz/pkg/subs/domain"
+	"github.com/google/uuid"
+)
+
+const (
+	// сколько ждать остальные ключи пачки
+	loaderWait = 2 * time.Millisecond
+	// не больше значений в IN фильтре домена
+	loaderMaxBatch = 100
+)
+
+// batchLoader собирает ключи, запрошенные резолверами за loaderWait, и загружает их одним вызовом fetch.
+// Результаты кэшируются на время запроса
+type batchLoader[K comparable, V any] struct {
+	ctx      context.Context
+	fetch    func(ctx context.Context, keys []K) (map[K]V, error)
+	wait     time.Duration
+	maxBatch int
+
+	mu      sync.Mutex
+	results map[K]*loaderResult[V]
+	pending []K
+}
+
+type loaderResult[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+func newBatchLoader[K comparable, V any](ctx context.Context, fetch func(context.Context, []K) (map[K]V, error)) *batchLoader[K, V] {
+	return &batchLoader[K, V]{
+		ctx:      ctx,
+		fetch:    fetch,
+		wait:     loaderWait,
+		maxBatch: loaderMaxBatch,
+		results:  make(map[K]*loaderResult[V]),
+	}
+}
+
+// Load значение по ключу, отсутствующий в результате fetch ключ - нулевое значение
+func (l *batchLoader[K, V]) Load(ctx context.Context, key K) (V, error) {
+	l.mu.Lock()
+	res, ok := l.results[key]
+	if !ok {
+		res = &loaderResult[V]{done: make(chan struct{})}
+		l.results[key] = res
+		l.pending = append(l.pending, key)
+
+		switch {
+		case len(l.pending) >= l.maxBatch:
+			go l.dispatch(l.takePending())
+		case len(l.pending) == 1:
+			// первый ключ пачки - запускаем таймер
+			time.AfterFunc(l.wait, func() {
+				l.mu.Lock()
+				keys := l.takePending()
+				l.mu.Unlock()
+				l.dispatch(keys)
+			})
+		}
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-res.done:
+		return res.value, res.err
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+// takePending забирает накопленные ключи, вызывается под mu
+func (l *batchLoader[K, V]) takePending() []K {
+	keys := l.pending
+	l.pending = nil
+	return keys
+}
+
+func (l *batchLoader[K, V]) dispatch(keys []K) {
+	if len(keys) == 0 {
+		return
+	}
+
+	values, err := l.fetch(l.ctx, keys)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, k := range keys {
+		res := l.results[k]
+		res.value, res.err = values[k], err
+		close(res.done)
+	}
+}
+
+// loaders загрузчики одного запроса
+type loaders struct {
+	subscriptionsByUser *batchLoader[uuid.UUID, []*domain.Subscription]
+}
+
+type loadersKey struct{}
+
+func newLoaders(ctx context.Context, c *container.Container) *loaders {
+	return &loaders{
+		subscriptionsByUser: newBatchLoader(ctx, func(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID][]*domain.Subscription, error) {
+			byUser := make(map[uuid.UUID][]*domain.Subscription, len(ids))
+
+			// один запрос на всю пачку пользователей
+			err := c.ExportSubscriptionsHandler.Handle(ctx, queries.ExportSubscriptionsQuery{
+				Filters: queries.SubscriptionFilters{UserIDs: ids},
+				Sorting: &p.Sorting{OrderBy: string(domain.SortByStartDate), Direction: p.Ascending},
+			}, func(s *domain.Subscription) error {
+				byUser[s.UserID()] = append(byUser[s.UserID()], s)
+				return nil
+			})
+
+			return byUser, err
+		}),
+	}
+}
+
+// withLoaders кладёт в контекст запроса новые загрузчики
+func withLoaders(c *container.Container) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), loadersKey{}, newLoaders(r.Context(), c))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
@@ -0,0 +1,100 @@
+package gql
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/rsql"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application/queries"
+	"github.com/end1essrage/efmob-tz/pkg/subs/domain"
+)
+
+const dateLayout = "01-2006"
+
+func parseMonth(s string) (time.Time, error) {
+	t, err := time.Parse(dateLayout, s)
+	if err != nil {
+		return t, fmt.Errorf("%w: %q", domain.ErrInvalidDateFormat, s)
+	}
+	return t, nil
+}
+
+func parseOptionalMonth(s *string) (*time.Time, error) {
+	if s == nil {
+		return nil, nil
+	}
+	t, err := parseMonth(*s)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func formatOptionalMonth(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := t.Format(dateLayout)
+	return &s
+}
+
+// period даты периодов из аргумента
+type period struct {
+	startFrom, startTo, endFrom, endTo *time.Time
+	nilEnd                             *bool
+}
+
+func parsePeriod(in *PeriodInput) (period, error) {
+	var (
+		res period
+		err error
+	)
+	if in == nil {
+		return res, nil
+	}
+
+	if res.startFrom, err = parseOptionalMonth(in.StartFrom); err != nil {
+		return res, err
+	}
+	if res.startTo, err = parseOptionalMonth(in.StartTo); err != nil {
+		return res, err
+	}
+	if res.endFrom, err = parseOptionalMonth(in.EndFrom); err != nil {
+		return res, err
+	}
+	if res.endTo, err = parseOptionalMonth(in.EndTo); err != nil {
+		return res, err
+	}
+	res.nilEnd = in.NilEnd
+
+	return res, nil
+}
+
+// parseFilter переводит фильтр в фильтры приложения, RSQL разбирается так же, как в REST
+func parseFilter(in *SubscriptionFilter) (queries.SubscriptionFilters, error) {
+	var filters queries.SubscriptionFilters
+	if in == nil {
+		return filters, nil
+	}
+
+	filters.UserIDs = in.UserIds
+	filters.ServiceNames = in.ServiceNames
+	filters.PriceMin = in.PriceMin
+	filters.PriceMax = in.PriceMax
+	if in.ServiceMatch != nil {
+		filters.ServiceNameMatch = *in.ServiceMatch
+	}
+
+	if in.Expression != nil {
+		node, err := rsql.Parse(*in.Expression)
+		if err != nil {
+			return filters, application.NewErrorValidationQuery(err.Error())
+		}
+		if filters.Expression, err = domain.NewFilterExpr(node); err != nil {
+			return filters, err
+		}
+	}
+
+	return filters, nil
+}
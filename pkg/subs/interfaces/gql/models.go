@@ -0,0 +1,8 @@
+package gql
+
+import "github.com/google/uuid"
+
+// User сводка по пользователю, поля вычисляются резолверами
+type User struct {
+	ID uuid.UUID `json:"id"`
+}
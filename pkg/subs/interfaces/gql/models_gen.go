@@ -0,0 +1,61 @@
+// Code generated by github.com/99designs/gqlgen, DO NOT EDIT.
+
+package gql
+
+import (
+	"github.com/end1essrage/efmob-tz/pkg/subs/domain"
+	"github.com/google/uuid"
+)
+
+type CreateSubscriptionInput struct {
+	UserID      uuid.UUID `json:"userId"`
+	ServiceName string    `json:"serviceName"`
+	Price       int       `json:"price"`
+	StartDate   string    `json:"startDate"`
+	EndDate     *string   `json:"endDate,omitempty"`
+}
+
+type Mutation struct {
+}
+
+// Периоды по start_date и end_date, месяцы в формате MM-YYYY
+type PeriodInput struct {
+	StartFrom *string `json:"startFrom,omitempty"`
+	StartTo   *string `json:"startTo,omitempty"`
+	EndFrom   *string `json:"endFrom,omitempty"`
+	EndTo     *string `json:"endTo,omitempty"`
+	// Включать подписки без end_date
+	NilEnd *bool `json:"nilEnd,omitempty"`
+}
+
+type Query struct {
+}
+
+type SubscriptionFilter struct {
+	UserIds      []uuid.UUID `json:"userIds,omitempty"`
+	ServiceNames []string    `json:"serviceNames,omitempty"`
+	// exact (по умолчанию), icase или fuzzy
+	ServiceMatch *string `json:"serviceMatch,omitempty"`
+	PriceMin     *int    `json:"priceMin,omitempty"`
+	PriceMax     *int    `json:"priceMax,omitempty"`
+	// RSQL выражение, как filter= в REST
+	Expression *string `json:"expression,omitempty"`
+}
+
+type SubscriptionPage struct {
+	Items []*domain.Subscription `json:"items"`
+	// Курсор следующей страницы, пусто - страница последняя
+	NextCursor *string `json:"nextCursor,omitempty"`
+	// Общее кол-во, только при withTotal
+	Total *int `json:"total,omitempty"`
+}
+
+type UpdateSubscriptionInput struct {
+	Price     *int    `json:"price,omitempty"`
+	StartDate *string `json:"startDate,omitempty"`
+	EndDate   *string `json:"endDate,omitempty"`
+	// Сбросить end_date
+	ClearEndDate *bool `json:"clearEndDate,omitempty"`
+	// Обновить только если версия совпадает
+	ExpectedVersion *int `json:"expectedVersion,omitempty"`
+}
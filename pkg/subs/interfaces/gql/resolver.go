@@ -0,0 +1,16 @@
+package gql
+
+import (
+	"github.com/end1essrage/efmob-tz/pkg/subs/application/container"
+)
+
+//go:generate go tool gqlgen generate
+
+// Resolver корневой резолвер, зависимости - только хендлеры приложения
+type Resolver struct {
+	container *container.Container
+}
+
+func NewResolver(container *container.Container) *Resolver {
+	return &Resolver{container: container}
+}
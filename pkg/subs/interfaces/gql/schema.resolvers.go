@@ -0,0 +1,222 @@
+package gql
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.78
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	p "github.com/end1essrage/efmob-tz/pkg/common/persistance"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application/commands"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application/queries"
+	"github.com/end1essrage/efmob-tz/pkg/subs/domain"
+	"github.com/google/uuid"
+)
+
+// CreateSubscription is the resolver for the createSubscription field.
+func (r *mutationResolver) CreateSubscription(ctx context.Context, input CreateSubscriptionInput) (*domain.Subscription, error) {
+	start, err := parseMonth(input.StartDate)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseOptionalMonth(input.EndDate)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.container.CreateSubscriptionHandler.Handle(ctx, commands.CreateSubscriptionCommand{
+		UserID:      input.UserID,
+		ServiceName: input.ServiceName,
+		Price:       input.Price,
+		StartDate:   start,
+		EndDate:     end,
+	})
+}
+
+// UpdateSubscription is the resolver for the updateSubscription field.
+func (r *mutationResolver) UpdateSubscription(ctx context.Context, id uuid.UUID, input UpdateSubscriptionInput) (*domain.Subscription, error) {
+	start, err := parseOptionalMonth(input.StartDate)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseOptionalMonth(input.EndDate)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.container.UpdateSubscriptionHandler.Handle(ctx, commands.UpdateSubscriptionCommand{
+		ID:              id,
+		Price:           input.Price,
+		StartDate:       start,
+		EndDate:         end,
+		SetEndDateNull:  input.ClearEndDate != nil && *input.ClearEndDate,
+		ExpectedVersion: input.ExpectedVersion,
+	})
+}
+
+// DeleteSubscription is the resolver for the deleteSubscription field.
+func (r *mutationResolver) DeleteSubscription(ctx context.Context, id uuid.UUID, expectedVersion *int) (bool, error) {
+	if err := r.container.DeleteSubscriptionHandler.Handle(ctx, commands.DeleteSubscriptionCommand{
+		ID:              id,
+		ExpectedVersion: expectedVersion,
+	}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Subscription is the resolver for the subscription field.
+func (r *queryResolver) Subscription(ctx context.Context, id uuid.UUID) (*domain.Subscription, error) {
+	sub, err := r.container.GetSubscriptionHandler.Handle(ctx, queries.GetSubscriptionQuery{ID: id})
+	if errors.Is(err, domain.ErrSubscriptionNotFound) {
+		// поле nullable - отсутствие не ошибка
+		return nil, nil
+	}
+	return sub, err
+}
+
+// Subscriptions is the resolver for the subscriptions field.
+func (r *queryResolver) Subscriptions(ctx context.Context, filter *SubscriptionFilter, period *PeriodInput, sort *string, first *int, after *string, withTotal *bool) (*SubscriptionPage, error) {
+	filters, err := parseFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	filters.Sort = sort
+
+	per, err := parsePeriod(period)
+	if err != nil {
+		return nil, err
+	}
+
+	pagination := p.DefaultPagination()
+	if first != nil {
+		pagination.Limit = *first
+	}
+	if after != nil && *after != "" {
+		if pagination.After, err = p.DecodeCursor(*after); err != nil {
+			return nil, application.NewErrorValidationQuery(err.Error())
+		}
+	}
+
+	result, err := r.container.ListSubscriptionsHandler.Handle(ctx, queries.ListSubscriptionsQuery{
+		StartFrom:  per.startFrom,
+		StartTo:    per.startTo,
+		EndFrom:    per.endFrom,
+		EndTo:      per.endTo,
+		WithNilEnd: per.nilEnd,
+		Filters:    filters,
+		Pagination: pagination,
+		WithTotal:  withTotal != nil && *withTotal,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page := &SubscriptionPage{Items: result.Items, Total: result.Total}
+	if result.NextCursor != nil {
+		next := result.NextCursor.Encode()
+		page.NextCursor = &next
+	}
+	return page, nil
+}
+
+// TotalCost is the resolver for the totalCost field.
+func (r *queryResolver) TotalCost(ctx context.Context, filter *SubscriptionFilter, period *PeriodInput) (int, error) {
+	filters, err := parseFilter(filter)
+	if err != nil {
+		return 0, err
+	}
+	per, err := parsePeriod(period)
+	if err != nil {
+		return 0, err
+	}
+
+	return r.container.TotalCostHandler.Handle(ctx, queries.TotalCostQuery{
+		StartFrom:  per.startFrom,
+		StartTo:    per.startTo,
+		EndFrom:    per.endFrom,
+		EndTo:      per.endTo,
+		WithNilEnd: per.nilEnd,
+		Filters:    filters,
+	})
+}
+
+// Users is the resolver for the users field.
+func (r *queryResolver) Users(ctx context.Context, ids []uuid.UUID) ([]*User, error) {
+	if len(ids) > maxUsers {
+		return nil, application.NewErrorValidationQuery(fmt.Sprintf("no more than %d users per request", maxUsers))
+	}
+
+	users := make([]*User, len(ids))
+	for i, id := range ids {
+		if id == uuid.Nil {
+			return nil, domain.ErrInvalidUserID
+		}
+		users[i] = &User{ID: id}
+	}
+	return users, nil
+}
+
+// StartDate is the resolver for the startDate field.
+func (r *subscriptionResolver) StartDate(ctx context.Context, obj *domain.Subscription) (string, error) {
+	return obj.StartDate().Format(dateLayout), nil
+}
+
+// EndDate is the resolver for the endDate field.
+func (r *subscriptionResolver) EndDate(ctx context.Context, obj *domain.Subscription) (*string, error) {
+	return formatOptionalMonth(obj.EndDate()), nil
+}
+
+// User is the resolver for the user field.
+func (r *subscriptionResolver) User(ctx context.Context, obj *domain.Subscription) (*User, error) {
+	return &User{ID: obj.UserID()}, nil
+}
+
+// Subscriptions is the resolver for the subscriptions field.
+func (r *userResolver) Subscriptions(ctx context.Context, obj *User) ([]*domain.Subscription, error) {
+	subs, err := loadersFrom(ctx).subscriptionsByUser.Load(ctx, obj.ID)
+	if subs == nil && err == nil {
+		// пустой список, не null
+		subs = []*domain.Subscription{}
+	}
+	return subs, err
+}
+
+// Cost is the resolver for the cost field.
+func (r *userResolver) Cost(ctx context.Context, obj *User, period *PeriodInput, serviceName *string) (int, error) {
+	per, err := parsePeriod(period)
+	if err != nil {
+		return 0, err
+	}
+
+	return r.container.TotalCostHandler.Handle(ctx, queries.TotalCostQuery{
+		UserID:      &obj.ID,
+		ServiceName: serviceName,
+		StartFrom:   per.startFrom,
+		StartTo:     per.startTo,
+		EndFrom:     per.endFrom,
+		EndTo:       per.endTo,
+		WithNilEnd:  per.nilEnd,
+	})
+}
+
+// Mutation returns MutationResolver implementation.
+func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
+
+// Query returns QueryResolver implementation.
+func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
+
+// Subscription returns SubscriptionResolver implementation.
+func (r *Resolver) Subscription() SubscriptionResolver { return &subscriptionResolver{r} }
+
+// User returns UserResolver implementation.
+func (r *Resolver) User() UserResolver { return &userResolver{r} }
+
+type mutationResolver struct{ *Resolver }
+type queryResolver struct{ *Resolver }
+type subscriptionResolver struct{ *Resolver }
+type userResolver struct{ *Resolver }
@@ -0,0 +1,181 @@
+package gql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/logger"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application/container"
+)
+
+const (
+	// maxUsers не больше пользователей в users(ids)
+	maxUsers = 100
+
+	// оценка кол-ва подписок пользователя для сложности
+	userSubscriptionsEstimate = 10
+	// поля, выполняющие отдельный запрос к базе
+	queryFieldCost = 10
+)
+
+type Options struct {
+	// MaxDepth максимальная вложенность полей запроса
+	MaxDepth int
+	// MaxComplexity максимальная оценка сложности запроса
+	MaxComplexity int
+	// Introspection разрешить запросы схемы
+	Introspection bool
+}
+
+// NewHandler http хендлер GraphQL, загрузчики создаются на каждый запрос
+func NewHandler(c *container.Container, opts Options) http.Handler {
+	cfg := Config{Resolvers: NewResolver(c)}
+	setComplexity(&cfg.Complexity)
+
+	srv := handler.New(NewExecutableSchema(cfg))
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	srv.SetQueryCache(lru.New[*ast.QueryDocument](1000))
+
+	if opts.Introspection {
+		srv.Use(extension.Introspection{})
+	}
+	srv.Use(depthLimit{max: opts.MaxDepth})
+	srv.Use(extension.FixedComplexityLimit(opts.MaxComplexity))
+
+	srv.SetErrorPresenter(presentError)
+	srv.SetRecoverFunc(func(ctx context.Context, p interface{}) error {
+		logger.Logger().WithFields(logger.LogOptions{
+			Pkg:  "gql",
+			Func: "Recover",
+			Ctx:  ctx,
+		}).Errorf("паника в резолвере: %v", p)
+		return errors.New("internal error")
+	})
+
+	return withLoaders(c)(srv)
+}
+
+// AddRoutes добавляет маршрут GraphQL
+func AddRoutes(r chi.Router, h http.Handler) {
+	r.Handle("/graphql", h)
+}
+
+// setComplexity списки умножают сложность потомков на кол-во элементов
+func setComplexity(c *ComplexityRoot) {
+	c.Query.Subscriptions = func(child int, _ *SubscriptionFilter, _ *PeriodInput, _ *string, first *int, _ *string, _ *bool) int {
+		n := 20
+		if first != nil && *first > 0 {
+			n = *first
+		}
+		return queryFieldCost + n*child
+	}
+	c.Query.Users = func(child int, ids []uuid.UUID) int {
+		return len(ids) * child
+	}
+	c.Query.TotalCost = func(child int, _ *SubscriptionFilter, _ *PeriodInput) int {
+		return queryFieldCost
+	}
+	c.User.Subscriptions = func(child int) int {
+		return queryFieldCost + userSubscriptionsEstimate*child
+	}
+	c.User.Cost = func(child int, _ *PeriodInput, _ *string) int {
+		return queryFieldCost
+	}
+}
+
+// presentError ошибки приложения получают код и статус MapError в extensions
+func presentError(ctx context.Context, err error) *gqlerror.Error {
+	gqlErr := graphql.DefaultErrorPresenter(ctx, err)
+
+	// ошибки разбора и валидации запроса самого gqlgen
+	var inner *gqlerror.Error
+	if gqlErr.Err == nil || errors.As(gqlErr.Err, &inner) {
+		return gqlErr
+	}
+
+	appErr := application.MapError(gqlErr.Err)
+	if gqlErr.Extensions == nil {
+		gqlErr.Extensions = map[string]interface{}{}
+	}
+	gqlErr.Extensions["code"] = appErr.Code
+	gqlErr.Extensions["status"] = appErr.HTTPStatus
+
+	if appErr.HTTPStatus >= 500 {
+		logger.Logger().WithFields(logger.LogOptions{
+			Pkg:  "gql",
+			Func: "presentError",
+			Ctx:  ctx,
+		}).Errorf("ошибка резолвера %s: %v", gqlErr.Path, err)
+		// детали внутренних ошибок не раскрываем
+		gqlErr.Message = "INTERNAL_ERROR"
+	}
+
+	return gqlErr
+}
+
+// depthLimit отклоняет запросы глубже max, поля интроспекции не считаются
+type depthLimit struct {
+	max int
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationContextMutator
+} = depthLimit{}
+
+func (depthLimit) ExtensionName() string { return "DepthLimit" }
+
+func (d depthLimit) Validate(graphql.ExecutableSchema) error {
+	if d.max <= 0 {
+		return fmt.Errorf("max depth must be positive")
+	}
+	return nil
+}
+
+func (d depthLimit) MutateOperationContext(ctx context.Context, rc *graphql.OperationContext) *gqlerror.Error {
+	if depth := selectionDepth(rc.Operation.SelectionSet); depth > d.max {
+		err := gqlerror.Errorf("operation has depth %d, which exceeds the limit of %d", depth, d.max)
+		err.Extensions = map[string]interface{}{"code": "DEPTH_LIMIT_EXCEEDED"}
+		return err
+	}
+	return nil
+}
+
+func selectionDepth(set ast.SelectionSet) int {
+	depth := 0
+	for _, sel := range set {
+		var d int
+		switch s := sel.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(s.Name, "__") {
+				continue
+			}
+			d = 1 + selectionDepth(s.SelectionSet)
+		case *ast.InlineFragment:
+			d = selectionDepth(s.SelectionSet)
+		case *ast.FragmentSpread:
+			if s.Definition != nil {
+				d = selectionDepth(s.Definition.SelectionSet)
+			}
+		}
+		if d > depth {
+			depth = d
+		}
+	}
+	return depth
+}
@@ -0,0 +1,272 @@
+package gql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	p "github.com/end1essrage/efmob-tz/pkg/common/persistance"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application/container"
+	"github.com/end1essrage/efmob-tz/pkg/subs/domain"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRepo хранилище в памяти, считает обращения к Stream
+type fakeRepo struct {
+	subs    []*domain.Subscription
+	streams atomic.Int32
+}
+
+func (f *fakeRepo) RunInTransaction(ctx context.Context, fn func(tx domain.TxSubscriptionRepository) error) error {
+	return fn(f)
+}
+
+func (f *fakeRepo) CreateEvent(context.Context, domain.Event) error { return nil }
+
+func (f *fakeRepo) Create(_ context.Context, sub *domain.Subscription) (uuid.UUID, error) {
+	f.subs = append(f.subs, sub)
+	return sub.ID(), nil
+}
+
+func (f *fakeRepo) GetByID(_ context.Context, id uuid.UUID) (*domain.Subscription, error) {
+	for _, s := range f.subs {
+		if s.ID() == id {
+			return s, nil
+		}
+	}
+	return nil, domain.ErrSubscriptionNotFound
+}
+
+func (f *fakeRepo) Update(context.Context, *domain.Subscription) error { return nil }
+func (f *fakeRepo) Delete(context.Context, uuid.UUID) error            { return nil }
+func (f *fakeRepo) DeleteWithVersion(context.Context, uuid.UUID, int) error {
+	return nil
+}
+
+func (f *fakeRepo) Find(_ context.Context, q domain.SubscriptionQuery, pagination p.Pagination) ([]*domain.Subscription, error) {
+	res := f.match(q)
+	if len(res) > pagination.Limit {
+		res = res[:pagination.Limit]
+	}
+	return res, nil
+}
+
+func (f *fakeRepo) Stream(_ context.Context, q domain.SubscriptionQuery, fn func(*domain.Subscription) error) error {
+	f.streams.Add(1)
+	for _, s := range f.match(q) {
+		if err := fn(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeRepo) CalculateTotalCost(_ context.Context, q domain.SubscriptionQuery) (int, error) {
+	total := 0
+	for _, s := range f.match(q) {
+		total += s.Price()
+	}
+	return total, nil
+}
+
+func (f *fakeRepo) CountSubscriptions(_ context.Context, q domain.SubscriptionQuery) (int, error) {
+	return len(f.match(q)), nil
+}
+
+func (f *fakeRepo) SaveFeedToken(context.Context, uuid.UUID, string) error { return nil }
+func (f *fakeRepo) GetFeedTokenHash(context.Context, uuid.UUID) (string, error) {
+	return "", domain.ErrFeedTokenNotFound
+}
+
+func (f *fakeRepo) match(q domain.SubscriptionQuery) []*domain.Subscription {
+	ids := q.UserIDs()
+	var res []*domain.Subscription
+	for _, s := range f.subs {
+		if len(ids) == 0 {
+			res = append(res, s)
+			continue
+		}
+		for _, id := range ids {
+			if s.UserID() == id {
+				res = append(res, s)
+				break
+			}
+		}
+	}
+	return res
+}
+
+type gqlResponse struct {
+	Data   map[string]json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+func newTestServer(t *testing.T, opts Options) (*fakeRepo, func(query string) gqlResponse) {
+	repo := &fakeRepo{}
+	h := NewHandler(container.NewContainer(repo, repo, repo, repo, repo, nil, nil), opts)
+
+	return repo, func(query string) gqlResponse {
+		body, _ := json.Marshal(map[string]string{"query": query})
+		req := httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		var resp gqlResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp), rec.Body.String())
+		return resp
+	}
+}
+
+var defaultOptions = Options{MaxDepth: 8, MaxComplexity: 1000}
+
+func TestGraphQL_UsersSubscriptionsBatched(t *testing.T) {
+	repo, exec := newTestServer(t, defaultOptions)
+
+	users := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+	for i, u := range users {
+		for j := 0; j <= i; j++ {
+			sub, err := domain.NewSubscription(uuid.New(), u, "Netflix", 100, time.Now(), nil)
+			require.NoError(t, err)
+			repo.subs = append(repo.subs, sub)
+		}
+	}
+
+	resp := exec(`{ users(ids: ["` + users[0].String() + `","` + users[1].String() + `","` + users[2].String() + `"]) {
+		id
+		subscriptions { serviceName price user { id } }
+		cost
+	} }`)
+	require.Empty(t, resp.Errors)
+
+	var data []struct {
+		Subscriptions []struct{ Price int } `json:"subscriptions"`
+		Cost          int                   `json:"cost"`
+	}
+	require.NoError(t, json.Unmarshal(resp.Data["users"], &data))
+	require.Len(t, data, 3)
+	for i, u := range data {
+		require.Len(t, u.Subscriptions, i+1)
+		require.Equal(t, (i+1)*100, u.Cost)
+	}
+
+	// подписки трёх пользователей - одним запросом
+	require.Equal(t, int32(1), repo.streams.Load())
+}
+
+func TestGraphQL_CreateAndGet(t *testing.T) {
+	_, exec := newTestServer(t, defaultOptions)
+
+	resp := exec(`mutation { createSubscription(input: {userId: "` + uuid.NewString() + `", serviceName: "Okko", price: 300, startDate: "02-2025"}) { id startDate endDate version } }`)
+	require.Empty(t, resp.Errors)
+
+	var created struct {
+		ID        uuid.UUID `json:"id"`
+		StartDate string    `json:"startDate"`
+		EndDate   *string   `json:"endDate"`
+	}
+	require.NoError(t, json.Unmarshal(resp.Data["createSubscription"], &created))
+	require.Equal(t, "02-2025", created.StartDate)
+	require.Nil(t, created.EndDate)
+
+	resp = exec(`{ subscription(id: "` + created.ID.String() + `") { serviceName } missing: subscription(id: "` + uuid.NewString() + `") { id } }`)
+	require.Empty(t, resp.Errors)
+	require.JSONEq(t, `{"serviceName":"Okko"}`, string(resp.Data["subscription"]))
+	require.JSONEq(t, `null`, string(resp.Data["missing"]))
+}
+
+func TestGraphQL_ErrorCodes(t *testing.T) {
+	_, exec := newTestServer(t, defaultOptions)
+
+	tests := []struct {
+		name  string
+		query string
+		code  string
+	}{
+		{
+			name:  "domain validation",
+			query: `mutation { createSubscription(input: {userId: "` + uuid.NewString() + `", serviceName: "Okko", price: -1, startDate: "02-2025"}) { id } }`,
+			code:  "INVALID_PRICE",
+		},
+		{
+			name:  "date format",
+			query: `{ totalCost(period: {startFrom: "2025-02"}) }`,
+			code:  "INVALID_DATE",
+		},
+		{
+			name:  "rsql syntax",
+			query: `{ subscriptions(filter: {expression: "price=gt="}) { items { id } } }`,
+			code:  "INVALID_QUERY",
+		},
+		{
+			name:  "rsql field",
+			query: `{ subscriptions(filter: {expression: "version==1"}) { items { id } } }`,
+			code:  "INVALID_FILTER",
+		},
+		{
+			name:  "sort field",
+			query: `{ subscriptions(sort: "-user_id") { items { id } } }`,
+			code:  "INVALID_SORTING_FIELD",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := exec(tt.query)
+			require.Len(t, resp.Errors, 1)
+			require.Equal(t, tt.code, resp.Errors[0].Extensions["code"])
+			require.EqualValues(t, http.StatusBadRequest, resp.Errors[0].Extensions["status"])
+		})
+	}
+}
+
+func TestGraphQL_DepthLimit(t *testing.T) {
+	_, exec := newTestServer(t, Options{MaxDepth: 3, MaxComplexity: 1000})
+
+	id := uuid.NewString()
+	resp := exec(`{ users(ids: ["` + id + `"]) { subscriptions { user { id } } } }`)
+	require.Len(t, resp.Errors, 1)
+	require.Equal(t, "DEPTH_LIMIT_EXCEEDED", resp.Errors[0].Extensions["code"])
+
+	// фрагменты считаются по месту использования
+	resp = exec(`{ users(ids: ["` + id + `"]) { ...f } } fragment f on User { subscriptions { user { id } } }`)
+	require.Len(t, resp.Errors, 1)
+	require.Equal(t, "DEPTH_LIMIT_EXCEEDED", resp.Errors[0].Extensions["code"])
+
+	resp = exec(`{ users(ids: ["` + id + `"]) { subscriptions { id } } }`)
+	require.Empty(t, resp.Errors)
+}
+
+func TestGraphQL_ComplexityLimit(t *testing.T) {
+	_, exec := newTestServer(t, Options{MaxDepth: 8, MaxComplexity: 200})
+
+	resp := exec(`{ subscriptions(first: 100) { items { id user { id subscriptions { id } } } } }`)
+	require.Len(t, resp.Errors, 1)
+	require.Equal(t, "COMPLEXITY_LIMIT_EXCEEDED", resp.Errors[0].Extensions["code"])
+
+	resp = exec(`{ subscriptions(first: 5) { items { id } } }`)
+	require.Empty(t, resp.Errors)
+}
+
+func TestGraphQL_TooManyUsers(t *testing.T) {
+	_, exec := newTestServer(t, Options{MaxDepth: 8, MaxComplexity: 100000})
+
+	ids := make([]string, maxUsers+1)
+	for i := range ids {
+		ids[i] = `"` + uuid.NewString() + `"`
+	}
+
+	resp := exec(`{ users(ids: [` + strings.Join(ids, ",") + `]) { id } }`)
+	require.Len(t, resp.Errors, 1)
+	require.Equal(t, "INVALID_QUERY", resp.Errors[0].Extensions["code"])
+}
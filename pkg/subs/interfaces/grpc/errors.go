@@ -0,0 +1,82 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/logger"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errorDomain домен google.rpc.ErrorInfo ошибок сервиса
+const errorDomain = "subs.efmob"
+
+// codesByAppCode коды, которые не выводятся однозначно из http статуса
+var codesByAppCode = map[string]codes.Code{
+	"CONCURRENT_MODIFICATION": codes.Aborted,
+	"PRECONDITION_FAILED":     codes.FailedPrecondition,
+}
+
+// toStatus ошибка приложения в статус gRPC, код MapError передается в ErrorInfo.Reason
+func toStatus(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if errors.Is(err, context.Canceled) {
+		return status.Error(codes.Canceled, err.Error())
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+
+	appErr := application.MapError(err)
+
+	code, ok := codesByAppCode[appErr.Code]
+	if !ok {
+		code = codeByHTTPStatus(appErr.HTTPStatus)
+	}
+
+	msg := err.Error()
+	if code == codes.Internal {
+		logger.Logger().WithFields(logger.LogOptions{
+			Pkg:  "grpc",
+			Func: "toStatus",
+			Ctx:  ctx,
+		}).Errorf("внутренняя ошибка: %v", err)
+		// детали внутренних ошибок не раскрываем
+		msg = appErr.Code
+	}
+
+	st, detErr := status.New(code, msg).WithDetails(&errdetails.ErrorInfo{
+		Reason: appErr.Code,
+		Domain: errorDomain,
+	})
+	if detErr != nil {
+		return status.Error(code, msg)
+	}
+	return st.Err()
+}
+
+func codeByHTTPStatus(s int) codes.Code {
+	switch s {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.Aborted
+	case http.StatusPreconditionFailed:
+		return codes.FailedPrecondition
+	default:
+		return codes.Internal
+	}
+}
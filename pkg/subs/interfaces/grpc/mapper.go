@@ -0,0 +1,160 @@
+package grpc
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/rsql"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application/queries"
+	"github.com/end1essrage/efmob-tz/pkg/subs/domain"
+	"github.com/end1essrage/efmob-tz/pkg/subs/interfaces/grpc/subsv1"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const dateLayout = "01-2006"
+
+func toProto(s *domain.Subscription) *subsv1.Subscription {
+	res := &subsv1.Subscription{
+		Id:          s.ID().String(),
+		UserId:      s.UserID().String(),
+		ServiceName: s.ServiceName(),
+		Price:       int64(s.Price()),
+		StartDate:   s.StartDate().Format(dateLayout),
+		Version:     int64(s.Version()),
+		CreatedAt:   timestamppb.New(s.CreatedAt()),
+		UpdatedAt:   timestamppb.New(s.UpdatedAt()),
+	}
+	if end := s.EndDate(); end != nil {
+		e := end.Format(dateLayout)
+		res.EndDate = &e
+	}
+	return res
+}
+
+func parseID(field, s string) (uuid.UUID, error) {
+	id, err := uuid.Parse(s)
+	if err != nil {
+		return uuid.Nil, application.NewErrorValidationQuery(fmt.Sprintf("invalid %s format, should be uuid", field))
+	}
+	return id, nil
+}
+
+func parseMonth(s string) (time.Time, error) {
+	t, err := time.Parse(dateLayout, s)
+	if err != nil {
+		return t, fmt.Errorf("%w: %q", domain.ErrInvalidDateFormat, s)
+	}
+	return t, nil
+}
+
+func parseOptionalMonth(s *string) (*time.Time, error) {
+	if s == nil {
+		return nil, nil
+	}
+	t, err := parseMonth(*s)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// parseOptionalDay день "YYYY-MM-DD", endOfDay - последний момент дня
+func parseOptionalDay(s *string, endOfDay bool) (*time.Time, error) {
+	if s == nil {
+		return nil, nil
+	}
+	t, err := time.Parse(time.DateOnly, *s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", domain.ErrInvalidDateFormat, *s)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1).Add(-time.Microsecond)
+	}
+	return &t, nil
+}
+
+func optionalInt(v *int64) *int {
+	if v == nil {
+		return nil
+	}
+	i := int(*v)
+	return &i
+}
+
+// period даты периодов из запроса
+type period struct {
+	startFrom, startTo, endFrom, endTo *time.Time
+	nilEnd                             *bool
+}
+
+func parsePeriod(in *subsv1.Period) (period, error) {
+	var (
+		res period
+		err error
+	)
+	if in == nil {
+		return res, nil
+	}
+
+	if res.startFrom, err = parseOptionalMonth(in.StartFrom); err != nil {
+		return res, err
+	}
+	if res.startTo, err = parseOptionalMonth(in.StartTo); err != nil {
+		return res, err
+	}
+	if res.endFrom, err = parseOptionalMonth(in.EndFrom); err != nil {
+		return res, err
+	}
+	if res.endTo, err = parseOptionalMonth(in.EndTo); err != nil {
+		return res, err
+	}
+	res.nilEnd = in.NilEnd
+
+	return res, nil
+}
+
+// parseFilter переводит фильтр в фильтры приложения, RSQL разбирается так же, как в REST
+func parseFilter(in *subsv1.SubscriptionFilter, sort string) (queries.SubscriptionFilters, error) {
+	var filters queries.SubscriptionFilters
+	if sort != "" {
+		filters.Sort = &sort
+	}
+	if in == nil {
+		return filters, nil
+	}
+
+	for _, s := range in.UserIds {
+		id, err := parseID("user_ids", s)
+		if err != nil {
+			return filters, err
+		}
+		filters.UserIDs = append(filters.UserIDs, id)
+	}
+	filters.ServiceNames = in.ServiceNames
+	filters.ServiceNameMatch = in.ServiceMatch
+	filters.PriceMin = optionalInt(in.PriceMin)
+	filters.PriceMax = optionalInt(in.PriceMax)
+
+	var err error
+	if filters.CreatedFrom, err = parseOptionalDay(in.CreatedFrom, false); err != nil {
+		return filters, err
+	}
+	if filters.CreatedTo, err = parseOptionalDay(in.CreatedTo, true); err != nil {
+		return filters, err
+	}
+
+	if strings.TrimSpace(in.Expression) != "" {
+		node, err := rsql.Parse(in.Expression)
+		if err != nil {
+			return filters, application.NewErrorValidationQuery(err.Error())
+		}
+		if filters.Expression, err = domain.NewFilterExpr(node); err != nil {
+			return filters, err
+		}
+	}
+
+	return filters, nil
+}
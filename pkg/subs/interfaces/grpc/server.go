@@ -0,0 +1,217 @@
+package grpc
+
+import (
+	"context"
+
+	p "github.com/end1essrage/efmob-tz/pkg/common/persistance"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application/commands"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application/container"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application/queries"
+	"github.com/end1essrage/efmob-tz/pkg/subs/domain"
+	"github.com/end1essrage/efmob-tz/pkg/subs/interfaces/grpc/subsv1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// ServiceName полное имя сервиса для health и рефлексии
+const ServiceName = "subs.v1.SubscriptionService"
+
+// Server реализация subs.v1.SubscriptionService поверх хендлеров приложения
+type Server struct {
+	subsv1.UnimplementedSubscriptionServiceServer
+
+	container *container.Container
+}
+
+func NewServer(c *container.Container) *Server {
+	return &Server{container: c}
+}
+
+// Register регистрирует сервис на gRPC сервере
+func Register(s grpc.ServiceRegistrar, srv *Server) {
+	subsv1.RegisterSubscriptionServiceServer(s, srv)
+}
+
+func (s *Server) CreateSubscription(ctx context.Context, req *subsv1.CreateSubscriptionRequest) (*subsv1.Subscription, error) {
+	userID, err := parseID("user_id", req.UserId)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	start, err := parseMonth(req.StartDate)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	end, err := parseOptionalMonth(req.EndDate)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	sub, err := s.container.CreateSubscriptionHandler.Handle(ctx, commands.CreateSubscriptionCommand{
+		UserID:      userID,
+		ServiceName: req.ServiceName,
+		Price:       int(req.Price),
+		StartDate:   start,
+		EndDate:     end,
+	})
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return toProto(sub), nil
+}
+
+func (s *Server) GetSubscription(ctx context.Context, req *subsv1.GetSubscriptionRequest) (*subsv1.Subscription, error) {
+	id, err := parseID("id", req.Id)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	sub, err := s.container.GetSubscriptionHandler.Handle(ctx, queries.GetSubscriptionQuery{ID: id})
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return toProto(sub), nil
+}
+
+func (s *Server) UpdateSubscription(ctx context.Context, req *subsv1.UpdateSubscriptionRequest) (*subsv1.Subscription, error) {
+	id, err := parseID("id", req.Id)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	start, err := parseOptionalMonth(req.StartDate)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	end, err := parseOptionalMonth(req.EndDate)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	sub, err := s.container.UpdateSubscriptionHandler.Handle(ctx, commands.UpdateSubscriptionCommand{
+		ID:              id,
+		Price:           optionalInt(req.Price),
+		StartDate:       start,
+		EndDate:         end,
+		SetEndDateNull:  req.ClearEndDate,
+		ExpectedVersion: optionalInt(req.ExpectedVersion),
+	})
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return toProto(sub), nil
+}
+
+func (s *Server) DeleteSubscription(ctx context.Context, req *subsv1.DeleteSubscriptionRequest) (*emptypb.Empty, error) {
+	id, err := parseID("id", req.Id)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	if err := s.container.DeleteSubscriptionHandler.Handle(ctx, commands.DeleteSubscriptionCommand{
+		ID:              id,
+		ExpectedVersion: optionalInt(req.ExpectedVersion),
+	}); err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) ListSubscriptions(ctx context.Context, req *subsv1.ListSubscriptionsRequest) (*subsv1.ListSubscriptionsResponse, error) {
+	filters, err := parseFilter(req.Filter, req.Sort)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	per, err := parsePeriod(req.Period)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	pagination := p.DefaultPagination()
+	if req.PageSize != 0 {
+		pagination.Limit = int(req.PageSize)
+	}
+	if req.PageToken != "" {
+		if pagination.After, err = p.DecodeCursor(req.PageToken); err != nil {
+			return nil, toStatus(ctx, application.NewErrorValidationQuery(err.Error()))
+		}
+	}
+
+	result, err := s.container.ListSubscriptionsHandler.Handle(ctx, queries.ListSubscriptionsQuery{
+		StartFrom:  per.startFrom,
+		StartTo:    per.startTo,
+		EndFrom:    per.endFrom,
+		EndTo:      per.endTo,
+		WithNilEnd: per.nilEnd,
+		Filters:    filters,
+		Pagination: pagination,
+		WithTotal:  req.WithTotal,
+	})
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	resp := &subsv1.ListSubscriptionsResponse{
+		Subscriptions: make([]*subsv1.Subscription, 0, len(result.Items)),
+	}
+	for _, sub := range result.Items {
+		resp.Subscriptions = append(resp.Subscriptions, toProto(sub))
+	}
+	if result.NextCursor != nil {
+		resp.NextPageToken = result.NextCursor.Encode()
+	}
+	if result.Total != nil {
+		total := int64(*result.Total)
+		resp.Total = &total
+	}
+	return resp, nil
+}
+
+func (s *Server) GetTotalCost(ctx context.Context, req *subsv1.GetTotalCostRequest) (*subsv1.GetTotalCostResponse, error) {
+	filters, err := parseFilter(req.Filter, "")
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	per, err := parsePeriod(req.Period)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	total, err := s.container.TotalCostHandler.Handle(ctx, queries.TotalCostQuery{
+		StartFrom:  per.startFrom,
+		StartTo:    per.startTo,
+		EndFrom:    per.endFrom,
+		EndTo:      per.endTo,
+		WithNilEnd: per.nilEnd,
+		Filters:    filters,
+	})
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return &subsv1.GetTotalCostResponse{Total: int64(total)}, nil
+}
+
+func (s *Server) ExportSubscriptions(req *subsv1.ExportSubscriptionsRequest, stream grpc.ServerStreamingServer[subsv1.Subscription]) error {
+	ctx := stream.Context()
+
+	filters, err := parseFilter(req.Filter, req.Sort)
+	if err != nil {
+		return toStatus(ctx, err)
+	}
+	per, err := parsePeriod(req.Period)
+	if err != nil {
+		return toStatus(ctx, err)
+	}
+
+	// подписки отправляются по мере чтения из базы, без буферизации
+	err = s.container.ExportSubscriptionsHandler.Handle(ctx, queries.ExportSubscriptionsQuery{
+		StartFrom:  per.startFrom,
+		StartTo:    per.startTo,
+		EndFrom:    per.endFrom,
+		EndTo:      per.endTo,
+		WithNilEnd: per.nilEnd,
+		Filters:    filters,
+	}, func(sub *domain.Subscription) error {
+		return stream.Send(toProto(sub))
+	})
+	return toStatus(ctx, err)
+}
@@ -0,0 +1,438 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/auth"
+	common "github.com/end1essrage/efmob-tz/pkg/common/cmd"
+	cg "github.com/end1essrage/efmob-tz/pkg/common/interfaces/grpc"
+	p "github.com/end1essrage/efmob-tz/pkg/common/persistance"
+	"github.com/end1essrage/efmob-tz/pkg/common/ratelimit"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application/container"
+	"github.com/end1essrage/efmob-tz/pkg/subs/domain"
+	"github.com/end1essrage/efmob-tz/pkg/subs/interfaces/grpc/subsv1"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeRepo хранилище в памяти
+type fakeRepo struct {
+	subs []*domain.Subscription
+}
+
+func (f *fakeRepo) RunInTransaction(ctx context.Context, fn func(tx domain.TxSubscriptionRepository) error) error {
+	return fn(f)
+}
+
+func (f *fakeRepo) CreateEvent(context.Context, domain.Event) error { return nil }
+
+func (f *fakeRepo) Create(_ context.Context, sub *domain.Subscription) (uuid.UUID, error) {
+	f.subs = append(f.subs, sub)
+	return sub.ID(), nil
+}
+
+func (f *fakeRepo) GetByID(_ context.Context, id uuid.UUID) (*domain.Subscription, error) {
+	for _, s := range f.subs {
+		if s.ID() == id {
+			return s, nil
+		}
+	}
+	return nil, domain.ErrSubscriptionNotFound
+}
+
+func (f *fakeRepo) Update(context.Context, *domain.Subscription) error { return nil }
+func (f *fakeRepo) Delete(context.Context, uuid.UUID) error            { return nil }
+func (f *fakeRepo) DeleteWithVersion(context.Context, uuid.UUID, int) error {
+	return nil
+}
+
+func (f *fakeRepo) Find(_ context.Context, _ domain.SubscriptionQuery, pagination p.Pagination) ([]*domain.Subscription, error) {
+	res := f.subs
+	if len(res) > pagination.Limit {
+		res = res[:pagination.Limit]
+	}
+	return res, nil
+}
+
+func (f *fakeRepo) Stream(_ context.Context, _ domain.SubscriptionQuery, fn func(*domain.Subscription) error) error {
+	for _, s := range f.subs {
+		if err := fn(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeRepo) CalculateTotalCost(context.Context, domain.SubscriptionQuery) (int, error) {
+	total := 0
+	for _, s := range f.subs {
+		total += s.Price()
+	}
+	return total, nil
+}
+
+func (f *fakeRepo) CountSubscriptions(context.Context, domain.SubscriptionQuery) (int, error) {
+	return len(f.subs), nil
+}
+
+func (f *fakeRepo) SaveFeedToken(context.Context, uuid.UUID, string) error { return nil }
+func (f *fakeRepo) GetFeedTokenHash(context.Context, uuid.UUID) (string, error) {
+	return "", domain.ErrFeedTokenNotFound
+}
+
+func newTestClient(t *testing.T) (*fakeRepo, *grpc.ClientConn) {
+	return newAuthTestClient(t, nil)
+}
+
+func newAuthTestClient(t *testing.T, a *auth.Authenticator) (*fakeRepo, *grpc.ClientConn) {
+	return newLimitedTestClient(t, a, nil)
+}
+
+func newLimitedTestClient(t *testing.T, a *auth.Authenticator, limits *cg.RateLimits) (*fakeRepo, *grpc.ClientConn) {
+	repo := &fakeRepo{}
+
+	srv, hs := common.CreateGRPCServer(a, limits)
+	Register(srv, NewServer(container.NewContainer(repo, repo, repo, repo, repo, nil, nil)))
+	hs.SetServingStatus(ServiceName, healthpb.HealthCheckResponse_SERVING)
+
+	lis := bufconn.Listen(1 << 20)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return repo, conn
+}
+
+func reasonOf(t *testing.T, err error) string {
+	st, ok := status.FromError(err)
+	require.True(t, ok)
+	for _, d := range st.Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok {
+			return info.Reason
+		}
+	}
+	return ""
+}
+
+func TestGRPC_CreateAndGet(t *testing.T) {
+	_, conn := newTestClient(t)
+	client := subsv1.NewSubscriptionServiceClient(conn)
+	ctx := context.Background()
+
+	created, err := client.CreateSubscription(ctx, &subsv1.CreateSubscriptionRequest{
+		UserId:      uuid.NewString(),
+		ServiceName: "Okko",
+		Price:       300,
+		StartDate:   "02-2025",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "02-2025", created.StartDate)
+	require.Nil(t, created.EndDate)
+
+	var header metadata.MD
+	got, err := client.GetSubscription(
+		metadata.AppendToOutgoingContext(ctx, "x-request-id", "req-1"),
+		&subsv1.GetSubscriptionRequest{Id: created.Id},
+		grpc.Header(&header),
+	)
+	require.NoError(t, err)
+	require.Equal(t, "Okko", got.ServiceName)
+	require.Equal(t, int64(300), got.Price)
+	// id запроса возвращается клиенту
+	require.Equal(t, []string{"req-1"}, header.Get("x-request-id"))
+}
+
+func TestGRPC_ErrorCodes(t *testing.T) {
+	_, conn := newTestClient(t)
+	client := subsv1.NewSubscriptionServiceClient(conn)
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		call   func() error
+		code   codes.Code
+		reason string
+	}{
+		{
+			name: "domain validation",
+			call: func() error {
+				_, err := client.CreateSubscription(ctx, &subsv1.CreateSubscriptionRequest{
+					UserId: uuid.NewString(), ServiceName: "Okko", Price: -1, StartDate: "02-2025",
+				})
+				return err
+			},
+			code:   codes.InvalidArgument,
+			reason: "INVALID_PRICE",
+		},
+		{
+			name: "date format",
+			call: func() error {
+				_, err := client.CreateSubscription(ctx, &subsv1.CreateSubscriptionRequest{
+					UserId: uuid.NewString(), ServiceName: "Okko", Price: 1, StartDate: "2025-02",
+				})
+				return err
+			},
+			code:   codes.InvalidArgument,
+			reason: "INVALID_DATE",
+		},
+		{
+			name: "not found",
+			call: func() error {
+				_, err := client.GetSubscription(ctx, &subsv1.GetSubscriptionRequest{Id: uuid.NewString()})
+				return err
+			},
+			code:   codes.NotFound,
+			reason: "NOT_FOUND",
+		},
+		{
+			name: "bad id",
+			call: func() error {
+				_, err := client.GetSubscription(ctx, &subsv1.GetSubscriptionRequest{Id: "1"})
+				return err
+			},
+			code:   codes.InvalidArgument,
+			reason: "INVALID_QUERY",
+		},
+		{
+			name: "rsql syntax",
+			call: func() error {
+				_, err := client.ListSubscriptions(ctx, &subsv1.ListSubscriptionsRequest{
+					Filter: &subsv1.SubscriptionFilter{Expression: "price=gt="},
+				})
+				return err
+			},
+			code:   codes.InvalidArgument,
+			reason: "INVALID_QUERY",
+		},
+		{
+			name: "rsql field",
+			call: func() error {
+				_, err := client.GetTotalCost(ctx, &subsv1.GetTotalCostRequest{
+					Filter: &subsv1.SubscriptionFilter{Expression: "version==1"},
+				})
+				return err
+			},
+			code:   codes.InvalidArgument,
+			reason: "INVALID_FILTER",
+		},
+		{
+			name: "page size",
+			call: func() error {
+				_, err := client.ListSubscriptions(ctx, &subsv1.ListSubscriptionsRequest{PageSize: 100000})
+				return err
+			},
+			code:   codes.InvalidArgument,
+			reason: "INVALID_QUERY",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			require.Equal(t, tt.code, status.Code(err), err)
+			require.Equal(t, tt.reason, reasonOf(t, err))
+		})
+	}
+}
+
+func TestGRPC_ListAndExport(t *testing.T) {
+	repo, conn := newTestClient(t)
+	client := subsv1.NewSubscriptionServiceClient(conn)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		sub, err := domain.NewSubscription(uuid.New(), uuid.New(), fmt.Sprintf("svc%d", i), 100, time.Now(), nil)
+		require.NoError(t, err)
+		repo.subs = append(repo.subs, sub)
+	}
+
+	list, err := client.ListSubscriptions(ctx, &subsv1.ListSubscriptionsRequest{PageSize: 2, WithTotal: true})
+	require.NoError(t, err)
+	require.Len(t, list.Subscriptions, 2)
+	require.NotEmpty(t, list.NextPageToken)
+	require.Equal(t, int64(3), list.GetTotal())
+
+	cost, err := client.GetTotalCost(ctx, &subsv1.GetTotalCostRequest{})
+	require.NoError(t, err)
+	require.Equal(t, int64(300), cost.Total)
+
+	stream, err := client.ExportSubscriptions(ctx, &subsv1.ExportSubscriptionsRequest{})
+	require.NoError(t, err)
+
+	n := 0
+	for {
+		_, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		n++
+	}
+	require.Equal(t, 3, n)
+}
+
+func TestGRPC_Health(t *testing.T) {
+	_, conn := newTestClient(t)
+
+	resp, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{Service: ServiceName})
+	require.NoError(t, err)
+	require.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+}
+
+func TestToStatus(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		err    error
+		code   codes.Code
+		reason string
+	}{
+		{application.ErrConcurrentModification, codes.Aborted, "CONCURRENT_MODIFICATION"},
+		{application.ErrPreconditionFailed, codes.FailedPrecondition, "PRECONDITION_FAILED"},
+		{domain.ErrFeedTokenNotFound, codes.NotFound, "FEED_NOT_FOUND"},
+		{application.ErrForbidden, codes.PermissionDenied, "FORBIDDEN"},
+		{errors.New("db is down"), codes.Internal, "INTERNAL_ERROR"},
+	}
+
+	for _, tt := range tests {
+		err := toStatus(ctx, tt.err)
+		require.Equal(t, tt.code, status.Code(err), tt.err)
+		require.Equal(t, tt.reason, reasonOf(t, err))
+	}
+
+	// детали внутренних ошибок не раскрываются
+	require.NotContains(t, status.Convert(toStatus(ctx, errors.New("db is down"))).Message(), "db")
+	require.Equal(t, codes.Canceled, status.Code(toStatus(ctx, context.Canceled)))
+}
+
+// tokenVerifier токен - id пользователя
+type tokenVerifier struct{}
+
+func (tokenVerifier) Verify(_ context.Context, token string) (*auth.Principal, error) {
+	id, err := uuid.Parse(token)
+	if err != nil {
+		return nil, auth.ErrInvalidToken
+	}
+	return &auth.Principal{Subject: token, UserID: id}, nil
+}
+
+func TestGRPC_Auth(t *testing.T) {
+	_, conn := newAuthTestClient(t, auth.NewAuthenticator(
+		auth.Scheme{Name: auth.SchemeBearer, Verifier: tokenVerifier{}},
+		auth.Scheme{Name: auth.SchemeAPIKey, Verifier: tokenVerifier{}},
+	))
+	client := subsv1.NewSubscriptionServiceClient(conn)
+
+	withToken := func(token string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+	}
+
+	_, err := client.GetTotalCost(context.Background(), &subsv1.GetTotalCostRequest{})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = client.GetTotalCost(withToken("bad"), &subsv1.GetTotalCostRequest{})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// ключ API дает того же principal, что и bearer токен
+	apiKey := metadata.AppendToOutgoingContext(context.Background(), "authorization", "ApiKey "+uuid.NewString())
+	_, err = client.GetTotalCost(apiKey, &subsv1.GetTotalCostRequest{})
+	require.NoError(t, err)
+
+	// пробы работают без токена
+	_, err = healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+
+	alice := uuid.NewString()
+	created, err := client.CreateSubscription(withToken(alice), &subsv1.CreateSubscriptionRequest{
+		UserId: uuid.NewString(), ServiceName: "Okko", Price: 300, StartDate: "02-2025",
+	})
+	require.NoError(t, err)
+	require.Equal(t, alice, created.UserId)
+
+	_, err = client.GetSubscription(withToken(uuid.NewString()), &subsv1.GetSubscriptionRequest{Id: created.Id})
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestGRPC_Tenant(t *testing.T) {
+	_, conn := newAuthTestClient(t, auth.NewAuthenticator(
+		auth.Scheme{Name: auth.SchemeBearer, Verifier: tokenVerifier{}},
+	))
+	client := subsv1.NewSubscriptionServiceClient(conn)
+
+	call := func(tenantID string) error {
+		ctx := metadata.AppendToOutgoingContext(context.Background(),
+			"authorization", "Bearer "+uuid.NewString(),
+			"x-tenant-id", tenantID,
+		)
+		_, err := client.GetTotalCost(ctx, &subsv1.GetTotalCostRequest{})
+		return err
+	}
+
+	// токен без тенанта принадлежит default
+	require.NoError(t, call("default"))
+	require.Equal(t, codes.PermissionDenied, status.Code(call("acme")))
+	require.Equal(t, codes.InvalidArgument, status.Code(call("Acme; drop")))
+}
+
+func TestGRPC_RateLimit(t *testing.T) {
+	ipLimit := ratelimit.Limit{Requests: 6, Period: time.Minute}
+	clientLimit := ratelimit.Limit{Requests: 2, Period: time.Minute}
+	_, conn := newLimitedTestClient(t, auth.NewAuthenticator(
+		auth.Scheme{Name: auth.SchemeBearer, Verifier: tokenVerifier{}},
+	), &cg.RateLimits{
+		Store:  ratelimit.NewMemoryStore(time.Minute),
+		IP:     func() ratelimit.Limit { return ipLimit },
+		Client: func() ratelimit.Limit { return clientLimit },
+	})
+	client := subsv1.NewSubscriptionServiceClient(conn)
+
+	call := func(token string, opts ...grpc.CallOption) error {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+		_, err := client.GetTotalCost(ctx, &subsv1.GetTotalCostRequest{}, opts...)
+		return err
+	}
+
+	// у клиента свое ведро
+	alice, bob := uuid.NewString(), uuid.NewString()
+	require.NoError(t, call(alice))
+	require.NoError(t, call(alice))
+	var header metadata.MD
+	err := call(alice, grpc.Header(&header))
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+	require.NotEmpty(t, header.Get(cg.RetryAfterMetadataKey))
+	require.NoError(t, call(bob))
+
+	// вызовы с неверным токеном считаются по адресу до аутентификации
+	require.Equal(t, codes.Unauthenticated, status.Code(call("bad")))
+	require.Equal(t, codes.Unauthenticated, status.Code(call("bad")))
+	require.Equal(t, codes.ResourceExhausted, status.Code(call("bad")))
+
+	// пробы не лимитируются
+	for i := 0; i < 10; i++ {
+		_, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+		require.NoError(t, err)
+	}
+}
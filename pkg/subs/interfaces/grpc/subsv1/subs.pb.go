@@ -0,0 +1,1072 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.10
+// 	protoc        v5.29.3
+// source: subs/v1/subs.proto
+
+package subsv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Subscription struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	UserId        string                 `protobuf:"bytes,2,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	ServiceName   string                 `protobuf:"bytes,3,opt,name=service_name,json=serviceName,proto3" json:"service_name,omitempty"`
+	Price         int64                  `protobuf:"varint,4,opt,name=price,proto3" json:"price,omitempty"`
+	StartDate     string                 `protobuf:"bytes,5,opt,name=start_date,json=startDate,proto3" json:"start_date,omitempty"`
+	EndDate       *string                `protobuf:"bytes,6,opt,name=end_date,json=endDate,proto3,oneof" json:"end_date,omitempty"`
+	Version       int64                  `protobuf:"varint,7,opt,name=version,proto3" json:"version,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt     *timestamppb.Timestamp `protobuf:"bytes,9,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Subscription) Reset() {
+	*x = Subscription{}
+	mi := &file_subs_v1_subs_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Subscription) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Subscription) ProtoMessage() {}
+
+func (x *Subscription) ProtoReflect() protoreflect.Message {
+	mi := &file_subs_v1_subs_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Subscription.ProtoReflect.Descriptor instead.
+func (*Subscription) Descriptor() ([]byte, []int) {
+	return file_subs_v1_subs_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Subscription) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Subscription) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *Subscription) GetServiceName() string {
+	if x != nil {
+		return x.ServiceName
+	}
+	return ""
+}
+
+func (x *Subscription) GetPrice() int64 {
+	if x != nil {
+		return x.Price
+	}
+	return 0
+}
+
+func (x *Subscription) GetStartDate() string {
+	if x != nil {
+		return x.StartDate
+	}
+	return ""
+}
+
+func (x *Subscription) GetEndDate() string {
+	if x != nil && x.EndDate != nil {
+		return *x.EndDate
+	}
+	return ""
+}
+
+func (x *Subscription) GetVersion() int64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *Subscription) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Subscription) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+type CreateSubscriptionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	ServiceName   string                 `protobuf:"bytes,2,opt,name=service_name,json=serviceName,proto3" json:"service_name,omitempty"`
+	Price         int64                  `protobuf:"varint,3,opt,name=price,proto3" json:"price,omitempty"`
+	StartDate     string                 `protobuf:"bytes,4,opt,name=start_date,json=startDate,proto3" json:"start_date,omitempty"`
+	EndDate       *string                `protobuf:"bytes,5,opt,name=end_date,json=endDate,proto3,oneof" json:"end_date,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateSubscriptionRequest) Reset() {
+	*x = CreateSubscriptionRequest{}
+	mi := &file_subs_v1_subs_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateSubscriptionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateSubscriptionRequest) ProtoMessage() {}
+
+func (x *CreateSubscriptionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_subs_v1_subs_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateSubscriptionRequest.ProtoReflect.Descriptor instead.
+func (*CreateSubscriptionRequest) Descriptor() ([]byte, []int) {
+	return file_subs_v1_subs_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *CreateSubscriptionRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *CreateSubscriptionRequest) GetServiceName() string {
+	if x != nil {
+		return x.ServiceName
+	}
+	return ""
+}
+
+func (x *CreateSubscriptionRequest) GetPrice() int64 {
+	if x != nil {
+		return x.Price
+	}
+	return 0
+}
+
+func (x *CreateSubscriptionRequest) GetStartDate() string {
+	if x != nil {
+		return x.StartDate
+	}
+	return ""
+}
+
+func (x *CreateSubscriptionRequest) GetEndDate() string {
+	if x != nil && x.EndDate != nil {
+		return *x.EndDate
+	}
+	return ""
+}
+
+type GetSubscriptionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetSubscriptionRequest) Reset() {
+	*x = GetSubscriptionRequest{}
+	mi := &file_subs_v1_subs_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetSubscriptionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetSubscriptionRequest) ProtoMessage() {}
+
+func (x *GetSubscriptionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_subs_v1_subs_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetSubscriptionRequest.ProtoReflect.Descriptor instead.
+func (*GetSubscriptionRequest) Descriptor() ([]byte, []int) {
+	return file_subs_v1_subs_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GetSubscriptionRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type UpdateSubscriptionRequest struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Id        string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Price     *int64                 `protobuf:"varint,2,opt,name=price,proto3,oneof" json:"price,omitempty"`
+	StartDate *string                `protobuf:"bytes,3,opt,name=start_date,json=startDate,proto3,oneof" json:"start_date,omitempty"`
+	EndDate   *string                `protobuf:"bytes,4,opt,name=end_date,json=endDate,proto3,oneof" json:"end_date,omitempty"`
+	// clear_end_date делает подписку бессрочной
+	ClearEndDate bool `protobuf:"varint,5,opt,name=clear_end_date,json=clearEndDate,proto3" json:"clear_end_date,omitempty"`
+	// expected_version версия, которую видел клиент, аналог If-Match
+	ExpectedVersion *int64 `protobuf:"varint,6,opt,name=expected_version,json=expectedVersion,proto3,oneof" json:"expected_version,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *UpdateSubscriptionRequest) Reset() {
+	*x = UpdateSubscriptionRequest{}
+	mi := &file_subs_v1_subs_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateSubscriptionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateSubscriptionRequest) ProtoMessage() {}
+
+func (x *UpdateSubscriptionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_subs_v1_subs_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateSubscriptionRequest.ProtoReflect.Descriptor instead.
+func (*UpdateSubscriptionRequest) Descriptor() ([]byte, []int) {
+	return file_subs_v1_subs_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *UpdateSubscriptionRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *UpdateSubscriptionRequest) GetPrice() int64 {
+	if x != nil && x.Price != nil {
+		return *x.Price
+	}
+	return 0
+}
+
+func (x *UpdateSubscriptionRequest) GetStartDate() string {
+	if x != nil && x.StartDate != nil {
+		return *x.StartDate
+	}
+	return ""
+}
+
+func (x *UpdateSubscriptionRequest) GetEndDate() string {
+	if x != nil && x.EndDate != nil {
+		return *x.EndDate
+	}
+	return ""
+}
+
+func (x *UpdateSubscriptionRequest) GetClearEndDate() bool {
+	if x != nil {
+		return x.ClearEndDate
+	}
+	return false
+}
+
+func (x *UpdateSubscriptionRequest) GetExpectedVersion() int64 {
+	if x != nil && x.ExpectedVersion != nil {
+		return *x.ExpectedVersion
+	}
+	return 0
+}
+
+type DeleteSubscriptionRequest struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Id              string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	ExpectedVersion *int64                 `protobuf:"varint,2,opt,name=expected_version,json=expectedVersion,proto3,oneof" json:"expected_version,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *DeleteSubscriptionRequest) Reset() {
+	*x = DeleteSubscriptionRequest{}
+	mi := &file_subs_v1_subs_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteSubscriptionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteSubscriptionRequest) ProtoMessage() {}
+
+func (x *DeleteSubscriptionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_subs_v1_subs_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteSubscriptionRequest.ProtoReflect.Descriptor instead.
+func (*DeleteSubscriptionRequest) Descriptor() ([]byte, []int) {
+	return file_subs_v1_subs_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *DeleteSubscriptionRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *DeleteSubscriptionRequest) GetExpectedVersion() int64 {
+	if x != nil && x.ExpectedVersion != nil {
+		return *x.ExpectedVersion
+	}
+	return 0
+}
+
+// Period границы месяцев начала и окончания подписок
+type Period struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	StartFrom     *string                `protobuf:"bytes,1,opt,name=start_from,json=startFrom,proto3,oneof" json:"start_from,omitempty"`
+	StartTo       *string                `protobuf:"bytes,2,opt,name=start_to,json=startTo,proto3,oneof" json:"start_to,omitempty"`
+	EndFrom       *string                `protobuf:"bytes,3,opt,name=end_from,json=endFrom,proto3,oneof" json:"end_from,omitempty"`
+	EndTo         *string                `protobuf:"bytes,4,opt,name=end_to,json=endTo,proto3,oneof" json:"end_to,omitempty"`
+	NilEnd        *bool                  `protobuf:"varint,5,opt,name=nil_end,json=nilEnd,proto3,oneof" json:"nil_end,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Period) Reset() {
+	*x = Period{}
+	mi := &file_subs_v1_subs_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Period) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Period) ProtoMessage() {}
+
+func (x *Period) ProtoReflect() protoreflect.Message {
+	mi := &file_subs_v1_subs_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Period.ProtoReflect.Descriptor instead.
+func (*Period) Descriptor() ([]byte, []int) {
+	return file_subs_v1_subs_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Period) GetStartFrom() string {
+	if x != nil && x.StartFrom != nil {
+		return *x.StartFrom
+	}
+	return ""
+}
+
+func (x *Period) GetStartTo() string {
+	if x != nil && x.StartTo != nil {
+		return *x.StartTo
+	}
+	return ""
+}
+
+func (x *Period) GetEndFrom() string {
+	if x != nil && x.EndFrom != nil {
+		return *x.EndFrom
+	}
+	return ""
+}
+
+func (x *Period) GetEndTo() string {
+	if x != nil && x.EndTo != nil {
+		return *x.EndTo
+	}
+	return ""
+}
+
+func (x *Period) GetNilEnd() bool {
+	if x != nil && x.NilEnd != nil {
+		return *x.NilEnd
+	}
+	return false
+}
+
+// SubscriptionFilter фильтры, общие для списка, выгрузки и суммы
+type SubscriptionFilter struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	UserIds      []string               `protobuf:"bytes,1,rep,name=user_ids,json=userIds,proto3" json:"user_ids,omitempty"`
+	ServiceNames []string               `protobuf:"bytes,2,rep,name=service_names,json=serviceNames,proto3" json:"service_names,omitempty"`
+	// service_match режим сравнения service_names: exact, icase, fuzzy
+	ServiceMatch string `protobuf:"bytes,3,opt,name=service_match,json=serviceMatch,proto3" json:"service_match,omitempty"`
+	PriceMin     *int64 `protobuf:"varint,4,opt,name=price_min,json=priceMin,proto3,oneof" json:"price_min,omitempty"`
+	PriceMax     *int64 `protobuf:"varint,5,opt,name=price_max,json=priceMax,proto3,oneof" json:"price_max,omitempty"`
+	// created_from, created_to дни создания в формате "YYYY-MM-DD" включительно
+	CreatedFrom *string `protobuf:"bytes,6,opt,name=created_from,json=createdFrom,proto3,oneof" json:"created_from,omitempty"`
+	CreatedTo   *string `protobuf:"bytes,7,opt,name=created_to,json=createdTo,proto3,oneof" json:"created_to,omitempty"`
+	// expression RSQL выражение, как filter= в REST
+	Expression    string `protobuf:"bytes,8,opt,name=expression,proto3" json:"expression,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubscriptionFilter) Reset() {
+	*x = SubscriptionFilter{}
+	mi := &file_subs_v1_subs_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubscriptionFilter) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubscriptionFilter) ProtoMessage() {}
+
+func (x *SubscriptionFilter) ProtoReflect() protoreflect.Message {
+	mi := &file_subs_v1_subs_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubscriptionFilter.ProtoReflect.Descriptor instead.
+func (*SubscriptionFilter) Descriptor() ([]byte, []int) {
+	return file_subs_v1_subs_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *SubscriptionFilter) GetUserIds() []string {
+	if x != nil {
+		return x.UserIds
+	}
+	return nil
+}
+
+func (x *SubscriptionFilter) GetServiceNames() []string {
+	if x != nil {
+		return x.ServiceNames
+	}
+	return nil
+}
+
+func (x *SubscriptionFilter) GetServiceMatch() string {
+	if x != nil {
+		return x.ServiceMatch
+	}
+	return ""
+}
+
+func (x *SubscriptionFilter) GetPriceMin() int64 {
+	if x != nil && x.PriceMin != nil {
+		return *x.PriceMin
+	}
+	return 0
+}
+
+func (x *SubscriptionFilter) GetPriceMax() int64 {
+	if x != nil && x.PriceMax != nil {
+		return *x.PriceMax
+	}
+	return 0
+}
+
+func (x *SubscriptionFilter) GetCreatedFrom() string {
+	if x != nil && x.CreatedFrom != nil {
+		return *x.CreatedFrom
+	}
+	return ""
+}
+
+func (x *SubscriptionFilter) GetCreatedTo() string {
+	if x != nil && x.CreatedTo != nil {
+		return *x.CreatedTo
+	}
+	return ""
+}
+
+func (x *SubscriptionFilter) GetExpression() string {
+	if x != nil {
+		return x.Expression
+	}
+	return ""
+}
+
+type ListSubscriptionsRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Filter *SubscriptionFilter    `protobuf:"bytes,1,opt,name=filter,proto3" json:"filter,omitempty"`
+	Period *Period                `protobuf:"bytes,2,opt,name=period,proto3" json:"period,omitempty"`
+	// sort сортировка вида "-price,start_date"
+	Sort          string `protobuf:"bytes,3,opt,name=sort,proto3" json:"sort,omitempty"`
+	PageSize      int32  `protobuf:"varint,4,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	PageToken     string `protobuf:"bytes,5,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+	WithTotal     bool   `protobuf:"varint,6,opt,name=with_total,json=withTotal,proto3" json:"with_total,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListSubscriptionsRequest) Reset() {
+	*x = ListSubscriptionsRequest{}
+	mi := &file_subs_v1_subs_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListSubscriptionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListSubscriptionsRequest) ProtoMessage() {}
+
+func (x *ListSubscriptionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_subs_v1_subs_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListSubscriptionsRequest.ProtoReflect.Descriptor instead.
+func (*ListSubscriptionsRequest) Descriptor() ([]byte, []int) {
+	return file_subs_v1_subs_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ListSubscriptionsRequest) GetFilter() *SubscriptionFilter {
+	if x != nil {
+		return x.Filter
+	}
+	return nil
+}
+
+func (x *ListSubscriptionsRequest) GetPeriod() *Period {
+	if x != nil {
+		return x.Period
+	}
+	return nil
+}
+
+func (x *ListSubscriptionsRequest) GetSort() string {
+	if x != nil {
+		return x.Sort
+	}
+	return ""
+}
+
+func (x *ListSubscriptionsRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *ListSubscriptionsRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+func (x *ListSubscriptionsRequest) GetWithTotal() bool {
+	if x != nil {
+		return x.WithTotal
+	}
+	return false
+}
+
+type ListSubscriptionsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Subscriptions []*Subscription        `protobuf:"bytes,1,rep,name=subscriptions,proto3" json:"subscriptions,omitempty"`
+	NextPageToken string                 `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"`
+	// total только при with_total
+	Total         *int64 `protobuf:"varint,3,opt,name=total,proto3,oneof" json:"total,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListSubscriptionsResponse) Reset() {
+	*x = ListSubscriptionsResponse{}
+	mi := &file_subs_v1_subs_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListSubscriptionsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListSubscriptionsResponse) ProtoMessage() {}
+
+func (x *ListSubscriptionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_subs_v1_subs_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListSubscriptionsResponse.ProtoReflect.Descriptor instead.
+func (*ListSubscriptionsResponse) Descriptor() ([]byte, []int) {
+	return file_subs_v1_subs_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ListSubscriptionsResponse) GetSubscriptions() []*Subscription {
+	if x != nil {
+		return x.Subscriptions
+	}
+	return nil
+}
+
+func (x *ListSubscriptionsResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
+func (x *ListSubscriptionsResponse) GetTotal() int64 {
+	if x != nil && x.Total != nil {
+		return *x.Total
+	}
+	return 0
+}
+
+type GetTotalCostRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Filter        *SubscriptionFilter    `protobuf:"bytes,1,opt,name=filter,proto3" json:"filter,omitempty"`
+	Period        *Period                `protobuf:"bytes,2,opt,name=period,proto3" json:"period,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTotalCostRequest) Reset() {
+	*x = GetTotalCostRequest{}
+	mi := &file_subs_v1_subs_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTotalCostRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTotalCostRequest) ProtoMessage() {}
+
+func (x *GetTotalCostRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_subs_v1_subs_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTotalCostRequest.ProtoReflect.Descriptor instead.
+func (*GetTotalCostRequest) Descriptor() ([]byte, []int) {
+	return file_subs_v1_subs_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *GetTotalCostRequest) GetFilter() *SubscriptionFilter {
+	if x != nil {
+		return x.Filter
+	}
+	return nil
+}
+
+func (x *GetTotalCostRequest) GetPeriod() *Period {
+	if x != nil {
+		return x.Period
+	}
+	return nil
+}
+
+type GetTotalCostResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Total         int64                  `protobuf:"varint,1,opt,name=total,proto3" json:"total,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTotalCostResponse) Reset() {
+	*x = GetTotalCostResponse{}
+	mi := &file_subs_v1_subs_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTotalCostResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTotalCostResponse) ProtoMessage() {}
+
+func (x *GetTotalCostResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_subs_v1_subs_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTotalCostResponse.ProtoReflect.Descriptor instead.
+func (*GetTotalCostResponse) Descriptor() ([]byte, []int) {
+	return file_subs_v1_subs_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *GetTotalCostResponse) GetTotal() int64 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+type ExportSubscriptionsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Filter        *SubscriptionFilter    `protobuf:"bytes,1,opt,name=filter,proto3" json:"filter,omitempty"`
+	Period        *Period                `protobuf:"bytes,2,opt,name=period,proto3" json:"period,omitempty"`
+	Sort          string                 `protobuf:"bytes,3,opt,name=sort,proto3" json:"sort,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExportSubscriptionsRequest) Reset() {
+	*x = ExportSubscriptionsRequest{}
+	mi := &file_subs_v1_subs_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExportSubscriptionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExportSubscriptionsRequest) ProtoMessage() {}
+
+func (x *ExportSubscriptionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_subs_v1_subs_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExportSubscriptionsRequest.ProtoReflect.Descriptor instead.
+func (*ExportSubscriptionsRequest) Descriptor() ([]byte, []int) {
+	return file_subs_v1_subs_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ExportSubscriptionsRequest) GetFilter() *SubscriptionFilter {
+	if x != nil {
+		return x.Filter
+	}
+	return nil
+}
+
+func (x *ExportSubscriptionsRequest) GetPeriod() *Period {
+	if x != nil {
+		return x.Period
+	}
+	return nil
+}
+
+func (x *ExportSubscriptionsRequest) GetSort() string {
+	if x != nil {
+		return x.Sort
+	}
+	return ""
+}
+
+var File_subs_v1_subs_proto protoreflect.FileDescriptor
+
+const file_subs_v1_subs_proto_rawDesc = "" +
+	"\n" +
+	"\x12subs/v1/subs.proto\x12\asubs.v1\x1a\x1bgoogle/protobuf/empty.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\xcc\x02\n" +
+	"\fSubscription\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x17\n" +
+	"\auser_id\x18\x02 \x01(\tR\x06userId\x12!\n" +
+	"\fservice_name\x18\x03 \x01(\tR\vserviceName\x12\x14\n" +
+	"\x05price\x18\x04 \x01(\x03R\x05price\x12\x1d\n" +
+	"\n" +
+	"start_date\x18\x05 \x01(\tR\tstartDate\x12\x1e\n" +
+	"\bend_date\x18\x06 \x01(\tH\x00R\aendDate\x88\x01\x01\x12\x18\n" +
+	"\aversion\x18\a \x01(\x03R\aversion\x129\n" +
+	"\n" +
+	"created_at\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\t \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAtB\v\n" +
+	"\t_end_date\"\xb9\x01\n" +
+	"\x19CreateSubscriptionRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12!\n" +
+	"\fservice_name\x18\x02 \x01(\tR\vserviceName\x12\x14\n" +
+	"\x05price\x18\x03 \x01(\x03R\x05price\x12\x1d\n" +
+	"\n" +
+	"start_date\x18\x04 \x01(\tR\tstartDate\x12\x1e\n" +
+	"\bend_date\x18\x05 \x01(\tH\x00R\aendDate\x88\x01\x01B\v\n" +
+	"\t_end_date\"(\n" +
+	"\x16GetSubscriptionRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\x9b\x02\n" +
+	"\x19UpdateSubscriptionRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x19\n" +
+	"\x05price\x18\x02 \x01(\x03H\x00R\x05price\x88\x01\x01\x12\"\n" +
+	"\n" +
+	"start_date\x18\x03 \x01(\tH\x01R\tstartDate\x88\x01\x01\x12\x1e\n" +
+	"\bend_date\x18\x04 \x01(\tH\x02R\aendDate\x88\x01\x01\x12$\n" +
+	"\x0eclear_end_date\x18\x05 \x01(\bR\fclearEndDate\x12.\n" +
+	"\x10expected_version\x18\x06 \x01(\x03H\x03R\x0fexpectedVersion\x88\x01\x01B\b\n" +
+	"\x06_priceB\r\n" +
+	"\v_start_dateB\v\n" +
+	"\t_end_dateB\x13\n" +
+	"\x11_expected_version\"p\n" +
+	"\x19DeleteSubscriptionRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12.\n" +
+	"\x10expected_version\x18\x02 \x01(\x03H\x00R\x0fexpectedVersion\x88\x01\x01B\x13\n" +
+	"\x11_expected_version\"\xe6\x01\n" +
+	"\x06Period\x12\"\n" +
+	"\n" +
+	"start_from\x18\x01 \x01(\tH\x00R\tstartFrom\x88\x01\x01\x12\x1e\n" +
+	"\bstart_to\x18\x02 \x01(\tH\x01R\astartTo\x88\x01\x01\x12\x1e\n" +
+	"\bend_from\x18\x03 \x01(\tH\x02R\aendFrom\x88\x01\x01\x12\x1a\n" +
+	"\x06end_to\x18\x04 \x01(\tH\x03R\x05endTo\x88\x01\x01\x12\x1c\n" +
+	"\anil_end\x18\x05 \x01(\bH\x04R\x06nilEnd\x88\x01\x01B\r\n" +
+	"\v_start_fromB\v\n" +
+	"\t_start_toB\v\n" +
+	"\t_end_fromB\t\n" +
+	"\a_end_toB\n" +
+	"\n" +
+	"\b_nil_end\"\xe5\x02\n" +
+	"\x12SubscriptionFilter\x12\x19\n" +
+	"\buser_ids\x18\x01 \x03(\tR\auserIds\x12#\n" +
+	"\rservice_names\x18\x02 \x03(\tR\fserviceNames\x12#\n" +
+	"\rservice_match\x18\x03 \x01(\tR\fserviceMatch\x12 \n" +
+	"\tprice_min\x18\x04 \x01(\x03H\x00R\bpriceMin\x88\x01\x01\x12 \n" +
+	"\tprice_max\x18\x05 \x01(\x03H\x01R\bpriceMax\x88\x01\x01\x12&\n" +
+	"\fcreated_from\x18\x06 \x01(\tH\x02R\vcreatedFrom\x88\x01\x01\x12\"\n" +
+	"\n" +
+	"created_to\x18\a \x01(\tH\x03R\tcreatedTo\x88\x01\x01\x12\x1e\n" +
+	"\n" +
+	"expression\x18\b \x01(\tR\n" +
+	"expressionB\f\n" +
+	"\n" +
+	"_price_minB\f\n" +
+	"\n" +
+	"_price_maxB\x0f\n" +
+	"\r_created_fromB\r\n" +
+	"\v_created_to\"\xe7\x01\n" +
+	"\x18ListSubscriptionsRequest\x123\n" +
+	"\x06filter\x18\x01 \x01(\v2\x1b.subs.v1.SubscriptionFilterR\x06filter\x12'\n" +
+	"\x06period\x18\x02 \x01(\v2\x0f.subs.v1.PeriodR\x06period\x12\x12\n" +
+	"\x04sort\x18\x03 \x01(\tR\x04sort\x12\x1b\n" +
+	"\tpage_size\x18\x04 \x01(\x05R\bpageSize\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\x05 \x01(\tR\tpageToken\x12\x1d\n" +
+	"\n" +
+	"with_total\x18\x06 \x01(\bR\twithTotal\"\xa5\x01\n" +
+	"\x19ListSubscriptionsResponse\x12;\n" +
+	"\rsubscriptions\x18\x01 \x03(\v2\x15.subs.v1.SubscriptionR\rsubscriptions\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\x12\x19\n" +
+	"\x05total\x18\x03 \x01(\x03H\x00R\x05total\x88\x01\x01B\b\n" +
+	"\x06_total\"s\n" +
+	"\x13GetTotalCostRequest\x123\n" +
+	"\x06filter\x18\x01 \x01(\v2\x1b.subs.v1.SubscriptionFilterR\x06filter\x12'\n" +
+	"\x06period\x18\x02 \x01(\v2\x0f.subs.v1.PeriodR\x06period\",\n" +
+	"\x14GetTotalCostResponse\x12\x14\n" +
+	"\x05total\x18\x01 \x01(\x03R\x05total\"\x8e\x01\n" +
+	"\x1aExportSubscriptionsRequest\x123\n" +
+	"\x06filter\x18\x01 \x01(\v2\x1b.subs.v1.SubscriptionFilterR\x06filter\x12'\n" +
+	"\x06period\x18\x02 \x01(\v2\x0f.subs.v1.PeriodR\x06period\x12\x12\n" +
+	"\x04sort\x18\x03 \x01(\tR\x04sort2\xd2\x04\n" +
+	"\x13SubscriptionService\x12O\n" +
+	"\x12CreateSubscription\x12\".subs.v1.CreateSubscriptionRequest\x1a\x15.subs.v1.Subscription\x12I\n" +
+	"\x0fGetSubscription\x12\x1f.subs.v1.GetSubscriptionRequest\x1a\x15.subs.v1.Subscription\x12O\n" +
+	"\x12UpdateSubscription\x12\".subs.v1.UpdateSubscriptionRequest\x1a\x15.subs.v1.Subscription\x12P\n" +
+	"\x12DeleteSubscription\x12\".subs.v1.DeleteSubscriptionRequest\x1a\x16.google.protobuf.Empty\x12Z\n" +
+	"\x11ListSubscriptions\x12!.subs.v1.ListSubscriptionsRequest\x1a\".subs.v1.ListSubscriptionsResponse\x12K\n" +
+	"\fGetTotalCost\x12\x1c.subs.v1.GetTotalCostRequest\x1a\x1d.subs.v1.GetTotalCostResponse\x12S\n" +
+	"\x13ExportSubscriptions\x12#.subs.v1.ExportSubscriptionsRequest\x1a\x15.subs.v1.Subscription0\x01BHZFgithub.com/end1essrage/efmob-tz/pkg/subs/interfaces/grpc/subsv1;subsv1b\x06proto3"
+
+var (
+	file_subs_v1_subs_proto_rawDescOnce sync.Once
+	file_subs_v1_subs_proto_rawDescData []byte
+)
+
+func file_subs_v1_subs_proto_rawDescGZIP() []byte {
+	file_subs_v1_subs_proto_rawDescOnce.Do(func() {
+		file_subs_v1_subs_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_subs_v1_subs_proto_rawDesc), len(file_subs_v1_subs_proto_rawDesc)))
+	})
+	return file_subs_v1_subs_proto_rawDescData
+}
+
+var file_subs_v1_subs_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
+var file_subs_v1_subs_proto_goTypes = []any{
+	(*Subscription)(nil),               // 0: subs.v1.Subscription
+	(*CreateSubscriptionRequest)(nil),  // 1: subs.v1.CreateSubscriptionRequest
+	(*GetSubscriptionRequest)(nil),     // 2: subs.v1.GetSubscriptionRequest
+	(*UpdateSubscriptionRequest)(nil),  // 3: subs.v1.UpdateSubscriptionRequest
+	(*DeleteSubscriptionRequest)(nil),  // 4: subs.v1.DeleteSubscriptionRequest
+	(*Period)(nil),                     // 5: subs.v1.Period
+	(*SubscriptionFilter)(nil),         // 6: subs.v1.SubscriptionFilter
+	(*ListSubscriptionsRequest)(nil),   // 7: subs.v1.ListSubscriptionsRequest
+	(*ListSubscriptionsResponse)(nil),  // 8: subs.v1.ListSubscriptionsResponse
+	(*GetTotalCostRequest)(nil),        // 9: subs.v1.GetTotalCostRequest
+	(*GetTotalCostResponse)(nil),       // 10: subs.v1.GetTotalCostResponse
+	(*ExportSubscriptionsRequest)(nil), // 11: subs.v1.ExportSubscriptionsRequest
+	(*timestamppb.Timestamp)(nil),      // 12: google.protobuf.Timestamp
+	(*emptypb.Empty)(nil),              // 13: google.protobuf.Empty
+}
+var file_subs_v1_subs_proto_depIdxs = []int32{
+	12, // 0: subs.v1.Subscription.created_at:type_name -> google.protobuf.Timestamp
+	12, // 1: subs.v1.Subscription.updated_at:type_name -> google.protobuf.Timestamp
+	6,  // 2: subs.v1.ListSubscriptionsRequest.filter:type_name -> subs.v1.SubscriptionFilter
+	5,  // 3: subs.v1.ListSubscriptionsRequest.period:type_name -> subs.v1.Period
+	0,  // 4: subs.v1.ListSubscriptionsResponse.subscriptions:type_name -> subs.v1.Subscription
+	6,  // 5: subs.v1.GetTotalCostRequest.filter:type_name -> subs.v1.SubscriptionFilter
+	5,  // 6: subs.v1.GetTotalCostRequest.period:type_name -> subs.v1.Period
+	6,  // 7: subs.v1.ExportSubscriptionsRequest.filter:type_name -> subs.v1.SubscriptionFilter
+	5,  // 8: subs.v1.ExportSubscriptionsRequest.period:type_name -> subs.v1.Period
+	1,  // 9: subs.v1.SubscriptionService.CreateSubscription:input_type -> subs.v1.CreateSubscriptionRequest
+	2,  // 10: subs.v1.SubscriptionService.GetSubscription:input_type -> subs.v1.GetSubscriptionRequest
+	3,  // 11: subs.v1.SubscriptionService.UpdateSubscription:input_type -> subs.v1.UpdateSubscriptionRequest
+	4,  // 12: subs.v1.SubscriptionService.DeleteSubscription:input_type -> subs.v1.DeleteSubscriptionRequest
+	7,  // 13: subs.v1.SubscriptionService.ListSubscriptions:input_type -> subs.v1.ListSubscriptionsRequest
+	9,  // 14: subs.v1.SubscriptionService.GetTotalCost:input_type -> subs.v1.GetTotalCostRequest
+	11, // 15: subs.v1.SubscriptionService.ExportSubscriptions:input_type -> subs.v1.ExportSubscriptionsRequest
+	0,  // 16: subs.v1.SubscriptionService.CreateSubscription:output_type -> subs.v1.Subscription
+	0,  // 17: subs.v1.SubscriptionService.GetSubscription:output_type -> subs.v1.Subscription
+	0,  // 18: subs.v1.SubscriptionService.UpdateSubscription:output_type -> subs.v1.Subscription
+	13, // 19: subs.v1.SubscriptionService.DeleteSubscription:output_type -> google.protobuf.Empty
+	8,  // 20: subs.v1.SubscriptionService.ListSubscriptions:output_type -> subs.v1.ListSubscriptionsResponse
+	10, // 21: subs.v1.SubscriptionService.GetTotalCost:output_type -> subs.v1.GetTotalCostResponse
+	0,  // 22: subs.v1.SubscriptionService.ExportSubscriptions:output_type -> subs.v1.Subscription
+	16, // [16:23] is the sub-list for method output_type
+	9,  // [9:16] is the sub-list for method input_type
+	9,  // [9:9] is the sub-list for extension type_name
+	9,  // [9:9] is the sub-list for extension extendee
+	0,  // [0:9] is the sub-list for field type_name
+}
+
+func init() { file_subs_v1_subs_proto_init() }
+func file_subs_v1_subs_proto_init() {
+	if File_subs_v1_subs_proto != nil {
+		return
+	}
+	file_subs_v1_subs_proto_msgTypes[0].OneofWrappers = []any{}
+	file_subs_v1_subs_proto_msgTypes[1].OneofWrappers = []any{}
+	file_subs_v1_subs_proto_msgTypes[3].OneofWrappers = []any{}
+	file_subs_v1_subs_proto_msgTypes[4].OneofWrappers = []any{}
+	file_subs_v1_subs_proto_msgTypes[5].OneofWrappers = []any{}
+	file_subs_v1_subs_proto_msgTypes[6].OneofWrappers = []any{}
+	file_subs_v1_subs_proto_msgTypes[8].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_subs_v1_subs_proto_rawDesc), len(file_subs_v1_subs_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   12,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_subs_v1_subs_proto_goTypes,
+		DependencyIndexes: file_subs_v1_subs_proto_depIdxs,
+		MessageInfos:      file_subs_v1_subs_proto_msgTypes,
+	}.Build()
+	File_subs_v1_subs_proto = out.File
+	file_subs_v1_subs_proto_goTypes = nil
+	file_subs_v1_subs_proto_depIdxs = nil
+}
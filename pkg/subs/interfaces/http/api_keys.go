@@ -0,0 +1,159 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/interfaces/http/utils"
+	"github.com/end1essrage/efmob-tz/pkg/common/logger"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application/commands"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application/queries"
+)
+
+// IssueAPIKey godoc
+// @Summary Issue API key
+// @Description Issue a key for batch jobs and partner systems, sent as "Authorization: ApiKey <key>".
+// @Description The key is shown only in this response, the service stores its hash
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Param request body APIKeyCreateRequest true "Key owner and scopes"
+// @Success 201 {object} APIKeyIssuedResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Missing, invalid or expired bearer token or API key"
+// @Failure 403 {object} ErrorResponse "Not permitted for the caller's roles and scopes"
+// @Failure 413 {object} ErrorResponse "Request body too large"
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /admin/api-keys [post]
+func (h *SubsHandler) IssueAPIKey(w http.ResponseWriter, r *http.Request) {
+	log := logger.Logger().WithFields(logger.LogOptions{
+		Pkg:  "SubsHandler",
+		Func: "IssueAPIKey",
+		Ctx:  r.Context(),
+	})
+
+	var req APIKeyCreateRequest
+	if err := utils.DecodeJSONBody(w, r, &req); err != nil {
+		log.Warnf("ошибка парсинга тела запроса: %v", err)
+		return
+	}
+
+	key, secret, err := h.container.IssueAPIKeyHandler.Handle(r.Context(), commands.IssueAPIKeyCommand{
+		Name:      req.Name,
+		Owner:     req.Owner,
+		Scopes:    req.Scopes,
+		ExpiresAt: req.ExpiresAt,
+	})
+	if err != nil {
+		// оборачиваем ошибку
+		h.writeAppError(w, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	utils.WriteJSON(w, http.StatusCreated, APIKeyIssuedResponse{APIKey: mapAPIKey(key), Key: secret})
+}
+
+// ListAPIKeys godoc
+// @Summary List API keys
+// @Description All keys including revoked ones, newest first. Keys themselves are never returned
+// @Tags api-keys
+// @Produce json
+// @Success 200 {object} APIKeyListResponse
+// @Failure 401 {object} ErrorResponse "Missing, invalid or expired bearer token or API key"
+// @Failure 403 {object} ErrorResponse "Not permitted for the caller's roles and scopes"
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /admin/api-keys [get]
+func (h *SubsHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.container.ListAPIKeysHandler.Handle(r.Context(), queries.ListAPIKeysQuery{})
+	if err != nil {
+		// оборачиваем ошибку
+		h.writeAppError(w, err)
+		return
+	}
+
+	resp := APIKeyListResponse{Items: make([]APIKey, 0, len(keys))}
+	for _, k := range keys {
+		resp.Items = append(resp.Items, mapAPIKey(k))
+	}
+	utils.WriteJSON(w, http.StatusOK, resp)
+}
+
+// RotateAPIKey godoc
+// @Summary Rotate API key
+// @Description Issue a new key with the same owner and scopes. The previous key stops working immediately
+// @Tags api-keys
+// @Produce json
+// @Param id path string true "API key ID (UUID)"
+// @Success 200 {object} APIKeyIssuedResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Missing, invalid or expired bearer token or API key"
+// @Failure 403 {object} ErrorResponse "Not permitted for the caller's roles and scopes"
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse "The key is revoked"
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /admin/api-keys/{id}/rotate [post]
+func (h *SubsHandler) RotateAPIKey(w http.ResponseWriter, r *http.Request) {
+	log := logger.Logger().WithFields(logger.LogOptions{
+		Pkg:  "SubsHandler",
+		Func: "RotateAPIKey",
+		Ctx:  r.Context(),
+	})
+
+	id, err := extrudeUidFromQuery(w, r)
+	if err != nil {
+		log.Warnf("ошибка парсинга id: %v", err)
+		return
+	}
+
+	key, secret, err := h.container.RotateAPIKeyHandler.Handle(r.Context(), commands.RotateAPIKeyCommand{ID: id})
+	if err != nil {
+		// оборачиваем ошибку
+		h.writeAppError(w, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	utils.WriteJSON(w, http.StatusOK, APIKeyIssuedResponse{APIKey: mapAPIKey(key), Key: secret})
+}
+
+// RevokeAPIKey godoc
+// @Summary Revoke API key
+// @Description Revoke the key permanently, repeated revocation is a no-op
+// @Tags api-keys
+// @Param id path string true "API key ID (UUID)"
+// @Success 204
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Missing, invalid or expired bearer token or API key"
+// @Failure 403 {object} ErrorResponse "Not permitted for the caller's roles and scopes"
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /admin/api-keys/{id} [delete]
+func (h *SubsHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	log := logger.Logger().WithFields(logger.LogOptions{
+		Pkg:  "SubsHandler",
+		Func: "RevokeAPIKey",
+		Ctx:  r.Context(),
+	})
+
+	id, err := extrudeUidFromQuery(w, r)
+	if err != nil {
+		log.Warnf("ошибка парсинга id: %v", err)
+		return
+	}
+
+	if err := h.container.RevokeAPIKeyHandler.Handle(r.Context(), commands.RevokeAPIKeyCommand{ID: id}); err != nil {
+		// оборачиваем ошибку
+		h.writeAppError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
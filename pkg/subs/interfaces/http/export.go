@@ -0,0 +1,285 @@
+package http
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/subs/domain"
+)
+
+const (
+	exportFormatCSV    = "csv"
+	exportFormatNDJSON = "ndjson"
+	exportFormatXLSX   = "xlsx"
+
+	contentTypeXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+	// через сколько строк сбрасывать буфер клиенту
+	exportFlushEvery = 500
+)
+
+var exportContentTypes = map[string]string{
+	exportFormatCSV:    contentTypeCSV + "; charset=utf-8",
+	exportFormatNDJSON: contentTypeNDJSON,
+	exportFormatXLSX:   contentTypeXLSX,
+}
+
+var (
+	exportColumns         = []string{"id", "user_id", "service_name", "price", "start_date", "end_date", "version"}
+	exportComputedColumns = []string{"months_active", "total_paid"}
+)
+
+// exportWriter пишет строки выгрузки в конкретном формате
+type exportWriter interface {
+	WriteRow(row *ExportRow) error
+	Flush() error
+	Close() error
+}
+
+func newExportWriter(format string, w io.Writer, computed bool) (exportWriter, error) {
+	columns := exportColumns
+	if computed {
+		columns = append(append([]string{}, exportColumns...), exportComputedColumns...)
+	}
+
+	switch format {
+	case exportFormatCSV:
+		return newCSVExportWriter(w, columns)
+	case exportFormatNDJSON:
+		return newNDJSONExportWriter(w), nil
+	case exportFormatXLSX:
+		return newXLSXExportWriter(w, columns)
+	default:
+		return nil, fmt.Errorf("unknown export format %q", format)
+	}
+}
+
+func mapExportRow(record *domain.Subscription, computed bool, now time.Time) *ExportRow {
+	sub := mapSubscriptionFromDomain(record)
+	row := &ExportRow{
+		ID:          sub.ID,
+		UserID:      sub.UserID,
+		ServiceName: sub.ServiceName,
+		Price:       sub.Price,
+		StartDate:   sub.StartDate,
+		EndDate:     sub.EndDate,
+		Version:     sub.Version,
+	}
+
+	if computed {
+		months := record.MonthsActive(now)
+		paid := record.TotalPaid(now)
+		row.MonthsActive = &months
+		row.TotalPaid = &paid
+	}
+
+	return row
+}
+
+// values значения строки в порядке колонок, string или int
+func (r *ExportRow) values() []interface{} {
+	values := []interface{}{r.ID.String(), r.UserID.String(), r.ServiceName, r.Price, r.StartDate, r.EndDate, r.Version}
+	if r.MonthsActive != nil && r.TotalPaid != nil {
+		values = append(values, *r.MonthsActive, *r.TotalPaid)
+	}
+	return values
+}
+
+// csvExportWriter
+
+type csvExportWriter struct {
+	w *csv.Writer
+}
+
+func newCSVExportWriter(w io.Writer, columns []string) (*csvExportWriter, error) {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(columns); err != nil {
+		return nil, err
+	}
+	return &csvExportWriter{w: cw}, nil
+}
+
+func (e *csvExportWriter) WriteRow(row *ExportRow) error {
+	values := row.values()
+	record := make([]string, len(values))
+	for i, v := range values {
+		switch val := v.(type) {
+		case string:
+			record[i] = csvSafe(val)
+		default:
+			record[i] = fmt.Sprint(val)
+		}
+	}
+	return e.w.Write(record)
+}
+
+// csvSafe экранирует строку, которую табличный редактор принял бы за формулу:
+// название сервиса задает пользователь, а выгрузку открывают в Excel
+func csvSafe(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func (e *csvExportWriter) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvExportWriter) Close() error {
+	return e.Flush()
+}
+
+// ndjsonExportWriter
+
+type ndjsonExportWriter struct {
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+func newNDJSONExportWriter(w io.Writer) *ndjsonExportWriter {
+	buf := bufio.NewWriter(w)
+	return &ndjsonExportWriter{buf: buf, enc: json.NewEncoder(buf)}
+}
+
+func (e *ndjsonExportWriter) WriteRow(row *ExportRow) error {
+	return e.enc.Encode(row)
+}
+
+func (e *ndjsonExportWriter) Flush() error {
+	return e.buf.Flush()
+}
+
+func (e *ndjsonExportWriter) Close() error {
+	return e.Flush()
+}
+
+// xlsxExportWriter пишет минимальную книгу с одним листом прямо в ответ:
+// служебные части пишутся сразу, лист - построчно, без буферизации всей книги
+type xlsxExportWriter struct {
+	zw    *zip.Writer
+	sheet *bufio.Writer
+}
+
+const (
+	xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`
+
+	xlsxRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`
+
+	xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="subscriptions" sheetId="1" r:id="rId1"/></sheets>` +
+		`</workbook>`
+
+	xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`
+
+	xlsxSheetStart = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+
+	xlsxSheetEnd = `</sheetData></worksheet>`
+)
+
+func newXLSXExportWriter(w io.Writer, columns []string) (*xlsxExportWriter, error) {
+	zw := zip.NewWriter(w)
+
+	parts := []struct{ name, body string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRels},
+		{"xl/workbook.xml", xlsxWorkbook},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+	}
+	for _, part := range parts {
+		f, err := zw.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, part.body); err != nil {
+			return nil, err
+		}
+	}
+
+	// лист должен быть последней частью - zip пишет части последовательно
+	f, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+
+	e := &xlsxExportWriter{zw: zw, sheet: bufio.NewWriter(f)}
+	if _, err := e.sheet.WriteString(xlsxSheetStart); err != nil {
+		return nil, err
+	}
+
+	header := make([]interface{}, len(columns))
+	for i, c := range columns {
+		header[i] = c
+	}
+	if err := e.writeValues(header); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+func (e *xlsxExportWriter) WriteRow(row *ExportRow) error {
+	return e.writeValues(row.values())
+}
+
+func (e *xlsxExportWriter) writeValues(values []interface{}) error {
+	var b strings.Builder
+	b.WriteString("<row>")
+	for _, v := range values {
+		switch val := v.(type) {
+		case int:
+			b.WriteString("<c><v>" + strconv.Itoa(val) + "</v></c>")
+		default:
+			// строки всегда inlineStr - значение с = не станет формулой, экранировать как в csv не нужно
+			b.WriteString(`<c t="inlineStr"><is><t>`)
+			if err := xml.EscapeText(&b, []byte(fmt.Sprint(val))); err != nil {
+				return err
+			}
+			b.WriteString("</t></is></c>")
+		}
+	}
+	b.WriteString("</row>")
+
+	_, err := e.sheet.WriteString(b.String())
+	return err
+}
+
+func (e *xlsxExportWriter) Flush() error {
+	if err := e.sheet.Flush(); err != nil {
+		return err
+	}
+	return e.zw.Flush()
+}
+
+func (e *xlsxExportWriter) Close() error {
+	if _, err := e.sheet.WriteString(xlsxSheetEnd); err != nil {
+		return err
+	}
+	if err := e.sheet.Flush(); err != nil {
+		return err
+	}
+	return e.zw.Close()
+}
@@ -0,0 +1,131 @@
+package http
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/subs/domain"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func testExportRow(t *testing.T, computed bool) *ExportRow {
+	t.Helper()
+
+	start := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+	sub, err := domain.NewSubscription(uuid.New(), uuid.New(), `Netflix "Premium" <4K>`, 400, start, nil)
+	require.NoError(t, err)
+
+	return mapExportRow(sub, computed, time.Date(2025, 9, 15, 0, 0, 0, 0, time.UTC))
+}
+
+func writeExport(t *testing.T, format string, computed bool, rows ...*ExportRow) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	ew, err := newExportWriter(format, &buf, computed)
+	require.NoError(t, err)
+	for _, row := range rows {
+		require.NoError(t, ew.WriteRow(row))
+	}
+	require.NoError(t, ew.Close())
+
+	return buf.Bytes()
+}
+
+func TestExportWriter_CSV(t *testing.T) {
+	row := testExportRow(t, true)
+
+	out := string(writeExport(t, exportFormatCSV, true, row))
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+
+	require.Len(t, lines, 2)
+	require.Equal(t, "id,user_id,service_name,price,start_date,end_date,version,months_active,total_paid", lines[0])
+	require.Contains(t, lines[1], `"Netflix ""Premium"" <4K>",400,07-2025,,1,3,1200`)
+}
+
+func TestExportWriter_NDJSON(t *testing.T) {
+	out := writeExport(t, exportFormatNDJSON, false, testExportRow(t, false), testExportRow(t, false))
+
+	lines := bytes.Split(bytes.TrimSpace(out), []byte("\n"))
+	require.Len(t, lines, 2)
+
+	var got map[string]interface{}
+	require.NoError(t, json.Unmarshal(lines[0], &got))
+	require.Equal(t, "07-2025", got["start_date"])
+	require.NotContains(t, got, "months_active")
+}
+
+func TestExportWriter_XLSX(t *testing.T) {
+	out := writeExport(t, exportFormatXLSX, true, testExportRow(t, true))
+
+	zr, err := zip.NewReader(bytes.NewReader(out), int64(len(out)))
+	require.NoError(t, err)
+
+	var sheet string
+	names := map[string]bool{}
+	for _, f := range zr.File {
+		names[f.Name] = true
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			rc, err := f.Open()
+			require.NoError(t, err)
+			data, err := io.ReadAll(rc)
+			require.NoError(t, err)
+			sheet = string(data)
+		}
+	}
+
+	require.True(t, names["[Content_Types].xml"])
+	require.True(t, names["xl/workbook.xml"])
+	require.Contains(t, sheet, "<t>total_paid</t>")
+	require.Contains(t, sheet, "<t>Netflix &#34;Premium&#34; &lt;4K&gt;</t>")
+	require.Contains(t, sheet, "<c><v>1200</v></c>")
+	require.True(t, strings.HasSuffix(sheet, "</sheetData></worksheet>"))
+}
+
+func TestExportWriter_FormulaInjection(t *testing.T) {
+	names := []string{`=HYPERLINK("http://evil","x")`, "+1", "-1+2", "@SUM(A1)", "\tcmd", "\rcmd"}
+	rows := make([]*ExportRow, len(names))
+	for i, name := range names {
+		rows[i] = testExportRow(t, false)
+		rows[i].ServiceName = name
+	}
+
+	// csv - ячейка начинается с апострофа, редактор покажет ее как текст
+	records, err := csv.NewReader(bytes.NewReader(writeExport(t, exportFormatCSV, false, rows...))).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, len(names)+1)
+	for i, name := range names {
+		require.Equal(t, "'"+name, records[i+1][2])
+	}
+
+	// обычное значение не меняется
+	require.Equal(t, "Netflix", csvSafe("Netflix"))
+
+	// xlsx - строки пишутся inlineStr без формул
+	out := writeExport(t, exportFormatXLSX, false, rows[0])
+	zr, err := zip.NewReader(bytes.NewReader(out), int64(len(out)))
+	require.NoError(t, err)
+	for _, f := range zr.File {
+		if f.Name != "xl/worksheets/sheet1.xml" {
+			continue
+		}
+		rc, err := f.Open()
+		require.NoError(t, err)
+		data, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		require.Contains(t, string(data), `<c t="inlineStr"><is><t>=HYPERLINK(&#34;http://evil&#34;,&#34;x&#34;)</t></is></c>`)
+		require.NotContains(t, string(data), "<f>")
+	}
+}
+
+func TestExportWriter_UnknownFormat(t *testing.T) {
+	_, err := newExportWriter("pdf", io.Discard, false)
+	require.Error(t, err)
+}
@@ -1,14 +1,19 @@
 package http
 
 import (
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/end1essrage/efmob-tz/pkg/common/interfaces/http/utils"
 	"github.com/end1essrage/efmob-tz/pkg/common/logger"
 	"github.com/end1essrage/efmob-tz/pkg/common/persistance"
+	"github.com/end1essrage/efmob-tz/pkg/common/tenant"
 	"github.com/end1essrage/efmob-tz/pkg/subs/application/commands"
 	"github.com/end1essrage/efmob-tz/pkg/subs/application/queries"
+	"github.com/end1essrage/efmob-tz/pkg/subs/domain"
 )
 
 // CreateSubscription godoc
@@ -18,10 +23,18 @@ import (
 // @Accept json
 // @Produce json
 // @Param request body SubscriptionCreateRequest true "Subscription data"
+// @Param Idempotency-Key header string false "Key for safe retries, repeated requests get the stored response"
 // @Success 201 {object} Subscription
+// @Header 201 {string} ETag "Subscription version"
 // @Failure 400 {object} ErrorResponse
-// @Failure 409 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Missing, invalid or expired bearer token or API key"
+// @Failure 403 {object} ErrorResponse "Not permitted for the caller's roles and scopes"
+// @Failure 409 {object} ErrorResponse "Concurrent modification or request with the same Idempotency-Key in progress"
+// @Failure 422 {object} ErrorResponse "Idempotency-Key reused with a different request"
+// @Failure 413 {object} ErrorResponse "Request body too large"
 // @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /subscriptions [post]
 func (h *SubsHandler) CreateSubscription(w http.ResponseWriter, r *http.Request) {
 	log := logger.Logger().WithFields(logger.LogOptions{
@@ -61,7 +74,95 @@ func (h *SubsHandler) CreateSubscription(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	utils.WriteJSON(w, http.StatusCreated, mapSubscriptionFromDomain(record))
+	writeSubscription(w, http.StatusCreated, record)
+}
+
+// ImportSubscriptions godoc
+// @Summary Import subscriptions
+// @Description Bulk create subscriptions from CSV (header: user_id,service_name,price,start_date,end_date)
+// @Description or NDJSON (one SubscriptionCreateRequest per line). The file is processed as a stream.
+// @Tags subs
+// @Accept text/csv,application/x-ndjson
+// @Produce json
+// @Param mode query string false "atomic (default) - all or nothing, best_effort - skip invalid rows"
+// @Success 200 {object} ImportResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Missing, invalid or expired bearer token or API key"
+// @Failure 403 {object} ErrorResponse "Not permitted for the caller's roles and scopes"
+// @Failure 413 {object} ErrorResponse "Import file exceeds 32 MiB"
+// @Failure 415 {object} ErrorResponse
+// @Failure 422 {object} ImportResponse "Atomic import rejected, nothing created"
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /subscriptions/import [post]
+func (h *SubsHandler) ImportSubscriptions(w http.ResponseWriter, r *http.Request) {
+	log := logger.Logger().WithFields(logger.LogOptions{
+		Pkg:  "SubsHandler",
+		Func: "ImportSubscriptions",
+		Ctx:  r.Context(),
+	})
+
+	var req ImportRequest
+	if !utils.ParseQuery(w, r, &req) {
+		log.Warnf("ошибка парсинга query")
+		return
+	}
+
+	mode := commands.ImportAtomic
+	if req.Mode != nil {
+		mode = commands.ImportMode(*req.Mode)
+	}
+
+	body := http.MaxBytesReader(w, r.Body, maxImportBodySize)
+
+	var source commands.ImportSource
+	switch mediaType := patchMediaType(r); mediaType {
+	case contentTypeCSV:
+		csvSource, err := newCSVImportSource(body)
+		if err != nil {
+			log.Warnf("ошибка чтения csv: %v", err)
+			if writeImportTooLarge(w, err) {
+				return
+			}
+			utils.WriteJSON(w, http.StatusBadRequest, ErrorResponse{
+				Error: err.Error(),
+				Code:  "VALIDATION_ERROR",
+			})
+			return
+		}
+		source = csvSource
+	case contentTypeNDJSON, "application/ndjson":
+		source = newNDJSONImportSource(body)
+	default:
+		log.Warnf("неподдерживаемый Content-Type: %s", mediaType)
+		utils.WriteJSON(w, http.StatusUnsupportedMediaType, ErrorResponse{
+			Error: "unsupported content type " + mediaType,
+			Code:  "UNSUPPORTED_MEDIA_TYPE",
+		})
+		return
+	}
+
+	result, err := h.container.ImportSubscriptionsHandler.Handle(r.Context(), commands.ImportSubscriptionsCommand{
+		Mode:   mode,
+		Source: source,
+	})
+	if err != nil {
+		if writeImportTooLarge(w, err) {
+			log.Warnf("файл импорта больше %d байт", maxImportBodySize)
+			return
+		}
+		// оборачиваем ошибку
+		h.writeAppError(w, err)
+		return
+	}
+
+	status := http.StatusOK
+	if mode == commands.ImportAtomic && result.Failed > 0 {
+		status = http.StatusUnprocessableEntity
+	}
+
+	utils.WriteJSON(w, status, mapImportResult(mode, result))
 }
 
 // GetSubscription godoc
@@ -70,9 +171,16 @@ func (h *SubsHandler) CreateSubscription(w http.ResponseWriter, r *http.Request)
 // @Tags subs
 // @Produce json
 // @Param id path string true "Subscription ID (UUID)"
+// @Param If-None-Match header string false "ETag from previous response"
 // @Success 200 {object} Subscription
+// @Header 200 {string} ETag "Subscription version"
+// @Success 304 "Not Modified"
+// @Failure 401 {object} ErrorResponse "Missing, invalid or expired bearer token or API key"
+// @Failure 403 {object} ErrorResponse "Not permitted for the caller's roles and scopes"
 // @Failure 404 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /subscriptions/{id} [get]
 func (h *SubsHandler) GetSubscription(w http.ResponseWriter, r *http.Request) {
 	log := logger.Logger().WithFields(logger.LogOptions{
@@ -94,22 +202,43 @@ func (h *SubsHandler) GetSubscription(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	etag := formatETag(record.Version())
+	w.Header().Set("ETag", etag)
+
+	if matchesIfNoneMatch(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
 	utils.WriteJSON(w, http.StatusOK, mapSubscriptionFromDomain(record))
 }
 
 // UpdateSubscription godoc
 // @Summary Update subscription
-// @Description Update subscription by ID
+// @Description Update subscription by ID.
+// @Description Supports application/json (SubscriptionUpdateRequest), application/merge-patch+json (RFC 7396)
+// @Description and application/json-patch+json (RFC 6902) applied to the Subscription representation.
+// @Description Only price, start_date and end_date can be changed.
 // @Tags subs
-// @Accept json
+// @Accept json,application/merge-patch+json,application/json-patch+json
 // @Produce json
 // @Param id path string true "Subscription ID"
 // @Param request body SubscriptionUpdateRequest true "Updated data"
-// @Success 202 "Accepted"
+// @Param If-Match header string false "Single ETag from GET, update only if version matches. A list of ETags is rejected with 400"
+// @Success 200 {object} Subscription
+// @Header 200 {string} ETag "New subscription version"
+// @Success 204 "Nothing to update"
 // @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Missing, invalid or expired bearer token or API key"
+// @Failure 403 {object} ErrorResponse "Not permitted for the caller's roles and scopes"
 // @Failure 404 {object} ErrorResponse
-// @Failure 409 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse "Concurrent modification or failed JSON Patch test operation"
+// @Failure 412 {object} ErrorResponse "Version does not match If-Match"
+// @Failure 413 {object} ErrorResponse "Request body too large"
+// @Failure 415 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /subscriptions/{id} [patch]
 func (h *SubsHandler) UpdateSubscription(w http.ResponseWriter, r *http.Request) {
 	log := logger.Logger().WithFields(logger.LogOptions{
@@ -124,6 +253,26 @@ func (h *SubsHandler) UpdateSubscription(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	expectedVersion, ok := parseIfMatch(w, r)
+	if !ok {
+		log.Warn("ошибка парсинга If-Match")
+		return
+	}
+
+	switch mediaType := patchMediaType(r); mediaType {
+	case contentTypeMergePatch, contentTypeJSONPatch:
+		h.patchSubscription(w, r, uid, expectedVersion, mediaType)
+		return
+	case contentTypeJSON:
+	default:
+		log.Warnf("неподдерживаемый Content-Type: %s", mediaType)
+		utils.WriteJSON(w, http.StatusUnsupportedMediaType, ErrorResponse{
+			Error: "unsupported content type " + mediaType,
+			Code:  "UNSUPPORTED_MEDIA_TYPE",
+		})
+		return
+	}
+
 	var req SubscriptionUpdateRequest
 	if err := utils.DecodeJSONBody(w, r, &req); err != nil {
 		log.Warnf("ошибка парсинга тела запроса: %v", err)
@@ -155,27 +304,29 @@ func (h *SubsHandler) UpdateSubscription(w http.ResponseWriter, r *http.Request)
 			}
 			endDate = &parsedTime
 		}
-	} else {
-		log.Warn("бесполезный update")
+	} else if req.Price == nil && sD == nil {
 		// если не было остальных полей
-		if req.Price == nil && sD == nil {
-			utils.WriteJSON(w, http.StatusNoContent, nil)
-		}
+		log.Warn("бесполезный update")
+		utils.WriteJSON(w, http.StatusNoContent, nil)
+		return
 	}
 
-	if err := h.container.UpdateSubscriptionHandler.Handle(r.Context(), commands.UpdateSubscriptionCommand{
+	record, err := h.container.UpdateSubscriptionHandler.Handle(r.Context(), commands.UpdateSubscriptionCommand{
 		ID:             uid,
 		Price:          req.Price,
 		StartDate:      sD,
 		EndDate:        endDate,
 		SetEndDateNull: setEndDateNull,
-	}); err != nil {
+
+		ExpectedVersion: expectedVersion,
+	})
+	if err != nil {
 		// оборачиваем ошибку
 		h.writeAppError(w, err)
 		return
 	}
 
-	utils.WriteJSON(w, http.StatusAccepted, nil)
+	writeSubscription(w, http.StatusOK, record)
 }
 
 // DeleteSubscription godoc
@@ -184,10 +335,17 @@ func (h *SubsHandler) UpdateSubscription(w http.ResponseWriter, r *http.Request)
 // @Tags subs
 // @Produce json
 // @Param id path string true "Subscription ID"
+// @Param If-Match header string false "Single ETag from GET, delete only if version matches. A list of ETags is rejected with 400"
 // @Success 204 "No Content"
+// @Failure 400 {object} ErrorResponse "Several ETags in If-Match"
+// @Failure 401 {object} ErrorResponse "Missing, invalid or expired bearer token or API key"
+// @Failure 403 {object} ErrorResponse "Not permitted for the caller's roles and scopes"
 // @Failure 404 {object} ErrorResponse
 // @Failure 409 {object} ErrorResponse
+// @Failure 412 {object} ErrorResponse "Version does not match If-Match"
 // @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /subscriptions/{id} [delete]
 func (h *SubsHandler) DeleteSubscription(w http.ResponseWriter, r *http.Request) {
 	log := logger.Logger().WithFields(logger.LogOptions{
@@ -202,7 +360,16 @@ func (h *SubsHandler) DeleteSubscription(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if err := h.container.DeleteSubscriptionHandler.Handle(r.Context(), commands.DeleteSubscriptionCommand{ID: uid}); err != nil {
+	expectedVersion, ok := parseIfMatch(w, r)
+	if !ok {
+		log.Warn("ошибка парсинга If-Match")
+		return
+	}
+
+	if err := h.container.DeleteSubscriptionHandler.Handle(r.Context(), commands.DeleteSubscriptionCommand{
+		ID:              uid,
+		ExpectedVersion: expectedVersion,
+	}); err != nil {
 		// оборачиваем ошибку
 		h.writeAppError(w, err)
 		return
@@ -216,20 +383,36 @@ func (h *SubsHandler) DeleteSubscription(w http.ResponseWriter, r *http.Request)
 // @Description List subscriptions with filters
 // @Tags subs
 // @Produce json
-// @Param user_id query string false "User ID (UUID)"
-// @Param service_name query string false "Service name"
+// @Param user_id query []string false "User IDs (UUID), repeated or comma separated" collectionFormat(csv)
+// @Param service_name query []string false "Service names, repeated or comma separated" collectionFormat(csv)
+// @Param service_match query string false "Service name match: exact (default), icase or fuzzy (substring or trigram similarity, min 3 chars)"
+// @Param price_min query int false "Minimal price"
+// @Param price_max query int false "Maximal price"
+// @Param created_from query string false "Created from (YYYY-MM-DD)"
+// @Param created_to query string false "Created to, inclusive (YYYY-MM-DD)"
+// @Param filter query string false "RSQL expression, e.g. 'price=gt=500;(service_name==Netflix,service_name==Spotify)'. Fields: user_id, service_name, price, start_date, end_date (MM-YYYY), created_at (YYYY-MM-DD)"
 // @Param start_from query string false "Start period from (MM-YYYY)"
 // @Param start_to query string false "Start period to  (MM-YYYY)"
 // @Param end_from query string false "End period from (MM-YYYY)"
 // @Param end_to query string false "End period to  (MM-YYYY)"
 // @Param nil_end query bool false "includes items with empty end_date(by default - true: if end_to != nil - false)"
 // @Param page query int false "Page num can use 0 or 1 for first"
-// @Param page_size query int false "Page Size / Limit"
+// @Param page_size query int false "Page Size / Limit, max 1000"
+// @Param cursor query string false "Opaque cursor from X-Next-Cursor, page is ignored when set"
+// @Param with_total query bool false "Return total count in X-Total-Count"
+// @Param sort query string false "Multi-field sort, e.g. '-price,start_date'. Fields: start_date, end_date, price, service_name, created_at. Overrides order_by"
 // @Param order_by query string false "Sorting field name"
 // @Param direction query string false "Sorting direction use 'asc'(default) or 'desc'"
 // @Success 200 {array} Subscription
+// @Header 200 {string} X-Next-Cursor "Cursor of the next page, absent on the last page"
+// @Header 200 {string} Link "Next page URL with rel=next"
+// @Header 200 {integer} X-Total-Count "Total count, only with with_total=true"
 // @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Missing, invalid or expired bearer token or API key"
+// @Failure 403 {object} ErrorResponse "Not permitted for the caller's roles and scopes"
 // @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /subscriptions [get]
 func (h *SubsHandler) ListSubscriptions(w http.ResponseWriter, r *http.Request) {
 	log := logger.Logger().WithFields(logger.LogOptions{
@@ -245,26 +428,9 @@ func (h *SubsHandler) ListSubscriptions(w http.ResponseWriter, r *http.Request)
 	}
 
 	//собираем квери
-	// Парсим optional dates
-	sfD, err := parseOptionalDate(w, req.StartFrom)
-	if err != nil {
-		log.Warnf("ошибка парсинга опциональной даты: %v", err)
-		return
-	}
-	stD, err := parseOptionalDate(w, req.StartTo)
-	if err != nil {
-		log.Warnf("ошибка парсинга опциональной даты: %v", err)
-		return
-	}
-
-	efD, err := parseOptionalDate(w, req.EndFrom)
+	filter, err := parseSubscriptionFilter(w, req.SubscriptionFilterRequest)
 	if err != nil {
-		log.Warnf("ошибка парсинга опциональной даты: %v", err)
-		return
-	}
-	etD, err := parseOptionalDate(w, req.EndTo)
-	if err != nil {
-		log.Warnf("ошибка парсинга опциональной даты: %v", err)
+		log.Warnf("ошибка парсинга фильтров: %v", err)
 		return
 	}
 
@@ -277,30 +443,31 @@ func (h *SubsHandler) ListSubscriptions(w http.ResponseWriter, r *http.Request)
 		page := *req.Page
 		pagination.Offset = pagination.Limit * (page - 1)
 	}
-
-	// собираем сортировку
-	var sorting *persistance.Sorting
-	if req.OrderBy != nil {
-		sorting = &persistance.Sorting{OrderBy: *req.OrderBy}
-		if req.Direction != nil {
-			sorting.Direction = persistance.SortingDirection(*req.Direction)
-		} else {
-			sorting.Direction = persistance.Descending
+	if req.Cursor != nil && *req.Cursor != "" {
+		cursor, err := persistance.DecodeCursor(*req.Cursor)
+		if err != nil {
+			log.Warnf("ошибка парсинга курсора: %v", err)
+			utils.WriteJSON(w, http.StatusBadRequest, ErrorResponse{
+				Error: "invalid cursor",
+				Code:  "INVALID_QUERY",
+			})
+			return
 		}
+		pagination.After = cursor
 	}
 
 	// исполняем квери
-	records, err := h.container.ListSubscriptionsHandler.Handle(r.Context(), queries.ListSubscriptionsQuery{
-		UserID:      req.UserID,
-		ServiceName: req.ServiceName,
-		StartFrom:   sfD,
-		StartTo:     stD,
-		EndFrom:     efD,
-		EndTo:       etD,
-		WithNilEnd:  req.NilEnd,
+	result, err := h.container.ListSubscriptionsHandler.Handle(r.Context(), queries.ListSubscriptionsQuery{
+		StartFrom:  filter.StartFrom,
+		StartTo:    filter.StartTo,
+		EndFrom:    filter.EndFrom,
+		EndTo:      filter.EndTo,
+		WithNilEnd: filter.WithNilEnd,
+		Filters:    filter.Filters,
 
 		Pagination: pagination,
-		Sorting:    sorting,
+		Sorting:    filter.Sorting,
+		WithTotal:  req.WithTotal != nil && *req.WithTotal,
 	})
 	if err != nil {
 		// оборачиваем ошибку
@@ -308,9 +475,18 @@ func (h *SubsHandler) ListSubscriptions(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if result.NextCursor != nil {
+		next := result.NextCursor.Encode()
+		w.Header().Set("X-Next-Cursor", next)
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, nextPageURL(r, next)))
+	}
+	if result.Total != nil {
+		w.Header().Set("X-Total-Count", strconv.Itoa(*result.Total))
+	}
+
 	// маппим ответ
-	resp := make([]*Subscription, len(records))
-	for i, r := range records {
+	resp := make([]*Subscription, len(result.Items))
+	for i, r := range result.Items {
 		resp[i] = mapSubscriptionFromDomain(r)
 	}
 
@@ -322,8 +498,14 @@ func (h *SubsHandler) ListSubscriptions(w http.ResponseWriter, r *http.Request)
 // @Description Calculate total cost for selected period
 // @Tags subs
 // @Produce json
-// @Param user_id query string true "User ID (UUID)"
-// @Param service_name query string false "Service name"
+// @Param user_id query []string true "User IDs (UUID), repeated or comma separated" collectionFormat(csv)
+// @Param service_name query []string false "Service names, repeated or comma separated" collectionFormat(csv)
+// @Param service_match query string false "Service name match: exact (default), icase or fuzzy (substring or trigram similarity, min 3 chars)"
+// @Param price_min query int false "Minimal price"
+// @Param price_max query int false "Maximal price"
+// @Param created_from query string false "Created from (YYYY-MM-DD)"
+// @Param created_to query string false "Created to, inclusive (YYYY-MM-DD)"
+// @Param filter query string false "RSQL expression, e.g. 'price=gt=500;(service_name==Netflix,service_name==Spotify)'. Fields: user_id, service_name, price, start_date, end_date (MM-YYYY), created_at (YYYY-MM-DD)"
 // @Param start_from query string false "Start period from (MM-YYYY)"
 // @Param start_to query string false "Start period to  (MM-YYYY)"
 // @Param end_from query string false "End period from (MM-YYYY)"
@@ -331,7 +513,11 @@ func (h *SubsHandler) ListSubscriptions(w http.ResponseWriter, r *http.Request)
 // @Param nil_end query bool false "includes items with empty end_date(by default - true: if end_to != nil - false)"
 // @Success 200 {object} TotalCostResponse
 // @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Missing, invalid or expired bearer token or API key"
+// @Failure 403 {object} ErrorResponse "Not permitted for the caller's roles and scopes"
 // @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /subscriptions/total [get]
 func (h *SubsHandler) GetTotalCost(w http.ResponseWriter, r *http.Request) {
 	log := logger.Logger().WithFields(logger.LogOptions{
@@ -369,14 +555,21 @@ func (h *SubsHandler) GetTotalCost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	filters, err := parseFilters(w, req.ServiceMatch, req.PriceMin, req.PriceMax, req.CreatedFrom, req.CreatedTo, nil, req.Filter)
+	if err != nil {
+		log.Warnf("ошибка парсинга фильтров: %v", err)
+		return
+	}
+	filters.UserIDs = req.UserID
+	filters.ServiceNames = req.ServiceName
+
 	result, err := h.container.TotalCostHandler.Handle(r.Context(), queries.TotalCostQuery{
-		UserID:      req.UserID,
-		ServiceName: req.ServiceName,
-		StartFrom:   sfD,
-		StartTo:     stD,
-		EndFrom:     efD,
-		EndTo:       etD,
-		WithNilEnd:  req.NilEnd,
+		StartFrom:  sfD,
+		StartTo:    stD,
+		EndFrom:    efD,
+		EndTo:      etD,
+		WithNilEnd: req.NilEnd,
+		Filters:    filters,
 	})
 	if err != nil {
 		// оборачиваем ошибку
@@ -386,3 +579,248 @@ func (h *SubsHandler) GetTotalCost(w http.ResponseWriter, r *http.Request) {
 
 	utils.WriteJSON(w, http.StatusOK, TotalCostResponse{Total: result})
 }
+
+// ExportSubscriptions godoc
+// @Summary Export subscriptions
+// @Description Stream subscriptions matching filters as a file. Rows are read from the database with a cursor
+// @Tags subs
+// @Produce text/csv,application/x-ndjson,application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param format query string false "csv (default), ndjson or xlsx"
+// @Param computed query bool false "Add computed columns months_active and total_paid"
+// @Param user_id query []string false "User IDs (UUID), repeated or comma separated" collectionFormat(csv)
+// @Param service_name query []string false "Service names, repeated or comma separated" collectionFormat(csv)
+// @Param service_match query string false "Service name match: exact (default), icase or fuzzy (substring or trigram similarity, min 3 chars)"
+// @Param price_min query int false "Minimal price"
+// @Param price_max query int false "Maximal price"
+// @Param created_from query string false "Created from (YYYY-MM-DD)"
+// @Param created_to query string false "Created to, inclusive (YYYY-MM-DD)"
+// @Param filter query string false "RSQL expression, e.g. 'price=gt=500;(service_name==Netflix,service_name==Spotify)'. Fields: user_id, service_name, price, start_date, end_date (MM-YYYY), created_at (YYYY-MM-DD)"
+// @Param start_from query string false "Start period from (MM-YYYY)"
+// @Param start_to query string false "Start period to  (MM-YYYY)"
+// @Param end_from query string false "End period from (MM-YYYY)"
+// @Param end_to query string false "End period to  (MM-YYYY)"
+// @Param nil_end query bool false "includes items with empty end_date(by default - true: if end_to != nil - false)"
+// @Param sort query string false "Multi-field sort, e.g. '-price,start_date'. Fields: start_date, end_date, price, service_name, created_at. Overrides order_by"
+// @Param order_by query string false "Sorting field name"
+// @Param direction query string false "Sorting direction use 'asc'(default) or 'desc'"
+// @Success 200 {file} file "Subscriptions file, ndjson rows are ExportRow"
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Missing, invalid or expired bearer token or API key"
+// @Failure 403 {object} ErrorResponse "Not permitted for the caller's roles and scopes"
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /subscriptions/export [get]
+func (h *SubsHandler) ExportSubscriptions(w http.ResponseWriter, r *http.Request) {
+	log := logger.Logger().WithFields(logger.LogOptions{
+		Pkg:  "SubsHandler",
+		Func: "ExportSubscriptions",
+		Ctx:  r.Context(),
+	})
+
+	var req ExportRequest
+	if !utils.ParseQuery(w, r, &req) {
+		log.Warnf("ошибка парсинга query")
+		return
+	}
+
+	format := exportFormatCSV
+	if req.Format != nil {
+		format = strings.ToLower(*req.Format)
+	}
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		log.Warnf("неизвестный формат выгрузки: %s", format)
+		utils.WriteJSON(w, http.StatusBadRequest, ErrorResponse{
+			Error: "format should be csv, ndjson or xlsx",
+			Code:  "VALIDATION_ERROR",
+		})
+		return
+	}
+	computed := req.Computed != nil && *req.Computed
+
+	filter, err := parseSubscriptionFilter(w, req.SubscriptionFilterRequest)
+	if err != nil {
+		log.Warnf("ошибка парсинга фильтров: %v", err)
+		return
+	}
+
+	now := time.Now()
+	rc := http.NewResponseController(w)
+
+	// ответ начинаем только с первой строкой - до этого ошибки квери отдаются как обычно
+	var ew exportWriter
+	start := func() error {
+		// выгрузка идет дольше WriteTimeout сервера - пишем до дедлайна ее контекста
+		if dl, ok := r.Context().Deadline(); ok {
+			if err := rc.SetWriteDeadline(dl); err != nil {
+				log.Warnf("не удалось продлить write deadline: %v", err)
+			}
+		}
+
+		filename := fmt.Sprintf("subscriptions-%s.%s", now.Format("2006-01-02"), format)
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		w.WriteHeader(http.StatusOK)
+
+		ew, err = newExportWriter(format, w, computed)
+		return err
+	}
+
+	var count int
+	err = h.container.ExportSubscriptionsHandler.Handle(r.Context(), queries.ExportSubscriptionsQuery{
+		StartFrom:  filter.StartFrom,
+		StartTo:    filter.StartTo,
+		EndFrom:    filter.EndFrom,
+		EndTo:      filter.EndTo,
+		WithNilEnd: filter.WithNilEnd,
+		Filters:    filter.Filters,
+
+		Sorting: filter.Sorting,
+	}, func(record *domain.Subscription) error {
+		if ew == nil {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+
+		if err := ew.WriteRow(mapExportRow(record, computed, now)); err != nil {
+			return err
+		}
+
+		count++
+		if count%exportFlushEvery == 0 {
+			if err := ew.Flush(); err != nil {
+				return err
+			}
+			return rc.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		if ew == nil {
+			// оборачиваем ошибку
+			h.writeAppError(w, err)
+			return
+		}
+
+		// статус уже отправлен - клиент получит неполный файл
+		log.Errorf("выгрузка прервана после %d строк: %v", count, err)
+		return
+	}
+
+	if ew == nil {
+		// пустая выборка - файл только с заголовком
+		if err := start(); err != nil {
+			log.Errorf("ошибка начала выгрузки: %v", err)
+			return
+		}
+	}
+
+	if err := ew.Close(); err != nil {
+		log.Errorf("ошибка завершения выгрузки: %v", err)
+	}
+}
+
+// IssueFeedToken godoc
+// @Summary Issue calendar feed token
+// @Description Issue a new unguessable token for the renewals calendar feed. The previous token stops working
+// @Tags users
+// @Produce json
+// @Param user_id path string true "User ID (UUID)"
+// @Param X-User-ID header string false "Caller user ID set by the API gateway when authentication is disabled, must match user_id"
+// @Success 201 {object} FeedTokenResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Missing, invalid or expired bearer token or API key, or missing X-User-ID without authentication"
+// @Failure 403 {object} ErrorResponse "Not permitted for the caller's roles and scopes, or X-User-ID does not match user_id"
+// @Failure 404 {object} ErrorResponse "Another user's feed"
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /users/{user_id}/renewals/token [post]
+func (h *SubsHandler) IssueFeedToken(w http.ResponseWriter, r *http.Request) {
+	log := logger.Logger().WithFields(logger.LogOptions{
+		Pkg:  "SubsHandler",
+		Func: "IssueFeedToken",
+		Ctx:  r.Context(),
+	})
+
+	userID, err := extrudeUUIDParam(w, r, "user_id")
+	if err != nil {
+		log.Warnf("ошибка парсинга user_id: %v", err)
+		return
+	}
+
+	if !authorizeUser(w, r, userID) {
+		log.Warnf("отказано в выпуске токена фида для %s", userID)
+		return
+	}
+
+	token, err := h.container.IssueFeedTokenHandler.Handle(r.Context(), commands.IssueFeedTokenCommand{UserID: userID})
+	if err != nil {
+		// оборачиваем ошибку
+		h.writeAppError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, FeedTokenResponse{
+		Token: token,
+		// календарь не передает заголовки - тенант токена идет параметром
+		URL: fmt.Sprintf("/users/%s/renewals.ics?token=%s&tenant=%s", userID, token, tenant.Current(r.Context())),
+	})
+}
+
+// GetRenewalsCalendar godoc
+// @Summary Renewals calendar feed
+// @Description RFC 5545 calendar with one monthly recurring event per active subscription.
+// @Description Calendar apps can't send auth headers, so access is checked by the feed token
+// @Tags users
+// @Produce text/calendar
+// @Param user_id path string true "User ID (UUID)"
+// @Param token query string true "Feed token"
+// @Success 200 {string} string "iCalendar"
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse "Unknown user or invalid token"
+// @Failure 500 {object} ErrorResponse
+// @Router /users/{user_id}/renewals.ics [get]
+func (h *SubsHandler) GetRenewalsCalendar(w http.ResponseWriter, r *http.Request) {
+	log := logger.Logger().WithFields(logger.LogOptions{
+		Pkg:  "SubsHandler",
+		Func: "GetRenewalsCalendar",
+		Ctx:  r.Context(),
+	})
+
+	userID, err := extrudeUUIDParam(w, r, "user_id")
+	if err != nil {
+		log.Warnf("ошибка парсинга user_id: %v", err)
+		return
+	}
+
+	var req RenewalsRequest
+	if !utils.ParseQuery(w, r, &req) {
+		log.Warnf("ошибка парсинга query")
+		return
+	}
+
+	now := time.Now()
+	records, err := h.container.RenewalsHandler.Handle(r.Context(), queries.RenewalsQuery{
+		UserID: userID,
+		Token:  req.Token,
+		At:     now,
+	})
+	if err != nil {
+		// оборачиваем ошибку
+		h.writeAppError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentTypeCalendar+"; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="renewals.ics"`)
+	// в URL токен - не даём кэшировать общим прокси
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := w.Write([]byte(renderRenewalsCalendar(records, now))); err != nil {
+		log.Errorf("ошибка записи календаря: %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package http
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/end1essrage/efmob-tz/pkg/subs/domain"
+)
+
+const (
+	contentTypeCalendar = "text/calendar"
+
+	icsProdID    = "-//efmob-tz//subs//RU"
+	icsUIDDomain = "subs.efmob-tz"
+	// RFC 5545 3.1 - строки длиннее 75 октетов переносятся
+	icsMaxLineOctets = 75
+	icsRefresh       = "PT12H"
+	icsDateFormat    = "20060102"
+	icsStampFormat   = "20060102T150405Z"
+)
+
+// renderRenewalsCalendar собирает RFC 5545 календарь: по одному повторяющемуся
+// VEVENT на подписку, списание - первого числа каждого месяца с start_date по end_date
+func renderRenewalsCalendar(subs []*domain.Subscription, now time.Time) string {
+	var b strings.Builder
+
+	line := func(s string) { writeICSLine(&b, s) }
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:" + icsProdID)
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	line("X-WR-CALNAME:" + escapeICSText("Списания по подпискам"))
+	line("REFRESH-INTERVAL;VALUE=DURATION:" + icsRefresh)
+	line("X-PUBLISHED-TTL:" + icsRefresh)
+
+	for _, sub := range subs {
+		// DTSTAMP должен меняться только вместе с подпиской, иначе клиенты видят изменения на каждом опросе
+		stamp := sub.UpdatedAt()
+		if stamp.IsZero() {
+			stamp = now
+		}
+		start := sub.StartDate()
+
+		line("BEGIN:VEVENT")
+		// UID по ID подписки - клиент обновит событие, а не создаст дубль
+		line(fmt.Sprintf("UID:%s@%s", sub.ID(), icsUIDDomain))
+		line("DTSTAMP:" + stamp.UTC().Format(icsStampFormat))
+		line("SEQUENCE:" + fmt.Sprint(sub.Version()-1))
+		line("DTSTART;VALUE=DATE:" + start.Format(icsDateFormat))
+		line("DTEND;VALUE=DATE:" + start.AddDate(0, 0, 1).Format(icsDateFormat))
+
+		rrule := "RRULE:FREQ=MONTHLY;BYMONTHDAY=1"
+		if end := sub.EndDate(); end != nil {
+			rrule += ";UNTIL=" + end.Format(icsDateFormat)
+		}
+		line(rrule)
+
+		line("SUMMARY:" + escapeICSText(fmt.Sprintf("%s: %d", sub.ServiceName(), sub.Price())))
+		line("DESCRIPTION:" + escapeICSText(fmt.Sprintf("Ежемесячное списание %d за подписку %s", sub.Price(), sub.ServiceName())))
+		line("TRANSP:TRANSPARENT")
+		line("END:VEVENT")
+	}
+
+	line("END:VCALENDAR")
+
+	return b.String()
+}
+
+// escapeICSText экранирует TEXT значение (RFC 5545 3.3.11)
+func escapeICSText(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", `\n`,
+	).Replace(s)
+}
+
+// writeICSLine пишет строку с CRLF, перенося по 75 октетов без разрыва UTF-8 символов
+func writeICSLine(b *strings.Builder, s string) {
+	limit := icsMaxLineOctets
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		b.WriteString(s[:cut])
+		b.WriteString("\r\n ")
+		s = s[cut:]
+		// продолжение начинается с пробела, он входит в длину строки
+		limit = icsMaxLineOctets - 1
+	}
+	b.WriteString(s)
+	b.WriteString("\r\n")
+}
@@ -0,0 +1,100 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/auth"
+	"github.com/end1essrage/efmob-tz/pkg/subs/domain"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderRenewalsCalendar(t *testing.T) {
+	id := uuid.MustParse("bb601f22-2bf3-4721-ae6f-7636e79a0cba")
+	start := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC)
+	updated := time.Date(2025, 7, 3, 10, 0, 0, 0, time.UTC)
+
+	limited, err := domain.NewSubscriptionWithVersion(id, uuid.New(), "Netflix; Premium, 4K", 400, start, &end, updated, updated, 2)
+	require.NoError(t, err)
+	unlimited, err := domain.NewSubscription(uuid.New(), uuid.New(), "Ivi", 300, start, nil)
+	require.NoError(t, err)
+
+	cal := renderRenewalsCalendar([]*domain.Subscription{limited, unlimited}, time.Now())
+
+	require.True(t, strings.HasPrefix(cal, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"))
+	require.True(t, strings.HasSuffix(cal, "END:VCALENDAR\r\n"))
+	require.Equal(t, 2, strings.Count(cal, "BEGIN:VEVENT"))
+
+	require.Contains(t, cal, "UID:bb601f22-2bf3-4721-ae6f-7636e79a0cba@"+icsUIDDomain+"\r\n")
+	require.Contains(t, cal, "DTSTAMP:20250703T100000Z\r\n")
+	require.Contains(t, cal, "SEQUENCE:1\r\n")
+	require.Contains(t, cal, "DTSTART;VALUE=DATE:20250701\r\n")
+	require.Contains(t, cal, "RRULE:FREQ=MONTHLY;BYMONTHDAY=1;UNTIL=20251201\r\n")
+	require.Contains(t, cal, "RRULE:FREQ=MONTHLY;BYMONTHDAY=1\r\n")
+	require.Contains(t, cal, `SUMMARY:Netflix\; Premium\, 4K: 400`)
+
+	for _, line := range strings.Split(cal, "\r\n") {
+		require.LessOrEqual(t, len(line), icsMaxLineOctets)
+	}
+}
+
+func TestWriteICSLine_FoldsWithoutBreakingRunes(t *testing.T) {
+	var b strings.Builder
+	long := "DESCRIPTION:" + strings.Repeat("подписка ", 20)
+
+	writeICSLine(&b, long)
+
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\r\n"), "\r\n")
+	require.Greater(t, len(lines), 1)
+
+	var unfolded strings.Builder
+	for i, line := range lines {
+		require.LessOrEqual(t, len(line), icsMaxLineOctets)
+		require.True(t, strings.ToValidUTF8(line, "?") == line)
+		if i > 0 {
+			require.True(t, strings.HasPrefix(line, " "))
+			line = line[1:]
+		}
+		unfolded.WriteString(line)
+	}
+	require.Equal(t, long, unfolded.String())
+}
+
+func TestAuthorizeUser(t *testing.T) {
+	user := uuid.New()
+
+	cases := []struct {
+		name   string
+		caller string
+		ok     bool
+		status int
+	}{
+		{"без заголовка", "", false, http.StatusUnauthorized},
+		{"не uuid", "admin", false, http.StatusUnauthorized},
+		{"чужой пользователь", uuid.NewString(), false, http.StatusForbidden},
+		{"сам пользователь", user.String(), true, http.StatusOK},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/users/"+user.String()+"/renewals/token", nil)
+			if tc.caller != "" {
+				r.Header.Set(headerUserID, tc.caller)
+			}
+			w := httptest.NewRecorder()
+
+			require.Equal(t, tc.ok, authorizeUser(w, r, user))
+			require.Equal(t, tc.status, w.Code)
+		})
+	}
+
+	// аутентифицированного вызывающего проверяет приложение
+	r := httptest.NewRequest(http.MethodPost, "/users/"+user.String()+"/renewals/token", nil)
+	r = r.WithContext(auth.WithPrincipal(r.Context(), &auth.Principal{Subject: "other", UserID: uuid.New()}))
+	require.True(t, authorizeUser(httptest.NewRecorder(), r, user))
+}
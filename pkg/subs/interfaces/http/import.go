@@ -0,0 +1,226 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/interfaces/http/utils"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application/commands"
+	"github.com/google/uuid"
+)
+
+const (
+	contentTypeCSV    = "text/csv"
+	contentTypeNDJSON = "application/x-ndjson"
+
+	// максимальный размер файла импорта
+	maxImportBodySize = 32 << 20
+)
+
+// writeImportTooLarge отвечает 413, если тело импорта оборвано по maxImportBodySize, в т.ч. посреди чтения строк
+func writeImportTooLarge(w http.ResponseWriter, err error) bool {
+	var tooLarge *http.MaxBytesError
+	if !errors.As(err, &tooLarge) {
+		return false
+	}
+	utils.WriteJSON(w, http.StatusRequestEntityTooLarge, ErrorResponse{
+		Error: fmt.Sprintf("import file exceeds %d bytes", tooLarge.Limit),
+		Code:  "REQUEST_TOO_LARGE",
+	})
+	return true
+}
+
+var importCSVColumns = []string{"user_id", "service_name", "price", "start_date", "end_date"}
+
+// csvImportSource читает строки импорта из CSV с заголовком
+type csvImportSource struct {
+	r       *csv.Reader
+	columns map[string]int
+}
+
+func newCSVImportSource(body io.Reader) (*csvImportSource, error) {
+	r := csv.NewReader(body)
+	r.TrimLeadingSpace = true
+	r.ReuseRecord = true
+
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("invalid csv header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	// end_date опционален
+	for _, name := range importCSVColumns[:4] {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("csv header should contain column %q", name)
+		}
+	}
+
+	// строки могут иметь разное кол-во колонок, проверяем сами
+	r.FieldsPerRecord = -1
+
+	return &csvImportSource{r: r, columns: columns}, nil
+}
+
+func (s *csvImportSource) Next() (*commands.ImportRow, error) {
+	record, err := s.r.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, io.EOF
+	}
+
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return &commands.ImportRow{
+			Line: parseErr.StartLine,
+			Err:  application.NewErrorValidationCommand(parseErr.Err.Error()),
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	line, _ := s.r.FieldPos(0)
+
+	field := func(name string) string {
+		i, ok := s.columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var endDate *string
+	if e := field("end_date"); e != "" {
+		endDate = &e
+	}
+
+	price, err := strconv.Atoi(field("price"))
+	if err != nil {
+		return &commands.ImportRow{
+			Line: line,
+			Err:  application.NewErrorValidationCommand("invalid price, should be int"),
+		}, nil
+	}
+
+	cmd, err := parseImportFields(field("user_id"), field("service_name"), price, field("start_date"), endDate)
+	return &commands.ImportRow{Line: line, Cmd: cmd, Err: err}, nil
+}
+
+// ndjsonImportSource читает строки импорта из NDJSON, одна подписка на строку
+type ndjsonImportSource struct {
+	r    *bufio.Reader
+	line int
+}
+
+func newNDJSONImportSource(body io.Reader) *ndjsonImportSource {
+	return &ndjsonImportSource{r: bufio.NewReader(body)}
+}
+
+func (s *ndjsonImportSource) Next() (*commands.ImportRow, error) {
+	for {
+		data, err := s.r.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		if len(data) == 0 && errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+
+		s.line++
+
+		data = bytes.TrimSpace(data)
+		if len(data) == 0 {
+			// пустые строки пропускаем
+			continue
+		}
+
+		var req struct {
+			UserID      string  `json:"user_id"`
+			ServiceName string  `json:"service_name"`
+			Price       int     `json:"price"`
+			StartDate   string  `json:"start_date"`
+			EndDate     *string `json:"end_date"`
+		}
+		if err := json.Unmarshal(data, &req); err != nil {
+			return &commands.ImportRow{
+				Line: s.line,
+				Err:  application.NewErrorValidationCommand("invalid json: " + err.Error()),
+			}, nil
+		}
+
+		if req.EndDate != nil && *req.EndDate == "" {
+			req.EndDate = nil
+		}
+
+		cmd, err := parseImportFields(req.UserID, req.ServiceName, req.Price, req.StartDate, req.EndDate)
+		return &commands.ImportRow{Line: s.line, Cmd: cmd, Err: err}, nil
+	}
+}
+
+// parseImportFields разбирает поля строки импорта. Бизнес-валидация - в домене
+func parseImportFields(userID, serviceName string, price int, startDate string, endDate *string) (commands.CreateSubscriptionCommand, error) {
+	cmd := commands.CreateSubscriptionCommand{ServiceName: serviceName, Price: price}
+
+	// пустой user_id - подписка аутентифицированного пользователя, иначе домен отклонит строку
+	if userID != "" {
+		uid, err := uuid.Parse(userID)
+		if err != nil {
+			return cmd, application.NewErrorValidationCommand("invalid user_id, should be uuid")
+		}
+		cmd.UserID = uid
+	}
+
+	sD, err := time.Parse("01-2006", startDate)
+	if err != nil {
+		return cmd, application.NewErrorValidationCommand("invalid start_date, should be MM-YYYY")
+	}
+	cmd.StartDate = sD
+
+	if endDate != nil {
+		eD, err := time.Parse("01-2006", *endDate)
+		if err != nil {
+			return cmd, application.NewErrorValidationCommand("invalid end_date, should be MM-YYYY")
+		}
+		cmd.EndDate = &eD
+	}
+
+	return cmd, nil
+}
+
+func mapImportResult(mode commands.ImportMode, result *commands.ImportResult) ImportResponse {
+	resp := ImportResponse{
+		Mode:    string(mode),
+		Total:   len(result.Rows),
+		Created: result.Created,
+		Failed:  result.Failed,
+		Rows:    make([]ImportRowResponse, len(result.Rows)),
+	}
+
+	for i, row := range result.Rows {
+		resp.Rows[i] = ImportRowResponse{Line: row.Line, ID: row.ID}
+		if row.Err != nil {
+			appErr := application.MapError(row.Err)
+			resp.Rows[i].Code = appErr.Code
+			resp.Rows[i].Error = appErr.Code
+			if appErr.HTTPStatus < 500 {
+				resp.Rows[i].Error = row.Err.Error()
+			}
+		}
+	}
+
+	return resp
+}
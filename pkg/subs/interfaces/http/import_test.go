@@ -0,0 +1,111 @@
+package http
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/interfaces/http/utils"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application/commands"
+	"github.com/stretchr/testify/require"
+)
+
+func readAllRows(t *testing.T, source commands.ImportSource) []*commands.ImportRow {
+	t.Helper()
+
+	var rows []*commands.ImportRow
+	for {
+		row, err := source.Next()
+		if errors.Is(err, io.EOF) {
+			return rows
+		}
+		require.NoError(t, err)
+		rows = append(rows, row)
+	}
+}
+
+func TestCSVImportSource(t *testing.T) {
+	body := "service_name,user_id,price,start_date,end_date\n" +
+		"Netflix,90601fee-2bf1-4721-ae6f-7636e79a0cba,400,07-2025,12-2025\n" +
+		"Ivi,90601fee-2bf1-4721-ae6f-7636e79a0cba,abc,07-2025,\n" +
+		"Okko,not-uuid,100,07-2025\n"
+
+	source, err := newCSVImportSource(strings.NewReader(body))
+	require.NoError(t, err)
+
+	rows := readAllRows(t, source)
+	require.Len(t, rows, 3)
+
+	require.NoError(t, rows[0].Err)
+	require.Equal(t, 2, rows[0].Line)
+	require.Equal(t, "Netflix", rows[0].Cmd.ServiceName)
+	require.Equal(t, 400, rows[0].Cmd.Price)
+	require.Equal(t, time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC), *rows[0].Cmd.EndDate)
+
+	require.Error(t, rows[1].Err)
+	require.Equal(t, 3, rows[1].Line)
+
+	require.Error(t, rows[2].Err)
+	require.Equal(t, 4, rows[2].Line)
+}
+
+func TestCSVImportSource_MissingColumn(t *testing.T) {
+	_, err := newCSVImportSource(strings.NewReader("user_id,price,start_date\n"))
+	require.Error(t, err)
+}
+
+func TestNDJSONImportSource(t *testing.T) {
+	body := `{"user_id":"90601fee-2bf1-4721-ae6f-7636e79a0cba","service_name":"Netflix","price":400,"start_date":"07-2025"}` + "\n" +
+		"\n" +
+		`{"user_id":` + "\n" +
+		`{"user_id":"90601fee-2bf1-4721-ae6f-7636e79a0cba","service_name":"Ivi","price":300,"start_date":"07-2025","end_date":""}`
+
+	rows := readAllRows(t, newNDJSONImportSource(strings.NewReader(body)))
+	require.Len(t, rows, 3)
+
+	require.NoError(t, rows[0].Err)
+	require.Equal(t, 1, rows[0].Line)
+	require.Nil(t, rows[0].Cmd.EndDate)
+
+	require.Error(t, rows[1].Err)
+	require.Equal(t, 3, rows[1].Line)
+
+	require.NoError(t, rows[2].Err)
+	require.Equal(t, 4, rows[2].Line)
+	require.Equal(t, "Ivi", rows[2].Cmd.ServiceName)
+}
+
+func TestImportRequest_ParseMode(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions/import?mode=best_effort", nil)
+	w := httptest.NewRecorder()
+
+	var req ImportRequest
+	require.True(t, utils.ParseQuery(w, r, &req))
+	require.NotNil(t, req.Mode)
+	require.Equal(t, "best_effort", *req.Mode)
+}
+
+func TestImportBodyTooLarge(t *testing.T) {
+	row := `{"user_id":"90601fee-2bf1-4721-ae6f-7636e79a0cba","service_name":"Netflix","price":400,"start_date":"07-2025"}` + "\n"
+	w := httptest.NewRecorder()
+	body := http.MaxBytesReader(w, io.NopCloser(strings.NewReader(row+strings.Repeat("x", 1024))), int64(len(row)+100))
+
+	// первая строка читается, предел срабатывает посреди потока
+	source := newNDJSONImportSource(body)
+	first, err := source.Next()
+	require.NoError(t, err)
+	require.NoError(t, first.Err)
+
+	_, err = source.Next()
+	require.Error(t, err)
+	require.True(t, writeImportTooLarge(w, err))
+	require.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	require.Contains(t, w.Body.String(), "REQUEST_TOO_LARGE")
+
+	// остальные ошибки чтения - не 413
+	require.False(t, writeImportTooLarge(httptest.NewRecorder(), errors.New("connection reset")))
+}
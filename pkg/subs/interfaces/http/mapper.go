@@ -1,6 +1,12 @@
 package http
 
-import "github.com/end1essrage/efmob-tz/pkg/subs/domain"
+import (
+	"encoding/json"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/auth"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application"
+	"github.com/end1essrage/efmob-tz/pkg/subs/domain"
+)
 
 var mapSubscriptionFromDomain = func(record *domain.Subscription) *Subscription {
 	endDate := ""
@@ -16,5 +22,43 @@ var mapSubscriptionFromDomain = func(record *domain.Subscription) *Subscription
 		Price:       record.Price(),
 		StartDate:   formatDate(record.StartDate()),
 		EndDate:     endDate,
+		Version:     record.Version(),
+	}
+}
+
+// mapAPIKey хэш ключа наружу не отдается
+func mapAPIKey(k *auth.APIKey) APIKey {
+	scopes := k.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+	return APIKey{
+		ID:         k.ID,
+		Name:       k.Name,
+		Owner:      k.Owner,
+		Scopes:     scopes,
+		Hint:       k.Hint,
+		CreatedAt:  k.CreatedAt,
+		ExpiresAt:  k.ExpiresAt,
+		LastUsedAt: k.LastUsedAt,
+		RevokedAt:  k.RevokedAt,
+	}
+}
+
+// mapOutboxEvent payload событий - JSON, остальное отдается строкой
+func mapOutboxEvent(ev application.OutboxEvent) OutboxEvent {
+	payload := json.RawMessage(ev.Payload)
+	if !json.Valid(payload) {
+		payload, _ = json.Marshal(string(ev.Payload))
+	}
+	return OutboxEvent{
+		ID:            ev.ID,
+		AggregateID:   ev.AggregateID,
+		Type:          ev.Type,
+		Payload:       payload,
+		CreatedAt:     ev.CreatedAt,
+		Attempts:      ev.Attempts,
+		LastError:     ev.LastError,
+		NextAttemptAt: ev.NextAttemptAt,
 	}
 }
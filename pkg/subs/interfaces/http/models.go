@@ -1,6 +1,11 @@
 package http
 
-import "github.com/google/uuid"
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
 
 // TotalCostResponse
 // swagger:model TotalCostResponse
@@ -13,7 +18,7 @@ type TotalCostResponse struct {
 // SubscriptionCreateRequest
 // swagger:model SubscriptionCreateRequest
 type SubscriptionCreateRequest struct {
-	// User ID (UUID)
+	// User ID (UUID), for regular users taken from the token subject
 	// required: true
 	UserID uuid.UUID `json:"user_id"`
 
@@ -54,14 +59,32 @@ type SubscriptionUpdateRequest struct {
 	EndDate NullableStringUpdate `json:"end_date,omitempty"`
 }
 
-// SubscriptionQueryRequest
-// swagger:model SubscriptionQueryRequest
-type SubscriptionQueryRequest struct {
-	// Filter by User ID (UUID), optional
-	UserID *uuid.UUID `schema:"user_id,omitempty"`
+// SubscriptionFilterRequest фильтры и сортировка списка и выгрузки подписок
+// swagger:model SubscriptionFilterRequest
+type SubscriptionFilterRequest struct {
+	// Filter by User IDs (UUID), repeated or comma separated, optional
+	UserID []uuid.UUID `schema:"user_id,omitempty"`
+
+	// Filter by service names, repeated or comma separated, optional
+	ServiceName []string `schema:"service_name,omitempty"`
+
+	// Service name match: exact (default), icase or fuzzy
+	ServiceMatch *string `schema:"service_match,omitempty"`
+
+	// Minimal price, optional
+	PriceMin *int `schema:"price_min,omitempty"`
+
+	// Maximal price, optional
+	PriceMax *int `schema:"price_max,omitempty"`
 
-	// Filter by service name, optional
-	ServiceName *string `schema:"service_name,omitempty"`
+	// Filter by created_at from (YYYY-MM-DD), optional
+	CreatedFrom *string `schema:"created_from,omitempty"`
+
+	// Filter by created_at to (YYYY-MM-DD, inclusive), optional
+	CreatedTo *string `schema:"created_to,omitempty"`
+
+	// RSQL filter expression, combined with other filters by AND, optional
+	Filter *string `schema:"filter,omitempty"`
 
 	// Filter by start_date period from (MM-YYYY), optional
 	StartFrom *string `schema:"start_from,omitempty"`
@@ -78,27 +101,103 @@ type SubscriptionQueryRequest struct {
 	// Filter by end_date Include with EMPTY end_date
 	NilEnd *bool `schema:"nil_end,omitempty"`
 
+	// Multi-field sort, optional (e.g., "-price,start_date"). Overrides order_by and direction
+	Sort *string `schema:"sort,omitempty"`
+
+	// Sort field, optional (e.g., "start_date" or "price")
+	OrderBy *string `schema:"order_by,omitempty"`
+
+	// Sort direction, optional: "asc" or "desc"
+	Direction *string `schema:"direction,omitempty"`
+}
+
+// SubscriptionQueryRequest
+// swagger:model SubscriptionQueryRequest
+type SubscriptionQueryRequest struct {
+	SubscriptionFilterRequest
+
 	// Page number for pagination, optional
 	Page *int `schema:"page,omitempty"`
 
 	// Page size for pagination, optional
 	PageSize *int `schema:"page_size,omitempty"`
 
-	// Sort field, optional (e.g., "start_date" or "price")
-	OrderBy *string `schema:"order_by,omitempty"`
+	// Cursor from X-Next-Cursor of the previous page, optional. Page is ignored when set
+	Cursor *string `schema:"cursor,omitempty"`
 
-	// Sort direction, optional: "asc" or "desc"
-	Direction *string `schema:"direction,omitempty"`
+	// Return total count of matching subscriptions in X-Total-Count, optional
+	WithTotal *bool `schema:"with_total,omitempty"`
+}
+
+// ExportRequest
+// swagger:model ExportRequest
+type ExportRequest struct {
+	SubscriptionFilterRequest
+
+	// Export format: csv (default), ndjson or xlsx
+	Format *string `schema:"format,omitempty"`
+
+	// Add computed columns months_active and total_paid
+	Computed *bool `schema:"computed,omitempty"`
+}
+
+// ExportRow
+// swagger:model ExportRow
+type ExportRow struct {
+	// ID (UUID)
+	ID uuid.UUID `json:"id"`
+
+	// User ID (UUID)
+	UserID uuid.UUID `json:"user_id"`
+
+	// Service name
+	ServiceName string `json:"service_name"`
+
+	// Price per month
+	Price int `json:"price"`
+
+	// Start date (MM-YYYY)
+	StartDate string `json:"start_date"`
+
+	// End date (MM-YYYY), empty if not set
+	EndDate string `json:"end_date"`
+
+	// Subscription version
+	Version int `json:"version"`
+
+	// Paid months up to the current month, only with computed=true
+	MonthsActive *int `json:"months_active,omitempty"`
+
+	// MonthsActive * price, only with computed=true
+	TotalPaid *int `json:"total_paid,omitempty"`
 }
 
 // TotalCostRequest
 // swagger:model TotalCostRequest
 type TotalCostRequest struct {
-	// User ID (UUID)
-	UserID *uuid.UUID `schema:"user_id"`
+	// User IDs (UUID), repeated or comma separated, optional
+	UserID []uuid.UUID `schema:"user_id,omitempty"`
+
+	// Filter by service names, repeated or comma separated, optional
+	ServiceName []string `schema:"service_name,omitempty"`
+
+	// Service name match: exact (default), icase or fuzzy
+	ServiceMatch *string `schema:"service_match,omitempty"`
+
+	// Minimal price, optional
+	PriceMin *int `schema:"price_min,omitempty"`
+
+	// Maximal price, optional
+	PriceMax *int `schema:"price_max,omitempty"`
+
+	// Filter by created_at from (YYYY-MM-DD), optional
+	CreatedFrom *string `schema:"created_from,omitempty"`
+
+	// Filter by created_at to (YYYY-MM-DD, inclusive), optional
+	CreatedTo *string `schema:"created_to,omitempty"`
 
-	// Service name filter, optional
-	ServiceName *string `schema:"service_name"`
+	// RSQL filter expression, combined with other filters by AND, optional
+	Filter *string `schema:"filter,omitempty"`
 
 	// Filter by start_date period from (MM-YYYY), optional
 	StartFrom *string `schema:"start_from,omitempty"`
@@ -142,6 +241,225 @@ type Subscription struct {
 	// Subscription end date in MM-YYYY format, optional
 	// example: 07-2026
 	EndDate string `json:"end_date"`
+
+	// Version for optimistic concurrency, same as ETag
+	// example: 1
+	Version int `json:"version"`
+}
+
+// ImportRequest
+// swagger:model ImportRequest
+type ImportRequest struct {
+	// Import mode: "atomic" (default) - all or nothing, "best_effort" - skip invalid rows
+	Mode *string `schema:"mode,omitempty"`
+}
+
+// ImportRowResponse
+// swagger:model ImportRowResponse
+type ImportRowResponse struct {
+	// Line number in the imported file
+	// example: 2
+	Line int `json:"line"`
+
+	// Created subscription ID, empty if the row failed or atomic import was rejected
+	ID *uuid.UUID `json:"id,omitempty"`
+
+	// Error code, same as ErrorResponse.Code
+	// example: INVALID_PRICE
+	Code string `json:"code,omitempty"`
+
+	// Error message
+	Error string `json:"error,omitempty"`
+}
+
+// ImportResponse
+// swagger:model ImportResponse
+type ImportResponse struct {
+	// Import mode
+	// example: atomic
+	Mode string `json:"mode"`
+
+	// Total processed rows
+	Total int `json:"total"`
+
+	// Created subscriptions
+	Created int `json:"created"`
+
+	// Failed rows
+	Failed int `json:"failed"`
+
+	// Per-row report
+	Rows []ImportRowResponse `json:"rows"`
+}
+
+// FeedTokenResponse
+// swagger:model FeedTokenResponse
+type FeedTokenResponse struct {
+	// Feed token, shown only once
+	Token string `json:"token"`
+
+	// Calendar feed URL relative to the service root
+	// example: /users/60601fee-2bf1-4721-ae6f-7636e79a0cba/renewals.ics?token=...&tenant=default
+	URL string `json:"url"`
+}
+
+// RenewalsRequest
+// swagger:model RenewalsRequest
+type RenewalsRequest struct {
+	// Feed token
+	Token string `schema:"token"`
+}
+
+// APIKeyCreateRequest
+// swagger:model APIKeyCreateRequest
+type APIKeyCreateRequest struct {
+	// Human-readable key name
+	// required: true
+	// example: billing export job
+	Name string `json:"name"`
+
+	// Principal subject for requests with the key: user ID (UUID) or service name
+	// required: true
+	// example: billing-job
+	Owner string `json:"owner"`
+
+	// Permissions of the key, same values as token scopes
+	// required: true
+	// example: ["subs:read:any"]
+	Scopes []string `json:"scopes"`
+
+	// Expiration time (RFC 3339), omitted - the key doesn't expire
+	// required: false
+	// nullable: true
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// APIKey
+// swagger:model APIKey
+type APIKey struct {
+	// ID (UUID)
+	// example: 3f0c2d5e-8d6b-4c1e-9a57-1b2f3c4d5e6f
+	ID uuid.UUID `json:"id"`
+
+	// Key name
+	// example: billing export job
+	Name string `json:"name"`
+
+	// Principal subject for requests with the key
+	// example: billing-job
+	Owner string `json:"owner"`
+
+	// Permissions of the key
+	// example: ["subs:read:any"]
+	Scopes []string `json:"scopes"`
+
+	// Beginning of the key to recognize it
+	// example: efm_3f0c2d5e
+	Hint string `json:"hint"`
+
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// APIKeyIssuedResponse
+// swagger:model APIKeyIssuedResponse
+type APIKeyIssuedResponse struct {
+	APIKey APIKey `json:"api_key"`
+
+	// The key for "Authorization: ApiKey <key>", shown only once
+	Key string `json:"key"`
+}
+
+// APIKeyListResponse
+// swagger:model APIKeyListResponse
+type APIKeyListResponse struct {
+	Items []APIKey `json:"items"`
+}
+
+// OutboxRequest
+// swagger:model OutboxRequest
+type OutboxRequest struct {
+	// Filter by event type, optional
+	Type *string `schema:"type,omitempty"`
+
+	// Filter by aggregate (subscription) ID, optional
+	AggregateID *uuid.UUID `schema:"aggregate_id,omitempty"`
+
+	// Only events with failed publish attempts, optional
+	Failed *bool `schema:"failed,omitempty"`
+
+	// Max events in response, 1-1000, default 100
+	Limit *int `schema:"limit,omitempty"`
+}
+
+// OutboxEvent
+// swagger:model OutboxEvent
+type OutboxEvent struct {
+	// ID (UUID)
+	ID uuid.UUID `json:"id"`
+
+	// Subscription ID the event belongs to
+	AggregateID uuid.UUID `json:"aggregate_id"`
+
+	// Event type
+	// example: subscription_created
+	Type string `json:"type"`
+
+	// Event payload as published
+	Payload json.RawMessage `json:"payload" swaggertype:"object"`
+
+	CreatedAt time.Time `json:"created_at"`
+
+	// Failed publish attempts since the event was queued
+	// example: 3
+	Attempts int `json:"attempts"`
+
+	// Error of the last failed attempt
+	// example: broker unavailable
+	LastError string `json:"last_error,omitempty"`
+
+	// The event and later events of its subscription wait until this time, omitted - queued
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+}
+
+// OutboxStats
+// swagger:model OutboxStats
+type OutboxStats struct {
+	// Unpublished events
+	Pending int64 `json:"pending"`
+
+	// Events with failed publish attempts
+	Failed int64 `json:"failed"`
+
+	// Creation time of the oldest unpublished event
+	Oldest *time.Time `json:"oldest,omitempty"`
+}
+
+// OutboxResponse
+// swagger:model OutboxResponse
+type OutboxResponse struct {
+	Stats OutboxStats   `json:"stats"`
+	Items []OutboxEvent `json:"items"`
+}
+
+// OutboxRequeueRequest
+// swagger:model OutboxRequeueRequest
+type OutboxRequeueRequest struct {
+	// Event IDs to requeue
+	IDs []uuid.UUID `json:"ids,omitempty"`
+
+	// Requeue all deferred events, ids must be empty
+	All bool `json:"all,omitempty"`
+}
+
+// OutboxRequeueResponse
+// swagger:model OutboxRequeueResponse
+type OutboxRequeueResponse struct {
+	// Events returned to the queue
+	// example: 1
+	Requeued int64 `json:"requeued"`
 }
 
 // ErrorResponse
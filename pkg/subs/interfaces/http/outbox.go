@@ -0,0 +1,108 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/interfaces/http/utils"
+	"github.com/end1essrage/efmob-tz/pkg/common/logger"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application/commands"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application/queries"
+)
+
+// ListOutbox godoc
+// @Summary Inspect outbox
+// @Description Unpublished events of the tenant, oldest first, with failed attempts and the last error
+// @Tags outbox
+// @Produce json
+// @Param type query string false "Event type"
+// @Param aggregate_id query string false "Subscription ID (UUID)"
+// @Param failed query bool false "Only events with failed publish attempts"
+// @Param limit query int false "Max events, 1-1000, default 100"
+// @Success 200 {object} OutboxResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Missing, invalid or expired bearer token or API key"
+// @Failure 403 {object} ErrorResponse "Not permitted for the caller's roles and scopes"
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /admin/outbox [get]
+func (h *SubsHandler) ListOutbox(w http.ResponseWriter, r *http.Request) {
+	log := logger.Logger().WithFields(logger.LogOptions{
+		Pkg:  "SubsHandler",
+		Func: "ListOutbox",
+		Ctx:  r.Context(),
+	})
+
+	var req OutboxRequest
+	if !utils.ParseQuery(w, r, &req) {
+		log.Warnf("ошибка парсинга query")
+		return
+	}
+
+	q := queries.ListOutboxQuery{AggregateID: req.AggregateID}
+	if req.Type != nil {
+		q.Type = *req.Type
+	}
+	if req.Failed != nil {
+		q.Failed = *req.Failed
+	}
+	if req.Limit != nil {
+		q.Limit = *req.Limit
+	}
+
+	report, err := h.container.ListOutboxHandler.Handle(r.Context(), q)
+	if err != nil {
+		// оборачиваем ошибку
+		h.writeAppError(w, err)
+		return
+	}
+
+	resp := OutboxResponse{
+		Stats: OutboxStats{Pending: report.Stats.Pending, Failed: report.Stats.Failed, Oldest: report.Stats.Oldest},
+		Items: make([]OutboxEvent, 0, len(report.Events)),
+	}
+	for _, ev := range report.Events {
+		resp.Items = append(resp.Items, mapOutboxEvent(ev))
+	}
+	utils.WriteJSON(w, http.StatusOK, resp)
+}
+
+// RequeueOutbox godoc
+// @Summary Requeue outbox events
+// @Description Clear the retry delay of events deferred after failed publishes, they are published on the next worker pass.
+// @Description Pass event ids or all=true
+// @Tags outbox
+// @Accept json
+// @Produce json
+// @Param request body OutboxRequeueRequest true "Events to requeue"
+// @Success 200 {object} OutboxRequeueResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Missing, invalid or expired bearer token or API key"
+// @Failure 403 {object} ErrorResponse "Not permitted for the caller's roles and scopes"
+// @Failure 413 {object} ErrorResponse "Request body too large"
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /admin/outbox/requeue [post]
+func (h *SubsHandler) RequeueOutbox(w http.ResponseWriter, r *http.Request) {
+	log := logger.Logger().WithFields(logger.LogOptions{
+		Pkg:  "SubsHandler",
+		Func: "RequeueOutbox",
+		Ctx:  r.Context(),
+	})
+
+	var req OutboxRequeueRequest
+	if err := utils.DecodeJSONBody(w, r, &req); err != nil {
+		log.Warnf("ошибка парсинга тела запроса: %v", err)
+		return
+	}
+
+	n, err := h.container.RequeueOutboxHandler.Handle(r.Context(), commands.RequeueOutboxCommand{IDs: req.IDs, All: req.All})
+	if err != nil {
+		// оборачиваем ошибку
+		h.writeAppError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, OutboxRequeueResponse{Requeued: n})
+}
@@ -0,0 +1,20 @@
+package http
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/end1essrage/efmob-tz/pkg/subs/application"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMapOutboxEvent_Payload(t *testing.T) {
+	ev := mapOutboxEvent(application.OutboxEvent{Payload: []byte(`{"price":500}`)})
+	require.JSONEq(t, `{"price":500}`, string(ev.Payload))
+
+	// не JSON отдается строкой, ответ остается валидным
+	ev = mapOutboxEvent(application.OutboxEvent{Payload: []byte("raw\x00bytes")})
+	data, err := json.Marshal(ev)
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"payload":"raw\u0000bytes"`)
+}
@@ -0,0 +1,251 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"mime"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/end1essrage/efmob-tz/pkg/common/interfaces/http/utils"
+	"github.com/end1essrage/efmob-tz/pkg/common/logger"
+	app "github.com/end1essrage/efmob-tz/pkg/subs/application"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application/commands"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application/queries"
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	contentTypeJSON       = "application/json"
+	contentTypeMergePatch = "application/merge-patch+json"
+	contentTypeJSONPatch  = "application/json-patch+json"
+)
+
+// поля представления, которые нельзя менять через PATCH
+var readOnlyFields = map[string]bool{
+	"id":           true,
+	"user_id":      true,
+	"service_name": true,
+	"version":      true,
+}
+
+// поля, которые меняются через UpdateSubscriptionCommand
+var patchableFields = map[string]bool{
+	"price":      true,
+	"start_date": true,
+	"end_date":   true,
+}
+
+// patchMediaType возвращает тип тела PATCH запроса, пустой Content-Type - application/json
+func patchMediaType(r *http.Request) string {
+	ct := r.Header.Get("Content-Type")
+	if ct == "" {
+		return contentTypeJSON
+	}
+
+	mediaType, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return ct
+	}
+	return mediaType
+}
+
+// patchSubscription обновляет подписку через merge patch или json patch
+func (h *SubsHandler) patchSubscription(w http.ResponseWriter, r *http.Request, uid uuid.UUID, expectedVersion *int, mediaType string) {
+	log := logger.Logger().WithFields(logger.LogOptions{
+		Pkg:  "SubsHandler",
+		Func: "patchSubscription",
+		Ctx:  r.Context(),
+	})
+
+	patch, err := utils.ReadJSONBody(w, r)
+	if err != nil {
+		log.Warnf("ошибка чтения тела запроса: %v", err)
+		return
+	}
+
+	current, err := h.container.GetSubscriptionHandler.Handle(r.Context(), queries.GetSubscriptionQuery{ID: uid})
+	if err != nil {
+		h.writeAppError(w, err)
+		return
+	}
+
+	// patch применяется к прочитанной версии - если клиент не передал If-Match,
+	// всё равно не даём перезаписать чужие изменения
+	implicitVersion := expectedVersion == nil
+	if implicitVersion {
+		v := current.Version()
+		expectedVersion = &v
+	}
+	if *expectedVersion != current.Version() {
+		h.writeAppError(w, app.ErrPreconditionFailed)
+		return
+	}
+
+	representation := mapSubscriptionFromDomain(current)
+
+	patched, err := applyPatch(w, mediaType, representation, patch)
+	if err != nil {
+		log.Warnf("ошибка применения patch: %v", err)
+		return
+	}
+
+	cmd, changed, err := patchToCommand(w, uid, representation, patched)
+	if err != nil {
+		log.Warnf("некорректный результат patch: %v", err)
+		return
+	}
+
+	if !changed {
+		log.Info("patch ничего не изменил")
+		writeSubscription(w, http.StatusOK, current)
+		return
+	}
+
+	cmd.ExpectedVersion = expectedVersion
+
+	record, err := h.container.UpdateSubscriptionHandler.Handle(r.Context(), cmd)
+	if err != nil {
+		if implicitVersion && errors.Is(err, app.ErrPreconditionFailed) {
+			err = app.ErrConcurrentModification
+		}
+		h.writeAppError(w, err)
+		return
+	}
+
+	writeSubscription(w, http.StatusOK, record)
+}
+
+// applyPatch применяет RFC 7396 или RFC 6902 patch к представлению подписки
+func applyPatch(w http.ResponseWriter, mediaType string, current *Subscription, patch []byte) ([]byte, error) {
+	doc, err := json.Marshal(current)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusInternalServerError, ErrorResponse{
+			Error: "INTERNAL_ERROR",
+			Code:  "INTERNAL_ERROR",
+		})
+		return nil, err
+	}
+
+	var patched []byte
+	switch mediaType {
+	case contentTypeMergePatch:
+		patched, err = jsonpatch.MergePatch(doc, patch)
+	case contentTypeJSONPatch:
+		var ops jsonpatch.Patch
+		ops, err = jsonpatch.DecodePatch(patch)
+		if err == nil {
+			patched, err = ops.Apply(doc)
+		}
+	default:
+		err = fmt.Errorf("unsupported patch type %s", mediaType)
+	}
+
+	if err != nil {
+		if errors.Is(err, jsonpatch.ErrTestFailed) {
+			utils.WriteJSON(w, http.StatusConflict, ErrorResponse{
+				Error: err.Error(),
+				Code:  "PATCH_TEST_FAILED",
+			})
+			return nil, err
+		}
+
+		utils.WriteJSON(w, http.StatusBadRequest, ErrorResponse{
+			Error: err.Error(),
+			Code:  "INVALID_PATCH",
+		})
+		return nil, err
+	}
+
+	return patched, nil
+}
+
+// patchToCommand собирает команду обновления по разнице исходного и изменённого представлений.
+// changed = false, если patch ничего не поменял
+func patchToCommand(w http.ResponseWriter, id uuid.UUID, current *Subscription, patched []byte) (cmd commands.UpdateSubscriptionCommand, changed bool, err error) {
+	cmd.ID = id
+
+	invalid := func(msg string) error {
+		utils.WriteJSON(w, http.StatusBadRequest, ErrorResponse{
+			Error: msg,
+			Code:  "INVALID_PATCH",
+		})
+		return errors.New(msg)
+	}
+
+	var before, after map[string]interface{}
+	raw, _ := json.Marshal(current)
+	_ = json.Unmarshal(raw, &before)
+
+	if err := json.Unmarshal(patched, &after); err != nil {
+		return cmd, false, invalid("patched document should be an object")
+	}
+
+	for field := range after {
+		if !readOnlyFields[field] && !patchableFields[field] {
+			return cmd, false, invalid(fmt.Sprintf("unknown field %q", field))
+		}
+	}
+
+	for field := range readOnlyFields {
+		if !reflect.DeepEqual(before[field], after[field]) {
+			return cmd, false, invalid(fmt.Sprintf("field %q is read-only", field))
+		}
+	}
+
+	// price
+	if !reflect.DeepEqual(before["price"], after["price"]) {
+		price, ok := after["price"].(float64)
+		if !ok || price != math.Trunc(price) {
+			return cmd, false, invalid("price should be an integer")
+		}
+		p := int(price)
+		cmd.Price = &p
+		changed = true
+	}
+
+	// start_date
+	if !reflect.DeepEqual(before["start_date"], after["start_date"]) {
+		s, ok := after["start_date"].(string)
+		if !ok {
+			return cmd, false, invalid("start_date should be a string in MM-YYYY format")
+		}
+		t, err := parseDate(w, s)
+		if err != nil {
+			return cmd, false, err
+		}
+		cmd.StartDate = &t
+		changed = true
+	}
+
+	// end_date: отсутствие, null и "" - нет даты окончания
+	oldEnd, _ := before["end_date"].(string)
+	var newEnd string
+	switch v := after["end_date"].(type) {
+	case nil:
+	case string:
+		newEnd = v
+	default:
+		return cmd, false, invalid("end_date should be a string in MM-YYYY format or null")
+	}
+
+	if oldEnd != newEnd {
+		if newEnd == "" {
+			cmd.SetEndDateNull = true
+		} else {
+			var t time.Time
+			t, err = parseDate(w, newEnd)
+			if err != nil {
+				return cmd, false, err
+			}
+			cmd.EndDate = &t
+		}
+		changed = true
+	}
+
+	return cmd, changed, nil
+}
@@ -0,0 +1,115 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	common "github.com/end1essrage/efmob-tz/pkg/common/cmd"
+	"github.com/end1essrage/efmob-tz/pkg/common/interfaces/http/utils"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func testRepresentation() *Subscription {
+	return &Subscription{
+		ID:          uuid.New(),
+		UserID:      uuid.New(),
+		ServiceName: "Netflix",
+		Price:       400,
+		StartDate:   "07-2025",
+		EndDate:     "12-2025",
+		Version:     3,
+	}
+}
+
+func TestApplyPatch_MergePatchToCommand(t *testing.T) {
+	current := testRepresentation()
+	w := httptest.NewRecorder()
+
+	patched, err := applyPatch(w, contentTypeMergePatch, current, []byte(`{"price":500,"end_date":null}`))
+	require.NoError(t, err)
+
+	cmd, changed, err := patchToCommand(w, current.ID, current, patched)
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, 500, *cmd.Price)
+	require.Nil(t, cmd.StartDate)
+	require.Nil(t, cmd.EndDate)
+	require.True(t, cmd.SetEndDateNull)
+}
+
+func TestApplyPatch_JSONPatchToCommand(t *testing.T) {
+	current := testRepresentation()
+	w := httptest.NewRecorder()
+
+	patch := `[
+		{"op":"test","path":"/version","value":3},
+		{"op":"replace","path":"/start_date","value":"08-2025"},
+		{"op":"replace","path":"/end_date","value":"01-2026"}
+	]`
+
+	patched, err := applyPatch(w, contentTypeJSONPatch, current, []byte(patch))
+	require.NoError(t, err)
+
+	cmd, changed, err := patchToCommand(w, current.ID, current, patched)
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Nil(t, cmd.Price)
+	require.Equal(t, time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC), *cmd.StartDate)
+	require.Equal(t, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), *cmd.EndDate)
+	require.False(t, cmd.SetEndDateNull)
+}
+
+func TestApplyPatch_TestOperationFails(t *testing.T) {
+	current := testRepresentation()
+	w := httptest.NewRecorder()
+
+	_, err := applyPatch(w, contentTypeJSONPatch, current, []byte(`[{"op":"test","path":"/price","value":1}]`))
+
+	require.Error(t, err)
+	require.Equal(t, http.StatusConflict, w.Code)
+	require.Contains(t, w.Body.String(), "PATCH_TEST_FAILED")
+}
+
+func TestPatchToCommand_ReadOnlyField(t *testing.T) {
+	current := testRepresentation()
+	w := httptest.NewRecorder()
+
+	patched, err := applyPatch(w, contentTypeMergePatch, current, []byte(`{"service_name":"Spotify"}`))
+	require.NoError(t, err)
+
+	_, _, err = patchToCommand(w, current.ID, current, patched)
+
+	require.Error(t, err)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "INVALID_PATCH")
+}
+
+func TestPatchToCommand_NoChanges(t *testing.T) {
+	current := testRepresentation()
+	w := httptest.NewRecorder()
+
+	// клиент может прислать полное представление - неизменённые read-only поля допустимы
+	patched, err := applyPatch(w, contentTypeMergePatch, current, []byte(`{"service_name":"Netflix","price":400}`))
+	require.NoError(t, err)
+
+	_, changed, err := patchToCommand(w, current.ID, current, patched)
+	require.NoError(t, err)
+	require.False(t, changed)
+}
+
+func TestPatchSubscription_BodyTooLarge(t *testing.T) {
+	h := NewSubsHandler(common.ENV_TEST, nil)
+	body := `{"price":1,"pad":"` + strings.Repeat("a", utils.MaxJSONBodySize) + `"}`
+	r := httptest.NewRequest(http.MethodPatch, "/subscriptions/x", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	// тело отклоняется до чтения подписки
+	h.patchSubscription(w, r, uuid.New(), nil, contentTypeMergePatch)
+
+	require.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	require.Contains(t, w.Body.String(), "REQUEST_TOO_LARGE")
+}
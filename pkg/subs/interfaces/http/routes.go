@@ -1,6 +1,8 @@
 package http
 
 import (
+	"net/http"
+
 	"github.com/go-chi/chi/v5"
 
 	common "github.com/end1essrage/efmob-tz/pkg/common/cmd"
@@ -19,13 +21,21 @@ func NewSubsHandler(env common.ENV, container *container.Container) *SubsHandler
 	}
 }
 
-// AddRoutes добавляет маршруты аутентификации
+// AddRoutes добавляет маршруты аутентификации.
+// authenticate проверяет токен до идемпотентности - ключи разделены по пользователям.
+// rateLimit идет после authenticate - лимиты считаются по ключу API или пользователю, без аутентификации - по IP.
+// Календарь защищен своим токеном фида и доступен без аутентификации.
+// Ключи API выдает администратор, права проверяет policy приложения
 // @Summary Add subscriptions routes
-func AddRoutes(r *chi.Mux, h *SubsHandler) {
+func AddRoutes(r chi.Router, h *SubsHandler, authenticate, rateLimit, idempotency func(http.Handler) http.Handler) {
 	r.Route("/subscriptions", func(r chi.Router) {
-		r.Post("/", h.CreateSubscription)
+		r.Use(authenticate, rateLimit)
+
+		r.With(idempotency).Post("/", h.CreateSubscription)
 		r.Get("/", h.ListSubscriptions)
 		r.Get("/total", h.GetTotalCost)
+		r.Post("/import", h.ImportSubscriptions)
+		r.Get("/export", h.ExportSubscriptions)
 
 		r.Route("/{id}", func(r chi.Router) {
 			r.Get("/", h.GetSubscription)
@@ -33,4 +43,25 @@ func AddRoutes(r *chi.Mux, h *SubsHandler) {
 			r.Delete("/", h.DeleteSubscription)
 		})
 	})
+
+	r.Route("/users/{user_id}", func(r chi.Router) {
+		r.With(authenticate, rateLimit).Post("/renewals/token", h.IssueFeedToken)
+		r.With(rateLimit).Get("/renewals.ics", h.GetRenewalsCalendar)
+	})
+
+	r.Route("/admin/api-keys", func(r chi.Router) {
+		r.Use(authenticate, rateLimit)
+
+		r.Post("/", h.IssueAPIKey)
+		r.Get("/", h.ListAPIKeys)
+		r.Post("/{id}/rotate", h.RotateAPIKey)
+		r.Delete("/{id}", h.RevokeAPIKey)
+	})
+
+	r.Route("/admin/outbox", func(r chi.Router) {
+		r.Use(authenticate, rateLimit)
+
+		r.Get("/", h.ListOutbox)
+		r.Post("/requeue", h.RequeueOutbox)
+	})
 }
@@ -3,11 +3,18 @@ package http
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/end1essrage/efmob-tz/pkg/common/auth"
 	"github.com/end1essrage/efmob-tz/pkg/common/interfaces/http/utils"
 	"github.com/end1essrage/efmob-tz/pkg/common/logger"
+	"github.com/end1essrage/efmob-tz/pkg/common/persistance"
+	"github.com/end1essrage/efmob-tz/pkg/common/rsql"
 	app "github.com/end1essrage/efmob-tz/pkg/subs/application"
+	"github.com/end1essrage/efmob-tz/pkg/subs/application/queries"
+	"github.com/end1essrage/efmob-tz/pkg/subs/domain"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 )
@@ -33,13 +40,48 @@ func (h *SubsHandler) writeAppError(w http.ResponseWriter, err error) {
 	}
 }
 
+// headerUserID пользователь, которого аутентифицировал шлюз перед сервисом
+const headerUserID = "X-User-ID"
+
+// authorizeUser пускает только запросы от самого пользователя userID.
+// Аутентифицированного вызывающего проверяет приложение, без аутентификации -
+// заголовок шлюза: 401 без заголовка или 403 для чужого пользователя
+func authorizeUser(w http.ResponseWriter, r *http.Request, userID uuid.UUID) bool {
+	if _, ok := auth.FromContext(r.Context()); ok {
+		return true
+	}
+
+	caller, err := uuid.Parse(r.Header.Get(headerUserID))
+	if err != nil {
+		utils.WriteJSON(w, http.StatusUnauthorized, ErrorResponse{
+			Error: "missing or invalid " + headerUserID,
+			Code:  "UNAUTHORIZED",
+		})
+		return false
+	}
+
+	if caller != userID {
+		utils.WriteJSON(w, http.StatusForbidden, ErrorResponse{
+			Error: "access to another user is forbidden",
+			Code:  "FORBIDDEN",
+		})
+		return false
+	}
+
+	return true
+}
+
 func extrudeUidFromQuery(w http.ResponseWriter, r *http.Request) (uuid.UUID, error) {
-	id := chi.URLParam(r, "id")
+	return extrudeUUIDParam(w, r, "id")
+}
+
+func extrudeUUIDParam(w http.ResponseWriter, r *http.Request, param string) (uuid.UUID, error) {
+	id := chi.URLParam(r, param)
 
 	uid, err := uuid.Parse(id)
 	if err != nil {
 		utils.WriteJSON(w, http.StatusBadRequest, ErrorResponse{
-			Error: "invalid id format, should be uuid",
+			Error: "invalid " + param + " format, should be uuid",
 			Code:  "INVALID_QUERY",
 		})
 
@@ -87,3 +129,192 @@ func parseDate(w http.ResponseWriter, s string) (time.Time, error) {
 	}
 	return t, nil
 }
+
+// writeSubscription пишет представление подписки вместе с её версией в ETag
+func writeSubscription(w http.ResponseWriter, status int, record *domain.Subscription) {
+	w.Header().Set("ETag", formatETag(record.Version()))
+	utils.WriteJSON(w, status, mapSubscriptionFromDomain(record))
+}
+
+// formatETag версия подписки в виде сильного ETag
+func formatETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// parseIfMatch разбирает If-Match. nil - заголовка нет или передан "*".
+// Версия одна, поэтому список тегов отклоняется с 400: сверить его целиком с текущей версией нельзя.
+// Слабый или не являющийся версией тег ни с чем не совпадает - 412
+func parseIfMatch(w http.ResponseWriter, r *http.Request) (*int, bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return nil, true
+	}
+
+	if strings.Contains(header, ",") {
+		utils.WriteJSON(w, http.StatusBadRequest, ErrorResponse{
+			Error: "If-Match should contain a single ETag",
+			Code:  "INVALID_IF_MATCH",
+		})
+		return nil, false
+	}
+
+	// слабые теги не участвуют в If-Match
+	if !strings.HasPrefix(header, "W/") {
+		if v, err := strconv.Atoi(strings.Trim(header, `"`)); err == nil {
+			return &v, true
+		}
+	}
+
+	utils.WriteJSON(w, http.StatusPreconditionFailed, ErrorResponse{
+		Error: "invalid If-Match, should be ETag from GET",
+		Code:  "PRECONDITION_FAILED",
+	})
+	return nil, false
+}
+
+// matchesIfNoneMatch проверяет If-None-Match для условного GET
+func matchesIfNoneMatch(r *http.Request, etag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// nextPageURL текущий запрос с курсором следующей страницы вместо номера страницы
+func nextPageURL(r *http.Request, cursor string) string {
+	q := r.URL.Query()
+	q.Del("page")
+	q.Set("cursor", cursor)
+
+	u := *r.URL
+	u.RawQuery = q.Encode()
+	return u.RequestURI()
+}
+
+// parseOptionalDay дата в формате YYYY-MM-DD, endOfDay - включительно до конца дня
+func parseOptionalDay(w http.ResponseWriter, s *string, endOfDay bool) (*time.Time, error) {
+	if s == nil {
+		return nil, nil
+	}
+	t, err := time.Parse(time.DateOnly, *s)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, ErrorResponse{
+			Error: err.Error(),
+			Code:  "INVALID_DATE",
+		})
+		return nil, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1).Add(-time.Microsecond)
+	}
+	return &t, nil
+}
+
+// parseFilterExpression разбирает RSQL выражение и проверяет его по белому списку полей
+func parseFilterExpression(w http.ResponseWriter, s *string) (domain.FilterExpr, error) {
+	if s == nil || strings.TrimSpace(*s) == "" {
+		return nil, nil
+	}
+
+	node, err := rsql.Parse(*s)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, ErrorResponse{
+			Error: err.Error(),
+			Code:  "INVALID_QUERY",
+		})
+		return nil, err
+	}
+
+	expr, err := domain.NewFilterExpr(node)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, ErrorResponse{
+			Error: err.Error(),
+			Code:  "INVALID_FILTER",
+		})
+		return nil, err
+	}
+
+	return expr, nil
+}
+
+// subscriptionFilter разобранные фильтры и сортировка списка и выгрузки подписок
+type subscriptionFilter struct {
+	StartFrom  *time.Time
+	StartTo    *time.Time
+	EndFrom    *time.Time
+	EndTo      *time.Time
+	WithNilEnd *bool
+	Filters    queries.SubscriptionFilters
+	Sorting    *persistance.Sorting
+}
+
+// parseSubscriptionFilter разбирает фильтры запроса, ошибка уже записана в w
+func parseSubscriptionFilter(w http.ResponseWriter, req SubscriptionFilterRequest) (subscriptionFilter, error) {
+	f := subscriptionFilter{WithNilEnd: req.NilEnd}
+
+	// Парсим optional dates
+	var err error
+	if f.StartFrom, err = parseOptionalDate(w, req.StartFrom); err != nil {
+		return f, err
+	}
+	if f.StartTo, err = parseOptionalDate(w, req.StartTo); err != nil {
+		return f, err
+	}
+	if f.EndFrom, err = parseOptionalDate(w, req.EndFrom); err != nil {
+		return f, err
+	}
+	if f.EndTo, err = parseOptionalDate(w, req.EndTo); err != nil {
+		return f, err
+	}
+
+	if f.Filters, err = parseFilters(w, req.ServiceMatch, req.PriceMin, req.PriceMax, req.CreatedFrom, req.CreatedTo, req.Sort, req.Filter); err != nil {
+		return f, err
+	}
+	f.Filters.UserIDs = req.UserID
+	f.Filters.ServiceNames = req.ServiceName
+
+	// собираем сортировку
+	if req.OrderBy != nil {
+		f.Sorting = &persistance.Sorting{OrderBy: *req.OrderBy}
+		if req.Direction != nil {
+			f.Sorting.Direction = persistance.SortingDirection(*req.Direction)
+		} else {
+			f.Sorting.Direction = persistance.Descending
+		}
+	}
+
+	return f, nil
+}
+
+// parseFilters собирает расширенные фильтры из query
+func parseFilters(w http.ResponseWriter, serviceMatch *string, priceMin, priceMax *int, createdFrom, createdTo, sort, filter *string) (queries.SubscriptionFilters, error) {
+	filters := queries.SubscriptionFilters{
+		PriceMin: priceMin,
+		PriceMax: priceMax,
+		Sort:     sort,
+	}
+	if serviceMatch != nil {
+		filters.ServiceNameMatch = *serviceMatch
+	}
+
+	var err error
+	if filters.CreatedFrom, err = parseOptionalDay(w, createdFrom, false); err != nil {
+		return filters, err
+	}
+	if filters.CreatedTo, err = parseOptionalDay(w, createdTo, true); err != nil {
+		return filters, err
+	}
+	if filters.Expression, err = parseFilterExpression(w, filter); err != nil {
+		return filters, err
+	}
+
+	return filters, nil
+}
@@ -0,0 +1,44 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIfMatch(t *testing.T) {
+	cases := []struct {
+		header  string
+		version *int
+		status  int
+	}{
+		{header: "", status: http.StatusOK},
+		{header: "*", status: http.StatusOK},
+		{header: `"4"`, version: ptr(4), status: http.StatusOK},
+		{header: ` "4" `, version: ptr(4), status: http.StatusOK},
+		{header: `W/"4"`, status: http.StatusPreconditionFailed},
+		{header: `"abc"`, status: http.StatusPreconditionFailed},
+		// список версий нельзя сверить с одной текущей - 400, а не 412 по первому тегу
+		{header: `"3", "4"`, status: http.StatusBadRequest},
+		{header: `W/"3", "4"`, status: http.StatusBadRequest},
+	}
+
+	for _, c := range cases {
+		r := httptest.NewRequest(http.MethodPatch, "/subscriptions/x", nil)
+		if c.header != "" {
+			r.Header.Set("If-Match", c.header)
+		}
+		w := httptest.NewRecorder()
+
+		version, ok := parseIfMatch(w, r)
+		require.Equal(t, c.status == http.StatusOK, ok, c.header)
+		require.Equal(t, c.status, w.Code, c.header)
+		require.Equal(t, c.version, version, c.header)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
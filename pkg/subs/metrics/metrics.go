@@ -11,10 +11,115 @@ var (
 			Help:      "Total created subscriptions",
 		},
 	)
+
+	SubscriptionsUpdatedTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "efmob",
+			Subsystem: "subs",
+			Name:      "updated_total",
+			Help:      "Total updated subscriptions",
+		},
+	)
+
+	SubscriptionsDeletedTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "efmob",
+			Subsystem: "subs",
+			Name:      "deleted_total",
+			Help:      "Total deleted subscriptions",
+		},
+	)
+
+	// ActiveSubscriptions и MonthlySpend считает периодический сборщик, а не каждый scrape
+	ActiveSubscriptions = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "efmob",
+			Subsystem: "subs",
+			Name:      "active",
+			Help:      "Subscriptions active in the current month",
+		},
+	)
+
+	MonthlySpend = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "efmob",
+			Subsystem: "subs",
+			Name:      "monthly_spend",
+			Help:      "Monthly recurring spend of active subscriptions per service",
+		},
+		[]string{"service_name"},
+	)
+
+	OptimisticLockConflictsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "efmob",
+			Subsystem: "db",
+			Name:      "optimistic_lock_conflicts_total",
+			Help:      "Writes rejected because the row version changed",
+		},
+		[]string{"op"},
+	)
+
+	RetryAttemptsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "efmob",
+			Subsystem: "db",
+			Name:      "retry_attempts_total",
+			Help:      "Repeated attempts of retryable database operations",
+		},
+	)
+
+	RetriesExhaustedTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "efmob",
+			Subsystem: "db",
+			Name:      "retries_exhausted_total",
+			Help:      "Database operations that failed after all retries",
+		},
+	)
+
+	OutboxBacklog = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "efmob",
+			Subsystem: "outbox",
+			Name:      "backlog",
+			Help:      "Events waiting to be published",
+		},
+	)
+
+	OutboxOldestEventAge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "efmob",
+			Subsystem: "outbox",
+			Name:      "oldest_event_age_seconds",
+			Help:      "Age of the oldest unpublished event, 0 when the outbox is empty",
+		},
+	)
+
+	OutboxPublishDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "efmob",
+			Subsystem: "outbox",
+			Name:      "publish_duration_seconds",
+			Help:      "Event publish latency",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"type", "status"},
+	)
 )
 
 func Register() {
 	prometheus.MustRegister(
 		SubscriptionsCreatedTotal,
+		SubscriptionsUpdatedTotal,
+		SubscriptionsDeletedTotal,
+		ActiveSubscriptions,
+		MonthlySpend,
+		OptimisticLockConflictsTotal,
+		RetryAttemptsTotal,
+		RetriesExhaustedTotal,
+		OutboxBacklog,
+		OutboxOldestEventAge,
+		OutboxPublishDuration,
 	)
 }